@@ -20,6 +20,7 @@ import (
 	"github.com/namsral/flag"
 
 	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/decoder"
 )
 
 // Flags returns all flags.
@@ -83,7 +84,9 @@ var (
 	flagListInterfaces = fs.Bool("interfaces", false, "list all visible network interfaces")
 	flagQuiet          = fs.Bool("quiet", false, "don't print infos to stdout")
 
-	flagFileStorage = fs.String("fileStorage", "", "path to created extracted files (currently only for HTTP)")
+	flagFileStorage     = fs.String("fileStorage", "", "path to created extracted files (currently only for HTTP)")
+	flagPassiveDNSStore = fs.String("pdns-store", "", "path to a passive DNS database file, observed DNS answers will be merged into it, net util -pdns reads "+decoder.PassiveDNSStoreFile+" from the database source by default")
+	flagKeyboardLayout  = fs.String("keyboard-layout", "us", "keyboard layout for decoding USB HID keystrokes: us, de or fr")
 
 	flagReverseDNS    = fs.Bool("reverse-dns", false, "resolve ips to domains via the operating systems default dns resolver")
 	flagLocalDNS      = fs.Bool("local-dns", false, "resolve DNS locally via hosts file in the database dir")
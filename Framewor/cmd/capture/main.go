@@ -213,6 +213,8 @@ func Run() {
 			CloseInactiveTimeOut:    *flagCloseInactiveTimeout,
 			ClosePendingTimeOut:     *flagClosePendingTimeout,
 			FileStorage:             *flagFileStorage,
+			PassiveDNSStore:         *flagPassiveDNSStore,
+			KeyboardLayout:          *flagKeyboardLayout,
 			CalculateEntropy:        *flagCalcEntropy,
 			SaveConns:               *flagSaveConns,
 			TCPDebug:                *flagTCPDebug,
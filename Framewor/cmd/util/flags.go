@@ -15,10 +15,13 @@ package util
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/namsral/flag"
 
 	"github.com/dreadl0ck/netcap"
+	"github.com/dreadl0ck/netcap/decoder"
+	"github.com/dreadl0ck/netcap/resolvers"
 )
 
 // Flags returns all flags.
@@ -44,4 +47,6 @@ var (
 	flagEnv            = fs.Bool("env", false, "print netcap environment variables and exit")
 	flagInterfaces     = fs.Bool("interfaces", false, "print netcap environment variables and exit")
 	flagIndex          = fs.String("index", "", "index data for full text search")
+	flagPassiveDNS     = fs.String("pdns", "", "lookup a name or IP in the passive DNS database")
+	flagPassiveDNSDB   = fs.String("pdns-store", filepath.Join(resolvers.DataBaseSource, decoder.PassiveDNSStoreFile), "path to the passive DNS database file")
 )
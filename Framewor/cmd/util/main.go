@@ -83,4 +83,8 @@ func Run() {
 	if *flagIndex != "" {
 		indexData(*flagIndex)
 	}
+
+	if *flagPassiveDNS != "" {
+		lookupPassiveDNS(*flagPassiveDNS, *flagPassiveDNSDB)
+	}
 }
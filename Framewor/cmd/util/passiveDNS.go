@@ -0,0 +1,56 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package util
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/evilsocket/islazy/tui"
+
+	"github.com/dreadl0ck/netcap/decoder"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// lookupPassiveDNS prints all entries from the passive DNS database at path
+// that match the given name or IP as query or answer.
+func lookupPassiveDNS(term, path string) {
+	res, err := decoder.LookupPassiveDNS(path, term)
+	if err != nil {
+		log.Fatal("failed to read passive DNS database: ", err)
+	}
+
+	if len(res) == 0 {
+		fmt.Println("no passive DNS entries found for", term)
+
+		return
+	}
+
+	rows := make([][]string, 0, len(res))
+	for _, r := range res {
+		rows = append(rows, []string{
+			r.Query,
+			r.Type,
+			r.Answer,
+			utils.TimeToUTC(r.FirstSeen),
+			utils.TimeToUTC(r.LastSeen),
+			strconv.FormatInt(r.Count, 10),
+			strconv.FormatUint(uint64(r.TTLMin), 10) + "-" + strconv.FormatUint(uint64(r.TTLMax), 10),
+		})
+	}
+
+	tui.Table(os.Stdout, []string{"Query", "Type", "Answer", "FirstSeen", "LastSeen", "Count", "TTL"}, rows)
+}
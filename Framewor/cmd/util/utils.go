@@ -33,6 +33,7 @@ func printHeader() {
 	fmt.Println("	$ net util -read TCP.ncap.gz -check")
 	fmt.Println("	$ net util -read TCP.ncap.gz -check -sep '/'")
 	fmt.Println("	$ net util -ts2utc 1505839354.197231")
+	fmt.Println("	$ net util -pdns example.com")
 	fmt.Println()
 }
 
@@ -0,0 +1,991 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * BACnet/IP (ASHRAE 135 Annex J)
+ */
+
+const (
+	serviceBACnet = "BACnet"
+
+	bacnetPort layers.UDPPort = 47808
+
+	bvlcTypeBACnetIP        = 0x81
+	bvlcHeaderSize          = 4
+	bvlcForwardedNPDU       = 0x04
+	bvlcDistributeBroadcast = 0x09
+	bvlcOriginalUnicast     = 0x0a
+	bvlcOriginalBroadcast   = 0x0b
+
+	npduVersion = 0x01
+
+	// NPDU control flags
+	npduNetworkMessage = 0x80
+	npduDNETPresent    = 0x20
+	npduSNETPresent    = 0x08
+
+	// APDU flags
+	apduSegmented = 0x08
+)
+
+// APDU types
+const (
+	apduConfirmedRequest   = 0
+	apduUnconfirmedRequest = 1
+	apduSimpleACK          = 2
+	apduComplexACK         = 3
+	apduSegmentACK         = 4
+	apduError              = 5
+	apduReject             = 6
+	apduAbort              = 7
+)
+
+// services that carry an object identifier and a property
+const (
+	bacnetReadProperty               = 12
+	bacnetWriteProperty              = 15
+	bacnetDeviceCommunicationControl = 17
+	bacnetReinitializeDevice         = 20
+
+	bacnetIAm    = 0
+	bacnetWhoHas = 7
+	bacnetWhoIs  = 8
+)
+
+// application tag numbers
+const (
+	bacnetTagNull            = 0
+	bacnetTagBoolean         = 1
+	bacnetTagUnsigned        = 2
+	bacnetTagSigned          = 3
+	bacnetTagReal            = 4
+	bacnetTagDouble          = 5
+	bacnetTagCharacterString = 7
+	bacnetTagEnumerated      = 9
+	bacnetTagObjectID        = 12
+)
+
+// object types and properties of the device inventory
+const (
+	bacnetObjectDevice = 8
+
+	bacnetPropertyModelName  = 70
+	bacnetPropertyObjectName = 77
+	bacnetPropertyVendorName = 121
+)
+
+var (
+	errBACnetInvalidPacket = errors.New("invalid BACnet packet")
+	errBACnetTruncated     = errors.New("truncated BACnet tag")
+)
+
+var bvlcFunctions = map[byte]string{
+	0x00: "BVLC-Result",
+	0x01: "Write-Broadcast-Distribution-Table",
+	0x02: "Read-Broadcast-Distribution-Table",
+	0x03: "Read-Broadcast-Distribution-Table-Ack",
+	0x04: "Forwarded-NPDU",
+	0x05: "Register-Foreign-Device",
+	0x06: "Read-Foreign-Device-Table",
+	0x07: "Read-Foreign-Device-Table-Ack",
+	0x08: "Delete-Foreign-Device-Table-Entry",
+	0x09: "Distribute-Broadcast-To-Network",
+	0x0a: "Original-Unicast-NPDU",
+	0x0b: "Original-Broadcast-NPDU",
+	0x0c: "Secure-BVLL",
+}
+
+var bacnetNetworkMessages = map[byte]string{
+	0x00: "Who-Is-Router-To-Network",
+	0x01: "I-Am-Router-To-Network",
+	0x02: "I-Could-Be-Router-To-Network",
+	0x03: "Reject-Message-To-Network",
+	0x04: "Router-Busy-To-Network",
+	0x05: "Router-Available-To-Network",
+	0x06: "Initialize-Routing-Table",
+	0x07: "Initialize-Routing-Table-Ack",
+	0x08: "Establish-Connection-To-Network",
+	0x09: "Disconnect-Connection-To-Network",
+	0x12: "What-Is-Network-Number",
+	0x13: "Network-Number-Is",
+}
+
+var apduTypes = map[byte]string{
+	apduConfirmedRequest:   "Confirmed-Request",
+	apduUnconfirmedRequest: "Unconfirmed-Request",
+	apduSimpleACK:          "SimpleACK",
+	apduComplexACK:         "ComplexACK",
+	apduSegmentACK:         "SegmentACK",
+	apduError:              "Error",
+	apduReject:             "Reject",
+	apduAbort:              "Abort",
+}
+
+var bacnetConfirmedServices = map[uint32]string{
+	0:  "AcknowledgeAlarm",
+	1:  "ConfirmedCOVNotification",
+	2:  "ConfirmedEventNotification",
+	3:  "GetAlarmSummary",
+	4:  "GetEnrollmentSummary",
+	5:  "SubscribeCOV",
+	6:  "AtomicReadFile",
+	7:  "AtomicWriteFile",
+	8:  "AddListElement",
+	9:  "RemoveListElement",
+	10: "CreateObject",
+	11: "DeleteObject",
+	12: "ReadProperty",
+	14: "ReadPropertyMultiple",
+	15: "WriteProperty",
+	16: "WritePropertyMultiple",
+	17: "DeviceCommunicationControl",
+	18: "ConfirmedPrivateTransfer",
+	19: "ConfirmedTextMessage",
+	20: "ReinitializeDevice",
+	21: "VT-Open",
+	22: "VT-Close",
+	23: "VT-Data",
+	26: "ReadRange",
+	27: "LifeSafetyOperation",
+	28: "SubscribeCOVProperty",
+	29: "GetEventInformation",
+}
+
+var bacnetUnconfirmedServices = map[uint32]string{
+	0:  "I-Am",
+	1:  "I-Have",
+	2:  "UnconfirmedCOVNotification",
+	3:  "UnconfirmedEventNotification",
+	4:  "UnconfirmedPrivateTransfer",
+	5:  "UnconfirmedTextMessage",
+	6:  "TimeSynchronization",
+	7:  "Who-Has",
+	8:  "Who-Is",
+	9:  "UTCTimeSynchronization",
+	10: "WriteGroup",
+}
+
+// confirmed services that change the state of a device
+var bacnetServiceNotes = map[byte]string{
+	7:  "file write",
+	10: "object creation",
+	11: "object deletion",
+	15: "property write",
+	16: "property write",
+	17: "communication control",
+	20: "device reinitialization",
+}
+
+var bacnetObjectTypes = map[uint32]string{
+	0:  "analog-input",
+	1:  "analog-output",
+	2:  "analog-value",
+	3:  "binary-input",
+	4:  "binary-output",
+	5:  "binary-value",
+	6:  "calendar",
+	7:  "command",
+	8:  "device",
+	9:  "event-enrollment",
+	10: "file",
+	11: "group",
+	12: "loop",
+	13: "multi-state-input",
+	14: "multi-state-output",
+	15: "notification-class",
+	16: "program",
+	17: "schedule",
+	18: "averaging",
+	19: "multi-state-value",
+	20: "trend-log",
+	21: "life-safety-point",
+	22: "life-safety-zone",
+	23: "accumulator",
+	24: "pulse-converter",
+}
+
+var bacnetProperties = map[uint32]string{
+	8:   "all",
+	12:  "application-software-version",
+	28:  "description",
+	36:  "event-state",
+	44:  "firmware-revision",
+	58:  "location",
+	62:  "max-apdu-length-accepted",
+	70:  "model-name",
+	75:  "object-identifier",
+	76:  "object-list",
+	77:  "object-name",
+	79:  "object-type",
+	81:  "out-of-service",
+	85:  "present-value",
+	87:  "priority-array",
+	97:  "protocol-services-supported",
+	98:  "protocol-version",
+	103: "reliability",
+	104: "relinquish-default",
+	107: "segmentation-supported",
+	111: "status-flags",
+	112: "system-status",
+	117: "units",
+	120: "vendor-identifier",
+	121: "vendor-name",
+	139: "protocol-revision",
+}
+
+var bacnetReinitializedStates = map[uint32]string{
+	0: "coldstart",
+	1: "warmstart",
+	2: "start-backup",
+	3: "end-backup",
+	4: "start-restore",
+	5: "end-restore",
+	6: "abort-restore",
+	7: "activate-changes",
+}
+
+var bacnetCommunicationStates = map[uint32]string{
+	0: "enable",
+	1: "disable",
+	2: "disable-initiation",
+}
+
+var bacnetErrorClasses = map[uint32]string{
+	0: "device",
+	1: "object",
+	2: "property",
+	3: "resources",
+	4: "security",
+	5: "services",
+	6: "vt",
+	7: "communication",
+}
+
+var bacnetErrorCodes = map[uint32]string{
+	0:  "other",
+	2:  "configuration-in-progress",
+	3:  "device-busy",
+	9:  "invalid-data-type",
+	26: "password-failure",
+	27: "read-access-denied",
+	31: "unknown-object",
+	32: "unknown-property",
+	37: "value-out-of-range",
+	40: "write-access-denied",
+}
+
+var bacnetRejectReasons = map[byte]string{
+	0: "other",
+	1: "buffer-overflow",
+	2: "inconsistent-parameters",
+	3: "invalid-parameter-data-type",
+	4: "invalid-tag",
+	5: "missing-required-parameter",
+	6: "parameter-out-of-range",
+	7: "too-many-arguments",
+	8: "undefined-enumeration",
+	9: "unrecognized-service",
+}
+
+var bacnetAbortReasons = map[byte]string{
+	0:  "other",
+	1:  "buffer-overflow",
+	2:  "invalid-apdu-in-this-state",
+	3:  "preempted-by-higher-priority-task",
+	4:  "segmentation-not-supported",
+	5:  "security-error",
+	6:  "insufficient-security",
+	7:  "window-size-out-of-range",
+	8:  "application-exceeded-reply-time",
+	9:  "out-of-resources",
+	10: "tsm-timeout",
+	11: "apdu-too-long",
+}
+
+// bacnetName returns the name for a value or the number if it is unknown.
+func bacnetName(names map[uint32]string, v uint32) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// bacnetTag is an application or context tagged value.
+type bacnetTag struct {
+	number  byte
+	context bool
+	opening bool
+	closing bool
+
+	// length value type field, holds the value of application tagged booleans
+	lvt   byte
+	value []byte
+}
+
+// readBACnetTag reads a tag and returns the remaining data.
+func readBACnetTag(data []byte) (*bacnetTag, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, errBACnetTruncated
+	}
+
+	t := &bacnetTag{
+		number:  data[0] >> 4,
+		context: data[0]&0x08 != 0,
+		lvt:     data[0] & 0x07,
+	}
+	data = data[1:]
+
+	if t.number == 0x0f {
+		if len(data) < 1 {
+			return nil, nil, errBACnetTruncated
+		}
+
+		t.number = data[0]
+		data = data[1:]
+	}
+
+	switch {
+	case t.context && t.lvt == 6:
+		t.opening = true
+
+		return t, data, nil
+	case t.context && t.lvt == 7:
+		t.closing = true
+
+		return t, data, nil
+	case !t.context && t.number == bacnetTagBoolean:
+		return t, data, nil
+	}
+
+	length := int(t.lvt)
+	if t.lvt == 5 {
+		if len(data) < 1 {
+			return nil, nil, errBACnetTruncated
+		}
+
+		switch data[0] {
+		case 254:
+			if len(data) < 3 {
+				return nil, nil, errBACnetTruncated
+			}
+
+			length = int(binary.BigEndian.Uint16(data[1:3]))
+			data = data[3:]
+		case 255:
+			if len(data) < 5 {
+				return nil, nil, errBACnetTruncated
+			}
+
+			length = int(binary.BigEndian.Uint32(data[1:5]))
+			data = data[5:]
+		default:
+			length = int(data[0])
+			data = data[1:]
+		}
+	}
+
+	if length < 0 || len(data) < length {
+		return nil, nil, errBACnetTruncated
+	}
+
+	t.value = data[:length]
+
+	return t, data[length:], nil
+}
+
+// bacnetUnsigned decodes an unsigned integer of up to four octets.
+func bacnetUnsigned(v []byte) uint32 {
+	var n uint32
+	for _, b := range v {
+		n = n<<8 | uint32(b)
+	}
+
+	return n
+}
+
+// bacnetObjectID formats an object identifier as object type and instance number.
+func bacnetObjectID(v []byte) string {
+	if len(v) != 4 {
+		return ""
+	}
+
+	id := binary.BigEndian.Uint32(v)
+
+	return bacnetName(bacnetObjectTypes, id>>22) + "," + strconv.FormatUint(uint64(id&0x3fffff), 10)
+}
+
+// bacnetCharacterString decodes a character string, only the UTF-8 character set is decoded.
+func bacnetCharacterString(v []byte) string {
+	if len(v) == 0 {
+		return ""
+	}
+
+	if v[0] != 0 {
+		return hex.EncodeToString(v[1:])
+	}
+
+	return string(v[1:])
+}
+
+// bacnetValue formats an application tagged value.
+func bacnetValue(t *bacnetTag) string {
+	if t.context {
+		return hex.EncodeToString(t.value)
+	}
+
+	switch t.number {
+	case bacnetTagNull:
+		return "null"
+	case bacnetTagBoolean:
+		return strconv.FormatBool(t.lvt == 1)
+	case bacnetTagUnsigned, bacnetTagEnumerated:
+		return strconv.FormatUint(uint64(bacnetUnsigned(t.value)), 10)
+	case bacnetTagSigned:
+		if len(t.value) == 0 || len(t.value) > 4 {
+			break
+		}
+
+		n := int32(bacnetUnsigned(t.value)) << (32 - 8*uint(len(t.value))) >> (32 - 8*uint(len(t.value)))
+
+		return strconv.Itoa(int(n))
+	case bacnetTagReal:
+		if len(t.value) != 4 {
+			break
+		}
+
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(t.value))), 'f', -1, 32)
+	case bacnetTagDouble:
+		if len(t.value) != 8 {
+			break
+		}
+
+		return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(t.value)), 'f', -1, 64)
+	case bacnetTagCharacterString:
+		return bacnetCharacterString(t.value)
+	case bacnetTagObjectID:
+		return bacnetObjectID(t.value)
+	}
+
+	return hex.EncodeToString(t.value)
+}
+
+// bacnetMessage is a decoded BACnet/IP packet.
+type bacnetMessage struct {
+	record *types.BACnet
+
+	// source of a forwarded NPDU
+	origin string
+
+	// password of a device communication control or reinitialize device request
+	password string
+
+	confirmedService byte
+}
+
+// parseBACnet parses the BVLC, NPDU and APDU of a BACnet/IP packet.
+func parseBACnet(data []byte) (*bacnetMessage, error) {
+	if len(data) < bvlcHeaderSize || data[0] != bvlcTypeBACnetIP {
+		return nil, errBACnetInvalidPacket
+	}
+
+	var (
+		function = data[1]
+		length   = int(binary.BigEndian.Uint16(data[2:4]))
+		m        = &bacnetMessage{
+			record: &types.BACnet{
+				BVLCFunction: bvlcFunctions[function],
+			},
+		}
+	)
+
+	if m.record.BVLCFunction == "" {
+		m.record.BVLCFunction = fmt.Sprintf("unknown (0x%02x)", function)
+	}
+
+	if length < bvlcHeaderSize || length > len(data) {
+		return m, errBACnetInvalidPacket
+	}
+
+	data = data[bvlcHeaderSize:length]
+
+	switch function {
+	case bvlcForwardedNPDU:
+		if len(data) < 6 {
+			return m, errBACnetInvalidPacket
+		}
+
+		m.origin = net.IP(data[:4]).String()
+		m.record.Notes = addInfo(m.record.Notes, "forwarded from "+m.origin+":"+strconv.Itoa(int(binary.BigEndian.Uint16(data[4:6]))))
+		data = data[6:]
+	case bvlcOriginalUnicast, bvlcOriginalBroadcast, bvlcDistributeBroadcast:
+	default:
+		// BVLL messages without an NPDU
+		return m, nil
+	}
+
+	apdu, err := m.npdu(data)
+	if err != nil || apdu == nil {
+		return m, err
+	}
+
+	return m, m.apdu(apdu)
+}
+
+// npdu skips the network layer header and returns the APDU.
+func (m *bacnetMessage) npdu(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != npduVersion {
+		return nil, errBACnetInvalidPacket
+	}
+
+	control := data[1]
+	data = data[2:]
+
+	// network number, address length and address of the destination and source
+	for _, flag := range []byte{npduDNETPresent, npduSNETPresent} {
+		if control&flag == 0 {
+			continue
+		}
+
+		if len(data) < 3 || len(data) < 3+int(data[2]) {
+			return nil, errBACnetInvalidPacket
+		}
+
+		data = data[3+int(data[2]):]
+	}
+
+	// hop count
+	if control&npduDNETPresent != 0 {
+		if len(data) < 1 {
+			return nil, errBACnetInvalidPacket
+		}
+
+		data = data[1:]
+	}
+
+	if control&npduNetworkMessage != 0 {
+		if len(data) < 1 {
+			return nil, errBACnetInvalidPacket
+		}
+
+		if name, ok := bacnetNetworkMessages[data[0]]; ok {
+			m.record.NetworkMessage = name
+		} else {
+			m.record.NetworkMessage = fmt.Sprintf("unknown (0x%02x)", data[0])
+		}
+
+		return nil, nil
+	}
+
+	return data, nil
+}
+
+// apdu decodes the header and the service parameters of an APDU.
+func (m *bacnetMessage) apdu(data []byte) error {
+	if len(data) < 2 {
+		return errBACnetInvalidPacket
+	}
+
+	var (
+		r       = m.record
+		pduType = data[0] >> 4
+	)
+
+	r.APDUType = apduTypes[pduType]
+	if r.APDUType == "" {
+		r.APDUType = "unknown (" + strconv.Itoa(int(pduType)) + ")"
+	}
+
+	switch pduType {
+	case apduConfirmedRequest:
+		offset := 4
+		if data[0]&apduSegmented != 0 {
+			offset = 6
+		}
+
+		if len(data) < offset {
+			return errBACnetInvalidPacket
+		}
+
+		r.InvokeID = int32(data[2])
+		m.confirmedService = data[offset-1]
+		r.Service = bacnetName(bacnetConfirmedServices, uint32(m.confirmedService))
+
+		if note, ok := bacnetServiceNotes[m.confirmedService]; ok {
+			r.Notes = addInfo(r.Notes, note)
+		}
+
+		return m.confirmedRequest(data[offset:])
+	case apduUnconfirmedRequest:
+		r.Service = bacnetName(bacnetUnconfirmedServices, uint32(data[1]))
+
+		return m.unconfirmedRequest(data[1], data[2:])
+	case apduSimpleACK:
+		if len(data) < 3 {
+			return errBACnetInvalidPacket
+		}
+
+		r.InvokeID = int32(data[1])
+		r.Service = bacnetName(bacnetConfirmedServices, uint32(data[2]))
+	case apduComplexACK:
+		offset := 3
+		if data[0]&apduSegmented != 0 {
+			offset = 5
+		}
+
+		if len(data) < offset {
+			return errBACnetInvalidPacket
+		}
+
+		r.InvokeID = int32(data[1])
+		r.Service = bacnetName(bacnetConfirmedServices, uint32(data[offset-1]))
+
+		if data[offset-1] == bacnetReadProperty {
+			return m.property(data[offset:])
+		}
+	case apduSegmentACK:
+		r.InvokeID = int32(data[1])
+	case apduError:
+		if len(data) < 3 {
+			return errBACnetInvalidPacket
+		}
+
+		r.InvokeID = int32(data[1])
+		r.Service = bacnetName(bacnetConfirmedServices, uint32(data[2]))
+
+		class, rest, err := readBACnetTag(data[3:])
+		if err != nil {
+			return err
+		}
+
+		code, _, err := readBACnetTag(rest)
+		if err != nil {
+			return err
+		}
+
+		r.Error = bacnetName(bacnetErrorClasses, bacnetUnsigned(class.value)) + ": " + bacnetName(bacnetErrorCodes, bacnetUnsigned(code.value))
+	case apduReject, apduAbort:
+		if len(data) < 3 {
+			return errBACnetInvalidPacket
+		}
+
+		r.InvokeID = int32(data[1])
+
+		reasons := bacnetRejectReasons
+		if pduType == apduAbort {
+			reasons = bacnetAbortReasons
+		}
+
+		if reason, ok := reasons[data[2]]; ok {
+			r.Error = reason
+		} else {
+			r.Error = strconv.Itoa(int(data[2]))
+		}
+	}
+
+	return nil
+}
+
+// confirmedRequest decodes the parameters of confirmed services.
+func (m *bacnetMessage) confirmedRequest(data []byte) error {
+	switch m.confirmedService {
+	case bacnetReadProperty, bacnetWriteProperty:
+		return m.property(data)
+	case bacnetDeviceCommunicationControl, bacnetReinitializeDevice:
+		states := bacnetReinitializedStates
+		stateTag := byte(0)
+
+		if m.confirmedService == bacnetDeviceCommunicationControl {
+			states = bacnetCommunicationStates
+			stateTag = 1
+		}
+
+		for len(data) > 0 {
+			t, rest, err := readBACnetTag(data)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case t.context && t.number == stateTag:
+				m.record.Value = bacnetName(states, bacnetUnsigned(t.value))
+			case t.context && t.number == stateTag+1:
+				m.password = bacnetCharacterString(t.value)
+				m.record.Notes = addInfo(m.record.Notes, "password")
+			}
+
+			data = rest
+		}
+	}
+
+	return nil
+}
+
+// unconfirmedRequest decodes the parameters of the device and object discovery services.
+func (m *bacnetMessage) unconfirmedRequest(service byte, data []byte) error {
+	r := m.record
+
+	var values []*bacnetTag
+
+	for len(data) > 0 {
+		t, rest, err := readBACnetTag(data)
+		if err != nil {
+			return err
+		}
+
+		values = append(values, t)
+		data = rest
+	}
+
+	switch service {
+	case bacnetIAm:
+		// object identifier, max APDU length, segmentation and vendor identifier
+		if len(values) < 4 || values[0].number != bacnetTagObjectID || values[3].number != bacnetTagUnsigned {
+			return errBACnetInvalidPacket
+		}
+
+		r.ObjectID = bacnetObjectID(values[0].value)
+		r.VendorID = int32(bacnetUnsigned(values[3].value))
+	case bacnetWhoIs:
+		// optional device instance range limits
+		if len(values) == 2 {
+			r.Value = strconv.FormatUint(uint64(bacnetUnsigned(values[0].value)), 10) + "-" + strconv.FormatUint(uint64(bacnetUnsigned(values[1].value)), 10)
+		}
+	case bacnetWhoHas:
+		// the object is either referenced by its identifier or its name
+		for _, t := range values {
+			switch t.number {
+			case 2:
+				r.ObjectID = bacnetObjectID(t.value)
+			case 3:
+				r.Value = bacnetCharacterString(t.value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// property decodes the object identifier, property identifier, value and priority
+// of ReadProperty, WriteProperty and ReadProperty-ACK.
+func (m *bacnetMessage) property(data []byte) error {
+	var (
+		r       = m.record
+		inValue bool
+		values  []string
+	)
+
+	for len(data) > 0 {
+		t, rest, err := readBACnetTag(data)
+		if err != nil {
+			return err
+		}
+
+		data = rest
+
+		switch {
+		case t.opening && t.number == 3:
+			inValue = true
+		case t.closing && t.number == 3:
+			inValue = false
+		case inValue:
+			if !t.opening && !t.closing {
+				values = append(values, bacnetValue(t))
+			}
+		case t.context && t.number == 0:
+			r.ObjectID = bacnetObjectID(t.value)
+		case t.context && t.number == 1:
+			r.Property = bacnetName(bacnetProperties, bacnetUnsigned(t.value))
+		case t.context && t.number == 2:
+			r.Property += "[" + strconv.FormatUint(uint64(bacnetUnsigned(t.value)), 10) + "]"
+		case t.context && t.number == 4:
+			r.Priority = int32(bacnetUnsigned(t.value))
+		}
+	}
+
+	r.Value = strings.Join(values, ",")
+
+	return nil
+}
+
+// bacnetCredentials returns the password of a device management request as credentials.
+func bacnetCredentials(m *bacnetMessage, ident string, ts time.Time) *types.Credentials {
+	if m.password == "" {
+		return nil
+	}
+
+	return &types.Credentials{
+		Timestamp: utils.TimeToString(ts),
+		Service:   serviceBACnet,
+		Flow:      ident,
+		Password:  m.password,
+		Notes:     m.record.Service,
+	}
+}
+
+// inventory adds the devices and clients of the message to the ICS device inventory.
+func (m *bacnetMessage) inventory(ts time.Time) {
+	var (
+		r   = m.record
+		src = r.SrcIP
+	)
+
+	if m.origin != "" {
+		src = m.origin
+	}
+
+	unicast := r.BVLCFunction == bvlcFunctions[bvlcOriginalUnicast]
+
+	switch r.APDUType {
+	case apduTypes[apduUnconfirmedRequest]:
+		updateICSDevice(src, serviceBACnet, ts, func(d *types.ICSDevice) {
+			if r.Service != bacnetUnconfirmedServices[bacnetIAm] {
+				if d.Role == "" {
+					d.Role = icsRoleClient
+				}
+
+				return
+			}
+
+			d.Role = icsRoleDevice
+			d.Addresses = appendUnique(d.Addresses, r.ObjectID)
+			d.VendorID = r.VendorID
+		})
+	case apduTypes[apduConfirmedRequest]:
+		updateICSDevice(src, serviceBACnet, ts, func(d *types.ICSDevice) {
+			if d.Role == "" {
+				d.Role = icsRoleClient
+			}
+
+			if unicast {
+				d.Peers = appendUnique(d.Peers, r.DstIP)
+			}
+
+			if _, ok := bacnetServiceNotes[m.confirmedService]; ok {
+				addICSDeviceNote(d, "sends "+r.Service)
+			}
+		})
+
+		if !unicast {
+			return
+		}
+
+		updateICSDevice(r.DstIP, serviceBACnet, ts, func(d *types.ICSDevice) {
+			d.Role = icsRoleDevice
+			d.Peers = appendUnique(d.Peers, src)
+
+			if _, ok := bacnetServiceNotes[m.confirmedService]; ok {
+				addICSDeviceNote(d, "receives "+r.Service)
+			}
+		})
+	case "":
+	default:
+		// responses are sent by devices
+		updateICSDevice(src, serviceBACnet, ts, func(d *types.ICSDevice) {
+			d.Role = icsRoleDevice
+			d.Peers = appendUnique(d.Peers, r.DstIP)
+
+			if r.APDUType != apduTypes[apduComplexACK] || r.Value == "" || !isBACnetDevice(r.ObjectID) {
+				return
+			}
+
+			d.Addresses = appendUnique(d.Addresses, r.ObjectID)
+
+			switch r.Property {
+			case bacnetProperties[bacnetPropertyObjectName]:
+				d.Name = r.Value
+			case bacnetProperties[bacnetPropertyModelName]:
+				d.Model = r.Value
+			case bacnetProperties[bacnetPropertyVendorName]:
+				d.Vendor = r.Value
+			}
+		})
+	}
+}
+
+// isBACnetDevice checks if the object identifier references a device object.
+func isBACnetDevice(objectID string) bool {
+	prefix := bacnetObjectTypes[bacnetObjectDevice] + ","
+
+	return len(objectID) > len(prefix) && objectID[:len(prefix)] == prefix
+}
+
+var bacnetDecoder = newCustomDecoder(
+	types.Type_NC_BACnet,
+	serviceBACnet,
+	"BACnet is used by building automation systems to monitor and control heating, ventilation, lighting and access control",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		udp, srcIP, dstIP := udpLayerForPort(p, bacnetPort)
+		if udp == nil {
+			return nil
+		}
+
+		m, err := parseBACnet(udp.Payload)
+		if m == nil {
+			return nil
+		}
+
+		if err != nil {
+			m.record.Notes = addInfo(m.record.Notes, err.Error())
+		}
+
+		var (
+			ts    = p.Metadata().Timestamp
+			ident = srcIP + "->" + dstIP + "-" + udp.TransportFlow().String()
+			r     = m.record
+		)
+
+		r.Timestamp = utils.TimeToString(ts)
+		r.SrcIP = srcIP
+		r.DstIP = dstIP
+		r.SrcPort = int32(udp.SrcPort)
+		r.DstPort = int32(udp.DstPort)
+
+		m.inventory(ts)
+
+		if useHarvesters {
+			if creds := bacnetCredentials(m, ident, ts); creds != nil {
+				writeCredentials(creds)
+			}
+		}
+
+		return r
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
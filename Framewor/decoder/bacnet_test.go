@@ -0,0 +1,167 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// bacnetTestPacket prepends the BVLC header and the NPDU version and control octets.
+func bacnetTestPacket(function, control byte, data ...byte) []byte {
+	data = append([]byte{npduVersion, control}, data...)
+
+	return append([]byte{bvlcTypeBACnetIP, function, 0, byte(bvlcHeaderSize + len(data))}, data...)
+}
+
+func TestBACnet(t *testing.T) {
+	var (
+		records = &recordCollector{}
+		oldConf = conf
+		ts      = time.Unix(1600000000, 0)
+	)
+
+	defer func() {
+		conf = oldConf
+
+		icsDeviceState.Lock()
+		delete(icsDeviceState.devices, "10.0.0.5/"+serviceBACnet)
+		delete(icsDeviceState.devices, "10.0.0.20/"+serviceBACnet)
+		icsDeviceState.Unlock()
+	}()
+
+	conf = &Config{}
+
+	tests := []struct {
+		src, dst string
+		data     []byte
+		check    func(m *bacnetMessage) bool
+	}{
+		{
+			// Who-Is for devices 1 to 100 to all networks
+			src:  "10.0.0.5",
+			dst:  "10.0.0.255",
+			data: bacnetTestPacket(bvlcOriginalBroadcast, npduDNETPresent, 0xff, 0xff, 0, 0xff, 0x10, bacnetWhoIs, 0x09, 1, 0x19, 100),
+			check: func(m *bacnetMessage) bool {
+				r := m.record
+
+				return r.BVLCFunction == "Original-Broadcast-NPDU" && r.APDUType == "Unconfirmed-Request" && r.Service == "Who-Is" && r.Value == "1-100"
+			},
+		},
+		{
+			// I-Am device 1234 with vendor 7
+			src:  "10.0.0.20",
+			dst:  "10.0.0.255",
+			data: bacnetTestPacket(bvlcOriginalBroadcast, 0, 0x10, bacnetIAm, 0xc4, 0x02, 0x00, 0x04, 0xd2, 0x22, 0x05, 0xc4, 0x91, 0x00, 0x21, 0x07),
+			check: func(m *bacnetMessage) bool {
+				return m.record.Service == "I-Am" && m.record.ObjectID == "device,1234" && m.record.VendorID == 7
+			},
+		},
+		{
+			// WriteProperty present value 42.0 of analog-value 1 with priority 8
+			src:  "10.0.0.5",
+			dst:  "10.0.0.20",
+			data: bacnetTestPacket(bvlcOriginalUnicast, 0x04, 0x00, 0x05, 0x01, bacnetWriteProperty, 0x0c, 0x00, 0x80, 0x00, 0x01, 0x19, 85, 0x3e, 0x44, 0x42, 0x28, 0x00, 0x00, 0x3f, 0x49, 8),
+			check: func(m *bacnetMessage) bool {
+				r := m.record
+
+				return r.Service == "WriteProperty" && r.InvokeID == 1 && r.ObjectID == "analog-value,1" && r.Property == "present-value" && r.Value == "42" && r.Priority == 8 && r.Notes == "property write"
+			},
+		},
+		{
+			// ReadProperty-ACK with the object name of the device
+			src:  "10.0.0.20",
+			dst:  "10.0.0.5",
+			data: bacnetTestPacket(bvlcOriginalUnicast, 0, 0x30, 0x02, bacnetReadProperty, 0x0c, 0x02, 0x00, 0x04, 0xd2, 0x19, 77, 0x3e, 0x75, 0x06, 0x00, 'A', 'H', 'U', '-', '1', 0x3f),
+			check: func(m *bacnetMessage) bool {
+				r := m.record
+
+				return r.APDUType == "ComplexACK" && r.Service == "ReadProperty" && r.Property == "object-name" && r.Value == "AHU-1" && r.InvokeID == 2
+			},
+		},
+		{
+			// ReinitializeDevice coldstart with a password
+			src:  "10.0.0.5",
+			dst:  "10.0.0.20",
+			data: bacnetTestPacket(bvlcOriginalUnicast, 0x04, 0x00, 0x05, 0x03, bacnetReinitializeDevice, 0x09, 0x00, 0x1d, 0x07, 0x00, 's', 'e', 'c', 'r', 'e', 't'),
+			check: func(m *bacnetMessage) bool {
+				return m.record.Value == "coldstart" && m.password == "secret" && m.record.Notes == "device reinitialization | password"
+			},
+		},
+		{
+			// WriteProperty error
+			src:  "10.0.0.20",
+			dst:  "10.0.0.5",
+			data: bacnetTestPacket(bvlcOriginalUnicast, 0, 0x50, 0x03, bacnetWriteProperty, 0x91, 0x02, 0x91, 40),
+			check: func(m *bacnetMessage) bool {
+				return m.record.APDUType == "Error" && m.record.Error == "property: write-access-denied"
+			},
+		},
+		{
+			src:  "10.0.0.5",
+			dst:  "10.0.0.255",
+			data: bacnetTestPacket(bvlcOriginalBroadcast, npduNetworkMessage, 0x00),
+			check: func(m *bacnetMessage) bool {
+				return m.record.NetworkMessage == "Who-Is-Router-To-Network" && m.record.APDUType == ""
+			},
+		},
+	}
+
+	for i, test := range tests {
+		m, err := parseBACnet(test.data)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+
+		m.record.SrcIP = test.src
+		m.record.DstIP = test.dst
+
+		if !test.check(m) {
+			t.Fatal(i, "unexpected BACnet record:", m.record)
+		}
+
+		m.inventory(ts)
+	}
+
+	if m, err := parseBACnet(bacnetTestPacket(bvlcOriginalUnicast, 0, 0x10, bacnetIAm, 0xc4, 0x02)); err == nil {
+		t.Fatal("expected an error for a truncated I-Am:", m.record)
+	}
+
+	err := icsDeviceDecoder.deInit(&customDecoder{writer: records})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var device, client *types.ICSDevice
+
+	for _, r := range records.records {
+		switch d := r.(*types.ICSDevice); d.IP {
+		case "10.0.0.20":
+			device = d
+		case "10.0.0.5":
+			client = d
+		}
+	}
+
+	if device == nil || device.Role != icsRoleDevice || device.Name != "AHU-1" || device.VendorID != 7 || strings.Join(device.Addresses, ",") != "device,1234" || device.Notes != "receives WriteProperty | receives ReinitializeDevice" {
+		t.Fatal("unexpected BACnet device:", device)
+	}
+
+	if client == nil || client.Role != icsRoleClient || strings.Join(client.Peers, ",") != "10.0.0.20" || client.Messages != 3 || client.Notes != "sends WriteProperty | sends ReinitializeDevice" {
+		t.Fatal("unexpected BACnet client:", client)
+	}
+}
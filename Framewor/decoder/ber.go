@@ -0,0 +1,154 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"errors"
+)
+
+/*
+ * BER - Basic Encoding Rules
+ */
+
+const (
+	berClassUniversal   = 0
+	berClassApplication = 1
+	berClassContext     = 2
+)
+
+var (
+	errBERTruncated   = errors.New("truncated BER element")
+	errBERInvalidSize = errors.New("unsupported BER length")
+)
+
+// berElement is a single BER encoded element.
+type berElement struct {
+	class       int
+	constructed bool
+	tag         int
+	data        []byte
+}
+
+// readBER decodes the element at the start of b and returns the remaining data.
+// errBERTruncated is returned if b does not contain the complete element yet.
+func readBER(b []byte) (*berElement, []byte, error) {
+	if len(b) < 2 {
+		return nil, b, errBERTruncated
+	}
+
+	var (
+		e = &berElement{
+			class:       int(b[0] >> 6),
+			constructed: b[0]&0x20 != 0,
+			tag:         int(b[0] & 0x1f),
+		}
+		off = 1
+	)
+
+	// high tag numbers are encoded in base 128
+	if e.tag == 0x1f {
+		e.tag = 0
+
+		for {
+			if off >= len(b) {
+				return nil, b, errBERTruncated
+			}
+
+			c := b[off]
+			off++
+
+			e.tag = e.tag<<7 | int(c&0x7f)
+			if c&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	if off >= len(b) {
+		return nil, b, errBERTruncated
+	}
+
+	length := int(b[off])
+	off++
+
+	// long form, the indefinite form is not supported
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 {
+			return nil, b, errBERInvalidSize
+		}
+
+		if off+n > len(b) {
+			return nil, b, errBERTruncated
+		}
+
+		length = 0
+		for _, c := range b[off : off+n] {
+			length = length<<8 | int(c)
+		}
+
+		off += n
+	}
+
+	if length < 0 {
+		return nil, b, errBERInvalidSize
+	}
+
+	if off+length > len(b) {
+		return nil, b, errBERTruncated
+	}
+
+	e.data = b[off : off+length]
+
+	return e, b[off+length:], nil
+}
+
+// children decodes the elements contained in a constructed element.
+func (e *berElement) children() []*berElement {
+	var (
+		out  []*berElement
+		data = e.data
+	)
+
+	for len(data) > 0 {
+		c, rest, err := readBER(data)
+		if err != nil {
+			break
+		}
+
+		out = append(out, c)
+		data = rest
+	}
+
+	return out
+}
+
+// is checks the class and tag of the element.
+func (e *berElement) is(class, tag int) bool {
+	return e != nil && e.class == class && e.tag == tag
+}
+
+// berInteger decodes a big endian two's complement integer.
+func berInteger(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+
+	return v
+}
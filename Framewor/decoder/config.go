@@ -79,6 +79,7 @@ var DefaultConfig = &Config{
 	CloseInactiveTimeOut:    24 * time.Hour,
 	ClosePendingTimeOut:     5 * time.Second,
 	FileStorage:             netcap.DefaultFileStorage,
+	KeyboardLayout:          "us",
 	CalculateEntropy:        false,
 	SaveConns:               false,
 	TCPDebug:                false,
@@ -115,6 +116,12 @@ type Config struct {
 	// If a path is set files will be extracted and written to the specified path
 	FileStorage string
 
+	// If a path is set the passive DNS database will be merged into the JSON file at the specified path on teardown
+	PassiveDNSStore string
+
+	// Keyboard layout used to decode USB HID keystrokes (us, de or fr), defaults to us
+	KeyboardLayout string
+
 	// Number of packets to arrive until the connections are checked for timeouts
 	ConnFlushInterval int
 
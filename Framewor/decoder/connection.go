@@ -33,10 +33,13 @@ type connectionID struct {
 	LinkFlowID      uint64
 	NetworkFlowID   uint64
 	TransportFlowID uint64
+
+	// tunnel protocol and identifier for overlay traffic
+	TunnelID string
 }
 
 func (c connectionID) String() string {
-	return strconv.FormatUint(c.LinkFlowID, 10) + strconv.FormatUint(c.NetworkFlowID, 10) + strconv.FormatUint(c.TransportFlowID, 10)
+	return strconv.FormatUint(c.LinkFlowID, 10) + strconv.FormatUint(c.NetworkFlowID, 10) + strconv.FormatUint(c.TransportFlowID, 10) + c.TunnelID
 }
 
 type connection struct {
@@ -98,19 +101,28 @@ func (cd *connectionDecoder) Destroy() (name string, size int64) {
 
 func (cd *connectionDecoder) handlePacket(p gopacket.Packet) proto.Message {
 	// assemble connectionID
-	connID := connectionID{}
-	if ll := p.LinkLayer(); ll != nil {
+	var (
+		connID = connectionID{}
+		l      = innerLayers(p)
+	)
+
+	if ll := l.link; ll != nil {
 		connID.LinkFlowID = ll.LinkFlow().FastHash()
 	}
 
-	if nl := p.NetworkLayer(); nl != nil {
+	if nl := l.network; nl != nil {
 		connID.NetworkFlowID = nl.NetworkFlow().FastHash()
 	}
 
-	if tl := p.TransportLayer(); tl != nil {
+	if tl := l.transport; tl != nil {
 		connID.TransportFlowID = tl.TransportFlow().FastHash()
 	}
 
+	// overlay networks can reuse the same inner addresses
+	if t := l.tunnel; t != nil {
+		connID.TunnelID = t.proto + strconv.FormatUint(uint64(t.id), 10)
+	}
+
 	// lookup flow
 	cd.Conns.Lock()
 
@@ -131,20 +143,25 @@ func (cd *connectionDecoder) handlePacket(p gopacket.Packet) proto.Message {
 
 			// rewrite source and destination parameters
 			// since the first packet decides about the flow direction
-			if ll := p.LinkLayer(); ll != nil {
+			if ll := l.link; ll != nil {
 				conn.SrcMAC = ll.LinkFlow().Src().String()
 				conn.DstMAC = ll.LinkFlow().Dst().String()
 			}
 
-			if nl := p.NetworkLayer(); nl != nil {
+			if nl := l.network; nl != nil {
 				conn.SrcIP = nl.NetworkFlow().Src().String()
 				conn.DstIP = nl.NetworkFlow().Dst().String()
 			}
 
-			if tl := p.TransportLayer(); tl != nil {
+			if tl := l.transport; tl != nil {
 				conn.SrcPort = tl.TransportFlow().Src().String()
 				conn.DstPort = tl.TransportFlow().Dst().String()
 			}
+
+			if t := l.tunnel; t != nil {
+				conn.TunnelSrcIP = t.srcIP
+				conn.TunnelDstIP = t.dstIP
+			}
 		}
 
 		// check if last timestamp was before the current packet
@@ -170,25 +187,31 @@ func (cd *connectionDecoder) handlePacket(p gopacket.Packet) proto.Message {
 		co.TimestampFirst = utils.TimeToString(p.Metadata().Timestamp)
 		co.TimestampLast = utils.TimeToString(p.Metadata().Timestamp)
 
-		if ll := p.LinkLayer(); ll != nil {
+		if ll := l.link; ll != nil {
 			co.LinkProto = ll.LayerType().String()
 			co.SrcMAC = ll.LinkFlow().Src().String()
 			co.DstMAC = ll.LinkFlow().Dst().String()
 		}
-		if nl := p.NetworkLayer(); nl != nil {
+		if nl := l.network; nl != nil {
 			co.NetworkProto = nl.LayerType().String()
 			co.SrcIP = nl.NetworkFlow().Src().String()
 			co.DstIP = nl.NetworkFlow().Dst().String()
 		}
-		if tl := p.TransportLayer(); tl != nil {
+		if tl := l.transport; tl != nil {
 			co.TransportProto = tl.LayerType().String()
 			co.SrcPort = tl.TransportFlow().Src().String()
 			co.DstPort = tl.TransportFlow().Dst().String()
 		}
-		if al := p.ApplicationLayer(); al != nil {
+		if al := l.application; al != nil {
 			co.ApplicationProto = al.LayerType().String()
 			co.AppPayloadSize = int32(len(al.Payload()))
 		}
+		if t := l.tunnel; t != nil {
+			co.TunnelProto = t.proto
+			co.TunnelSrcIP = t.srcIP
+			co.TunnelDstIP = t.dstIP
+			co.TunnelID = t.id
+		}
 		cd.Conns.Items[connID.String()] = &connection{
 			Connection: co,
 		}
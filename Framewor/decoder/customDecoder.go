@@ -49,6 +49,36 @@ var (
 		sshDecoder,
 		vulnerabilityDecoder,
 		exploitDecoder,
+		passiveDNSDecoder,
+		dnsAnomalyDecoder,
+		smbDecoder,
+		ftpDecoder,
+		smtpSessionDecoder,
+		imapDecoder,
+		kerberosDecoder,
+		rdpDecoder,
+		snmpDecoder,
+		ldapDecoder,
+		postgreSQLDecoder,
+		mySQLDecoder,
+		redisDecoder,
+		memcachedDecoder,
+		radiusDecoder,
+		mdnsDecoder,
+		llmnrDecoder,
+		nbnsDecoder,
+		gtpcDecoder,
+		wirelessNetworkDecoder,
+		wirelessClientDecoder,
+		wpaHandshakeDecoder,
+		callDecoder,
+		usbKeystrokesDecoder,
+		usbMassStorageDecoder,
+		dnp3Decoder,
+		s7Decoder,
+		iec104Decoder,
+		bacnetDecoder,
+		icsDeviceDecoder,
 	} // contains all available custom decoders
 )
 
@@ -194,6 +194,7 @@ var profileDecoder = newCustomDecoder(
 		// flush writer
 		for _, item := range Profiles.Items {
 			item.Lock()
+			item.RDPFingerprints = deviceRDPFingerprints(item.DeviceIPs)
 			writeProfile(item.DeviceProfile)
 			item.Unlock()
 		}
@@ -202,6 +203,29 @@ var profileDecoder = newCustomDecoder(
 	},
 )
 
+// deviceRDPFingerprints collects the RDP client fingerprints attributed to the ip addresses of a device.
+func deviceRDPFingerprints(ips []*types.IPProfile) map[string]string {
+	var fingerprints map[string]string
+
+	for _, ip := range ips {
+		if ip == nil {
+			continue
+		}
+
+		for fingerprint, client := range rdpFingerprints(ip.Addr) {
+			if fingerprints == nil {
+				fingerprints = make(map[string]string)
+			}
+
+			if _, ok := fingerprints[fingerprint]; !ok {
+				fingerprints[fingerprint] = client
+			}
+		}
+	}
+
+	return fingerprints
+}
+
 // writeProfile writes the profile.
 func writeProfile(d *types.DeviceProfile) {
 	if conf.ExportMetrics {
@@ -0,0 +1,202 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// dgaCorpus is the training data for the character level bigram model used for DGA detection.
+// It contains common english words and frequently seen second level domain labels.
+const dgaCorpus = `
+about above academy access account action active actor adobe advice affair agency agent airline airport album alert
+alliance amazon america analysis android animal answer apache apple application archive area army article artist asset
+audio author auto avenue award baby back bakery balance bank banner barber base basket battle beach beauty bedroom
+belgium berlin best better beyond bible bicycle bike bill bing biology bird birthday black blog blue board body book
+booking border boston bottle bound brand bread bridge bright broadcast brother browser budget builder building bureau
+business butter button cable cafe calendar camera campaign campus canada candle capital captain carbon card care career
+carpet cart castle catalog category center central century chain chair challenge champion channel chapter charity chart
+check cheese chemical chicken child china choice christmas chrome church cinema circle citizen city civil class classic
+clean client climate clinic clock cloud cloudflare club coach coast coffee collection college colony color column comfort
+comic command comment commerce common community company compare computer concert conference connect consult contact
+content contest control cookie cooking corner corporate cottage council counter country county course court cover craft
+credit cricket crystal culture customer cycle daily dance danger data date dealer debate decision default defense degree
+delivery dental deposit design desk detail develop device diamond diary digital dinner direct director discount discover
+disney doctor document dollar domain double download dragon drama dream dress drive dropbox driver early earth east
+economy edition editor education effect election electric element email emergency empire energy engine english enter
+entry environment equal estate europe event evidence exchange expert explore express facebook factory fair family
+fashion father feature federal festival field figure file film final finance fire firefox first fish fitness flash
+flight floor flower focus folder follow food football force forest forum foundation france free fresh friend front
+fruit fund future gallery game garden gateway general gentle german gift girl github glass global gmail gold golf good
+google government grace grand graphic great green group growth guard guide guitar hair hand happy harbor hardware
+health heart heaven help hero high history hockey holiday home honey horizon horse hospital host hotel house human
+hunter image impact index industry info inside insight instagram institute insurance intel interest internet island
+item jacket japan jewel job join journal journey judge jungle junior justice kernel key king kitchen knight knowledge
+label labor lake land language laptop laser launch lawyer leader league learning legal lemon lesson letter level
+library license life light limit line linkedin linux list live local location login london love lucky machine magazine
+magic mail main maker manager manual map marine market marketing master match material media medical member memory
+menu message metal method micro microsoft middle military million mind mirror mobile model modern money monitor month
+morning mother motion motor mountain mouse movie mozilla music nation native nature network news newspaper night north
+notice number ocean office official online open opera option oracle orange order origin outdoor owner pacific package
+page paint palace panel paper parent paris park partner party passion patient pattern payment paypal peace people
+pepper perfect person phone photo physics piano picture pilot pink pizza place planet plant platform player plaza
+pocket point police policy portal position post power practice premium press price prime print private prize product
+profile program project property protect provider public publisher purple quality quarter question quick radio rain
+range reader real record recovery reddit region register release remote rental report research reserve resort
+resource restaurant result review reward river road robot rock room royal rugby safety sale salon sample savings
+scholar school science score screen search season second secure security select senior server service session
+settings share shell shipping shop shopping short show signal silver simple single site skype smart snow social
+software solar solution sound source south space special sport spotify spring square stack staff stage standard star
+start state station steam stock stone storage store story strategy stream street strong student studio style summer
+super support surface survey sweet system table talent target teacher team tech technology telecom telegram television
+template tennis terminal test text theater theme thunder ticket tiger time today tool total tourism tower track trade
+traffic training travel treasure trend trust tumblr twitter ubuntu ultra union united unity universe university update
+upload urban user valley value vector venture version video view village vision visit voice volume wallet water
+weather webmail website wedding weekly welcome west whatsapp white wiki wikipedia window windows wine winter wireless
+women wonder wood word wordpress work world writer yahoo yellow young youtube zone
+`
+
+const (
+	// index of the start and end markers in the bigram model
+	dgaStart = 37
+	dgaEnd   = 38
+	// alphabet size including the start and end markers
+	dgaAlphabetSize = 39
+)
+
+// dgaBigramModel holds log probabilities for character transitions
+// and the calibration values used to normalize scores.
+type dgaBigramModel struct {
+	logProb [dgaAlphabetSize][dgaAlphabetSize]float64
+
+	// per transition log probability below which training data is considered unusual
+	benignMean float64
+
+	// mean per transition log probability for uniformly random labels
+	randomMean float64
+}
+
+// dgaModel is trained on the bundled corpus at startup.
+var dgaModel = newDGABigramModel(strings.Fields(dgaCorpus))
+
+// dgaCharIndex maps a character of a domain label to its index in the model.
+// The second return value is false if the character is not part of the alphabet.
+func dgaCharIndex(c byte) (int, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= '0' && c <= '9':
+		return 26 + int(c-'0'), true
+	case c == '-':
+		return 36, true
+	default:
+		return 0, false
+	}
+}
+
+// dgaTransitions returns the model indices for the label, enclosed by start and end markers.
+func dgaTransitions(label string) []int {
+	idx := make([]int, 0, len(label)+2)
+	idx = append(idx, dgaStart)
+
+	for i := 0; i < len(label); i++ {
+		if n, ok := dgaCharIndex(label[i]); ok {
+			idx = append(idx, n)
+		}
+	}
+
+	return append(idx, dgaEnd)
+}
+
+// newDGABigramModel trains a bigram model with add-one smoothing on the given words.
+func newDGABigramModel(words []string) *dgaBigramModel {
+	var (
+		m      = new(dgaBigramModel)
+		counts [dgaAlphabetSize][dgaAlphabetSize]float64
+	)
+
+	for _, w := range words {
+		idx := dgaTransitions(strings.ToLower(w))
+		for i := 1; i < len(idx); i++ {
+			counts[idx[i-1]][idx[i]]++
+		}
+	}
+
+	for i := 0; i < dgaAlphabetSize; i++ {
+		var total float64
+		for j := 0; j < dgaAlphabetSize; j++ {
+			total += counts[i][j] + 1
+		}
+
+		for j := 0; j < dgaAlphabetSize; j++ {
+			m.logProb[i][j] = math.Log2((counts[i][j] + 1) / total)
+		}
+	}
+
+	// calibrate using the training data, the reference value is chosen
+	// so that 90 percent of the training words score zero
+	benign := make([]float64, len(words))
+	for i, w := range words {
+		benign[i] = m.meanLogProb(strings.ToLower(w))
+	}
+
+	sort.Float64s(benign)
+	m.benignMean = benign[len(benign)/10]
+
+	var sum float64
+
+	// expected value for labels drawn uniformly from letters and digits
+	for i := 0; i < 36; i++ {
+		for j := 0; j < 36; j++ {
+			sum += m.logProb[i][j]
+		}
+	}
+
+	m.randomMean = sum / (36 * 36)
+
+	return m
+}
+
+// meanLogProb returns the mean log probability per character transition for the label.
+func (m *dgaBigramModel) meanLogProb(label string) float64 {
+	idx := dgaTransitions(label)
+
+	var sum float64
+	for i := 1; i < len(idx); i++ {
+		sum += m.logProb[idx[i-1]][idx[i]]
+	}
+
+	return sum / float64(len(idx)-1)
+}
+
+// score returns the likelihood that the label was produced by a domain generation algorithm
+// as a value between 0 (looks like natural language) and 1 (looks random).
+func (m *dgaBigramModel) score(label string) float64 {
+	return clamp((m.benignMean - m.meanLogProb(label)) / (m.benignMean - m.randomMean))
+}
+
+// clamp limits the value to the range [0, 1].
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+
+	if v > 1 {
+		return 1
+	}
+
+	return v
+}
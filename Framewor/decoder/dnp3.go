@@ -0,0 +1,40 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+const serviceDNP3 = "DNP3"
+
+var dnp3Decoder = newCustomDecoder(
+	types.Type_NC_DNP3,
+	serviceDNP3,
+	"The Distributed Network Protocol 3 (DNP3) is used between the control centers and the outstations of utilities",
+	func(d *customDecoder) error {
+		streamFactory.decodeDNP3 = true
+
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		return nil
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
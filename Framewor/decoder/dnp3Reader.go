@@ -0,0 +1,666 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * DNP3 - link, transport and application layer
+ */
+
+const (
+	// link layer header including its CRC
+	dnp3HeaderSize = 10
+
+	// user data is split into blocks followed by a CRC
+	dnp3BlockSize = 16
+
+	// minimum value of the length field: control, destination and source
+	dnp3MinLength = 5
+
+	// upper limit for reassembled application fragments
+	dnp3MaxFragmentSize = 64 * 1024
+)
+
+// link layer control bits
+const (
+	dnp3LinkDir = 0x80
+	dnp3LinkPRM = 0x40
+
+	dnp3LinkConfirmedUserData   = 0x03
+	dnp3LinkUnconfirmedUserData = 0x04
+)
+
+// transport header bits
+const (
+	dnp3TransportFIN = 0x80
+	dnp3TransportFIR = 0x40
+)
+
+// application layer function codes that are referenced directly
+const (
+	dnp3FuncRead                 = 0x01
+	dnp3FuncImmediateFreeze      = 0x07
+	dnp3FuncFreezeClearNoAck     = 0x0a
+	dnp3FuncEnableUnsolicited    = 0x14
+	dnp3FuncAssignClass          = 0x16
+	dnp3FuncResponse             = 0x81
+	dnp3FuncUnsolicitedResponse  = 0x82
+	dnp3FuncAuthenticateResponse = 0x83
+
+	dnp3ApplicationSequenceMask = 0x0f
+)
+
+// object header qualifier codes and object groups
+const (
+	dnp3ObjectPrefixMask        = 0x07
+	dnp3ObjectPrefixSizeOneByte = 0x04
+	dnp3ObjectRangeMask         = 0x0f
+	dnp3ObjectRangeAll          = 0x06
+	dnp3ObjectRangeFreeFormat   = 0x0b
+
+	dnp3ObjectGroupCROB             = 12
+	dnp3ObjectGroupAnalogOutput     = 41
+	dnp3ObjectGroupOctetString      = 110
+	dnp3ObjectGroupOctetStringEvent = 111
+)
+
+var (
+	dnp3Magic = []byte{0x05, 0x64}
+
+	errDNP3Incomplete = errors.New("incomplete DNP3 frame")
+	errDNP3Invalid    = errors.New("invalid DNP3 frame")
+)
+
+var dnp3Functions = map[byte]string{
+	0x00: "CONFIRM",
+	0x01: "READ",
+	0x02: "WRITE",
+	0x03: "SELECT",
+	0x04: "OPERATE",
+	0x05: "DIRECT_OPERATE",
+	0x06: "DIRECT_OPERATE_NR",
+	0x07: "IMMED_FREEZE",
+	0x08: "IMMED_FREEZE_NR",
+	0x09: "FREEZE_CLEAR",
+	0x0a: "FREEZE_CLEAR_NR",
+	0x0b: "FREEZE_AT_TIME",
+	0x0c: "FREEZE_AT_TIME_NR",
+	0x0d: "COLD_RESTART",
+	0x0e: "WARM_RESTART",
+	0x0f: "INITIALIZE_DATA",
+	0x10: "INITIALIZE_APPL",
+	0x11: "START_APPL",
+	0x12: "STOP_APPL",
+	0x13: "SAVE_CONFIG",
+	0x14: "ENABLE_UNSOLICITED",
+	0x15: "DISABLE_UNSOLICITED",
+	0x16: "ASSIGN_CLASS",
+	0x17: "DELAY_MEASURE",
+	0x18: "RECORD_CURRENT_TIME",
+	0x19: "OPEN_FILE",
+	0x1a: "CLOSE_FILE",
+	0x1b: "DELETE_FILE",
+	0x1c: "GET_FILE_INFO",
+	0x1d: "AUTHENTICATE_FILE",
+	0x1e: "ABORT_FILE",
+	0x1f: "ACTIVATE_CONFIG",
+	0x20: "AUTHENTICATE_REQ",
+	0x21: "AUTH_REQ_NO_ACK",
+	0x81: "RESPONSE",
+	0x82: "UNSOLICITED_RESPONSE",
+	0x83: "AUTHENTICATE_RESP",
+}
+
+// dnp3CriticalFunctions change the state of the process or the outstation.
+var dnp3CriticalFunctions = map[byte]string{
+	0x03: "control operation (select)",
+	0x04: "control operation",
+	0x05: "control operation",
+	0x06: "control operation without acknowledgement",
+	0x0d: "cold restart",
+	0x0e: "warm restart",
+	0x0f: "initializes data to startup values",
+	0x12: "stops an application",
+	0x15: "disables unsolicited responses",
+	0x1b: "deletes a file",
+	0x1f: "activates a configuration",
+}
+
+// dnp3InternalIndications are the names of the IIN bits, IIN1.0 to IIN2.7.
+var dnp3InternalIndications = [16]string{
+	"ALL_STATIONS",
+	"CLASS_1_EVENTS",
+	"CLASS_2_EVENTS",
+	"CLASS_3_EVENTS",
+	"NEED_TIME",
+	"LOCAL_CONTROL",
+	"DEVICE_TROUBLE",
+	"DEVICE_RESTART",
+	"NO_FUNC_CODE_SUPPORT",
+	"OBJECT_UNKNOWN",
+	"PARAMETER_ERROR",
+	"EVENT_BUFFER_OVERFLOW",
+	"ALREADY_EXECUTING",
+	"CONFIG_CORRUPT",
+	"RESERVED_2",
+	"RESERVED_1",
+}
+
+// dnp3CROBOperations are the operation types of the control relay output block.
+var dnp3CROBOperations = map[byte]string{
+	0: "NUL",
+	1: "PULSE_ON",
+	2: "PULSE_OFF",
+	3: "LATCH_ON",
+	4: "LATCH_OFF",
+}
+
+func dnp3Object(group, variation byte) uint16 {
+	return uint16(group)<<8 | uint16(variation)
+}
+
+// dnp3ObjectSizes contains the sizes of fixed size objects in bytes.
+var dnp3ObjectSizes = map[uint16]int{
+	dnp3Object(1, 2): 1,
+	dnp3Object(2, 1): 1, dnp3Object(2, 2): 7, dnp3Object(2, 3): 3,
+	dnp3Object(3, 2): 1,
+	dnp3Object(4, 1): 1, dnp3Object(4, 2): 7, dnp3Object(4, 3): 3,
+	dnp3Object(10, 2): 1,
+	dnp3Object(11, 1): 1, dnp3Object(11, 2): 7,
+	dnp3Object(12, 1): 11, dnp3Object(12, 2): 11,
+	dnp3Object(13, 1): 1, dnp3Object(13, 2): 7,
+	dnp3Object(20, 1): 5, dnp3Object(20, 2): 3, dnp3Object(20, 5): 4, dnp3Object(20, 6): 2,
+	dnp3Object(21, 1): 5, dnp3Object(21, 2): 3, dnp3Object(21, 5): 11, dnp3Object(21, 6): 9, dnp3Object(21, 9): 4, dnp3Object(21, 10): 2,
+	dnp3Object(22, 1): 5, dnp3Object(22, 2): 3, dnp3Object(22, 5): 11, dnp3Object(22, 6): 9,
+	dnp3Object(23, 1): 5, dnp3Object(23, 2): 3, dnp3Object(23, 5): 11, dnp3Object(23, 6): 9,
+	dnp3Object(30, 1): 5, dnp3Object(30, 2): 3, dnp3Object(30, 3): 4, dnp3Object(30, 4): 2, dnp3Object(30, 5): 5, dnp3Object(30, 6): 9,
+	dnp3Object(31, 1): 5, dnp3Object(31, 2): 3, dnp3Object(31, 3): 11, dnp3Object(31, 4): 9, dnp3Object(31, 5): 4, dnp3Object(31, 6): 2, dnp3Object(31, 7): 5, dnp3Object(31, 8): 9,
+	dnp3Object(32, 1): 5, dnp3Object(32, 2): 3, dnp3Object(32, 3): 11, dnp3Object(32, 4): 9, dnp3Object(32, 5): 5, dnp3Object(32, 6): 9, dnp3Object(32, 7): 11, dnp3Object(32, 8): 15,
+	dnp3Object(33, 1): 5, dnp3Object(33, 2): 3, dnp3Object(33, 3): 11, dnp3Object(33, 4): 9, dnp3Object(33, 5): 5, dnp3Object(33, 6): 9, dnp3Object(33, 7): 11, dnp3Object(33, 8): 15,
+	dnp3Object(34, 1): 2, dnp3Object(34, 2): 4, dnp3Object(34, 3): 4,
+	dnp3Object(40, 1): 5, dnp3Object(40, 2): 3, dnp3Object(40, 3): 5, dnp3Object(40, 4): 9,
+	dnp3Object(41, 1): 5, dnp3Object(41, 2): 3, dnp3Object(41, 3): 5, dnp3Object(41, 4): 9,
+	dnp3Object(42, 1): 5, dnp3Object(42, 2): 3, dnp3Object(42, 3): 11, dnp3Object(42, 4): 9, dnp3Object(42, 5): 5, dnp3Object(42, 6): 9, dnp3Object(42, 7): 11, dnp3Object(42, 8): 15,
+	dnp3Object(43, 1): 5, dnp3Object(43, 2): 3, dnp3Object(43, 3): 11, dnp3Object(43, 4): 9, dnp3Object(43, 5): 5, dnp3Object(43, 6): 9, dnp3Object(43, 7): 11, dnp3Object(43, 8): 15,
+	dnp3Object(50, 1): 6, dnp3Object(50, 2): 10, dnp3Object(50, 3): 6, dnp3Object(50, 4): 11,
+	dnp3Object(51, 1): 6, dnp3Object(51, 2): 6,
+	dnp3Object(52, 1): 2, dnp3Object(52, 2): 2,
+}
+
+// dnp3PackedObjects contains the number of bits of objects that are packed without padding.
+var dnp3PackedObjects = map[uint16]int{
+	dnp3Object(1, 1):  1,
+	dnp3Object(3, 1):  2,
+	dnp3Object(10, 1): 1,
+	dnp3Object(12, 3): 1,
+	dnp3Object(80, 1): 1,
+}
+
+// isDNP3 checks if the server sent a DNP3 frame or the default port is used.
+func isDNP3(banner []byte, transport gopacket.Flow) bool {
+	return transport.Dst().String() == "20000" || bytes.HasPrefix(banner, dnp3Magic)
+}
+
+// dnp3CRC calculates the CRC used for the header and the data blocks of link layer frames.
+func dnp3CRC(b []byte) uint16 {
+	var crc uint16
+
+	for _, c := range b {
+		crc ^= uint16(c)
+
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa6bc
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+
+	return ^crc
+}
+
+type dnp3Frame struct {
+	control byte
+	dst     uint16
+	src     uint16
+
+	// user data without the CRCs
+	data []byte
+}
+
+// readDNP3Frame parses a link layer frame and returns it with the number of consumed bytes.
+func readDNP3Frame(b []byte) (*dnp3Frame, int, error) {
+	if len(b) >= 2 && !bytes.Equal(b[:2], dnp3Magic) {
+		return nil, 0, errDNP3Invalid
+	}
+
+	if len(b) < dnp3HeaderSize {
+		return nil, 0, errDNP3Incomplete
+	}
+
+	if int(b[2]) < dnp3MinLength || dnp3CRC(b[:8]) != binary.LittleEndian.Uint16(b[8:10]) {
+		return nil, 0, errDNP3Invalid
+	}
+
+	var (
+		userLen = int(b[2]) - dnp3MinLength
+		size    = dnp3HeaderSize + userLen + 2*((userLen+dnp3BlockSize-1)/dnp3BlockSize)
+		f       = &dnp3Frame{
+			control: b[3],
+			dst:     binary.LittleEndian.Uint16(b[4:6]),
+			src:     binary.LittleEndian.Uint16(b[6:8]),
+		}
+	)
+
+	if len(b) < size {
+		return nil, 0, errDNP3Incomplete
+	}
+
+	for i := dnp3HeaderSize; i < size; i += dnp3BlockSize + 2 {
+		end := i + dnp3BlockSize
+		if end > size-2 {
+			end = size - 2
+		}
+
+		if dnp3CRC(b[i:end]) != binary.LittleEndian.Uint16(b[end:end+2]) {
+			return nil, 0, errDNP3Invalid
+		}
+
+		f.data = append(f.data, b[i:end]...)
+	}
+
+	return f, size, nil
+}
+
+// dnp3RequestHasData checks if the object headers of a request are followed by objects.
+func dnp3RequestHasData(fc byte) bool {
+	switch {
+	case fc == dnp3FuncRead,
+		fc >= dnp3FuncImmediateFreeze && fc <= dnp3FuncFreezeClearNoAck,
+		fc >= dnp3FuncEnableUnsolicited && fc <= dnp3FuncAssignClass:
+		return false
+	}
+
+	return true
+}
+
+// dnp3Objects parses the object headers of an application fragment
+// and decodes the commands of control relay output blocks and analog outputs.
+func dnp3Objects(b []byte, hasData bool) (objects, controls []string) {
+	for len(b) >= 3 {
+		var (
+			group, variation = b[0], b[1]
+			prefix           = int(b[2]>>4) & dnp3ObjectPrefixMask
+			rng              = b[2] & dnp3ObjectRangeMask
+			obj              = dnp3Object(group, variation)
+			start, count     uint32
+			desc             string
+			n                int
+		)
+
+		b = b[3:]
+
+		switch rng {
+		case 0x00, 0x01, 0x02, 0x03, 0x04, 0x05: // start and stop index or virtual address
+			n = 1 << (rng % 3)
+			if len(b) < 2*n {
+				return
+			}
+
+			start = dnp3Uint(b[:n])
+			stop := dnp3Uint(b[n : 2*n])
+			b = b[2*n:]
+
+			if stop < start {
+				return
+			}
+
+			count = stop - start + 1
+			desc = fmt.Sprintf("%d-%d", start, stop)
+		case dnp3ObjectRangeAll:
+			desc = "all"
+		case 0x07, 0x08, 0x09, dnp3ObjectRangeFreeFormat: // number of objects
+			n = 1 << ((rng - 0x07) % 4)
+			if rng == dnp3ObjectRangeFreeFormat {
+				n = 1
+			}
+
+			if len(b) < n {
+				return
+			}
+
+			count = dnp3Uint(b[:n])
+			b = b[n:]
+			desc = "count " + strconv.FormatUint(uint64(count), 10)
+		default:
+			objects = append(objects, fmt.Sprintf("g%dv%d", group, variation))
+
+			return
+		}
+
+		objects = append(objects, fmt.Sprintf("g%dv%d %s", group, variation, desc))
+
+		if !hasData || rng == dnp3ObjectRangeAll {
+			continue
+		}
+
+		// packed objects without prefix
+		if bits, ok := dnp3PackedObjects[obj]; ok && prefix == 0 {
+			size := (int(count)*bits + 7) / 8
+			if len(b) < size {
+				return
+			}
+
+			b = b[size:]
+
+			continue
+		}
+
+		size, ok := dnp3ObjectSizes[obj]
+		if group == dnp3ObjectGroupOctetString || group == dnp3ObjectGroupOctetStringEvent {
+			size, ok = int(variation), true
+		}
+
+		// the prefix contains the object size
+		if !ok && prefix < dnp3ObjectPrefixSizeOneByte {
+			return
+		}
+
+		for i := uint32(0); i < count; i++ {
+			var (
+				index = start + i
+				p     = 0
+			)
+
+			if prefix > 0 {
+				p = 1 << ((prefix - 1) % 3)
+				if len(b) < p {
+					return
+				}
+
+				if prefix < dnp3ObjectPrefixSizeOneByte {
+					index = dnp3Uint(b[:p])
+				} else {
+					size = int(dnp3Uint(b[:p]))
+				}
+			}
+
+			if len(b) < p+size {
+				return
+			}
+
+			if c := dnp3Control(group, variation, index, b[p:p+size]); c != "" {
+				controls = append(controls, c)
+			}
+
+			b = b[p+size:]
+		}
+	}
+
+	return objects, controls
+}
+
+// dnp3Uint decodes a little endian index, range or size field.
+func dnp3Uint(b []byte) uint32 {
+	switch len(b) {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(binary.LittleEndian.Uint16(b))
+	}
+
+	return binary.LittleEndian.Uint32(b)
+}
+
+// dnp3Control describes the command of control relay output block and analog output objects.
+func dnp3Control(group, variation byte, index uint32, data []byte) string {
+	prefix := "index " + strconv.FormatUint(uint64(index), 10) + " "
+
+	switch group {
+	case dnp3ObjectGroupCROB:
+		if variation != 1 || len(data) < 11 {
+			return ""
+		}
+
+		op, ok := dnp3CROBOperations[data[0]&0x0f]
+		if !ok {
+			op = fmt.Sprintf("OP(%d)", data[0]&0x0f)
+		}
+
+		switch data[0] >> 6 {
+		case 1:
+			op += " CLOSE"
+		case 2:
+			op += " TRIP"
+		}
+
+		if data[1] > 1 {
+			op += " count " + strconv.Itoa(int(data[1]))
+		}
+
+		if data[0]&0x0f == 1 || data[0]&0x0f == 2 {
+			op += " on " + strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data[2:6])), 10) + "ms"
+		}
+
+		return prefix + op
+	case dnp3ObjectGroupAnalogOutput:
+		var value string
+
+		switch {
+		case variation == 1 && len(data) >= 4:
+			value = strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(data))), 10)
+		case variation == 2 && len(data) >= 2:
+			value = strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(data))), 10)
+		case variation == 3 && len(data) >= 4:
+			value = strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32)
+		case variation == 4 && len(data) >= 8:
+			value = strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64)
+		default:
+			return ""
+		}
+
+		return prefix + "value " + value
+	}
+
+	return ""
+}
+
+// dnp3Fragment collects the transport segments of an application fragment.
+type dnp3Fragment struct {
+	data     []byte
+	segments int32
+	ts       time.Time
+}
+
+type dnp3Reader struct {
+	parent *tcpConnection
+
+	// transport layer reassembly by direction, source and destination
+	fragments map[string]*dnp3Fragment
+}
+
+// Decode parses the stream according to the DNP3 protocol.
+func (h *dnp3Reader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	h.fragments = make(map[string]*dnp3Fragment)
+
+	for _, d := range h.parent.merged {
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.read(&clientBuf, d.dir, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.read(&serverBuf, d.dir, ts)
+		}
+	}
+}
+
+// read parses the link layer frames in the buffer.
+func (h *dnp3Reader) read(buf *bytes.Buffer, dir reassembly.TCPFlowDirection, ts time.Time) {
+	for buf.Len() > 0 {
+		f, n, err := readDNP3Frame(buf.Bytes())
+		if err == errDNP3Incomplete {
+			return
+		}
+
+		if err != nil {
+			// resynchronize at the next start bytes
+			idx := bytes.Index(buf.Bytes()[1:], dnp3Magic)
+			if idx == -1 {
+				buf.Next(buf.Len() - 1)
+
+				return
+			}
+
+			utils.DebugLog.Println("DNP3: skipping", idx+1, "invalid bytes on", h.parent.ident)
+			buf.Next(idx + 1)
+
+			continue
+		}
+
+		buf.Next(n)
+
+		fc := f.control & 0x0f
+		if f.control&dnp3LinkPRM == 0 || (fc != dnp3LinkConfirmedUserData && fc != dnp3LinkUnconfirmedUserData) || len(f.data) == 0 {
+			// link layer services without user data
+			continue
+		}
+
+		h.transport(f, dir, ts)
+	}
+}
+
+// transport reassembles the application fragment from the transport segments.
+func (h *dnp3Reader) transport(f *dnp3Frame, dir reassembly.TCPFlowDirection, ts time.Time) {
+	var (
+		th   = f.data[0]
+		key  = fmt.Sprintf("%v-%d-%d", dir, f.src, f.dst)
+		frag = h.fragments[key]
+	)
+
+	if th&dnp3TransportFIR != 0 {
+		frag = &dnp3Fragment{ts: ts}
+		h.fragments[key] = frag
+	}
+
+	// segments without the first one can not be decoded
+	if frag == nil {
+		return
+	}
+
+	frag.data = append(frag.data, f.data[1:]...)
+	frag.segments++
+
+	if len(frag.data) > dnp3MaxFragmentSize {
+		delete(h.fragments, key)
+
+		return
+	}
+
+	if th&dnp3TransportFIN != 0 {
+		delete(h.fragments, key)
+		h.application(f, frag, dir)
+	}
+}
+
+// application decodes an application fragment and writes the audit record.
+func (h *dnp3Reader) application(f *dnp3Frame, frag *dnp3Fragment, dir reassembly.TCPFlowDirection) {
+	b := frag.data
+	if len(b) < 2 {
+		return
+	}
+
+	var (
+		fc       = b[1]
+		response = fc == dnp3FuncResponse || fc == dnp3FuncUnsolicitedResponse || fc == dnp3FuncAuthenticateResponse
+		r        = &types.DNP3{
+			Timestamp:    utils.TimeToString(frag.ts),
+			Flow:         h.parent.ident,
+			SrcAddress:   int32(f.src),
+			DstAddress:   int32(f.dst),
+			FromMaster:   f.control&dnp3LinkDir != 0,
+			Sequence:     int32(b[0] & dnp3ApplicationSequenceMask),
+			FunctionCode: int32(fc),
+			Function:     dnp3Functions[fc],
+			Segments:     frag.segments,
+		}
+	)
+
+	if dir == reassembly.TCPDirClientToServer {
+		r.SrcIP, r.DstIP = h.parent.net.Src().String(), h.parent.net.Dst().String()
+	} else {
+		r.SrcIP, r.DstIP = h.parent.net.Dst().String(), h.parent.net.Src().String()
+	}
+
+	if r.Function == "" {
+		r.Function = "UNKNOWN(" + strconv.Itoa(int(fc)) + ")"
+	}
+
+	b = b[2:]
+
+	if response {
+		if len(b) < 2 {
+			return
+		}
+
+		iin := binary.LittleEndian.Uint16(b[:2])
+		for i, name := range dnp3InternalIndications {
+			if iin&(1<<uint(i)) != 0 {
+				r.IIN = append(r.IIN, name)
+			}
+		}
+
+		b = b[2:]
+	}
+
+	r.Objects, r.Controls = dnp3Objects(b, response || dnp3RequestHasData(fc))
+
+	if note, ok := dnp3CriticalFunctions[fc]; ok && !response {
+		r.Notes = note
+	}
+
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&dnp3Decoder.numRecords, 1)
+
+	err := dnp3Decoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// dnp3TestFrame builds a link layer frame with the CRCs for the header and the data blocks.
+func dnp3TestFrame(control byte, dst, src uint16, data []byte) []byte {
+	b := []byte{0x05, 0x64, byte(len(data) + dnp3MinLength), control, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(b[4:6], dst)
+	binary.LittleEndian.PutUint16(b[6:8], src)
+	b = append(b, 0, 0)
+	binary.LittleEndian.PutUint16(b[8:10], dnp3CRC(b[:8]))
+
+	for len(data) > 0 {
+		n := dnp3BlockSize
+		if len(data) < n {
+			n = len(data)
+		}
+
+		b = append(b, data[:n]...)
+		b = append(b, 0, 0)
+		binary.LittleEndian.PutUint16(b[len(b)-2:], dnp3CRC(data[:n]))
+		data = data[n:]
+	}
+
+	return b
+}
+
+func TestDNP3CRC(t *testing.T) {
+	if crc := dnp3CRC([]byte{0x05, 0x64, 0x05, 0xc0, 0x01, 0x00, 0x00, 0x04}); crc != 0x21e9 {
+		t.Fatalf("unexpected CRC: %04x", crc)
+	}
+}
+
+func TestDNP3Reader(t *testing.T) {
+	var (
+		records = &recordCollector{}
+		oldConf = conf
+
+		// master 1 and outstation 10
+		request = func(app ...byte) []byte {
+			return dnp3TestFrame(0xc4, 10, 1, append([]byte{0xc0}, app...))
+		}
+		response = []byte{
+			0xc0, 0x81, 0x80, 0x00,
+			// analog inputs 0 and 1
+			0x1e, 0x01, 0x00, 0x00, 0x01, 0x01, 0x64, 0x00, 0x00, 0x00, 0x01, 0xc8, 0x00, 0x00, 0x00,
+			// binary input 0
+			0x01, 0x02, 0x00, 0x00, 0x00, 0x81,
+		}
+		segmented = append(
+			dnp3TestFrame(0x44, 1, 10, append([]byte{0x40}, response[:12]...)),
+			dnp3TestFrame(0x44, 1, 10, append([]byte{0x81}, response[12:]...))...,
+		)
+	)
+
+	defer func() {
+		conf = oldConf
+	}()
+
+	conf = &Config{}
+	dnp3Decoder.writer = records
+
+	if !isDNP3(dnp3TestFrame(0x44, 1, 10, nil), newTestConnection(50000, 20001).transport) {
+		t.Fatal("failed to detect DNP3")
+	}
+
+	c := newTestConnection(50000, 20000,
+		// link status request and class 0 poll
+		append(dnp3TestFrame(0xc9, 10, 1, nil), request(0xc0, 0x01, 0x3c, 0x01, 0x06)...),
+		segmented[:15],
+		nil,
+		segmented[15:],
+		// direct operate, pulse on for point 3
+		append([]byte{0x00, 0x01}, request(0xc1, 0x05, 0x0c, 0x01, 0x28, 0x01, 0x00, 0x03, 0x00, 0x41, 0x01, 0xe8, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)...),
+		nil,
+		request(0xc2, 0x0d),
+	)
+
+	(&dnp3Reader{parent: c}).Decode()
+
+	if len(records.records) != 4 {
+		t.Fatal("expected 4 DNP3 records, got", len(records.records))
+	}
+
+	r := records.records[0].(*types.DNP3)
+	if r.Function != "READ" || !r.FromMaster || r.SrcAddress != 1 || r.DstAddress != 10 || strings.Join(r.Objects, ",") != "g60v1 all" || r.Notes != "" {
+		t.Fatal("unexpected read record:", r)
+	}
+
+	r = records.records[1].(*types.DNP3)
+	if r.Function != "RESPONSE" || r.FromMaster || r.SrcIP != "10.0.0.2" || r.Segments != 2 || r.Sequence != 0 {
+		t.Fatal("unexpected response record:", r)
+	}
+
+	if strings.Join(r.Objects, ",") != "g30v1 0-1,g1v2 0-0" || strings.Join(r.IIN, ",") != "DEVICE_RESTART" {
+		t.Fatal("unexpected response objects:", r.Objects, r.IIN)
+	}
+
+	r = records.records[2].(*types.DNP3)
+	if r.Function != "DIRECT_OPERATE" || r.Notes != "control operation" || r.Sequence != 1 {
+		t.Fatal("unexpected direct operate record:", r)
+	}
+
+	if strings.Join(r.Objects, ",") != "g12v1 count 1" || strings.Join(r.Controls, ",") != "index 3 PULSE_ON CLOSE on 1000ms" {
+		t.Fatal("unexpected controls:", r.Objects, r.Controls)
+	}
+
+	r = records.records[3].(*types.DNP3)
+	if r.Function != "COLD_RESTART" || r.Notes != "cold restart" || r.FunctionCode != 13 {
+		t.Fatal("unexpected cold restart record:", r)
+	}
+}
@@ -0,0 +1,323 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+const (
+	// records with a score below the threshold are not emitted
+	dnsAnomalyThreshold = 0.6
+
+	// maximum number of unique subdomains tracked per client and domain
+	dnsAnomalyMaxSubdomains = 10000
+
+	// number of subdomain samples attached to an audit record
+	dnsAnomalyNumSamples = 5
+
+	// labels shorter than this are not scored by the DGA model
+	dgaMinLabelLength = 6
+)
+
+// dnsBehavior collects the DNS activity of a single client towards a registered domain.
+type dnsBehavior struct {
+	firstSeen time.Time
+	lastSeen  time.Time
+
+	numQueries  int64
+	numNXDomain int64
+	numTXT      int64
+	numNULL     int64
+
+	maxLabelLength int
+	subdomains     map[string]struct{}
+
+	// sums over all unique subdomains
+	totalSubdomainLength  int
+	totalSubdomainEntropy float64
+}
+
+// atomicDNSBehaviorMap contains the DNS behavior for all clients and provides synchronized access.
+type atomicDNSBehaviorMap struct {
+	// mapped client IP + registered domain to behavior
+	Items map[string]*dnsBehavior
+	sync.Mutex
+}
+
+// Size returns the number of elements in the Items map.
+func (a *atomicDNSBehaviorMap) Size() int {
+	a.Lock()
+	defer a.Unlock()
+
+	return len(a.Items)
+}
+
+// dnsBehaviorStore holds the DNS behavior for all client and domain combinations.
+var dnsBehaviorStore = &atomicDNSBehaviorMap{
+	Items: make(map[string]*dnsBehavior),
+}
+
+// splitDomain returns the registered domain (eTLD+1) and the subdomain part of a name.
+// Names that should not be scored, such as reverse lookups and link local names, return an empty domain.
+func splitDomain(name string) (domain, subdomain string) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" || strings.HasSuffix(name, ".arpa") || strings.HasSuffix(name, ".local") {
+		return "", ""
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return "", ""
+	}
+
+	return domain, strings.TrimSuffix(strings.TrimSuffix(name, domain), ".")
+}
+
+// get returns the behavior for the client and domain, a new entry is created if necessary.
+// The caller must hold the lock.
+func (a *atomicDNSBehaviorMap) get(client, domain string, ts time.Time) *dnsBehavior {
+	key := client + "|" + domain
+
+	b, ok := a.Items[key]
+	if !ok {
+		b = &dnsBehavior{
+			firstSeen:  ts,
+			subdomains: make(map[string]struct{}),
+		}
+		a.Items[key] = b
+	}
+
+	if ts.After(b.lastSeen) {
+		b.lastSeen = ts
+	}
+
+	return b
+}
+
+// addQuery adds a DNS question from a client to the behavior profile.
+func (a *atomicDNSBehaviorMap) addQuery(client string, q *layers.DNSQuestion, ts time.Time) {
+	domain, sub := splitDomain(string(q.Name))
+	if domain == "" {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	b := a.get(client, domain, ts)
+	b.numQueries++
+
+	switch q.Type {
+	case layers.DNSTypeTXT:
+		b.numTXT++
+	case layers.DNSTypeNULL:
+		b.numNULL++
+	}
+
+	if sub == "" {
+		return
+	}
+
+	for _, l := range strings.Split(sub, ".") {
+		if len(l) > b.maxLabelLength {
+			b.maxLabelLength = len(l)
+		}
+	}
+
+	if _, ok := b.subdomains[sub]; ok || len(b.subdomains) >= dnsAnomalyMaxSubdomains {
+		return
+	}
+
+	b.subdomains[sub] = struct{}{}
+	b.totalSubdomainLength += len(sub)
+	b.totalSubdomainEntropy += entropy([]byte(strings.ReplaceAll(sub, ".", "")))
+}
+
+// addNXDomain adds a non existent domain response for the client to the behavior profile.
+func (a *atomicDNSBehaviorMap) addNXDomain(client string, q *layers.DNSQuestion, ts time.Time) {
+	domain, _ := splitDomain(string(q.Name))
+	if domain == "" {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.get(client, domain, ts).numNXDomain++
+}
+
+// scoreRange maps v from the range [low, high] to [0, 1].
+func scoreRange(v, low, high float64) float64 {
+	return clamp((v - low) / (high - low))
+}
+
+// toAuditRecord scores the behavior and creates an audit record with the collected evidence.
+func (b *dnsBehavior) toAuditRecord(client, domain string) *types.DNSAnomaly {
+	var (
+		unique = len(b.subdomains)
+		a      = &types.DNSAnomaly{
+			Timestamp:        utils.TimeToString(b.firstSeen),
+			LastSeen:         utils.TimeToString(b.lastSeen),
+			Client:           client,
+			Domain:           domain,
+			NumQueries:       b.numQueries,
+			NumNXDomain:      b.numNXDomain,
+			UniqueSubdomains: int64(unique),
+			MaxLabelLength:   int32(b.maxLabelLength),
+		}
+		notes []string
+	)
+
+	if unique > 0 {
+		a.AvgSubdomainLength = float64(b.totalSubdomainLength) / float64(unique)
+		a.SubdomainEntropy = b.totalSubdomainEntropy / float64(unique)
+	}
+
+	if b.numQueries > 0 {
+		a.TXTRatio = float64(b.numTXT) / float64(b.numQueries)
+		a.NULLRatio = float64(b.numNULL) / float64(b.numQueries)
+	}
+
+	// tunneling: data is encoded into long, high entropy and mostly unique subdomains
+	var (
+		entropyScore = scoreRange(a.SubdomainEntropy, 2.5, 4)
+		lengthScore  = scoreRange(float64(b.maxLabelLength), 20, 52)
+		uniqueScore  = scoreRange(float64(unique), 10, 200)
+		volumeScore  = scoreRange(math.Log10(float64(b.numQueries+1)), 1, 3)
+		typeScore    = clamp(a.TXTRatio + a.NULLRatio)
+	)
+
+	a.TunnelScore = 0.25*entropyScore + 0.2*lengthScore + 0.25*uniqueScore + 0.15*volumeScore + 0.15*typeScore
+
+	// DGA: the registered label looks random and frequently does not resolve
+	label := strings.SplitN(domain, ".", 2)[0]
+	if len(label) >= dgaMinLabelLength {
+		a.DGAScore = dgaModel.score(label)
+		if b.numNXDomain > 0 {
+			a.DGAScore = clamp(a.DGAScore + 0.2)
+		}
+	}
+
+	a.Score = math.Max(a.TunnelScore, a.DGAScore)
+
+	if entropyScore > 0.5 {
+		notes = append(notes, fmt.Sprintf("high subdomain entropy (%.2f)", a.SubdomainEntropy))
+	}
+
+	if lengthScore > 0.5 {
+		notes = append(notes, fmt.Sprintf("long labels (max %d)", b.maxLabelLength))
+	}
+
+	if uniqueScore > 0.5 {
+		notes = append(notes, fmt.Sprintf("many unique subdomains (%d)", unique))
+	}
+
+	if typeScore > 0.5 {
+		notes = append(notes, fmt.Sprintf("unusual record types (TXT %.2f, NULL %.2f)", a.TXTRatio, a.NULLRatio))
+	}
+
+	if a.DGAScore > dnsAnomalyThreshold {
+		notes = append(notes, fmt.Sprintf("random looking domain label (%.2f)", a.DGAScore))
+	}
+
+	if b.numNXDomain > 0 {
+		notes = append(notes, fmt.Sprintf("NXDOMAIN responses (%d)", b.numNXDomain))
+	}
+
+	a.Notes = strings.Join(notes, ", ")
+
+	// collect a deterministic set of samples, longest subdomains first
+	samples := make([]string, 0, unique)
+	for s := range b.subdomains {
+		samples = append(samples, s)
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		if len(samples[i]) == len(samples[j]) {
+			return samples[i] < samples[j]
+		}
+
+		return len(samples[i]) > len(samples[j])
+	})
+
+	if len(samples) > dnsAnomalyNumSamples {
+		samples = samples[:dnsAnomalyNumSamples]
+	}
+
+	a.Samples = samples
+
+	return a
+}
+
+var dnsAnomalyDecoder = newCustomDecoder(
+	types.Type_NC_DNSAnomaly,
+	"DNSAnomaly",
+	"DNSAnomaly scores the DNS behavior of clients towards registered domains to detect DNS tunneling and domain generation algorithms",
+	nil,
+	func(p gopacket.Packet) proto.Message {
+		l := p.Layer(layers.LayerTypeDNS)
+		if l == nil {
+			return nil
+		}
+
+		dns, ok := l.(*layers.DNS)
+		if !ok {
+			return nil
+		}
+
+		var (
+			i  = newPacketInfo(p)
+			ts = p.Metadata().Timestamp
+		)
+
+		for n := range dns.Questions {
+			if !dns.QR {
+				dnsBehaviorStore.addQuery(i.srcIP, &dns.Questions[n], ts)
+			} else if dns.ResponseCode == layers.DNSResponseCodeNXDomain {
+				dnsBehaviorStore.addNXDomain(i.dstIP, &dns.Questions[n], ts)
+			}
+		}
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		dnsBehaviorStore.Lock()
+		defer dnsBehaviorStore.Unlock()
+
+		for key, b := range dnsBehaviorStore.Items {
+			parts := strings.SplitN(key, "|", 2)
+
+			a := b.toAuditRecord(parts[0], parts[1])
+			if a.Score >= dnsAnomalyThreshold {
+				e.write(a)
+			}
+		}
+
+		return nil
+	},
+)
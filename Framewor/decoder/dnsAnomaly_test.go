@@ -0,0 +1,81 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/base32"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+func TestSplitDomain(t *testing.T) {
+	domain, sub := splitDomain("a.b.example.co.uk.")
+	if domain != "example.co.uk" || sub != "a.b" {
+		t.Fatal("unexpected result:", domain, sub)
+	}
+
+	domain, _ = splitDomain("1.0.168.192.in-addr.arpa")
+	if domain != "" {
+		t.Fatal("expected reverse lookups to be ignored, got:", domain)
+	}
+}
+
+func TestDGAScore(t *testing.T) {
+	for _, label := range []string{"facebook", "wikipedia", "weatherchannel", "musicstore", "netflix"} {
+		if s := dgaModel.score(label); s >= dnsAnomalyThreshold {
+			t.Fatal("expected low DGA score for", label, "got", s)
+		}
+	}
+
+	for _, label := range []string{"xjwqkpzlrmvb", "qx7v0zk2jw9t", "kvbzqgwxptfh"} {
+		if s := dgaModel.score(label); s < dnsAnomalyThreshold {
+			t.Fatal("expected high DGA score for", label, "got", s)
+		}
+	}
+}
+
+func TestDNSAnomalyTunnel(t *testing.T) {
+	var (
+		store = &atomicDNSBehaviorMap{Items: make(map[string]*dnsBehavior)}
+		ts    = time.Now()
+	)
+
+	for i := 0; i < 300; i++ {
+		data := base32.StdEncoding.EncodeToString([]byte("exfiltrated data chunk number " + strconv.Itoa(i)))
+		q := &layers.DNSQuestion{
+			Name: []byte(strings.ToLower(strings.TrimRight(data, "=")) + ".t.example.com"),
+			Type: layers.DNSTypeTXT,
+		}
+		store.addQuery("10.0.0.1", q, ts)
+		store.addQuery("10.0.0.2", &layers.DNSQuestion{Name: []byte("www.example.com"), Type: layers.DNSTypeA}, ts)
+	}
+
+	a := store.Items["10.0.0.1|example.com"].toAuditRecord("10.0.0.1", "example.com")
+	if a.TunnelScore < dnsAnomalyThreshold {
+		t.Fatal("expected high tunnel score, got", a.TunnelScore, a.Notes)
+	}
+
+	if a.UniqueSubdomains != 300 || a.TXTRatio != 1 || len(a.Samples) != dnsAnomalyNumSamples {
+		t.Fatal("unexpected evidence:", a.UniqueSubdomains, a.TXTRatio, a.Samples)
+	}
+
+	a = store.Items["10.0.0.2|example.com"].toAuditRecord("10.0.0.2", "example.com")
+	if a.Score >= dnsAnomalyThreshold {
+		t.Fatal("expected low score for regular client, got", a.Score, a.Notes)
+	}
+}
@@ -14,9 +14,17 @@
 package decoder
 
 import (
+	"encoding/hex"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"sync/atomic"
 
+	"github.com/dreadl0ck/cryptoutils"
 	"github.com/dreadl0ck/gopacket"
 	"github.com/gogo/protobuf/proto"
 
@@ -55,3 +63,72 @@ func writeFile(f *types.File) {
 		log.Fatal("failed to write proto: ", err)
 	}
 }
+
+// saveExtractedFile writes a file reconstructed from network traffic into the FileStorage
+// and emits the File audit record for it.
+// The caller provides the Timestamp, Name, Ident, Source and Context for the audit record,
+// content type, hash, length and location are set here.
+func saveExtractedFile(f *types.File, body []byte) error {
+	// prevent saving zero bytes
+	if len(body) == 0 || conf.FileStorage == "" || fileDecoderInstance == nil {
+		return nil
+	}
+
+	if f.Name == "" || f.Name == "/" {
+		f.Name = "unknown"
+	}
+
+	var (
+		// detected content type
+		cType = trimEncoding(http.DetectContentType(body))
+
+		// root path
+		root = path.Join(conf.FileStorage, cType)
+
+		// file extension
+		ext = fileExtensionForContentType(cType)
+
+		// file basename
+		base = filepath.Clean(path.Base(f.Name) + "-" + path.Base(f.Ident))
+	)
+
+	// make sure root path exists
+	createContentTypePathIfRequired(root)
+
+	if len(base) > 250 {
+		base = base[:250]
+	}
+
+	var (
+		target = path.Join(root, base+ext)
+		n      = 0
+	)
+
+	for {
+		_, errStat := os.Stat(target)
+		if errStat != nil {
+			break
+		}
+
+		target = path.Join(root, base+"-"+strconv.Itoa(n)+ext)
+		n++
+	}
+
+	err := ioutil.WriteFile(target, body, defaultFilesPermission)
+	if err != nil {
+		logReassemblyError("File-create", "Cannot create %s: %s\n", target, err)
+
+		return err
+	}
+
+	logReassemblyInfo("%s: Saved %s (l:%d)\n", f.Ident, target, len(body))
+
+	f.Length = int64(len(body))
+	f.Hash = hex.EncodeToString(cryptoutils.MD5Data(body))
+	f.Location = target
+	f.ContentType = cType
+
+	writeFile(f)
+
+	return nil
+}
@@ -86,18 +86,24 @@ func (fd *flowCustomDecoder) handlePacket(p gopacket.Packet) proto.Message {
 	var (
 		net       gopacket.Flow
 		transport gopacket.Flow
+		l         = innerLayers(p)
 	)
 
-	if nl := p.NetworkLayer(); nl != nil {
-		net = nl.NetworkFlow()
+	if l.network != nil {
+		net = l.network.NetworkFlow()
 	}
 
-	if tl := p.TransportLayer(); tl != nil {
-		transport = tl.TransportFlow()
+	if l.transport != nil {
+		transport = l.transport.TransportFlow()
 	}
 
 	flowID := fmt.Sprintf("%s:%s", net, transport)
 
+	// overlay networks can reuse the same inner addresses
+	if l.tunnel != nil {
+		flowID += fmt.Sprintf(":%s:%d", l.tunnel.proto, l.tunnel.id)
+	}
+
 	// lookup flow
 	fd.Flows.Lock()
 
@@ -124,22 +130,27 @@ func (fd *flowCustomDecoder) handlePacket(p gopacket.Packet) proto.Message {
 
 			// rewrite source and destination parameters
 			// since the first packet decides about the flow direction
-			if ll := p.LinkLayer(); ll != nil {
+			if ll := l.link; ll != nil {
 				f.SrcMAC = ll.LinkFlow().Src().String()
 				f.DstMAC = ll.LinkFlow().Dst().String()
 			}
 
-			if nl := p.NetworkLayer(); nl != nil {
+			if nl := l.network; nl != nil {
 				f.NetworkProto = nl.LayerType().String()
 				f.SrcIP = nl.NetworkFlow().Src().String()
 				f.DstIP = nl.NetworkFlow().Dst().String()
 			}
 
-			if tl := p.TransportLayer(); tl != nil {
+			if tl := l.transport; tl != nil {
 				f.TransportProto = tl.LayerType().String()
 				f.SrcPort = tl.TransportFlow().Src().String()
 				f.DstPort = tl.TransportFlow().Dst().String()
 			}
+
+			if t := l.tunnel; t != nil {
+				f.TunnelSrcIP = t.srcIP
+				f.TunnelDstIP = t.dstIP
+			}
 		}
 
 		// check if last timestamp was before the current packet
@@ -166,25 +177,31 @@ func (fd *flowCustomDecoder) handlePacket(p gopacket.Packet) proto.Message {
 		fl.TimestampFirst = utils.TimeToString(p.Metadata().Timestamp)
 		fl.TimestampLast = utils.TimeToString(p.Metadata().Timestamp)
 
-		if ll := p.LinkLayer(); ll != nil {
+		if ll := l.link; ll != nil {
 			fl.LinkProto = ll.LayerType().String()
 			fl.SrcMAC = ll.LinkFlow().Src().String()
 			fl.DstMAC = ll.LinkFlow().Dst().String()
 		}
-		if nl := p.NetworkLayer(); nl != nil {
+		if nl := l.network; nl != nil {
 			fl.NetworkProto = nl.LayerType().String()
 			fl.SrcIP = nl.NetworkFlow().Src().String()
 			fl.DstIP = nl.NetworkFlow().Dst().String()
 		}
-		if tl := p.TransportLayer(); tl != nil {
+		if tl := l.transport; tl != nil {
 			fl.TransportProto = tl.LayerType().String()
 			fl.SrcPort = tl.TransportFlow().Src().String()
 			fl.DstPort = tl.TransportFlow().Dst().String()
 		}
-		if al := p.ApplicationLayer(); al != nil {
+		if al := l.application; al != nil {
 			fl.ApplicationProto = al.LayerType().String()
 			fl.AppPayloadSize = int32(len(al.Payload()))
 		}
+		if t := l.tunnel; t != nil {
+			fl.TunnelProto = t.proto
+			fl.TunnelSrcIP = t.srcIP
+			fl.TunnelDstIP = t.dstIP
+			fl.TunnelID = t.id
+		}
 		fd.Flows.Items[flowID] = &flow{
 			Flow: fl,
 		}
@@ -0,0 +1,609 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * FTP - File Transfer Protocol
+ */
+
+const (
+	ftpModeActive  = "active"
+	ftpModePassive = "passive"
+
+	ftpDirectionUpload   = "upload"
+	ftpDirectionDownload = "download"
+
+	// control connection fragments larger than this are not inspected for data channel announcements
+	ftpMaxControlFragment = 4096
+)
+
+var reFTPHostPort = regexp.MustCompile(`(\d{1,3}),(\d{1,3}),(\d{1,3}),(\d{1,3}),(\d{1,3}),(\d{1,3})`)
+
+// ftpTransferCommands maps the commands that open a data connection to the direction of the transfer.
+var ftpTransferCommands = map[string]string{
+	"RETR": ftpDirectionDownload,
+	"LIST": ftpDirectionDownload,
+	"NLST": ftpDirectionDownload,
+	"MLSD": ftpDirectionDownload,
+	"STOR": ftpDirectionUpload,
+	"STOU": ftpDirectionUpload,
+	"APPE": ftpDirectionUpload,
+}
+
+// isFTP checks if the server greeting looks like a FTP service.
+func isFTP(banner []byte, transport gopacket.Flow) bool {
+	if !bytes.HasPrefix(banner, []byte("220")) {
+		return false
+	}
+
+	return transport.Dst().String() == "21" || bytes.Contains(bytes.ToUpper(banner), []byte(serviceFTP))
+}
+
+// ftpOutcome returns a description for the reply code class.
+func ftpOutcome(code int) string {
+	switch code / 100 {
+	case 1:
+		return "preliminary"
+	case 2:
+		return "success"
+	case 3:
+		return "intermediate"
+	case 4:
+		return "transient failure"
+	case 5:
+		return "failure"
+	default:
+		return ""
+	}
+}
+
+// parseFTPHostPort parses the h1,h2,h3,h4,p1,p2 notation used by PORT and PASV.
+func parseFTPHostPort(s string) (string, bool) {
+	m := reFTPHostPort.FindStringSubmatch(s)
+	if len(m) != 7 {
+		return "", false
+	}
+
+	var v [6]int
+	for i := range v {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n > 255 {
+			return "", false
+		}
+
+		v[i] = n
+	}
+
+	return net.JoinHostPort(
+		strconv.Itoa(v[0])+"."+strconv.Itoa(v[1])+"."+strconv.Itoa(v[2])+"."+strconv.Itoa(v[3]),
+		strconv.Itoa(v[4]<<8|v[5]),
+	), true
+}
+
+// parseFTPExtended parses the |proto|address|port| notation used by EPRT and the EPSV reply.
+// The delimiter is the first character of the argument, for EPSV the address is empty
+// and the host is used instead.
+func parseFTPExtended(s, host string) (string, bool) {
+	if len(s) < 5 {
+		return "", false
+	}
+
+	parts := strings.Split(s, s[:1])
+	if len(parts) < 5 {
+		return "", false
+	}
+
+	port, err := strconv.Atoi(parts[3])
+	if err != nil || port <= 0 || port > 65535 {
+		return "", false
+	}
+
+	if parts[2] != "" {
+		host = parts[2]
+	}
+
+	if host == "" {
+		return "", false
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port)), true
+}
+
+// parseFTPEPSV extracts the data endpoint from a 229 reply.
+func parseFTPEPSV(msg, host string) (string, bool) {
+	start := strings.Index(msg, "(")
+	end := strings.LastIndex(msg, ")")
+
+	if start == -1 || end <= start {
+		return "", false
+	}
+
+	return parseFTPExtended(msg[start+1:end], host)
+}
+
+// ftpTransfer is a file transfer requested on the control connection.
+type ftpTransfer struct {
+	command   string
+	name      string
+	server    string
+	timestamp time.Time
+}
+
+// ftpPayload is the content received on a data connection.
+type ftpPayload struct {
+	data      []byte
+	ident     string
+	net       gopacket.Flow
+	transport gopacket.Flow
+	timestamp time.Time
+}
+
+// ftpDataStore correlates data connections with the transfers negotiated on the control connections.
+// Data connections are usually closed before the control connection,
+// so the payload is kept until the transfer command is decoded and vice versa.
+type ftpDataStore struct {
+	sync.Mutex
+
+	// data endpoints announced via PORT, EPRT, PASV or EPSV
+	endpoints map[string]struct{}
+
+	// data connections waiting for their transfer command, mapped by endpoint
+	payloads map[string]*ftpPayload
+
+	// transfer commands waiting for their data connection, mapped by endpoint
+	transfers map[string]*ftpTransfer
+}
+
+var ftpData = newFTPDataStore()
+
+func newFTPDataStore() *ftpDataStore {
+	return &ftpDataStore{
+		endpoints: make(map[string]struct{}),
+		payloads:  make(map[string]*ftpPayload),
+		transfers: make(map[string]*ftpTransfer),
+	}
+}
+
+// watch inspects control connection data while it is being reassembled
+// and registers announced data endpoints, so that data connections can be identified when they complete.
+// The sender is the address of the host that sent the data, the receiver is the other control peer.
+// Announced endpoints must belong to one of the control peers, others are ignored.
+func (s *ftpDataStore) watch(sender, receiver string, data []byte) {
+	if len(data) > ftpMaxControlFragment {
+		return
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var (
+			l        = strings.TrimSpace(string(line))
+			endpoint string
+			ok       bool
+		)
+
+		if len(l) < 4 {
+			continue
+		}
+
+		switch strings.ToUpper(l[:4]) {
+		case "PORT":
+			endpoint, ok = parseFTPHostPort(l[4:])
+		case "EPRT":
+			endpoint, ok = parseFTPExtended(strings.TrimSpace(l[4:]), sender)
+		case "227 ":
+			endpoint, ok = parseFTPHostPort(l[4:])
+		case "229 ":
+			endpoint, ok = parseFTPEPSV(l[4:], sender)
+		}
+
+		if ok && isFTPPeer(endpoint, sender, receiver) {
+			s.Lock()
+			s.endpoints[endpoint] = struct{}{}
+			s.Unlock()
+		}
+	}
+}
+
+// isFTPPeer checks whether the host of the endpoint is one of the control connection peers.
+func isFTPPeer(endpoint string, peers ...string) bool {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, p := range peers {
+		if ip.Equal(net.ParseIP(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// claimEndpoint checks whether the endpoint has been announced as a data channel.
+// A matching endpoint is removed from the store, since every data connection carries a single transfer.
+func (s *ftpDataStore) claimEndpoint(endpoint string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.endpoints[endpoint]; ok {
+		delete(s.endpoints, endpoint)
+
+		return true
+	}
+
+	return false
+}
+
+// addPayload stores the data of a finished data connection, or saves it if the transfer is already known.
+func (s *ftpDataStore) addPayload(endpoint string, p *ftpPayload) {
+	s.Lock()
+	t, ok := s.transfers[endpoint]
+	if ok {
+		delete(s.transfers, endpoint)
+	} else {
+		s.payloads[endpoint] = p
+	}
+	s.Unlock()
+
+	if ok {
+		saveFTPTransfer(t, p)
+	}
+}
+
+// addTransfer associates the transfer with the data received on the endpoint.
+// If the data connection has not finished yet, the transfer is stored
+// and the file will be saved once the data connection is complete.
+// Returns the number of bytes transferred and whether the data connection was seen.
+func (s *ftpDataStore) addTransfer(endpoint string, t *ftpTransfer) (int64, bool) {
+	s.Lock()
+	p, ok := s.payloads[endpoint]
+	if ok {
+		delete(s.payloads, endpoint)
+	} else {
+		s.transfers[endpoint] = t
+	}
+	s.Unlock()
+
+	if !ok {
+		return 0, false
+	}
+
+	saveFTPTransfer(t, p)
+
+	return int64(len(p.data)), true
+}
+
+// flush saves the data connections that were not associated with a transfer command and resets the store.
+func (s *ftpDataStore) flush() {
+	s.Lock()
+	payloads := s.payloads
+	s.payloads = make(map[string]*ftpPayload)
+	s.transfers = make(map[string]*ftpTransfer)
+	s.endpoints = make(map[string]struct{})
+	s.Unlock()
+
+	for endpoint, p := range payloads {
+		saveFTPTransfer(&ftpTransfer{
+			command:   "DATA",
+			name:      "ftp-data",
+			server:    endpoint,
+			timestamp: p.timestamp,
+		}, p)
+	}
+}
+
+// saveFTPTransfer writes the data of a file transfer to disk.
+func saveFTPTransfer(t *ftpTransfer, p *ftpPayload) {
+	// directory listings are not extracted
+	switch t.command {
+	case "LIST", "NLST", "MLSD":
+		return
+	}
+
+	err := saveExtractedFile(&types.File{
+		Timestamp: utils.TimeToString(t.timestamp),
+		Name:      t.name,
+		Ident:     p.ident,
+		Source:    serviceFTP + " " + t.command + " " + t.name,
+		Host:      t.server,
+		Context: &types.PacketContext{
+			SrcIP:   p.net.Src().String(),
+			DstIP:   p.net.Dst().String(),
+			SrcPort: p.transport.Src().String(),
+			DstPort: p.transport.Dst().String(),
+		},
+	}, p.data)
+	if err != nil {
+		logReassemblyError("FTP-save", "%s: failed to save %s: %s\n", p.ident, t.name, err)
+	}
+}
+
+// ftpDataEndpoint returns the address of the side that accepted the connection.
+// In passive mode this is the server, in active mode the client.
+func ftpDataEndpoint(t *tcpConnection) string {
+	return net.JoinHostPort(t.net.Dst().String(), t.transport.Dst().String())
+}
+
+// ftpDataReader collects the payload of a FTP data connection.
+type ftpDataReader struct {
+	parent *tcpConnection
+}
+
+// Decode collects the data transferred on the connection and hands it to the data store.
+func (h *ftpDataReader) Decode() {
+	var buf bytes.Buffer
+
+	// data flows only in one direction, depending on the transfer command
+	for _, d := range h.parent.merged {
+		buf.Write(d.raw)
+	}
+
+	ftpData.addPayload(ftpDataEndpoint(h.parent), &ftpPayload{
+		data:      buf.Bytes(),
+		ident:     h.parent.ident,
+		net:       h.parent.net,
+		transport: h.parent.transport,
+		timestamp: h.parent.firstPacket,
+	})
+}
+
+type ftpCommand struct {
+	record      *types.FTP
+	ts          time.Time
+	preliminary bool
+}
+
+type ftpReader struct {
+	parent *tcpConnection
+
+	user       string
+	banner     string
+	renameFrom string
+	encrypted  bool
+
+	// data channel negotiated by the last PORT, EPRT, PASV or EPSV command
+	dataMode     string
+	dataEndpoint string
+
+	pending []*ftpCommand
+	replies replyReader
+}
+
+// Decode parses the stream according to the FTP protocol.
+func (h *ftpReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	// the merged fragments are ordered by time, so commands are processed before their replies
+	for _, d := range h.parent.merged {
+		if h.encrypted {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readCommands(&clientBuf, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.replies.read(&serverBuf, h.handleReply)
+		}
+	}
+
+	// write commands that did not receive a reply
+	for _, c := range h.pending {
+		c.record.Outcome = "no response"
+		h.write(c.record)
+	}
+
+	h.pending = nil
+}
+
+func (h *ftpReader) readCommands(buf *bytes.Buffer, ts time.Time) {
+	for {
+		line, ok := readCRLFLine(buf)
+		if !ok {
+			return
+		}
+
+		// strip telnet control sequences that precede ABOR
+		line = strings.TrimLeftFunc(line, func(r rune) bool {
+			return r < 0x20 || r >= 0x7f
+		})
+
+		if line == "" {
+			continue
+		}
+
+		var (
+			parts = strings.SplitN(line, " ", 2)
+			cmd   = strings.ToUpper(parts[0])
+			arg   string
+		)
+
+		if len(parts) == 2 {
+			arg = strings.TrimSpace(parts[1])
+		}
+
+		if cmd == "USER" {
+			h.user = arg
+		}
+
+		h.pending = append(h.pending, &ftpCommand{
+			record: &types.FTP{
+				Timestamp: utils.TimeToString(ts),
+				ClientIP:  h.parent.net.Src().String(),
+				ServerIP:  h.parent.net.Dst().String(),
+				Flow:      h.parent.ident,
+				User:      h.user,
+				Command:   cmd,
+				Argument:  arg,
+			},
+			ts: ts,
+		})
+	}
+}
+
+func (h *ftpReader) handleReply(code int, lines []string) {
+	msg := strings.Join(lines, " ")
+
+	if len(h.pending) == 0 {
+		// service greeting
+		if code == 220 && h.banner == "" {
+			h.banner = msg
+		}
+
+		return
+	}
+
+	c := h.pending[0]
+
+	// preliminary replies are followed by the final reply for the same command
+	if code < 200 {
+		c.preliminary = true
+		c.record.Notes = addInfo(c.record.Notes, msg)
+
+		return
+	}
+
+	h.pending = h.pending[1:]
+
+	r := c.record
+	r.ReplyCode = int32(code)
+	r.ReplyMessage = msg
+	r.Outcome = ftpOutcome(code)
+
+	success := code >= 200 && code < 300
+
+	switch r.Command {
+	case "PORT":
+		if endpoint, ok := parseFTPHostPort(r.Argument); ok && success {
+			h.dataMode, h.dataEndpoint = ftpModeActive, endpoint
+		}
+	case "EPRT":
+		if endpoint, ok := parseFTPExtended(r.Argument, r.ClientIP); ok && success {
+			h.dataMode, h.dataEndpoint = ftpModeActive, endpoint
+		}
+	case "PASV":
+		if endpoint, ok := parseFTPHostPort(msg); ok && code == 227 {
+			h.dataMode, h.dataEndpoint = ftpModePassive, endpoint
+		}
+	case "EPSV":
+		if endpoint, ok := parseFTPEPSV(msg, r.ServerIP); ok && code == 229 {
+			h.dataMode, h.dataEndpoint = ftpModePassive, endpoint
+		}
+	case "RNFR":
+		h.renameFrom = r.Argument
+	case "RNTO":
+		if h.renameFrom != "" {
+			r.Notes = addInfo(r.Notes, "renamed from "+h.renameFrom)
+			h.renameFrom = ""
+		}
+	case "AUTH":
+		if code == 234 {
+			r.Notes = addInfo(r.Notes, "control connection encrypted")
+
+			// the remaining conversation is encrypted
+			h.encrypted = true
+		}
+	}
+
+	switch r.Command {
+	case "PORT", "EPRT", "PASV", "EPSV":
+		if success {
+			r.DataMode = h.dataMode
+			r.DataEndpoint = h.dataEndpoint
+		}
+	}
+
+	if direction, ok := ftpTransferCommands[r.Command]; ok {
+		h.transfer(c, direction, success)
+	}
+
+	h.write(r)
+}
+
+// transfer associates a transfer command with its data connection.
+func (h *ftpReader) transfer(c *ftpCommand, direction string, success bool) {
+	r := c.record
+	r.Direction = direction
+	r.Filename = r.Argument
+	r.DataMode = h.dataMode
+	r.DataEndpoint = h.dataEndpoint
+
+	if h.dataEndpoint == "" {
+		if success {
+			r.Notes = addInfo(r.Notes, "data channel not negotiated")
+		}
+
+		return
+	}
+
+	// every data connection carries a single transfer
+	endpoint := h.dataEndpoint
+	h.dataMode, h.dataEndpoint = "", ""
+
+	// the data connection is only opened if the server accepted the command
+	if !c.preliminary && !success {
+		return
+	}
+
+	length, ok := ftpData.addTransfer(endpoint, &ftpTransfer{
+		command:   r.Command,
+		name:      r.Filename,
+		server:    r.ServerIP,
+		timestamp: c.ts,
+	})
+	if ok {
+		r.Length = length
+	}
+}
+
+func (h *ftpReader) write(r *types.FTP) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&ftpDecoder.numRecords, 1)
+
+	err := ftpDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func TestFTPReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ftpRecords  = &recordCollector{}
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+		oldData     = ftpData
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+		ftpData = oldData
+	}()
+
+	conf = &Config{FileStorage: dir}
+	ftpDecoder.writer = ftpRecords
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+	ftpData = newFTPDataStore()
+
+	// the announcements are registered while the control connection is reassembled
+	ftpData.watch("10.0.0.2", "10.0.0.1", []byte("229 Entering Extended Passive Mode (|||40001|)\r\n"))
+	ftpData.watch("10.0.0.2", "10.0.0.1", []byte("227 Entering Passive Mode (10,0,0,2,156,66).\r\n"))
+
+	// the data connection is closed before the control connection
+	data := newTestConnection(50001, 40001, []byte{}, []byte("hello world"))
+	if !ftpData.claimEndpoint(ftpDataEndpoint(data)) {
+		t.Fatal("data connection not identified")
+	}
+
+	(&ftpDataReader{parent: data}).Decode()
+
+	if ftpData.claimEndpoint(ftpDataEndpoint(data)) {
+		t.Fatal("data endpoint claimed twice")
+	}
+
+	control := newTestConnection(50000, 21,
+		[]byte{},
+		[]byte("220 (vsFTPd 3.0.3)\r\n"),
+		[]byte("USER alice\r\n"),
+		[]byte("331 Please specify the password.\r\n"),
+		[]byte("PASS secret\r\n"),
+		[]byte("230 Login successful.\r\n"),
+		[]byte("EPSV\r\n"),
+		[]byte("229 Entering Extended Passive Mode (|||40001|)\r\n"),
+		[]byte("RETR /pub/report.txt\r\n"),
+		[]byte("150 Opening BINARY mode data connection for /pub/report.txt (11 bytes).\r\n226 Transfer complete.\r\n"),
+		[]byte("PASV\r\n"),
+		[]byte("227 Entering Passive Mode (10,0,0,2,156,66).\r\n"),
+		[]byte("STOR upload.bin\r\n"),
+		[]byte("553 Could not create file.\r\n"),
+		[]byte("QUIT\r\n"),
+		[]byte("221-Goodbye.\r\n221 See you\r\n"),
+	)
+
+	(&ftpReader{parent: control}).Decode()
+
+	if len(ftpRecords.records) != 7 {
+		t.Fatal("expected 7 FTP records, got", len(ftpRecords.records))
+	}
+
+	epsv := ftpRecords.records[2].(*types.FTP)
+	if epsv.DataMode != ftpModePassive || epsv.DataEndpoint != "10.0.0.2:40001" {
+		t.Fatal("unexpected data channel:", epsv.DataMode, epsv.DataEndpoint)
+	}
+
+	retr := ftpRecords.records[3].(*types.FTP)
+	if retr.User != "alice" || retr.Filename != "/pub/report.txt" || retr.Direction != ftpDirectionDownload {
+		t.Fatal("unexpected transfer:", retr)
+	}
+
+	if retr.ReplyCode != 226 || retr.Outcome != "success" || retr.Length != 11 {
+		t.Fatal("unexpected transfer outcome:", retr.ReplyCode, retr.Outcome, retr.Length)
+	}
+
+	pasv := ftpRecords.records[4].(*types.FTP)
+	if pasv.DataEndpoint != "10.0.0.2:40002" {
+		t.Fatal("unexpected data endpoint:", pasv.DataEndpoint)
+	}
+
+	stor := ftpRecords.records[5].(*types.FTP)
+	if stor.Direction != ftpDirectionUpload || stor.Outcome != "failure" || stor.Length != 0 {
+		t.Fatal("unexpected upload outcome:", stor.Direction, stor.Outcome, stor.Length)
+	}
+
+	quit := ftpRecords.records[6].(*types.FTP)
+	if quit.ReplyCode != 221 || quit.ReplyMessage != "Goodbye. See you" {
+		t.Fatal("unexpected multi line reply:", quit.ReplyCode, quit.ReplyMessage)
+	}
+
+	if len(fileRecords.records) != 1 {
+		t.Fatal("expected 1 file record, got", len(fileRecords.records))
+	}
+
+	f := fileRecords.records[0].(*types.File)
+	if f.Name != "/pub/report.txt" {
+		t.Fatal("unexpected file name:", f.Name)
+	}
+
+	content, err := ioutil.ReadFile(f.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "hello world" {
+		t.Fatal("unexpected file content:", string(content))
+	}
+}
+
+func TestFTPDataEndpoints(t *testing.T) {
+	tests := []struct {
+		in       string
+		sender   string
+		receiver string
+		endpoint string
+		ok       bool
+	}{
+		{in: "PORT 192,168,1,5,4,1", sender: "192.168.1.5", receiver: "10.0.0.2", endpoint: "192.168.1.5:1025", ok: true},
+		{in: "EPRT |1|192.168.1.5|6275|", sender: "192.168.1.5", receiver: "10.0.0.2", endpoint: "192.168.1.5:6275", ok: true},
+		{in: "EPRT |2|::1|6275|", sender: "::1", receiver: "::2", endpoint: "[::1]:6275", ok: true},
+		{in: "229 Entering Extended Passive Mode (|||6446|)", sender: "10.0.0.2", receiver: "192.168.1.5", endpoint: "10.0.0.2:6446", ok: true},
+		{in: "227 Entering Passive Mode (192,168,1,5,4,1).", sender: "10.0.0.2", receiver: "192.168.1.5", endpoint: "192.168.1.5:1025", ok: true},
+		{in: "PORT 192,168,1,300,4,1", sender: "192.168.1.5", receiver: "10.0.0.2", ok: false},
+		{in: "229 Entering Extended Passive Mode", sender: "10.0.0.2", receiver: "192.168.1.5", ok: false},
+		// endpoints of hosts outside the control connection are ignored
+		{in: "PORT 172,16,0,9,4,1", sender: "192.168.1.5", receiver: "10.0.0.2", endpoint: "172.16.0.9:1025", ok: false},
+		{in: "227 Entering Passive Mode (172,16,0,9,4,1).", sender: "10.0.0.2", receiver: "192.168.1.5", endpoint: "172.16.0.9:1025", ok: false},
+	}
+
+	for _, test := range tests {
+		store := newFTPDataStore()
+		store.watch(test.sender, test.receiver, []byte(test.in+"\r\n"))
+
+		if ok := store.claimEndpoint(test.endpoint); ok != test.ok {
+			t.Fatal(test.in, "expected", test.ok, "got", ok)
+		}
+
+		if !test.ok && len(store.endpoints) != 0 {
+			t.Fatal(test.in, "unexpected endpoints:", store.endpoints)
+		}
+	}
+}
+
+func TestFTPControlConnection(t *testing.T) {
+	control := newTestConnection(50000, 2121)
+	if !control.isFTPControl(reassembly.TCPDirServerToClient, []byte("220 ProFTPD Server (FTP)\r\n")) {
+		t.Fatal("FTP banner not identified")
+	}
+
+	other := newTestConnection(50000, 8080)
+	if other.isFTPControl(reassembly.TCPDirServerToClient, []byte("HTTP/1.1 200 OK\r\n")) {
+		t.Fatal("non FTP connection identified as control connection")
+	}
+
+	// the decision is kept for the lifetime of the connection
+	if other.isFTPControl(reassembly.TCPDirServerToClient, []byte("220 FTP\r\n")) {
+		t.Fatal("decision changed after the first server data")
+	}
+
+	if !newTestConnection(50000, 21).isFTPControl(reassembly.TCPDirClientToServer, []byte("USER alice\r\n")) {
+		t.Fatal("connection to port 21 not identified")
+	}
+}
@@ -0,0 +1,584 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * GTP - GPRS Tunnelling Protocol
+ */
+
+const (
+	serviceGTPC = "GTPC"
+
+	gtpcPort = 2123
+
+	// message type of GTPv1-U packets carrying user traffic
+	gtpMessageGPDU = 255
+
+	gtpv2HeaderLen     = 8
+	gtpv2HeaderLenTEID = 12
+	gtpv2IEHeaderLen   = 4
+
+	// session related message types
+	gtpv2CreateSessionRequest  = 32
+	gtpv2CreateSessionResponse = 33
+	gtpv2ModifyBearerRequest   = 34
+	gtpv2ModifyBearerResponse  = 35
+	gtpv2DeleteSessionRequest  = 36
+	gtpv2DeleteSessionResponse = 37
+
+	// information element types
+	gtpv2IEIMSI          = 1
+	gtpv2IECause         = 2
+	gtpv2IEAPN           = 71
+	gtpv2IEEBI           = 73
+	gtpv2IEMEI           = 75
+	gtpv2IEMSISDN        = 76
+	gtpv2IEPAA           = 79
+	gtpv2IERATType       = 82
+	gtpv2IEFTEID         = 87
+	gtpv2IEBearerContext = 93
+
+	// PDN types of the PDN address allocation
+	gtpv2PDNIPv4   = 1
+	gtpv2PDNIPv6   = 2
+	gtpv2PDNIPv4v6 = 3
+
+	gtpv2CauseRequestAccepted          = 16
+	gtpv2CauseRequestAcceptedPartially = 17
+)
+
+var (
+	gtpv2Messages = map[byte]string{
+		gtpv2CreateSessionRequest:  "Create Session Request",
+		gtpv2CreateSessionResponse: "Create Session Response",
+		gtpv2ModifyBearerRequest:   "Modify Bearer Request",
+		gtpv2ModifyBearerResponse:  "Modify Bearer Response",
+		gtpv2DeleteSessionRequest:  "Delete Session Request",
+		gtpv2DeleteSessionResponse: "Delete Session Response",
+	}
+
+	gtpv2Causes = map[byte]string{
+		gtpv2CauseRequestAccepted:          "Request accepted",
+		gtpv2CauseRequestAcceptedPartially: "Request accepted partially",
+		64:                                 "Context Not Found",
+		65:                                 "Invalid Message Format",
+		72:                                 "System failure",
+		73:                                 "No resources available",
+		78:                                 "Missing or unknown APN",
+		84:                                 "All dynamic addresses are occupied",
+		92:                                 "User authentication failed",
+		94:                                 "Request rejected",
+	}
+
+	gtpv2RATTypes = map[byte]string{
+		1:  "UTRAN",
+		2:  "GERAN",
+		3:  "WLAN",
+		4:  "GAN",
+		5:  "HSPA Evolution",
+		6:  "EUTRAN",
+		7:  "Virtual",
+		8:  "EUTRAN-NB-IoT",
+		10: "NR",
+	}
+
+	gtpv2Interfaces = map[byte]string{
+		0:  "S1-U eNodeB",
+		1:  "S1-U SGW",
+		2:  "S12 RNC",
+		3:  "S12 SGW",
+		4:  "S5/S8 SGW GTP-U",
+		5:  "S5/S8 PGW GTP-U",
+		6:  "S5/S8 SGW GTP-C",
+		7:  "S5/S8 PGW GTP-C",
+		10: "S11 MME",
+		11: "S11/S4 SGW",
+	}
+
+	errGTPv2InvalidPacket = errors.New("invalid GTPv2-C packet")
+)
+
+// isGTPv2Request checks if the message type is a request of the session procedures.
+func isGTPv2Request(typ byte) bool {
+	switch typ {
+	case gtpv2CreateSessionRequest, gtpv2ModifyBearerRequest, gtpv2DeleteSessionRequest:
+		return true
+	}
+
+	return false
+}
+
+// gtpTBCD decodes telephony binary coded decimal digits, as used for the IMSI, MSISDN and MEI.
+func gtpTBCD(b []byte) string {
+	var sb strings.Builder
+
+	for _, c := range b {
+		for _, d := range []byte{c & 0x0f, c >> 4} {
+			// filler for an odd number of digits
+			if d > 9 {
+				return sb.String()
+			}
+
+			sb.WriteByte('0' + d)
+		}
+	}
+
+	return sb.String()
+}
+
+// gtpAPN decodes the length prefixed labels of an access point name.
+func gtpAPN(b []byte) string {
+	var labels []string
+
+	for len(b) > 0 {
+		n := int(b[0])
+		if n+1 > len(b) {
+			break
+		}
+
+		labels = append(labels, string(b[1:n+1]))
+		b = b[n+1:]
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// gtpFTEID is a fully qualified tunnel endpoint identifier.
+type gtpFTEID struct {
+	iface   byte
+	teid    uint32
+	address string
+}
+
+// parseGTPFTEID decodes a F-TEID information element.
+func parseGTPFTEID(b []byte) (*gtpFTEID, bool) {
+	if len(b) < 5 {
+		return nil, false
+	}
+
+	var (
+		f = &gtpFTEID{
+			iface: b[0] & 0x3f,
+			teid:  binary.BigEndian.Uint32(b[1:5]),
+		}
+		addrs []string
+		rest  = b[5:]
+	)
+
+	if b[0]&0x80 != 0 && len(rest) >= net.IPv4len {
+		addrs = append(addrs, net.IP(rest[:net.IPv4len]).String())
+		rest = rest[net.IPv4len:]
+	}
+
+	if b[0]&0x40 != 0 && len(rest) >= net.IPv6len {
+		addrs = append(addrs, net.IP(rest[:net.IPv6len]).String())
+	}
+
+	f.address = strings.Join(addrs, "/")
+
+	return f, true
+}
+
+func (f *gtpFTEID) String() string {
+	iface, ok := gtpv2Interfaces[f.iface]
+	if !ok {
+		iface = strconv.Itoa(int(f.iface))
+	}
+
+	return iface + ":" + strconv.FormatUint(uint64(f.teid), 10) + "@" + f.address
+}
+
+// gtpPAA decodes the PDN address allocation into the addresses assigned to the user equipment.
+func gtpPAA(b []byte) string {
+	if len(b) < 1 {
+		return ""
+	}
+
+	var (
+		addrs []string
+		rest  = b[1:]
+	)
+
+	switch b[0] & 0x07 {
+	case gtpv2PDNIPv4:
+		if len(rest) >= net.IPv4len {
+			addrs = append(addrs, net.IP(rest[:net.IPv4len]).String())
+		}
+	case gtpv2PDNIPv6, gtpv2PDNIPv4v6:
+		// the prefix length is followed by the IPv6 address
+		if len(rest) >= 1+net.IPv6len {
+			addrs = append(addrs, net.IP(rest[1:1+net.IPv6len]).String())
+			rest = rest[1+net.IPv6len:]
+		}
+
+		if b[0]&0x07 == gtpv2PDNIPv4v6 && len(rest) >= net.IPv4len {
+			addrs = append(addrs, net.IP(rest[:net.IPv4len]).String())
+		}
+	}
+
+	return strings.Join(addrs, "/")
+}
+
+type gtpv2Packet struct {
+	typ  byte
+	teid uint32
+	seq  uint32
+
+	// sender control plane F-TEID
+	sender *gtpFTEID
+
+	// user equipment addresses
+	ueIPs []string
+
+	// cause value, zero if not present
+	cause byte
+
+	record *types.GTPC
+}
+
+// parseGTPv2 decodes the header and the information elements of a GTPv2-C session message.
+// Messages that do not belong to the session procedures are ignored by returning nil.
+func parseGTPv2(data []byte) (*gtpv2Packet, error) {
+	if len(data) < gtpv2HeaderLen || data[0]>>5 != 2 {
+		return nil, errGTPv2InvalidPacket
+	}
+
+	name, ok := gtpv2Messages[data[1]]
+	if !ok {
+		return nil, nil
+	}
+
+	var (
+		length    = int(binary.BigEndian.Uint16(data[2:4])) + 4
+		headerLen = gtpv2HeaderLen
+		pkt       = &gtpv2Packet{
+			typ:    data[1],
+			record: &types.GTPC{Message: name},
+		}
+	)
+
+	// TEID flag
+	if data[0]&0x08 != 0 {
+		headerLen = gtpv2HeaderLenTEID
+	}
+
+	if length < headerLen || length > len(data) {
+		return nil, errGTPv2InvalidPacket
+	}
+
+	if headerLen == gtpv2HeaderLenTEID {
+		pkt.teid = binary.BigEndian.Uint32(data[4:8])
+	}
+
+	// 24 bit sequence number followed by a spare byte
+	pkt.seq = binary.BigEndian.Uint32(data[headerLen-4:headerLen]) >> 8
+	pkt.record.Sequence = pkt.seq
+
+	return pkt, pkt.parseIEs(data[headerLen:length], "")
+}
+
+// parseIEs decodes the information elements, ebi is set for the contents of bearer contexts.
+func (pkt *gtpv2Packet) parseIEs(ies []byte, ebi string) error {
+	r := pkt.record
+
+	for len(ies) >= gtpv2IEHeaderLen {
+		var (
+			typ      = ies[0]
+			size     = int(binary.BigEndian.Uint16(ies[1:3]))
+			instance = ies[3] & 0x0f
+		)
+
+		if gtpv2IEHeaderLen+size > len(ies) {
+			return errGTPv2InvalidPacket
+		}
+
+		value := ies[gtpv2IEHeaderLen : gtpv2IEHeaderLen+size]
+		ies = ies[gtpv2IEHeaderLen+size:]
+
+		switch typ {
+		case gtpv2IEIMSI:
+			r.IMSI = gtpTBCD(value)
+		case gtpv2IEMSISDN:
+			r.MSISDN = gtpTBCD(value)
+		case gtpv2IEMEI:
+			r.MEI = gtpTBCD(value)
+		case gtpv2IEAPN:
+			r.APN = gtpAPN(value)
+		case gtpv2IERATType:
+			if len(value) > 0 {
+				if rat, ok := gtpv2RATTypes[value[0]]; ok {
+					r.RAT = rat
+				} else {
+					r.RAT = strconv.Itoa(int(value[0]))
+				}
+			}
+		case gtpv2IECause:
+			// the cause of a bearer context applies only to the bearer
+			if len(value) > 0 && ebi == "" {
+				pkt.cause = value[0]
+				if cause, ok := gtpv2Causes[value[0]]; ok {
+					r.Cause = cause
+				} else {
+					r.Cause = strconv.Itoa(int(value[0]))
+				}
+			}
+		case gtpv2IEPAA:
+			if ue := gtpPAA(value); ue != "" {
+				r.UEIP = ue
+				pkt.ueIPs = strings.Split(ue, "/")
+			}
+		case gtpv2IEFTEID:
+			f, ok := parseGTPFTEID(value)
+			if !ok {
+				continue
+			}
+
+			if ebi != "" {
+				r.BearerTEIDs = append(r.BearerTEIDs, ebi+":"+f.String())
+			} else if instance == 0 {
+				// instance zero is the control plane F-TEID of the sender
+				pkt.sender = f
+			}
+		case gtpv2IEBearerContext:
+			if err := pkt.parseIEs(value, gtpv2BearerID(value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// gtpv2BearerID returns the EPS bearer id of a bearer context.
+func gtpv2BearerID(ies []byte) string {
+	for len(ies) >= gtpv2IEHeaderLen {
+		size := int(binary.BigEndian.Uint16(ies[1:3]))
+		if gtpv2IEHeaderLen+size > len(ies) {
+			break
+		}
+
+		if ies[0] == gtpv2IEEBI && size > 0 {
+			return strconv.Itoa(int(ies[gtpv2IEHeaderLen] & 0x0f))
+		}
+
+		ies = ies[gtpv2IEHeaderLen+size:]
+	}
+
+	return "?"
+}
+
+type gtpcRequest struct {
+	record *types.GTPC
+	ts     time.Time
+}
+
+type gtpcSession struct {
+	imsi   string
+	msisdn string
+	apn    string
+	ueIP   string
+	start  time.Time
+}
+
+// gtpcState correlates requests with their responses and tracks sessions by their control plane TEIDs.
+var gtpcState = struct {
+	// client and server with the sequence number to requests
+	requests map[string]*gtpcRequest
+	// control plane TEIDs of both peers to sessions
+	sessions map[uint32]*gtpcSession
+	sync.Mutex
+}{
+	requests: make(map[string]*gtpcRequest),
+	sessions: make(map[uint32]*gtpcSession),
+}
+
+// gtpcKey returns the key used to correlate a request with its response.
+func gtpcKey(clientIP, serverIP string, seq uint32) string {
+	return clientIP + "-" + serverIP + "-" + strconv.FormatUint(uint64(seq), 10)
+}
+
+// handle correlates the packet and returns the record of a transaction once the response was seen.
+func (pkt *gtpv2Packet) handle(srcIP, dstIP string, ts time.Time) *types.GTPC {
+	r := pkt.record
+
+	if isGTPv2Request(pkt.typ) {
+		r.Timestamp = utils.TimeToString(ts)
+		r.ClientIP, r.ServerIP = srcIP, dstIP
+
+		if pkt.sender != nil {
+			r.ClientTEID = pkt.sender.teid
+		}
+
+		gtpcState.Lock()
+		defer gtpcState.Unlock()
+
+		// requests for existing sessions address the peer by its TEID
+		if s, ok := gtpcState.sessions[pkt.teid]; ok && pkt.teid != 0 {
+			if r.IMSI == "" {
+				r.IMSI = s.imsi
+			}
+
+			if r.MSISDN == "" {
+				r.MSISDN = s.msisdn
+			}
+
+			if r.APN == "" {
+				r.APN = s.apn
+			}
+
+			r.UEIP = s.ueIP
+			r.ServerTEID = pkt.teid
+		}
+
+		gtpcState.requests[gtpcKey(srcIP, dstIP, pkt.seq)] = &gtpcRequest{record: r, ts: ts}
+
+		return nil
+	}
+
+	key := gtpcKey(dstIP, srcIP, pkt.seq)
+
+	gtpcState.Lock()
+	defer gtpcState.Unlock()
+
+	req, ok := gtpcState.requests[key]
+	delete(gtpcState.requests, key)
+
+	if !ok {
+		r.Timestamp = utils.TimeToString(ts)
+		r.ClientIP, r.ServerIP = dstIP, srcIP
+		r.ClientTEID = pkt.teid
+		r.Notes = "no request"
+
+		if pkt.sender != nil {
+			r.ServerTEID = pkt.sender.teid
+		}
+
+		return r
+	}
+
+	res := req.record
+	res.Cause = r.Cause
+	res.Latency = ts.Sub(req.ts).Nanoseconds()
+	res.BearerTEIDs = append(res.BearerTEIDs, r.BearerTEIDs...)
+
+	if pkt.sender != nil {
+		res.ServerTEID = pkt.sender.teid
+	}
+
+	if r.UEIP != "" {
+		res.UEIP = r.UEIP
+	}
+
+	accepted := pkt.cause == gtpv2CauseRequestAccepted || pkt.cause == gtpv2CauseRequestAcceptedPartially
+	if !accepted {
+		return res
+	}
+
+	switch pkt.typ {
+	case gtpv2CreateSessionResponse:
+		s := &gtpcSession{
+			imsi:   res.IMSI,
+			msisdn: res.MSISDN,
+			apn:    res.APN,
+			ueIP:   res.UEIP,
+			start:  ts,
+		}
+
+		for _, teid := range []uint32{res.ClientTEID, res.ServerTEID} {
+			if teid != 0 {
+				gtpcState.sessions[teid] = s
+			}
+		}
+
+		// attribute the addresses of the user equipment to the subscriber
+		if res.IMSI != "" {
+			for _, ip := range pkt.ueIPs {
+				addIPProfileUser(ip, "IMSI "+res.IMSI)
+			}
+		}
+	case gtpv2DeleteSessionResponse:
+		if s, ok := gtpcState.sessions[res.ServerTEID]; ok {
+			res.SessionDuration = int64(ts.Sub(s.start).Seconds())
+
+			for teid, other := range gtpcState.sessions {
+				if other == s {
+					delete(gtpcState.sessions, teid)
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+var gtpcDecoder = newCustomDecoder(
+	types.Type_NC_GTPC,
+	serviceGTPC,
+	"The GPRS Tunnelling Protocol control plane manages the subscriber sessions in mobile core networks",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		udp, srcIP, dstIP := udpLayerForPort(p, gtpcPort)
+		if udp == nil {
+			return nil
+		}
+
+		pkt, err := parseGTPv2(udp.Payload)
+		if pkt == nil {
+			return nil
+		}
+
+		if err != nil {
+			pkt.record.Notes = addInfo(pkt.record.Notes, err.Error())
+		}
+
+		r := pkt.handle(srcIP, dstIP, p.Metadata().Timestamp)
+		if r == nil {
+			return nil
+		}
+
+		return r
+	},
+	func(e *customDecoder) error {
+		// write requests that did not receive a response
+		gtpcState.Lock()
+		defer gtpcState.Unlock()
+
+		for key, req := range gtpcState.requests {
+			req.record.Notes = addInfo(req.record.Notes, "no response")
+			e.write(req.record)
+
+			delete(gtpcState.requests, key)
+		}
+
+		return nil
+	},
+)
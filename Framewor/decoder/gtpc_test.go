@@ -0,0 +1,155 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func gtpv2TestIE(typ, instance byte, value ...byte) []byte {
+	ie := []byte{typ, 0, 0, instance}
+	binary.BigEndian.PutUint16(ie[1:3], uint16(len(value)))
+
+	return append(ie, value...)
+}
+
+func gtpv2TestFTEID(iface byte, teid uint32, ip ...byte) []byte {
+	v := []byte{0x80 | iface, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(v[1:], teid)
+
+	return append(v, ip...)
+}
+
+func gtpv2TestPacket(typ byte, teid, seq uint32, ies ...[]byte) []byte {
+	pkt := make([]byte, gtpv2HeaderLenTEID)
+	pkt[0], pkt[1] = 0x48, typ
+	binary.BigEndian.PutUint32(pkt[4:8], teid)
+	binary.BigEndian.PutUint32(pkt[8:12], seq<<8)
+
+	for _, ie := range ies {
+		pkt = append(pkt, ie...)
+	}
+
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)-4))
+
+	return pkt
+}
+
+func TestGTPTBCD(t *testing.T) {
+	if s := gtpTBCD([]byte{0x10, 0x10, 0x32, 0x54, 0xf6}); s != "010123456" {
+		t.Fatal("unexpected TBCD digits:", s)
+	}
+}
+
+func TestGTPC(t *testing.T) {
+	defer func() {
+		ipUsers.Lock()
+		delete(ipUsers.Items, "100.64.0.10")
+		ipUsers.Unlock()
+
+		ipProfiles.Lock()
+		delete(ipProfiles.Items, "100.64.0.10")
+		ipProfiles.Unlock()
+	}()
+
+	ipProfiles.Lock()
+	ipProfiles.Items["100.64.0.10"] = &ipProfile{IPProfile: &types.IPProfile{Addr: "100.64.0.10"}}
+	ipProfiles.Unlock()
+
+	var (
+		ts    = time.Unix(1600000000, 0)
+		check = func(pkt []byte, src, dst string, ts time.Time) *types.GTPC {
+			p, err := parseGTPv2(pkt)
+			if err != nil || p == nil {
+				t.Fatal("failed to parse:", err)
+			}
+
+			return p.handle(src, dst, ts)
+		}
+	)
+
+	create := gtpv2TestPacket(gtpv2CreateSessionRequest, 0, 1,
+		gtpv2TestIE(gtpv2IEIMSI, 0, 0x10, 0x10, 0x32, 0x54, 0x76, 0x98, 0xf0),
+		gtpv2TestIE(gtpv2IEMSISDN, 0, 0x94, 0x71, 0x52, 0x34, 0xf6),
+		gtpv2TestIE(gtpv2IERATType, 0, 6),
+		gtpv2TestIE(gtpv2IEFTEID, 0, gtpv2TestFTEID(10, 0x1000, 10, 0, 0, 1)...),
+		gtpv2TestIE(gtpv2IEAPN, 0, append([]byte{8}, "internet"...)...),
+		gtpv2TestIE(gtpv2IEBearerContext, 0, append(
+			gtpv2TestIE(gtpv2IEEBI, 0, 5),
+			gtpv2TestIE(gtpv2IEFTEID, 0, gtpv2TestFTEID(0, 0xabc, 10, 0, 1, 1)...)...,
+		)...),
+	)
+
+	if r := check(create, "10.0.0.1", "10.0.0.2", ts); r != nil {
+		t.Fatal("expected no record for request, got:", r)
+	}
+
+	response := gtpv2TestPacket(gtpv2CreateSessionResponse, 0x1000, 1,
+		gtpv2TestIE(gtpv2IECause, 0, gtpv2CauseRequestAccepted, 0),
+		gtpv2TestIE(gtpv2IEFTEID, 0, gtpv2TestFTEID(11, 0x2000, 10, 0, 0, 2)...),
+		gtpv2TestIE(gtpv2IEPAA, 0, gtpv2PDNIPv4, 100, 64, 0, 10),
+		gtpv2TestIE(gtpv2IEBearerContext, 0, append(
+			gtpv2TestIE(gtpv2IEEBI, 0, 5),
+			gtpv2TestIE(gtpv2IEFTEID, 0, gtpv2TestFTEID(1, 0xdef, 10, 0, 1, 2)...)...,
+		)...),
+	)
+
+	r := check(response, "10.0.0.2", "10.0.0.1", ts.Add(time.Millisecond))
+	if r == nil {
+		t.Fatal("expected record for response")
+	}
+
+	if r.Message != "Create Session Request" || r.Cause != "Request accepted" || r.IMSI != "0101234567890" || r.MSISDN != "491725436" || r.APN != "internet" || r.RAT != "EUTRAN" {
+		t.Fatal("unexpected session:", r)
+	}
+
+	if r.UEIP != "100.64.0.10" || r.ClientTEID != 0x1000 || r.ServerTEID != 0x2000 || r.Latency != int64(time.Millisecond) {
+		t.Fatal("unexpected session endpoints:", r)
+	}
+
+	if strings.Join(r.BearerTEIDs, ",") != "5:S1-U eNodeB:2748@10.0.1.1,5:S1-U SGW:3567@10.0.1.2" {
+		t.Fatal("unexpected bearers:", r.BearerTEIDs)
+	}
+
+	ipProfiles.Lock()
+	users := ipProfiles.Items["100.64.0.10"].Users
+	ipProfiles.Unlock()
+
+	if strings.Join(users, ",") != "IMSI 0101234567890" {
+		t.Fatal("expected subscriber on profile, got:", users)
+	}
+
+	// the delete request addresses the session by the TEID of the SGW
+	if r = check(gtpv2TestPacket(gtpv2DeleteSessionRequest, 0x2000, 2, gtpv2TestIE(gtpv2IEEBI, 0, 5)), "10.0.0.1", "10.0.0.2", ts.Add(time.Minute)); r != nil {
+		t.Fatal("expected no record for request, got:", r)
+	}
+
+	r = check(gtpv2TestPacket(gtpv2DeleteSessionResponse, 0x1000, 2, gtpv2TestIE(gtpv2IECause, 0, gtpv2CauseRequestAccepted, 0)), "10.0.0.2", "10.0.0.1", ts.Add(time.Minute+time.Second))
+	if r == nil || r.Message != "Delete Session Request" || r.IMSI != "0101234567890" || r.UEIP != "100.64.0.10" || r.SessionDuration != 60 {
+		t.Fatal("unexpected delete record:", r)
+	}
+
+	gtpcState.Lock()
+	n := len(gtpcState.sessions)
+	gtpcState.Unlock()
+
+	if n != 0 {
+		t.Fatal("expected session to be removed, got:", n)
+	}
+}
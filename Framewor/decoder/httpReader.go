@@ -17,6 +17,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -41,6 +42,8 @@ import (
 const (
 	headerContentType     = "Content-Type"
 	headerContentEncoding = "Content-Encoding"
+	headerAuthorization   = "Authorization"
+	headerAuthenticate    = "Www-Authenticate"
 
 	methodPost = "POST"
 
@@ -181,6 +184,11 @@ func (h *httpReader) Decode() {
 	// 	fmt.Println(err)
 	// }
 
+	// search for NTLM challenge response pairs before the requests are matched to their responses
+	if isCustomDecoderLoaded(credentialsDecoderName) {
+		h.searchForNTLM()
+	}
+
 	// iterate over responses
 	for _, res := range h.responses { // populate types.HTTP with all infos from response
 		ht := newHTTPFromResponse(res.response)
@@ -250,6 +258,56 @@ func (h *httpReader) searchForBasicAuth(req *http.Request) {
 	}
 }
 
+// searchForNTLM pairs the NTLM challenges sent by the server in the WWW-Authenticate header
+// with the AUTHENTICATE messages in the Authorization header of the following requests.
+func (h *httpReader) searchForNTLM() {
+	h.parent.Lock()
+	var (
+		requests  = h.requests
+		responses = h.responses
+		challenge []byte
+	)
+	h.parent.Unlock()
+
+	for i, req := range requests {
+		if req == nil {
+			continue
+		}
+
+		// the challenge is sent in the response to the previous request
+		if i > 0 && i <= len(responses) {
+			if ch := parseNTLMChallenge(ntlmHeaderToken(responses[i-1].response.Header[headerAuthenticate])); ch != nil {
+				challenge = ch
+			}
+		}
+
+		if auth := parseNTLMAuthenticate(ntlmHeaderToken(req.request.Header[headerAuthorization])); auth != nil {
+			writeNTLMCredentials(auth, challenge, h.parent.firstPacket, h.parent.ident)
+		}
+	}
+}
+
+// ntlmHeaderToken returns the decoded token of a NTLM or Negotiate authentication header.
+func ntlmHeaderToken(values []string) []byte {
+	for _, v := range values {
+		fields := strings.Fields(v)
+		if len(fields) != 2 || !(strings.EqualFold(fields[0], "NTLM") || strings.EqualFold(fields[0], "Negotiate")) {
+			continue
+		}
+
+		token, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			continue
+		}
+
+		if bytes.Contains(token, ntlmSignature) {
+			return token
+		}
+	}
+
+	return nil
+}
+
 // search for user name and password in http url params and body params.
 func (h *httpReader) searchForLoginParams(req *http.Request) {
 	for name, values := range req.Form {
@@ -637,7 +695,7 @@ func fileExtensionForContentType(typ string) string {
 		return ".ttf"
 	case "application/vnd.visio":
 		return ".vsd"
-	case "audio/wav":
+	case "audio/wav", "audio/wave":
 		return ".wav"
 	case "audio/webm":
 		return ".weba"
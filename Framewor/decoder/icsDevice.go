@@ -0,0 +1,137 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+const serviceICSDevice = "ICSDevice"
+
+// device roles in the inventory
+const (
+	icsRoleControlledStation  = "controlled station"
+	icsRoleControllingStation = "controlling station"
+	icsRoleDevice             = "device"
+	icsRoleClient             = "client"
+)
+
+type icsDevice struct {
+	record    *types.ICSDevice
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// icsDeviceState is the inventory of devices seen by the industrial protocol decoders.
+var icsDeviceState = struct {
+	// ip and protocol to devices
+	devices map[string]*icsDevice
+
+	sync.Mutex
+}{
+	devices: make(map[string]*icsDevice),
+}
+
+// updateICSDevice counts a message for the device at the given ip and applies the update to its inventory entry.
+func updateICSDevice(ip, protocol string, ts time.Time, update func(d *types.ICSDevice)) {
+	if ip == "" {
+		return
+	}
+
+	icsDeviceState.Lock()
+	defer icsDeviceState.Unlock()
+
+	key := ip + "/" + protocol
+
+	dev, ok := icsDeviceState.devices[key]
+	if !ok {
+		dev = &icsDevice{
+			record: &types.ICSDevice{
+				IP:       ip,
+				Protocol: protocol,
+			},
+			firstSeen: ts,
+			lastSeen:  ts,
+		}
+		icsDeviceState.devices[key] = dev
+	}
+
+	if ts.Before(dev.firstSeen) {
+		dev.firstSeen = ts
+	}
+
+	if ts.After(dev.lastSeen) {
+		dev.lastSeen = ts
+	}
+
+	dev.record.Messages++
+
+	if update != nil {
+		update(dev.record)
+	}
+}
+
+// addICSDeviceNote adds the note to the device, unless it is already present.
+func addICSDeviceNote(d *types.ICSDevice, note string) {
+	if containsString(strings.Split(d.Notes, " | "), note) {
+		return
+	}
+
+	d.Notes = addInfo(d.Notes, note)
+}
+
+var icsDeviceDecoder = newCustomDecoder(
+	types.Type_NC_ICSDevice,
+	serviceICSDevice,
+	"An inventory of the industrial control system devices seen by the IEC-104 and BACnet decoders",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		return nil
+	},
+	func(e *customDecoder) error {
+		icsDeviceState.Lock()
+		defer icsDeviceState.Unlock()
+
+		keys := make([]string, 0, len(icsDeviceState.devices))
+		for key := range icsDeviceState.devices {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			dev := icsDeviceState.devices[key]
+
+			r := dev.record
+			r.Timestamp = utils.TimeToString(dev.firstSeen)
+			r.TimestampLast = utils.TimeToString(dev.lastSeen)
+
+			sort.Strings(r.Peers)
+			e.write(r)
+		}
+
+		return nil
+	},
+)
@@ -0,0 +1,578 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * IEC 60870-5-104
+ */
+
+const (
+	iec104StartByte = 0x68
+
+	// the APCI consists of the start byte, the length and four control octets
+	iec104ControlSize = 4
+
+	// ASDU header with a two octet cause of transmission and common address
+	iec104ASDUHeaderSize = 6
+	iec104IOASize        = 3
+
+	iec104CauseActivation = 6
+)
+
+var errASDUTruncated = errors.New("truncated ASDU")
+
+// APCI frame formats
+const (
+	iec104FormatI = "I"
+	iec104FormatS = "S"
+	iec104FormatU = "U"
+)
+
+var iec104UFunctions = map[byte]string{
+	0x07: "STARTDT act",
+	0x0b: "STARTDT con",
+	0x13: "STOPDT act",
+	0x23: "STOPDT con",
+	0x43: "TESTFR act",
+	0x83: "TESTFR con",
+}
+
+var iec104Types = map[byte]string{
+	1:   "M_SP_NA_1",
+	2:   "M_SP_TA_1",
+	3:   "M_DP_NA_1",
+	4:   "M_DP_TA_1",
+	5:   "M_ST_NA_1",
+	6:   "M_ST_TA_1",
+	7:   "M_BO_NA_1",
+	8:   "M_BO_TA_1",
+	9:   "M_ME_NA_1",
+	10:  "M_ME_TA_1",
+	11:  "M_ME_NB_1",
+	12:  "M_ME_TB_1",
+	13:  "M_ME_NC_1",
+	14:  "M_ME_TC_1",
+	15:  "M_IT_NA_1",
+	16:  "M_IT_TA_1",
+	20:  "M_PS_NA_1",
+	21:  "M_ME_ND_1",
+	30:  "M_SP_TB_1",
+	31:  "M_DP_TB_1",
+	32:  "M_ST_TB_1",
+	33:  "M_BO_TB_1",
+	34:  "M_ME_TD_1",
+	35:  "M_ME_TE_1",
+	36:  "M_ME_TF_1",
+	37:  "M_IT_TB_1",
+	38:  "M_EP_TD_1",
+	39:  "M_EP_TE_1",
+	40:  "M_EP_TF_1",
+	45:  "C_SC_NA_1",
+	46:  "C_DC_NA_1",
+	47:  "C_RC_NA_1",
+	48:  "C_SE_NA_1",
+	49:  "C_SE_NB_1",
+	50:  "C_SE_NC_1",
+	51:  "C_BO_NA_1",
+	58:  "C_SC_TA_1",
+	59:  "C_DC_TA_1",
+	60:  "C_RC_TA_1",
+	61:  "C_SE_TA_1",
+	62:  "C_SE_TB_1",
+	63:  "C_SE_TC_1",
+	64:  "C_BO_TA_1",
+	70:  "M_EI_NA_1",
+	100: "C_IC_NA_1",
+	101: "C_CI_NA_1",
+	102: "C_RD_NA_1",
+	103: "C_CS_NA_1",
+	104: "C_TS_NA_1",
+	105: "C_RP_NA_1",
+	106: "C_CD_NA_1",
+	107: "C_TS_TA_1",
+	110: "P_ME_NA_1",
+	111: "P_ME_NB_1",
+	112: "P_ME_NC_1",
+	113: "P_AC_NA_1",
+}
+
+// size of an information element including its quality descriptor and time tag, without the object address
+var iec104ElementSizes = map[byte]int{
+	1:   1,
+	2:   4,
+	3:   1,
+	4:   4,
+	5:   2,
+	6:   5,
+	7:   5,
+	8:   8,
+	9:   3,
+	10:  6,
+	11:  3,
+	12:  6,
+	13:  5,
+	14:  8,
+	15:  5,
+	16:  8,
+	20:  5,
+	21:  2,
+	30:  8,
+	31:  8,
+	32:  9,
+	33:  12,
+	34:  10,
+	35:  10,
+	36:  12,
+	37:  12,
+	38:  10,
+	39:  11,
+	40:  11,
+	45:  1,
+	46:  1,
+	47:  1,
+	48:  3,
+	49:  3,
+	50:  5,
+	51:  4,
+	58:  8,
+	59:  8,
+	60:  8,
+	61:  10,
+	62:  10,
+	63:  12,
+	64:  11,
+	70:  1,
+	100: 1,
+	101: 1,
+	102: 0,
+	103: 7,
+	104: 2,
+	105: 1,
+	106: 2,
+	107: 9,
+	110: 3,
+	111: 3,
+	112: 5,
+	113: 1,
+}
+
+var iec104Causes = map[byte]string{
+	1:  "periodic",
+	2:  "background scan",
+	3:  "spontaneous",
+	4:  "initialized",
+	5:  "request",
+	6:  "activation",
+	7:  "activation confirmation",
+	8:  "deactivation",
+	9:  "deactivation confirmation",
+	10: "activation termination",
+	11: "return information remote command",
+	12: "return information local command",
+	13: "file transfer",
+	20: "interrogated by station interrogation",
+	37: "requested by general counter request",
+	44: "unknown type identification",
+	45: "unknown cause of transmission",
+	46: "unknown common address of ASDU",
+	47: "unknown information object address",
+}
+
+// notes for system commands in control direction
+var iec104SystemCommands = map[byte]string{
+	100: "interrogation",
+	101: "counter interrogation",
+	103: "clock synchronization",
+	105: "reset process",
+}
+
+// isIEC104 checks if the default port is used or the server confirmed the start of data transfer.
+func isIEC104(banner []byte, transport gopacket.Flow) bool {
+	if transport.Dst().String() == "2404" {
+		return true
+	}
+
+	return len(banner) >= 6 && banner[0] == iec104StartByte && banner[1] == iec104ControlSize && banner[2] == 0x0b
+}
+
+// iec104Cause returns the name of a cause of transmission.
+func iec104Cause(cot byte) string {
+	if name, ok := iec104Causes[cot]; ok {
+		return name
+	}
+
+	switch {
+	case cot >= 21 && cot <= 36:
+		return "interrogated by group " + strconv.Itoa(int(cot-20)) + " interrogation"
+	case cot >= 38 && cot <= 41:
+		return "requested by group " + strconv.Itoa(int(cot-37)) + " counter request"
+	}
+
+	return "unknown (" + strconv.Itoa(int(cot)) + ")"
+}
+
+// iec104SelectExecute describes the select / execute bit of a command qualifier.
+func iec104SelectExecute(qualifier byte) string {
+	if qualifier&0x80 != 0 {
+		return "select"
+	}
+
+	return "execute"
+}
+
+// iec104Time formats a seven octet binary time (CP56Time2a).
+func iec104Time(data []byte) string {
+	ms := binary.LittleEndian.Uint16(data[:2])
+
+	return fmt.Sprintf("20%02d-%02d-%02d %02d:%02d:%02d.%03d",
+		data[6]&0x7f, data[5]&0x0f, data[4]&0x1f, data[3]&0x1f, data[2]&0x3f, ms/1000, ms%1000,
+	)
+}
+
+// iec104Value describes the value of an information element.
+func iec104Value(typeID byte, e []byte) string {
+	switch typeID {
+	case 1, 2, 30:
+		if e[0]&0x01 != 0 {
+			return "ON"
+		}
+
+		return "OFF"
+	case 3, 4, 31:
+		switch e[0] & 0x03 {
+		case 1:
+			return "OFF"
+		case 2:
+			return "ON"
+		}
+
+		return "indeterminate"
+	case 5, 6, 32:
+		return strconv.Itoa(int(int8(e[0]<<1) >> 1))
+	case 7, 8, 33, 51, 64:
+		return fmt.Sprintf("0x%08x", binary.LittleEndian.Uint32(e[:4]))
+	case 9, 10, 21, 34, 110:
+		return strconv.FormatFloat(float64(int16(binary.LittleEndian.Uint16(e[:2])))/32768, 'f', -1, 64)
+	case 11, 12, 35, 111:
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(e[:2]))))
+	case 13, 14, 36, 112:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(e[:4]))), 'f', -1, 32)
+	case 15, 16, 37:
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(e[:4]))))
+	case 45, 58:
+		state := "OFF"
+		if e[0]&0x01 != 0 {
+			state = "ON"
+		}
+
+		return state + " " + iec104SelectExecute(e[0])
+	case 46, 59:
+		state := "invalid"
+
+		switch e[0] & 0x03 {
+		case 1:
+			state = "OFF"
+		case 2:
+			state = "ON"
+		}
+
+		return state + " " + iec104SelectExecute(e[0])
+	case 47, 60:
+		state := "invalid"
+
+		switch e[0] & 0x03 {
+		case 1:
+			state = "LOWER"
+		case 2:
+			state = "HIGHER"
+		}
+
+		return state + " " + iec104SelectExecute(e[0])
+	case 48, 61:
+		return strconv.FormatFloat(float64(int16(binary.LittleEndian.Uint16(e[:2])))/32768, 'f', -1, 64) + " " + iec104SelectExecute(e[2])
+	case 49, 62:
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(e[:2])))) + " " + iec104SelectExecute(e[2])
+	case 50, 63:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(e[:4]))), 'f', -1, 32) + " " + iec104SelectExecute(e[4])
+	case 100:
+		switch {
+		case e[0] == 20:
+			return "station"
+		case e[0] >= 21 && e[0] <= 36:
+			return "group " + strconv.Itoa(int(e[0]-20))
+		}
+	case 101:
+		switch q := e[0] & 0x3f; {
+		case q == 5:
+			return "general"
+		case q >= 1 && q <= 4:
+			return "group " + strconv.Itoa(int(q))
+		}
+	case 103:
+		return iec104Time(e)
+	case 105:
+		switch e[0] {
+		case 1:
+			return "general reset"
+		case 2:
+			return "reset of pending information with time tag"
+		}
+	}
+
+	return ""
+}
+
+// iec104Objects decodes the information objects of an ASDU.
+func iec104Objects(typeID byte, sequence bool, num int, data []byte) ([]string, error) {
+	size, ok := iec104ElementSizes[typeID]
+	if !ok {
+		return nil, fmt.Errorf("unsupported type identification %d", typeID)
+	}
+
+	var (
+		objects = make([]string, 0, num)
+		ioa     uint32
+	)
+
+	for i := 0; i < num; i++ {
+		// a sequence of elements only carries the address of the first one
+		if !sequence || i == 0 {
+			if len(data) < iec104IOASize {
+				return objects, errASDUTruncated
+			}
+
+			ioa = uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+			data = data[iec104IOASize:]
+		} else {
+			ioa++
+		}
+
+		if len(data) < size {
+			return objects, errASDUTruncated
+		}
+
+		obj := strconv.Itoa(int(ioa))
+		if value := iec104Value(typeID, data[:size]); value != "" {
+			obj += ": " + value
+		}
+
+		objects = append(objects, obj)
+		data = data[size:]
+	}
+
+	return objects, nil
+}
+
+type iec104Reader struct {
+	parent *tcpConnection
+}
+
+// Decode parses the stream according to the IEC 60870-5-104 protocol.
+func (h *iec104Reader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	for _, d := range h.parent.merged {
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.read(&clientBuf, true, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.read(&serverBuf, false, ts)
+		}
+	}
+}
+
+// read extracts the APDUs in the buffer.
+func (h *iec104Reader) read(buf *bytes.Buffer, client bool, ts time.Time) {
+	for buf.Len() >= 2 {
+		data := buf.Bytes()
+
+		// resync on the next start byte
+		if data[0] != iec104StartByte {
+			utils.DebugLog.Println("IEC-104: invalid start byte on", h.parent.ident)
+
+			idx := bytes.IndexByte(data, iec104StartByte)
+			if idx < 0 {
+				buf.Reset()
+
+				return
+			}
+
+			buf.Next(idx)
+
+			continue
+		}
+
+		length := int(data[1])
+		if length < iec104ControlSize {
+			buf.Next(1)
+
+			continue
+		}
+
+		if buf.Len() < 2+length {
+			return
+		}
+
+		apdu := buf.Next(2 + length)
+		h.apdu(apdu[2:], client, ts)
+	}
+}
+
+// apdu decodes the control field of an APDU and the ASDU of I-format APDUs.
+func (h *iec104Reader) apdu(data []byte, client bool, ts time.Time) {
+	r := &types.IEC104{
+		Timestamp:  utils.TimeToString(ts),
+		ClientIP:   h.parent.net.Src().String(),
+		ServerIP:   h.parent.net.Dst().String(),
+		Flow:       h.parent.ident,
+		FromClient: client,
+	}
+
+	switch {
+	case data[0]&0x01 == 0:
+		r.FrameType = iec104FormatI
+		r.SendSeq = int32(binary.LittleEndian.Uint16(data[0:2]) >> 1)
+		r.RecvSeq = int32(binary.LittleEndian.Uint16(data[2:4]) >> 1)
+
+		h.asdu(r, data[iec104ControlSize:])
+	case data[0]&0x03 == 0x01:
+		r.FrameType = iec104FormatS
+		r.RecvSeq = int32(binary.LittleEndian.Uint16(data[2:4]) >> 1)
+	default:
+		r.FrameType = iec104FormatU
+
+		if name, ok := iec104UFunctions[data[0]]; ok {
+			r.UFunction = name
+		} else {
+			r.UFunction = fmt.Sprintf("unknown (0x%02x)", data[0])
+		}
+	}
+
+	h.inventory(r, ts)
+	h.write(r)
+}
+
+// asdu decodes the data unit identifier and the information objects.
+func (h *iec104Reader) asdu(r *types.IEC104, asdu []byte) {
+	if len(asdu) < iec104ASDUHeaderSize {
+		r.Notes = addInfo(r.Notes, errASDUTruncated.Error())
+
+		return
+	}
+
+	var (
+		typeID = asdu[0]
+		cot    = asdu[2]
+	)
+
+	r.TypeID = int32(typeID)
+	if name, ok := iec104Types[typeID]; ok {
+		r.Type = name
+	} else {
+		r.Type = "unknown"
+	}
+
+	r.Cause = iec104Cause(cot & 0x3f)
+	r.Negative = cot&0x40 != 0
+	r.Test = cot&0x80 != 0
+	r.OriginatorAddress = int32(asdu[3])
+	r.CommonAddress = int32(binary.LittleEndian.Uint16(asdu[4:6]))
+
+	objects, err := iec104Objects(typeID, asdu[1]&0x80 != 0, int(asdu[1]&0x7f), asdu[iec104ASDUHeaderSize:])
+	r.Objects = objects
+
+	if err != nil {
+		r.Notes = addInfo(r.Notes, err.Error())
+	}
+
+	// process information in control direction
+	r.Command = typeID >= 45 && typeID <= 69
+
+	if r.Command && r.FromClient && cot&0x3f == iec104CauseActivation {
+		r.Notes = addInfo(r.Notes, "command activation")
+	}
+
+	if note, ok := iec104SystemCommands[typeID]; ok && r.FromClient {
+		r.Notes = addInfo(r.Notes, note)
+	}
+
+	if r.Negative {
+		r.Notes = addInfo(r.Notes, "negative confirmation")
+	}
+
+	if r.Test {
+		r.Notes = addInfo(r.Notes, "test")
+	}
+}
+
+// inventory adds both stations of the connection to the ICS device inventory.
+func (h *iec104Reader) inventory(r *types.IEC104, ts time.Time) {
+	updateICSDevice(r.ServerIP, serviceIEC104, ts, func(d *types.ICSDevice) {
+		d.Role = icsRoleControlledStation
+		d.Peers = appendUnique(d.Peers, r.ClientIP)
+
+		if r.FrameType == iec104FormatI && r.Type != "" {
+			d.Addresses = appendUnique(d.Addresses, strconv.Itoa(int(r.CommonAddress)))
+		}
+
+		if r.Command && r.FromClient {
+			addICSDeviceNote(d, "receives commands")
+		}
+	})
+
+	updateICSDevice(r.ClientIP, serviceIEC104, ts, func(d *types.ICSDevice) {
+		d.Role = icsRoleControllingStation
+		d.Peers = appendUnique(d.Peers, r.ServerIP)
+
+		if r.Command && r.FromClient {
+			addICSDeviceNote(d, "sends commands")
+		}
+	})
+}
+
+func (h *iec104Reader) write(r *types.IEC104) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&iec104Decoder.numRecords, 1)
+
+	err := iec104Decoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
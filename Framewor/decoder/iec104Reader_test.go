@@ -0,0 +1,130 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// iec104TestAPDU prepends the start byte and length to the control field and ASDU.
+func iec104TestAPDU(data ...byte) []byte {
+	return append([]byte{iec104StartByte, byte(len(data))}, data...)
+}
+
+func TestIEC104Reader(t *testing.T) {
+	var (
+		records = &recordCollector{}
+		oldConf = conf
+
+		startAct = iec104TestAPDU(0x07, 0, 0, 0)
+		startCon = iec104TestAPDU(0x0b, 0, 0, 0)
+
+		// general interrogation of the station with common address 1
+		interrogation = iec104TestAPDU(0, 0, 0, 0, 100, 0x01, 0x06, 0, 0x01, 0, 0, 0, 0, 20)
+
+		// sequence of two short floats starting at IOA 1000
+		floats = iec104TestAPDU(0, 0, 0x02, 0, 13, 0x82, 20, 0, 0x01, 0, 0xe8, 0x03, 0, 0, 0, 0xc0, 0x3f, 0, 0, 0, 0x10, 0xc0, 0)
+
+		// single command select ON for IOA 5000 and its negative confirmation
+		command = iec104TestAPDU(0x02, 0, 0x02, 0, 45, 0x01, 0x06, 0, 0x01, 0, 0x88, 0x13, 0, 0x81)
+		confirm = iec104TestAPDU(0x02, 0, 0x04, 0, 45, 0x01, 0x47, 0, 0x01, 0, 0x88, 0x13, 0, 0x81)
+
+		ack = iec104TestAPDU(0x01, 0, 0x04, 0)
+	)
+
+	defer func() {
+		conf = oldConf
+
+		icsDeviceState.Lock()
+		delete(icsDeviceState.devices, "10.0.0.1/"+serviceIEC104)
+		delete(icsDeviceState.devices, "10.0.0.2/"+serviceIEC104)
+		icsDeviceState.Unlock()
+	}()
+
+	conf = &Config{}
+	iec104Decoder.writer = records
+
+	if !isIEC104(startCon, newTestConnection(50000, 12404).transport) {
+		t.Fatal("failed to detect IEC-104")
+	}
+
+	if isIEC104(s7TestTPKT(0x02, cotpData, cotpEOT), newTestConnection(50000, 102).transport) {
+		t.Fatal("TPKT detected as IEC-104")
+	}
+
+	c := newTestConnection(50000, 2404,
+		startAct,
+		startCon,
+		interrogation,
+		// split APDU
+		floats[:10],
+		command,
+		floats[10:],
+		// invalid byte before the start of the next APDU
+		append([]byte{0xff}, ack...),
+		confirm,
+	)
+
+	(&iec104Reader{parent: c}).Decode()
+
+	if len(records.records) != 7 {
+		t.Fatal("expected 7 IEC-104 records, got", len(records.records))
+	}
+
+	r := records.records[0].(*types.IEC104)
+	if r.FrameType != iec104FormatU || r.UFunction != "STARTDT act" || !r.FromClient {
+		t.Fatal("unexpected STARTDT record:", r)
+	}
+
+	r = records.records[2].(*types.IEC104)
+	if r.Type != "C_IC_NA_1" || r.Cause != "activation" || strings.Join(r.Objects, ",") != "0: station" || r.Notes != "interrogation" || r.CommonAddress != 1 {
+		t.Fatal("unexpected interrogation record:", r)
+	}
+
+	r = records.records[3].(*types.IEC104)
+	if r.Type != "C_SC_NA_1" || !r.Command || strings.Join(r.Objects, ",") != "5000: ON select" || r.Notes != "command activation" || r.SendSeq != 1 || r.RecvSeq != 1 {
+		t.Fatal("unexpected command record:", r)
+	}
+
+	r = records.records[4].(*types.IEC104)
+	if r.Type != "M_ME_NC_1" || r.Cause != "interrogated by station interrogation" || strings.Join(r.Objects, ",") != "1000: 1.5,1001: -2.25" || r.FromClient {
+		t.Fatal("unexpected measurement record:", r)
+	}
+
+	r = records.records[5].(*types.IEC104)
+	if r.FrameType != iec104FormatS || r.RecvSeq != 2 {
+		t.Fatal("unexpected S-format record:", r)
+	}
+
+	r = records.records[6].(*types.IEC104)
+	if !r.Negative || r.Cause != "activation confirmation" || r.Notes != "negative confirmation" {
+		t.Fatal("unexpected confirmation record:", r)
+	}
+
+	icsDeviceState.Lock()
+	defer icsDeviceState.Unlock()
+
+	d := icsDeviceState.devices["10.0.0.2/"+serviceIEC104]
+	if d == nil || d.record.Role != icsRoleControlledStation || strings.Join(d.record.Addresses, ",") != "1" || d.record.Notes != "receives commands" || d.record.Messages != 7 {
+		t.Fatal("unexpected controlled station:", d)
+	}
+
+	d = icsDeviceState.devices["10.0.0.1/"+serviceIEC104]
+	if d == nil || d.record.Role != icsRoleControllingStation || d.record.Notes != "sends commands" {
+		t.Fatal("unexpected controlling station:", d)
+	}
+}
@@ -0,0 +1,479 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * IMAP - Internet Message Access Protocol
+ */
+
+// upper limit for literals kept in memory, larger literals are skipped.
+const imapMaxLiteralSize = 64 * 1024 * 1024
+
+var (
+	// literal at the end of a line: {size} or the non synchronizing {size+}
+	reIMAPLiteral = regexp.MustCompile(`\{(\d+)\+?\}$`)
+
+	// data item that precedes each literal of a line
+	reIMAPLiteralItem = regexp.MustCompile(`(\S*)\s*\{\d+\+?\}`)
+)
+
+// isIMAP checks if the server greeting looks like an IMAP service.
+func isIMAP(banner []byte, transport gopacket.Flow) bool {
+	if !bytes.HasPrefix(banner, []byte("* OK")) && !bytes.HasPrefix(banner, []byte("* PREAUTH")) {
+		return false
+	}
+
+	return transport.Dst().String() == "143" || bytes.Contains(bytes.ToUpper(banner), []byte(serviceIMAP))
+}
+
+// isIMAPMessageItem checks if a FETCH data item contains the full message.
+func isIMAPMessageItem(item string) bool {
+	item = strings.ToUpper(item)
+
+	// strip the partial specifier, e.g. BODY[]<0>
+	if idx := strings.Index(item, "<"); idx != -1 {
+		item = item[:idx]
+	}
+
+	// the item can be preceded by the opening parenthesis of the FETCH response
+	item = strings.TrimLeft(item, "(")
+
+	return item == "BODY[]" || item == "RFC822" || item == "BINARY[]"
+}
+
+// imapArguments splits command arguments into atoms, quoted strings and literals.
+// Literals are replaced with their content.
+func imapArguments(args string, literals [][]byte) []string {
+	var (
+		out []string
+		lit int
+	)
+
+	for i := 0; i < len(args); {
+		switch args[i] {
+		case ' ':
+			i++
+		case '"':
+			var b strings.Builder
+
+			i++
+			for ; i < len(args) && args[i] != '"'; i++ {
+				if args[i] == '\\' && i+1 < len(args) {
+					i++
+				}
+
+				b.WriteByte(args[i])
+			}
+
+			i++
+
+			out = append(out, b.String())
+		case '{':
+			end := strings.IndexByte(args[i:], '}')
+			if end == -1 {
+				end = len(args) - i - 1
+			}
+
+			if lit < len(literals) {
+				out = append(out, string(literals[lit]))
+				lit++
+			}
+
+			i += end + 1
+		default:
+			end := strings.IndexByte(args[i:], ' ')
+			if end == -1 {
+				end = len(args) - i
+			}
+
+			out = append(out, args[i:i+end])
+			i += end
+		}
+	}
+
+	return out
+}
+
+// imapParser splits one direction of an IMAP conversation into lines.
+// A line that announces a literal is continued after the literal data.
+type imapParser struct {
+	line     strings.Builder
+	literals [][]byte
+
+	// state of a literal that has not been received completely
+	literal   bytes.Buffer
+	remaining int
+	discard   bool
+}
+
+// read consumes the buffer and calls handle for every complete line with the literals it contains.
+func (p *imapParser) read(buf *bytes.Buffer, handle func(line string, literals [][]byte)) {
+	for {
+		if p.remaining > 0 {
+			data := buf.Next(p.remaining)
+			p.remaining -= len(data)
+
+			if !p.discard {
+				p.literal.Write(data)
+			}
+
+			if p.remaining > 0 {
+				return
+			}
+
+			lit := make([]byte, p.literal.Len())
+			copy(lit, p.literal.Bytes())
+			p.literals = append(p.literals, lit)
+			p.literal.Reset()
+
+			continue
+		}
+
+		line, ok := readCRLFLine(buf)
+		if !ok {
+			return
+		}
+
+		p.line.WriteString(line)
+
+		if m := reIMAPLiteral.FindStringSubmatch(line); len(m) == 2 {
+			size, err := strconv.Atoi(m[1])
+			if err == nil {
+				p.remaining = size
+				p.discard = size > imapMaxLiteralSize
+
+				if size == 0 {
+					p.literals = append(p.literals, nil)
+				}
+
+				continue
+			}
+		}
+
+		handle(p.line.String(), p.literals)
+
+		p.line.Reset()
+		p.literals = nil
+	}
+}
+
+type imapCommand struct {
+	record *types.IMAP
+	ts     time.Time
+}
+
+type imapReader struct {
+	parent *tcpConnection
+
+	user    string
+	mailbox string
+	banner  string
+
+	// client is sending SASL responses for AUTHENTICATE
+	inAuth    bool
+	mechanism string
+	encrypted bool
+
+	pending []*imapCommand
+
+	client imapParser
+	server imapParser
+}
+
+// Decode parses the stream according to the IMAP protocol.
+func (h *imapReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	// the merged fragments are ordered by time, so commands are processed before their responses
+	for _, d := range h.parent.merged {
+		if h.encrypted {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.client.read(&clientBuf, func(line string, literals [][]byte) {
+				h.handleCommand(line, literals, ts)
+			})
+		} else {
+			serverBuf.Write(d.raw)
+			h.server.read(&serverBuf, func(line string, literals [][]byte) {
+				h.handleResponse(line, literals, ts)
+			})
+		}
+	}
+
+	// write commands that did not receive a tagged response
+	for _, c := range h.pending {
+		c.record.Notes = addInfo(c.record.Notes, "no response")
+		h.write(c.record)
+	}
+
+	h.pending = nil
+}
+
+func (h *imapReader) handleCommand(line string, literals [][]byte, ts time.Time) {
+	if h.inAuth {
+		// the client can cancel the exchange with a single asterisk
+		if line != "*" {
+			h.authenticate(line)
+		}
+
+		return
+	}
+
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		// e.g. DONE to terminate IDLE
+		return
+	}
+
+	var (
+		tag  = parts[0]
+		cmd  = strings.ToUpper(parts[1])
+		args string
+	)
+
+	if len(parts) == 3 {
+		args = parts[2]
+	}
+
+	// the UID prefix changes the semantics of the message numbers
+	if cmd == "UID" {
+		sub := strings.SplitN(args, " ", 2)
+		cmd += " " + strings.ToUpper(sub[0])
+
+		args = ""
+		if len(sub) == 2 {
+			args = sub[1]
+		}
+	}
+
+	r := &types.IMAP{
+		Timestamp: utils.TimeToString(ts),
+		ClientIP:  h.parent.net.Src().String(),
+		ServerIP:  h.parent.net.Dst().String(),
+		Flow:      h.parent.ident,
+		Tag:       tag,
+		Command:   cmd,
+		Arguments: args,
+		Mailbox:   h.mailbox,
+		User:      h.user,
+	}
+
+	switch cmd {
+	case "LOGIN":
+		if a := imapArguments(args, literals); len(a) > 0 {
+			h.user = a[0]
+			r.User = h.user
+		}
+	case "AUTHENTICATE":
+		a := strings.Fields(args)
+		if len(a) > 0 {
+			h.mechanism = strings.ToUpper(a[0])
+		}
+
+		// initial response, RFC 4959
+		if len(a) > 1 {
+			h.authenticate(a[1])
+			r.User = h.user
+		} else {
+			h.inAuth = true
+		}
+	case "APPEND":
+		// the message is the last literal
+		if len(literals) > 0 {
+			mail := parseMail(h.parent.ident, literals[len(literals)-1])
+			saveMailAttachments(mail, serviceIMAP, h.parent, ts)
+			r.Mails = append(r.Mails, mail)
+		}
+	}
+
+	h.pending = append(h.pending, &imapCommand{record: r, ts: ts})
+}
+
+// authenticate extracts the user name from the base64 encoded SASL responses.
+func (h *imapReader) authenticate(line string) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil || len(data) == 0 {
+		return
+	}
+
+	switch h.mechanism {
+	case "PLAIN":
+		// authorization identity, user and password separated by null bytes
+		if parts := strings.Split(string(data), "\x00"); len(parts) == 3 {
+			h.user = parts[1]
+		}
+	case "LOGIN":
+		// the user is sent first, followed by the password
+		if h.user == "" {
+			h.user = string(data)
+		}
+	case "CRAM-MD5":
+		if fields := strings.Fields(string(data)); len(fields) == 2 {
+			h.user = fields[0]
+		}
+	}
+}
+
+func (h *imapReader) handleResponse(line string, literals [][]byte, ts time.Time) {
+	switch {
+	case strings.HasPrefix(line, "+"):
+		// continuation request
+		return
+	case strings.HasPrefix(line, "* "):
+		h.handleUntagged(line, literals, ts)
+
+		return
+	}
+
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return
+	}
+
+	var (
+		tag    = parts[0]
+		status = strings.ToUpper(parts[1])
+		msg    string
+	)
+
+	if len(parts) == 3 {
+		msg = parts[2]
+	}
+
+	for i, c := range h.pending {
+		if c.record.Tag != tag {
+			continue
+		}
+
+		h.pending = append(h.pending[:i], h.pending[i+1:]...)
+		h.complete(c.record, status, msg)
+
+		return
+	}
+}
+
+func (h *imapReader) handleUntagged(line string, literals [][]byte, ts time.Time) {
+	// service greeting
+	if h.banner == "" && len(h.pending) == 0 {
+		h.banner = line[2:]
+
+		return
+	}
+
+	if len(literals) == 0 || !strings.Contains(strings.ToUpper(line), " FETCH ") {
+		return
+	}
+
+	// locate the literals that contain a full message
+	var mails []*types.Mail
+
+	for i, m := range reIMAPLiteralItem.FindAllStringSubmatch(line, -1) {
+		if i >= len(literals) || len(literals[i]) == 0 || !isIMAPMessageItem(m[1]) {
+			continue
+		}
+
+		mail := parseMail(h.parent.ident, literals[i])
+		saveMailAttachments(mail, serviceIMAP, h.parent, ts)
+		mails = append(mails, mail)
+	}
+
+	if len(mails) == 0 {
+		return
+	}
+
+	// attach the messages to the pending FETCH command
+	for _, c := range h.pending {
+		if c.record.Command == "FETCH" || c.record.Command == "UID FETCH" {
+			c.record.Mails = append(c.record.Mails, mails...)
+
+			return
+		}
+	}
+
+	utils.DebugLog.Println("IMAP: unsolicited FETCH response on", h.parent.ident)
+}
+
+// complete processes the tagged response for a command and writes the audit record.
+func (h *imapReader) complete(r *types.IMAP, status, msg string) {
+	r.Status = status
+	r.StatusMessage = msg
+
+	switch r.Command {
+	case "AUTHENTICATE":
+		h.inAuth = false
+		r.User = h.user
+	case "SELECT", "EXAMINE":
+		if status == "OK" {
+			if a := imapArguments(r.Arguments, nil); len(a) > 0 {
+				h.mailbox = a[0]
+				r.Mailbox = h.mailbox
+			}
+		}
+	case "CLOSE", "UNSELECT":
+		if status == "OK" {
+			h.mailbox = ""
+		}
+	case "STARTTLS":
+		if status == "OK" {
+			r.Notes = addInfo(r.Notes, "connection encrypted")
+
+			// the remaining conversation is encrypted
+			h.encrypted = true
+		}
+	}
+
+	// do not keep failed logins
+	if (r.Command == "LOGIN" || r.Command == "AUTHENTICATE") && status != "OK" {
+		h.user = ""
+	}
+
+	h.write(r)
+}
+
+func (h *imapReader) write(r *types.IMAP) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&imapDecoder.numRecords, 1)
+
+	err := imapDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func TestIMAPReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		imapRecords = &recordCollector{}
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+	}()
+
+	conf = &Config{FileStorage: dir}
+	imapDecoder.writer = imapRecords
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+
+	// reuse the mail from the SMTP test without the terminating dot
+	mail := smtpTestMail[:len(smtpTestMail)-3]
+	fetch := "* 1 FETCH (UID 5 FLAGS (\\Seen) BODY[] {" + strconv.Itoa(len(mail)) + "}\r\n" + mail + ")\r\n"
+
+	c := newTestConnection(50000, 143,
+		[]byte{},
+		[]byte("* OK [CAPABILITY IMAP4rev1 STARTTLS] Dovecot ready.\r\n"),
+		[]byte("a1 LOGIN alice {6}\r\n"),
+		[]byte("+ OK\r\n"),
+		[]byte("secret\r\n"),
+		[]byte("a1 OK Logged in\r\n"),
+		[]byte("a2 SELECT \"INBOX\"\r\n"),
+		[]byte("* 1 EXISTS\r\n* OK [UIDVALIDITY 1] UIDs valid\r\na2 OK [READ-WRITE] Select completed.\r\n"),
+		[]byte("a3 UID FETCH 5 (FLAGS BODY.PEEK[])\r\n"),
+		// the literal is split across multiple segments
+		[]byte(fetch[:100]),
+		[]byte{},
+		[]byte(fetch[100:]+"a3 OK Fetch completed.\r\n"),
+		[]byte("a4 LOGOUT\r\n"),
+		[]byte("* BYE Logging out\r\na4 OK Logout completed.\r\n"),
+	)
+
+	(&imapReader{parent: c}).Decode()
+
+	if len(imapRecords.records) != 4 {
+		t.Fatal("expected 4 IMAP records, got", len(imapRecords.records))
+	}
+
+	login := imapRecords.records[0].(*types.IMAP)
+	if login.Command != "LOGIN" || login.User != "alice" || login.Status != "OK" {
+		t.Fatal("unexpected login:", login)
+	}
+
+	fetchRecord := imapRecords.records[2].(*types.IMAP)
+	if fetchRecord.Command != "UID FETCH" || fetchRecord.Mailbox != "INBOX" || fetchRecord.User != "alice" {
+		t.Fatal("unexpected fetch:", fetchRecord)
+	}
+
+	if len(fetchRecord.Mails) != 1 || fetchRecord.Mails[0].Subject != "Report" || !fetchRecord.Mails[0].HasAttachments {
+		t.Fatal("unexpected fetched mails:", fetchRecord.Mails)
+	}
+
+	logout := imapRecords.records[3].(*types.IMAP)
+	if logout.Command != "LOGOUT" || logout.Status != "OK" {
+		t.Fatal("unexpected logout:", logout)
+	}
+
+	if len(fileRecords.records) != 1 || fileRecords.records[0].(*types.File).Name != "notes.txt" {
+		t.Fatal("unexpected file records:", fileRecords.records)
+	}
+}
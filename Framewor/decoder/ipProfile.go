@@ -14,6 +14,7 @@
 package decoder
 
 import (
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -48,6 +49,63 @@ var ipProfiles = &atomicIPProfileMap{
 	Items: make(map[string]*ipProfile),
 }
 
+// ipAttributeMap contains values attributed to ip addresses by other protocols.
+// Addresses are usually announced or assigned before any other traffic of the host is seen,
+// so the values are stored until the profile is created.
+type ipAttributeMap struct {
+	Items map[string][]string
+	sync.Mutex
+}
+
+// add stores the value for the ip address and returns false if it was already present.
+func (a *ipAttributeMap) add(ipAddr string, value string) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	if containsString(a.Items[ipAddr], value) {
+		return false
+	}
+
+	a.Items[ipAddr] = append(a.Items[ipAddr], value)
+
+	return true
+}
+
+// get returns a copy of the values for the ip address.
+func (a *ipAttributeMap) get(ipAddr string) []string {
+	a.Lock()
+	defer a.Unlock()
+
+	return append([]string(nil), a.Items[ipAddr]...)
+}
+
+var (
+	// user names, e.g. from RADIUS accounting
+	ipUsers = &ipAttributeMap{
+		Items: make(map[string][]string),
+	}
+
+	// host names announced via mDNS, LLMNR or NBNS
+	ipNames = &ipAttributeMap{
+		Items: make(map[string][]string),
+	}
+
+	// RDP client fingerprints, each followed by a space and the client description
+	ipRDPFingerprints = &ipAttributeMap{
+		Items: make(map[string][]string),
+	}
+)
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 // wrapper for the types.IPProfile that can be locked.
 type ipProfile struct {
 	*types.IPProfile
@@ -223,20 +281,28 @@ func getIPProfile(ipAddr string, i *packetInfo) *ipProfile {
 		names = resolvers.LookupDNSNames(ipAddr)
 	}
 
+	for _, name := range ipNames.get(ipAddr) {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
 	// create new profile
 	p := &ipProfile{
 		IPProfile: &types.IPProfile{
-			Addr:           ipAddr,
-			NumPackets:     1,
-			Geolocation:    loc,
-			DNSNames:       names,
-			TimestampFirst: i.timestamp,
-			Ja3:            ja3Map,
-			Protocols:      protos,
-			Bytes:          dataLen,
-			SrcPorts:       srcPorts,
-			DstPorts:       dstPorts,
-			SNIs:           sniMap,
+			Addr:            ipAddr,
+			NumPackets:      1,
+			Geolocation:     loc,
+			DNSNames:        names,
+			TimestampFirst:  i.timestamp,
+			Ja3:             ja3Map,
+			Protocols:       protos,
+			Bytes:           dataLen,
+			SrcPorts:        srcPorts,
+			DstPorts:        dstPorts,
+			SNIs:            sniMap,
+			Users:           ipUsers.get(ipAddr),
+			RDPFingerprints: rdpFingerprints(ipAddr),
 		},
 	}
 
@@ -246,3 +312,84 @@ func getIPProfile(ipAddr string, i *packetInfo) *ipProfile {
 
 	return p
 }
+
+// addRDPFingerprint attributes a RDP client fingerprint to the given ip address.
+func addRDPFingerprint(ipAddr string, fingerprint string, client string) {
+	if !ipRDPFingerprints.add(ipAddr, fingerprint+" "+client) {
+		return
+	}
+
+	updateIPProfile(ipAddr, func(p *ipProfile) {
+		if p.RDPFingerprints == nil {
+			p.RDPFingerprints = make(map[string]string)
+		}
+
+		// the first client description seen for a fingerprint is kept
+		if _, ok := p.RDPFingerprints[fingerprint]; !ok {
+			p.RDPFingerprints[fingerprint] = client
+		}
+	})
+}
+
+// rdpFingerprints returns the RDP client fingerprints attributed to the given ip address.
+func rdpFingerprints(ipAddr string) map[string]string {
+	values := ipRDPFingerprints.get(ipAddr)
+	if len(values) == 0 {
+		return nil
+	}
+
+	fingerprints := make(map[string]string, len(values))
+
+	for _, v := range values {
+		parts := strings.SplitN(v, " ", 2)
+		if _, ok := fingerprints[parts[0]]; ok || len(parts) != 2 {
+			continue
+		}
+
+		fingerprints[parts[0]] = parts[1]
+	}
+
+	return fingerprints
+}
+
+// addIPProfileUser attributes a user name to the given ip address.
+func addIPProfileUser(ipAddr string, user string) {
+	if !ipUsers.add(ipAddr, user) {
+		return
+	}
+
+	updateIPProfile(ipAddr, func(p *ipProfile) {
+		// the profile could have been created with the user after it was added above
+		if !containsString(p.Users, user) {
+			p.Users = append(p.Users, user)
+		}
+	})
+}
+
+// addIPProfileName adds a host name announced by a local name resolution protocol to the given ip address.
+func addIPProfileName(ipAddr string, name string) {
+	if !ipNames.add(ipAddr, name) {
+		return
+	}
+
+	updateIPProfile(ipAddr, func(p *ipProfile) {
+		if !containsString(p.DNSNames, name) {
+			p.DNSNames = append(p.DNSNames, name)
+		}
+	})
+}
+
+// updateIPProfile calls the update function with the locked profile, if the profile exists.
+func updateIPProfile(ipAddr string, update func(p *ipProfile)) {
+	ipProfiles.Lock()
+	p, ok := ipProfiles.Items[ipAddr]
+	ipProfiles.Unlock()
+
+	if !ok {
+		return
+	}
+
+	p.Lock()
+	update(p)
+	p.Unlock()
+}
@@ -0,0 +1,450 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * Kerberos V5 - RFC 4120
+ */
+
+const (
+	serviceKerberos = "Kerberos"
+	kerberosPort    = 88
+
+	// application tags of the kerberos messages
+	krbASReq   = 10
+	krbASRep   = 11
+	krbTGSReq  = 12
+	krbTGSRep  = 13
+	krbError   = 30
+	krbPAEncTS = 2
+
+	// encryption types
+	krbAES128 = 17
+	krbAES256 = 18
+	krbRC4    = 23
+
+	// length of the HMAC in the cipher text
+	krbRC4ChecksumLen = 16
+	krbAESChecksumLen = 12
+)
+
+var krbMessageTypes = map[int]string{
+	krbASReq:  "AS-REQ",
+	krbASRep:  "AS-REP",
+	krbTGSReq: "TGS-REQ",
+	krbTGSRep: "TGS-REP",
+	krbError:  "KRB-ERROR",
+}
+
+var krbEncryptionTypes = map[int32]string{
+	1:         "des-cbc-crc",
+	3:         "des-cbc-md5",
+	krbAES128: "aes128-cts-hmac-sha1-96",
+	krbAES256: "aes256-cts-hmac-sha1-96",
+	krbRC4:    "rc4-hmac",
+	24:        "rc4-hmac-exp",
+}
+
+var krbErrorCodes = map[int32]string{
+	6:  "KDC_ERR_C_PRINCIPAL_UNKNOWN",
+	7:  "KDC_ERR_S_PRINCIPAL_UNKNOWN",
+	12: "KDC_ERR_POLICY",
+	13: "KDC_ERR_BADOPTION",
+	14: "KDC_ERR_ETYPE_NOSUPP",
+	18: "KDC_ERR_CLIENT_REVOKED",
+	23: "KDC_ERR_KEY_EXPIRED",
+	24: "KDC_ERR_PREAUTH_FAILED",
+	25: "KDC_ERR_PREAUTH_REQUIRED",
+	31: "KRB_AP_ERR_BAD_INTEGRITY",
+	32: "KRB_AP_ERR_TKT_EXPIRED",
+	37: "KRB_AP_ERR_SKEW",
+	41: "KRB_AP_ERR_MODIFIED",
+	52: "KRB_ERR_RESPONSE_TOO_BIG",
+	60: "KRB_ERR_GENERIC",
+	68: "KDC_ERR_WRONG_REALM",
+}
+
+func krbEncryptionTypeName(e int32) string {
+	if name, ok := krbEncryptionTypes[e]; ok {
+		return name
+	}
+
+	return strconv.Itoa(int(e))
+}
+
+func krbErrorName(code int32) string {
+	if name, ok := krbErrorCodes[code]; ok {
+		return name
+	}
+
+	return strconv.Itoa(int(code))
+}
+
+// ASN.1 structures, strings are GeneralString which is not supported by encoding/asn1
+// and are therefore decoded as raw values.
+// Note that an explicitly tagged raw value contains the complete inner element.
+
+// krbString returns the content of an explicitly tagged string.
+func krbString(v asn1.RawValue) string {
+	var inner asn1.RawValue
+
+	if _, err := asn1.Unmarshal(v.Bytes, &inner); err != nil {
+		return ""
+	}
+
+	return string(inner.Bytes)
+}
+
+type krbPrincipalName struct {
+	NameType   int32           `asn1:"explicit,tag:0"`
+	NameString []asn1.RawValue `asn1:"explicit,tag:1"`
+}
+
+func (p krbPrincipalName) String() string {
+	parts := make([]string, len(p.NameString))
+	for i, s := range p.NameString {
+		parts[i] = string(s.Bytes)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+type krbEncryptedData struct {
+	EType  int32  `asn1:"explicit,tag:0"`
+	KVNO   int    `asn1:"optional,explicit,tag:1"`
+	Cipher []byte `asn1:"explicit,tag:2"`
+}
+
+type krbPAData struct {
+	Type  int32  `asn1:"explicit,tag:1"`
+	Value []byte `asn1:"explicit,tag:2"`
+}
+
+type krbKDCReqBody struct {
+	KDCOptions asn1.BitString   `asn1:"explicit,tag:0"`
+	CName      krbPrincipalName `asn1:"optional,explicit,tag:1"`
+	Realm      asn1.RawValue    `asn1:"explicit,tag:2"`
+	SName      krbPrincipalName `asn1:"optional,explicit,tag:3"`
+	From       time.Time        `asn1:"generalized,optional,explicit,tag:4"`
+	Till       time.Time        `asn1:"generalized,explicit,tag:5"`
+	RTime      time.Time        `asn1:"generalized,optional,explicit,tag:6"`
+	Nonce      int64            `asn1:"explicit,tag:7"`
+	EType      []int32          `asn1:"explicit,tag:8"`
+}
+
+type krbKDCReq struct {
+	PVNO    int           `asn1:"explicit,tag:1"`
+	MsgType int           `asn1:"explicit,tag:2"`
+	PAData  []krbPAData   `asn1:"optional,explicit,tag:3"`
+	ReqBody krbKDCReqBody `asn1:"explicit,tag:4"`
+}
+
+type krbKDCRep struct {
+	PVNO    int              `asn1:"explicit,tag:0"`
+	MsgType int              `asn1:"explicit,tag:1"`
+	PAData  []krbPAData      `asn1:"optional,explicit,tag:2"`
+	CRealm  asn1.RawValue    `asn1:"explicit,tag:3"`
+	CName   krbPrincipalName `asn1:"explicit,tag:4"`
+	Ticket  asn1.RawValue    `asn1:"explicit,tag:5"`
+	EncPart krbEncryptedData `asn1:"explicit,tag:6"`
+}
+
+type krbTicket struct {
+	TktVNO  int              `asn1:"explicit,tag:0"`
+	Realm   asn1.RawValue    `asn1:"explicit,tag:1"`
+	SName   krbPrincipalName `asn1:"explicit,tag:2"`
+	EncPart krbEncryptedData `asn1:"explicit,tag:3"`
+}
+
+type krbErrorMessage struct {
+	PVNO      int              `asn1:"explicit,tag:0"`
+	MsgType   int              `asn1:"explicit,tag:1"`
+	CTime     time.Time        `asn1:"generalized,optional,explicit,tag:2"`
+	Cusec     int              `asn1:"optional,explicit,tag:3"`
+	STime     time.Time        `asn1:"generalized,explicit,tag:4"`
+	Susec     int              `asn1:"explicit,tag:5"`
+	ErrorCode int32            `asn1:"explicit,tag:6"`
+	CRealm    asn1.RawValue    `asn1:"optional,explicit,tag:7"`
+	CName     krbPrincipalName `asn1:"optional,explicit,tag:8"`
+	Realm     asn1.RawValue    `asn1:"explicit,tag:9"`
+	SName     krbPrincipalName `asn1:"explicit,tag:10"`
+	EText     asn1.RawValue    `asn1:"optional,explicit,tag:11"`
+}
+
+// krbApplicationTag returns the application tag of the message, or -1 if the data is not a kerberos message.
+func krbApplicationTag(data []byte) int {
+	if len(data) == 0 {
+		return -1
+	}
+
+	// application class, constructed
+	if data[0]&0xe0 != 0x60 {
+		return -1
+	}
+
+	tag := int(data[0] & 0x1f)
+	if _, ok := krbMessageTypes[tag]; !ok {
+		return -1
+	}
+
+	return tag
+}
+
+func krbParams(tag int) string {
+	return "application,explicit,tag:" + strconv.Itoa(tag)
+}
+
+// krbASREPHash formats the encrypted part of an AS-REP for hashcat.
+func krbASREPHash(user, realm string, ep *krbEncryptedData) (hash, mode string) {
+	c := ep.Cipher
+
+	switch ep.EType {
+	case krbRC4:
+		if len(c) <= krbRC4ChecksumLen {
+			return "", ""
+		}
+
+		return fmt.Sprintf("$krb5asrep$%d$%s@%s:%x$%x", ep.EType, user, realm, c[:krbRC4ChecksumLen], c[krbRC4ChecksumLen:]), "18200"
+	case krbAES128, krbAES256:
+		if len(c) <= krbAESChecksumLen {
+			return "", ""
+		}
+
+		mode = "32100"
+		if ep.EType == krbAES256 {
+			mode = "32200"
+		}
+
+		n := len(c) - krbAESChecksumLen
+
+		return fmt.Sprintf("$krb5asrep$%d$%s$%s$%x$%x", ep.EType, user, realm, c[n:], c[:n]), mode
+	}
+
+	return "", ""
+}
+
+// krbTGSREPHash formats the encrypted part of a service ticket for hashcat.
+func krbTGSREPHash(user, realm, spn string, ep *krbEncryptedData) (hash, mode string) {
+	var (
+		c = ep.Cipher
+
+		// colons are used as separator by hashcat
+		s = strings.ReplaceAll(spn, ":", "~")
+	)
+
+	switch ep.EType {
+	case krbRC4:
+		if len(c) <= krbRC4ChecksumLen {
+			return "", ""
+		}
+
+		return fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%x$%x", ep.EType, user, realm, s, c[:krbRC4ChecksumLen], c[krbRC4ChecksumLen:]), "13100"
+	case krbAES128, krbAES256:
+		if len(c) <= krbAESChecksumLen {
+			return "", ""
+		}
+
+		mode = "19600"
+		if ep.EType == krbAES256 {
+			mode = "19700"
+		}
+
+		n := len(c) - krbAESChecksumLen
+
+		return fmt.Sprintf("$krb5tgs$%d$%s$%s$*%s*$%x$%x", ep.EType, user, realm, s, c[n:], c[:n]), mode
+	}
+
+	return "", ""
+}
+
+// krbMessage is a decoded kerberos message and the crackable hash it contains.
+type krbMessage struct {
+	record *types.Kerberos
+	user   string
+	hash   string
+	mode   string
+}
+
+// parseKerberos decodes a kerberos message.
+func parseKerberos(data []byte) (*krbMessage, error) {
+	tag := krbApplicationTag(data)
+	if tag == -1 {
+		return nil, fmt.Errorf("not a kerberos message")
+	}
+
+	m := &krbMessage{
+		record: &types.Kerberos{
+			MessageType: krbMessageTypes[tag],
+		},
+	}
+	r := m.record
+
+	switch tag {
+	case krbASReq, krbTGSReq:
+		var req krbKDCReq
+
+		if _, err := asn1.UnmarshalWithParams(data, &req, krbParams(tag)); err != nil {
+			return nil, err
+		}
+
+		r.Realm = krbString(req.ReqBody.Realm)
+		r.ClientPrincipal = req.ReqBody.CName.String()
+		r.ServicePrincipal = req.ReqBody.SName.String()
+
+		for _, e := range req.ReqBody.EType {
+			r.EncryptionTypes = append(r.EncryptionTypes, krbEncryptionTypeName(e))
+		}
+
+		for _, pa := range req.PAData {
+			if pa.Type == krbPAEncTS {
+				r.PreAuthentication = true
+			}
+		}
+	case krbASRep, krbTGSRep:
+		var (
+			rep    krbKDCRep
+			ticket krbTicket
+		)
+
+		if _, err := asn1.UnmarshalWithParams(data, &rep, krbParams(tag)); err != nil {
+			return nil, err
+		}
+
+		if _, err := asn1.UnmarshalWithParams(rep.Ticket.Bytes, &ticket, krbParams(1)); err != nil {
+			return nil, err
+		}
+
+		r.Realm = krbString(rep.CRealm)
+		r.ClientPrincipal = rep.CName.String()
+		r.ServicePrincipal = ticket.SName.String()
+		r.EncryptionTypes = []string{krbEncryptionTypeName(rep.EncPart.EType)}
+		r.TicketEncryptionType = krbEncryptionTypeName(ticket.EncPart.EType)
+
+		m.user = r.ClientPrincipal + "@" + r.Realm
+
+		if tag == krbASRep {
+			// the encrypted part is protected with the key of the client
+			m.hash, m.mode = krbASREPHash(r.ClientPrincipal, r.Realm, &rep.EncPart)
+		} else if len(ticket.SName.NameString) > 0 && string(ticket.SName.NameString[0].Bytes) != "krbtgt" {
+			// the service ticket is protected with the key of the service account
+			m.hash, m.mode = krbTGSREPHash(r.ClientPrincipal, krbString(ticket.Realm), r.ServicePrincipal, &ticket.EncPart)
+		}
+
+		if m.hash != "" {
+			r.Notes = "hashcat mode " + m.mode
+		}
+	case krbError:
+		var e krbErrorMessage
+
+		if _, err := asn1.UnmarshalWithParams(data, &e, krbParams(tag)); err != nil {
+			return nil, err
+		}
+
+		r.Realm = krbString(e.Realm)
+		r.ClientPrincipal = e.CName.String()
+		r.ServicePrincipal = e.SName.String()
+		r.ErrorCode = e.ErrorCode
+		r.ErrorName = krbErrorName(e.ErrorCode)
+		r.Notes = krbString(e.EText)
+	}
+
+	return m, nil
+}
+
+// handleKerberos decodes a kerberos message and writes the hash into the credentials.
+// Requests are sent by the client, all other messages by the server.
+func handleKerberos(data []byte, src, dst, flow, transport string, ts time.Time) *types.Kerberos {
+	m, err := parseKerberos(data)
+	if err != nil {
+		utils.DebugLog.Println("failed to decode kerberos message:", err)
+
+		return nil
+	}
+
+	r := m.record
+	r.Timestamp = utils.TimeToString(ts)
+	r.Transport = transport
+	r.ClientIP, r.ServerIP = dst, src
+
+	if r.MessageType == krbMessageTypes[krbASReq] || r.MessageType == krbMessageTypes[krbTGSReq] {
+		r.ClientIP, r.ServerIP = src, dst
+	}
+
+	if m.hash != "" && useHarvesters {
+		writeCredentials(&types.Credentials{
+			Timestamp: r.Timestamp,
+			Service:   serviceKerberos,
+			Flow:      flow,
+			User:      m.user,
+			Password:  m.hash,
+			Notes:     r.MessageType + " " + r.Notes,
+		})
+	}
+
+	return r
+}
+
+var kerberosDecoder = newCustomDecoder(
+	types.Type_NC_Kerberos,
+	serviceKerberos,
+	"Kerberos is the authentication protocol used by Active Directory",
+	func(d *customDecoder) error {
+		streamFactory.decodeKerberos = true
+
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		// messages via TCP are handled by the kerberosReader
+		l := innerLayers(p)
+		if l.network == nil {
+			return nil
+		}
+
+		udp, ok := l.transport.(*layers.UDP)
+		if !ok || (udp.SrcPort != kerberosPort && udp.DstPort != kerberosPort) {
+			return nil
+		}
+
+		var (
+			nf = l.network.NetworkFlow()
+			tf = udp.TransportFlow()
+		)
+
+		r := handleKerberos(udp.Payload, nf.Src().String(), nf.Dst().String(), nf.String()+"-"+tf.String(), "UDP", p.Metadata().Timestamp)
+		if r == nil {
+			// prevent returning a typed nil
+			return nil
+		}
+
+		return r
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
@@ -0,0 +1,102 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// upper limit for kerberos messages via TCP, used to detect streams that are out of sync.
+const krbMaxRecordSize = 1024 * 1024
+
+// isKerberos checks if the data starts with a record marker followed by a kerberos reply.
+func isKerberos(data []byte) bool {
+	if len(data) < 5 {
+		return false
+	}
+
+	switch krbApplicationTag(data[4:]) {
+	case krbASRep, krbTGSRep, krbError:
+		return binary.BigEndian.Uint32(data[:4]) < krbMaxRecordSize
+	}
+
+	return false
+}
+
+type kerberosReader struct {
+	parent *tcpConnection
+}
+
+// Decode parses the stream according to the kerberos protocol.
+func (h *kerberosReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	for _, d := range h.parent.merged {
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readRecords(&clientBuf, h.parent.net.Src().String(), h.parent.net.Dst().String(), ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.readRecords(&serverBuf, h.parent.net.Dst().String(), h.parent.net.Src().String(), ts)
+		}
+	}
+}
+
+// readRecords consumes all complete messages from the buffer, each message is preceded by its length.
+func (h *kerberosReader) readRecords(buf *bytes.Buffer, src, dst string, ts time.Time) {
+	for buf.Len() >= 4 {
+		// the high bit is reserved
+		length := int(binary.BigEndian.Uint32(buf.Bytes()[:4]) & 0x7fffffff)
+		if length > krbMaxRecordSize {
+			// out of sync, drop the data
+			buf.Reset()
+
+			return
+		}
+
+		if buf.Len() < 4+length {
+			return
+		}
+
+		buf.Next(4)
+
+		if r := handleKerberos(buf.Next(length), src, dst, h.parent.ident, "TCP", ts); r != nil {
+			h.write(r)
+		}
+	}
+}
+
+func (h *kerberosReader) write(r *types.Kerberos) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&kerberosDecoder.numRecords, 1)
+
+	err := kerberosDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
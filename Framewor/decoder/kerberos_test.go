@@ -0,0 +1,190 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func krbTestString(s string) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: 27, Bytes: []byte(s)}
+}
+
+// krbTestExplicit creates an explicitly tagged string,
+// the tag must be set manually since encoding/asn1 ignores the field parameters for raw values.
+func krbTestExplicit(t *testing.T, tag int, s string) asn1.RawValue {
+	inner, err := asn1.Marshal(krbTestString(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: inner}
+}
+
+func krbTestPrincipal(parts ...string) krbPrincipalName {
+	p := krbPrincipalName{NameType: 1}
+	for _, s := range parts {
+		p.NameString = append(p.NameString, krbTestString(s))
+	}
+
+	return p
+}
+
+func krbTestMarshal(t *testing.T, v interface{}, tag int) []byte {
+	data, err := asn1.MarshalWithParams(v, krbParams(tag))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func krbTestReply(t *testing.T, tag int, user string, sname []string, repEType, ticketEType int32) []byte {
+	ticket := krbTestMarshal(t, krbTicket{
+		TktVNO: 5,
+		Realm:  krbTestExplicit(t, 1, "CORP.LOCAL"),
+		SName:  krbTestPrincipal(sname...),
+		EncPart: krbEncryptedData{
+			EType:  ticketEType,
+			KVNO:   2,
+			Cipher: bytes.Repeat([]byte{0xbb}, 40),
+		},
+	}, 1)
+
+	return krbTestMarshal(t, krbKDCRep{
+		PVNO:    5,
+		MsgType: tag,
+		CRealm:  krbTestExplicit(t, 3, "CORP.LOCAL"),
+		CName:   krbTestPrincipal(user),
+		Ticket:  asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 5, IsCompound: true, Bytes: ticket},
+		EncPart: krbEncryptedData{
+			EType:  repEType,
+			Cipher: append(bytes.Repeat([]byte{0x11}, 16), bytes.Repeat([]byte{0xaa}, 24)...),
+		},
+	}, tag)
+}
+
+func TestKerberos(t *testing.T) {
+	var (
+		krbRecords  = &recordCollector{}
+		credRecords = &recordCollector{}
+		oldConf     = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+	}()
+
+	conf = &Config{}
+	useHarvesters = true
+	kerberosDecoder.writer = krbRecords
+	credentialsDecoder.writer = credRecords
+
+	// AS-REQ with pre-authentication
+	req := krbTestMarshal(t, krbKDCReq{
+		PVNO:    5,
+		MsgType: krbASReq,
+		PAData:  []krbPAData{{Type: krbPAEncTS, Value: []byte{1, 2, 3}}},
+		ReqBody: krbKDCReqBody{
+			KDCOptions: asn1.BitString{Bytes: []byte{0x40, 0x81, 0, 0x10}, BitLength: 32},
+			CName:      krbTestPrincipal("alice"),
+			Realm:      krbTestExplicit(t, 2, "CORP.LOCAL"),
+			SName:      krbTestPrincipal("krbtgt", "CORP.LOCAL"),
+			Till:       time.Date(2037, 9, 13, 2, 48, 5, 0, time.UTC),
+			Nonce:      12345,
+			EType:      []int32{krbAES256, krbRC4},
+		},
+	}, krbASReq)
+
+	r := handleKerberos(req, "10.0.0.1", "10.0.0.2", "flow", "UDP", time.Unix(1600000000, 0))
+	if r == nil {
+		t.Fatal("failed to decode AS-REQ")
+	}
+
+	if r.MessageType != "AS-REQ" || r.ClientIP != "10.0.0.1" || r.ClientPrincipal != "alice" || r.ServicePrincipal != "krbtgt/CORP.LOCAL" {
+		t.Fatal("unexpected AS-REQ:", r)
+	}
+
+	if !r.PreAuthentication || strings.Join(r.EncryptionTypes, ",") != "aes256-cts-hmac-sha1-96,rc4-hmac" {
+		t.Fatal("unexpected AS-REQ details:", r.PreAuthentication, r.EncryptionTypes)
+	}
+
+	// AS-REP encrypted with RC4
+	r = handleKerberos(krbTestReply(t, krbASRep, "svc_backup", []string{"krbtgt", "CORP.LOCAL"}, krbRC4, krbAES256), "10.0.0.2", "10.0.0.1", "flow", "UDP", time.Unix(1600000000, 0))
+	if r == nil || r.MessageType != "AS-REP" || r.ClientIP != "10.0.0.1" || r.TicketEncryptionType != "aes256-cts-hmac-sha1-96" {
+		t.Fatal("unexpected AS-REP:", r)
+	}
+
+	// TGS-REP for a service account, received via TCP in two segments
+	rep := krbTestReply(t, krbTGSRep, "alice", []string{"MSSQLSvc", "db.corp.local:1433"}, krbAES256, krbAES256)
+	frame := make([]byte, 4)
+	binary.BigEndian.PutUint32(frame, uint32(len(rep)))
+	frame = append(frame, rep...)
+
+	if !isKerberos(frame) {
+		t.Fatal("kerberos stream not detected")
+	}
+
+	errMsg := krbTestMarshal(t, krbErrorMessage{
+		PVNO:      5,
+		MsgType:   krbError,
+		STime:     time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC),
+		ErrorCode: 25,
+		Realm:     krbTestExplicit(t, 9, "CORP.LOCAL"),
+		SName:     krbTestPrincipal("krbtgt", "CORP.LOCAL"),
+	}, krbError)
+	errFrame := make([]byte, 4)
+	binary.BigEndian.PutUint32(errFrame, uint32(len(errMsg)))
+	errFrame = append(errFrame, errMsg...)
+
+	c := newTestConnection(50000, 88, []byte{}, frame[:20], []byte{}, append(frame[20:], errFrame...))
+	(&kerberosReader{parent: c}).Decode()
+
+	if len(krbRecords.records) != 2 {
+		t.Fatal("expected 2 records from the TCP stream, got", len(krbRecords.records))
+	}
+
+	tgs := krbRecords.records[0].(*types.Kerberos)
+	if tgs.MessageType != "TGS-REP" || tgs.Transport != "TCP" || tgs.ServicePrincipal != "MSSQLSvc/db.corp.local:1433" || tgs.ClientIP != "10.0.0.1" {
+		t.Fatal("unexpected TGS-REP:", tgs)
+	}
+
+	krbErr := krbRecords.records[1].(*types.Kerberos)
+	if krbErr.ErrorCode != 25 || krbErr.ErrorName != "KDC_ERR_PREAUTH_REQUIRED" {
+		t.Fatal("unexpected KRB-ERROR:", krbErr)
+	}
+
+	if len(credRecords.records) != 2 {
+		t.Fatal("expected 2 credentials, got", len(credRecords.records))
+	}
+
+	asrep := credRecords.records[0].(*types.Credentials)
+	if asrep.Service != serviceKerberos || asrep.User != "svc_backup@CORP.LOCAL" ||
+		asrep.Password != "$krb5asrep$23$svc_backup@CORP.LOCAL:"+strings.Repeat("11", 16)+"$"+strings.Repeat("aa", 24) {
+		t.Fatal("unexpected AS-REP hash:", asrep.User, asrep.Password)
+	}
+
+	tgsHash := credRecords.records[1].(*types.Credentials)
+	if tgsHash.Password != "$krb5tgs$18$alice$CORP.LOCAL$*MSSQLSvc/db.corp.local~1433*$"+strings.Repeat("bb", 12)+"$"+strings.Repeat("bb", 28) {
+		t.Fatal("unexpected TGS-REP hash:", tgsHash.Password)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+/*
+ * LDAP - Lightweight Directory Access Protocol
+ */
+
+const (
+	serviceLDAP = "LDAP"
+
+	// protocol operations, the responses use the request tag + 1
+	ldapBindRequest     = 0
+	ldapBindResponse    = 1
+	ldapUnbindRequest   = 2
+	ldapSearchRequest   = 3
+	ldapSearchEntry     = 4
+	ldapSearchDone      = 5
+	ldapModifyRequest   = 6
+	ldapAddRequest      = 8
+	ldapDelRequest      = 10
+	ldapModifyDNRequest = 12
+	ldapCompareRequest  = 14
+	ldapAbandonRequest  = 16
+	ldapSearchReference = 19
+	ldapExtendedRequest = 23
+	ldapExtendedResp    = 24
+
+	// authentication choices of the bind request
+	ldapAuthSimple = 0
+	ldapAuthSASL   = 3
+
+	ldapResultSuccess = 0
+
+	ldapOIDStartTLS = "1.3.6.1.4.1.1466.20037"
+)
+
+var (
+	ldapOperations = map[int]string{
+		ldapBindRequest:     "Bind",
+		ldapSearchRequest:   "Search",
+		ldapModifyRequest:   "Modify",
+		ldapAddRequest:      "Add",
+		ldapDelRequest:      "Delete",
+		ldapModifyDNRequest: "ModifyDN",
+		ldapCompareRequest:  "Compare",
+		ldapExtendedRequest: "Extended",
+	}
+
+	ldapScopes = []string{
+		"baseObject",
+		"singleLevel",
+		"wholeSubtree",
+	}
+
+	ldapModifyOperations = []string{
+		"add",
+		"delete",
+		"replace",
+		"increment",
+	}
+
+	ldapResultCodes = map[int64]string{
+		0:  "success",
+		1:  "operationsError",
+		2:  "protocolError",
+		3:  "timeLimitExceeded",
+		4:  "sizeLimitExceeded",
+		5:  "compareFalse",
+		6:  "compareTrue",
+		7:  "authMethodNotSupported",
+		8:  "strongerAuthRequired",
+		10: "referral",
+		11: "adminLimitExceeded",
+		12: "unavailableCriticalExtension",
+		13: "confidentialityRequired",
+		14: "saslBindInProgress",
+		16: "noSuchAttribute",
+		17: "undefinedAttributeType",
+		18: "inappropriateMatching",
+		19: "constraintViolation",
+		20: "attributeOrValueExists",
+		21: "invalidAttributeSyntax",
+		32: "noSuchObject",
+		33: "aliasProblem",
+		34: "invalidDNSyntax",
+		36: "aliasDereferencingProblem",
+		48: "inappropriateAuthentication",
+		49: "invalidCredentials",
+		50: "insufficientAccessRights",
+		51: "busy",
+		52: "unavailable",
+		53: "unwillingToPerform",
+		54: "loopDetect",
+		64: "namingViolation",
+		65: "objectClassViolation",
+		66: "notAllowedOnNonLeaf",
+		67: "notAllowedOnRDN",
+		68: "entryAlreadyExists",
+		69: "objectClassModsProhibited",
+		71: "affectsMultipleDSAs",
+		80: "other",
+	}
+
+	// filter choices with an attribute value assertion
+	ldapFilterOperators = map[int]string{
+		3: "=",
+		5: ">=",
+		6: "<=",
+		8: "~=",
+	}
+)
+
+// ldapEscape escapes a value for the string representation of a filter (RFC 4515),
+// binary data such as SIDs is hex encoded.
+func ldapEscape(b []byte) string {
+	var s strings.Builder
+
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e || c == '*' || c == '(' || c == ')' || c == '\\' {
+			fmt.Fprintf(&s, "\\%02x", c)
+		} else {
+			s.WriteByte(c)
+		}
+	}
+
+	return s.String()
+}
+
+// ldapFilter returns the string representation of a search filter.
+func ldapFilter(e *berElement) string {
+	if e == nil || e.class != berClassContext {
+		return ""
+	}
+
+	switch e.tag {
+	case 0, 1: // and, or
+		var b strings.Builder
+
+		b.WriteString("(")
+
+		if e.tag == 0 {
+			b.WriteString("&")
+		} else {
+			b.WriteString("|")
+		}
+
+		for _, c := range e.children() {
+			b.WriteString(ldapFilter(c))
+		}
+
+		b.WriteString(")")
+
+		return b.String()
+	case 2: // not
+		if c := e.children(); len(c) > 0 {
+			return "(!" + ldapFilter(c[0]) + ")"
+		}
+	case 3, 5, 6, 8: // equalityMatch, greaterOrEqual, lessOrEqual, approxMatch
+		if c := e.children(); len(c) == 2 {
+			return "(" + string(c[0].data) + ldapFilterOperators[e.tag] + ldapEscape(c[1].data) + ")"
+		}
+	case 4: // substrings
+		c := e.children()
+		if len(c) != 2 {
+			break
+		}
+
+		var (
+			b     strings.Builder
+			parts = c[1].children()
+		)
+
+		b.WriteString("(" + string(c[0].data) + "=")
+
+		for i, p := range parts {
+			// any and final substrings are preceded by a wildcard
+			if p.tag != 0 || i > 0 {
+				b.WriteString("*")
+			}
+
+			b.WriteString(ldapEscape(p.data))
+		}
+
+		// a filter without final substring ends with a wildcard
+		if len(parts) == 0 || parts[len(parts)-1].tag != 2 {
+			b.WriteString("*")
+		}
+
+		b.WriteString(")")
+
+		return b.String()
+	case 7: // present
+		return "(" + string(e.data) + "=*)"
+	case 9: // extensibleMatch
+		var rule, typ, value, dn string
+
+		for _, c := range e.children() {
+			switch c.tag {
+			case 1:
+				rule = ":" + string(c.data)
+			case 2:
+				typ = string(c.data)
+			case 3:
+				value = ldapEscape(c.data)
+			case 4:
+				if berInteger(c.data) != 0 {
+					dn = ":dn"
+				}
+			}
+		}
+
+		return "(" + typ + dn + rule + ":=" + value + ")"
+	}
+
+	return "(?" + strconv.Itoa(e.tag) + ")"
+}
+
+// ldapAttribute formats an attribute with its values.
+func ldapAttribute(e *berElement) string {
+	c := e.children()
+	if len(c) == 0 {
+		return ""
+	}
+
+	var values []string
+	if len(c) > 1 {
+		for _, v := range c[1].children() {
+			values = append(values, ldapEscape(v.data))
+		}
+	}
+
+	if len(values) == 0 {
+		return string(c[0].data)
+	}
+
+	return string(c[0].data) + "=" + strings.Join(values, ",")
+}
+
+var ldapDecoder = newCustomDecoder(
+	types.Type_NC_LDAP,
+	serviceLDAP,
+	"The Lightweight Directory Access Protocol provides access to directory services such as Active Directory",
+	func(d *customDecoder) error {
+		streamFactory.decodeLDAP = true
+
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		return nil
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
@@ -0,0 +1,339 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// isLDAP checks if the server sent a LDAP response or the default ports are used.
+func isLDAP(banner []byte, transport gopacket.Flow) bool {
+	switch transport.Dst().String() {
+	case "389", "3268":
+		return true
+	}
+
+	// the banner can be truncated, so only the message header is checked:
+	// sequence, message id and the tag of the protocol operation
+	if len(banner) < 2 || banner[0] != 0x30 {
+		return false
+	}
+
+	off := 2
+	if banner[1]&0x80 != 0 {
+		off += int(banner[1] & 0x7f)
+	}
+
+	if len(banner) < off+2 || banner[off] != 0x02 {
+		return false
+	}
+
+	off += 2 + int(banner[off+1])
+	if len(banner) <= off {
+		return false
+	}
+
+	// responses have odd operation tags, search results use 4 and 19
+	op := int(banner[off] & 0x1f)
+
+	return banner[off]>>6 == berClassApplication && (op%2 == 1 || op == ldapSearchEntry) && op <= ldapExtendedResp
+}
+
+type ldapRequest struct {
+	record   *types.LDAP
+	password string
+}
+
+type ldapReader struct {
+	parent *tcpConnection
+
+	pending []*ldapRequest
+
+	// set once the conversation can no longer be parsed, e.g. after StartTLS
+	done bool
+}
+
+// Decode parses the stream according to the LDAP protocol.
+func (h *ldapReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	// the merged fragments are ordered by time, so requests are processed before their responses
+	for _, d := range h.parent.merged {
+		if h.done {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readMessages(&clientBuf, ts, h.handleRequest)
+		} else {
+			serverBuf.Write(d.raw)
+			h.readMessages(&serverBuf, ts, h.handleResponse)
+		}
+	}
+
+	// write requests that did not receive a response
+	sort.Slice(h.pending, func(i, j int) bool {
+		return h.pending[i].record.MessageID < h.pending[j].record.MessageID
+	})
+
+	for _, r := range h.pending {
+		r.record.Notes = addInfo(r.record.Notes, "no response")
+		h.complete(r)
+	}
+
+	h.pending = nil
+
+	// NTLM is used for SASL binds in Active Directory environments
+	if useHarvesters {
+		harvestNTLM(h.parent)
+	}
+}
+
+// readMessages consumes all complete LDAP messages from the buffer.
+func (h *ldapReader) readMessages(buf *bytes.Buffer, ts time.Time, handle func(id int32, op *berElement, ts time.Time)) {
+	for buf.Len() > 0 && !h.done {
+		msg, rest, err := readBER(buf.Bytes())
+		if err == errBERTruncated {
+			return
+		}
+
+		if err != nil || !msg.is(berClassUniversal, 0x10) {
+			// the messages are wrapped by a SASL security layer or the stream is out of sync
+			utils.DebugLog.Println("LDAP: failed to parse message on", h.parent.ident, err)
+
+			h.done = true
+
+			return
+		}
+
+		buf.Next(buf.Len() - len(rest))
+
+		c := msg.children()
+		if len(c) < 2 || !c[0].is(berClassUniversal, 2) || c[1].class != berClassApplication {
+			continue
+		}
+
+		handle(int32(berInteger(c[0].data)), c[1], ts)
+	}
+}
+
+func (h *ldapReader) handleRequest(id int32, op *berElement, ts time.Time) {
+	name, ok := ldapOperations[op.tag]
+	if !ok {
+		// unbind and abandon requests do not receive a response
+		return
+	}
+
+	var (
+		c = op.children()
+		r = &ldapRequest{
+			record: &types.LDAP{
+				Timestamp: utils.TimeToString(ts),
+				ClientIP:  h.parent.net.Src().String(),
+				ServerIP:  h.parent.net.Dst().String(),
+				Flow:      h.parent.ident,
+				MessageID: id,
+				Operation: name,
+			},
+		}
+		rec = r.record
+	)
+
+	switch op.tag {
+	case ldapBindRequest:
+		if len(c) < 3 {
+			break
+		}
+
+		rec.DN = string(c[1].data)
+
+		switch {
+		case c[2].is(berClassContext, ldapAuthSimple):
+			rec.AuthMechanism = "simple"
+			r.password = string(c[2].data)
+
+			if rec.DN == "" && r.password == "" {
+				rec.Notes = "anonymous"
+			}
+		case c[2].is(berClassContext, ldapAuthSASL):
+			if sasl := c[2].children(); len(sasl) > 0 {
+				rec.AuthMechanism = string(sasl[0].data)
+			}
+		}
+	case ldapSearchRequest:
+		if len(c) < 8 {
+			break
+		}
+
+		rec.DN = string(c[0].data)
+
+		if scope := berInteger(c[1].data); scope >= 0 && scope < int64(len(ldapScopes)) {
+			rec.Scope = ldapScopes[scope]
+		}
+
+		rec.Filter = ldapFilter(c[6])
+
+		for _, a := range c[7].children() {
+			rec.Attributes = append(rec.Attributes, string(a.data))
+		}
+	case ldapModifyRequest:
+		if len(c) < 2 {
+			break
+		}
+
+		rec.DN = string(c[0].data)
+
+		for _, change := range c[1].children() {
+			cc := change.children()
+			if len(cc) < 2 {
+				continue
+			}
+
+			mod := "unknown"
+			if o := berInteger(cc[0].data); o >= 0 && o < int64(len(ldapModifyOperations)) {
+				mod = ldapModifyOperations[o]
+			}
+
+			rec.Modifications = append(rec.Modifications, mod+": "+ldapAttribute(cc[1]))
+		}
+	case ldapAddRequest:
+		if len(c) < 2 {
+			break
+		}
+
+		rec.DN = string(c[0].data)
+
+		for _, a := range c[1].children() {
+			rec.Modifications = append(rec.Modifications, "add: "+ldapAttribute(a))
+		}
+	case ldapDelRequest:
+		// the DN is encoded directly in the operation
+		rec.DN = string(op.data)
+	case ldapModifyDNRequest:
+		if len(c) < 3 {
+			break
+		}
+
+		rec.DN = string(c[0].data)
+		rec.Modifications = append(rec.Modifications, "newrdn: "+string(c[1].data))
+
+		if len(c) > 3 && c[3].is(berClassContext, 0) {
+			rec.Modifications = append(rec.Modifications, "newSuperior: "+string(c[3].data))
+		}
+	case ldapCompareRequest:
+		if len(c) < 2 {
+			break
+		}
+
+		rec.DN = string(c[0].data)
+
+		if ava := c[1].children(); len(ava) == 2 {
+			rec.Filter = "(" + string(ava[0].data) + "=" + ldapEscape(ava[1].data) + ")"
+		}
+	case ldapExtendedRequest:
+		if len(c) > 0 && c[0].is(berClassContext, 0) {
+			rec.Notes = "request: " + string(c[0].data)
+		}
+	}
+
+	h.pending = append(h.pending, r)
+}
+
+func (h *ldapReader) handleResponse(id int32, op *berElement, ts time.Time) {
+	var r *ldapRequest
+
+	for i, p := range h.pending {
+		if p.record.MessageID == id {
+			r = p
+
+			// search results do not complete the request
+			if op.tag != ldapSearchEntry && op.tag != ldapSearchReference {
+				h.pending = append(h.pending[:i], h.pending[i+1:]...)
+			}
+
+			break
+		}
+	}
+
+	if r == nil {
+		utils.DebugLog.Println("LDAP: response without request on", h.parent.ident, id)
+
+		return
+	}
+
+	switch op.tag {
+	case ldapSearchEntry, ldapSearchReference:
+		r.record.NumEntries++
+
+		return
+	}
+
+	// LDAPResult: resultCode, matchedDN, diagnosticMessage
+	if c := op.children(); len(c) >= 3 {
+		code := berInteger(c[0].data)
+
+		r.record.ResultCode = int32(code)
+		r.record.ResultName = ldapResultCodes[code]
+		r.record.DiagnosticMessage = string(c[2].data)
+	}
+
+	if r.record.Operation == ldapOperations[ldapExtendedRequest] && r.record.Notes == "request: "+ldapOIDStartTLS && r.record.ResultCode == ldapResultSuccess {
+		r.record.Notes = addInfo(r.record.Notes, "connection encrypted")
+
+		// the remaining conversation is encrypted
+		h.done = true
+	}
+
+	h.complete(r)
+}
+
+// complete writes the audit record and the credentials of simple binds.
+func (h *ldapReader) complete(r *ldapRequest) {
+	if r.password != "" && useHarvesters {
+		writeCredentials(&types.Credentials{
+			Timestamp: r.record.Timestamp,
+			Service:   serviceLDAP,
+			Flow:      h.parent.ident,
+			User:      r.record.DN,
+			Password:  r.password,
+			Notes:     r.record.ResultName,
+		})
+	}
+
+	if conf.ExportMetrics {
+		r.record.Inc()
+	}
+
+	atomic.AddInt64(&ldapDecoder.numRecords, 1)
+
+	err := ldapDecoder.writer.Write(r.record)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
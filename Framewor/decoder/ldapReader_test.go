@@ -0,0 +1,172 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// berTest encodes a BER element with the given identifier octet.
+func berTest(id byte, content ...[]byte) []byte {
+	var data []byte
+	for _, c := range content {
+		data = append(data, c...)
+	}
+
+	out := []byte{id}
+	if len(data) < 0x80 {
+		out = append(out, byte(len(data)))
+	} else {
+		out = append(out, 0x82, byte(len(data)>>8), byte(len(data)))
+	}
+
+	return append(out, data...)
+}
+
+func berTestString(s string) []byte {
+	return berTest(0x04, []byte(s))
+}
+
+func ldapTestMessage(id byte, op []byte) []byte {
+	return berTest(0x30, berTest(0x02, []byte{id}), op)
+}
+
+func ldapTestResult(tag byte, code byte) []byte {
+	return berTest(0x60|tag, berTest(0x0a, []byte{code}), berTestString(""), berTestString(""))
+}
+
+func TestLDAPReader(t *testing.T) {
+	var (
+		ldapRecords = &recordCollector{}
+		credRecords = &recordCollector{}
+		oldConf     = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+	}()
+
+	conf = &Config{}
+	useHarvesters = true
+	ldapDecoder.writer = ldapRecords
+	credentialsDecoder.writer = credRecords
+
+	var (
+		bind = ldapTestMessage(1, berTest(0x60,
+			berTest(0x02, []byte{3}),
+			berTestString("cn=admin,dc=corp,dc=local"),
+			berTest(0x80, []byte("ldap-secret")),
+		))
+		filter = berTest(0xa0,
+			berTest(0xa3, berTestString("objectClass"), berTestString("user")),
+			berTest(0xa4, berTestString("cn"), berTest(0x30, berTest(0x80, []byte("adm")))),
+			berTest(0x87, []byte("mail")),
+		)
+		search = ldapTestMessage(2, berTest(0x63,
+			berTestString("dc=corp,dc=local"),
+			berTest(0x0a, []byte{2}),
+			berTest(0x0a, []byte{0}),
+			berTest(0x02, []byte{0}),
+			berTest(0x02, []byte{0}),
+			berTest(0x01, []byte{0}),
+			filter,
+			berTest(0x30, berTestString("cn"), berTestString("memberOf")),
+		))
+		entry  = ldapTestMessage(2, berTest(0x64, berTestString("cn=administrator,dc=corp,dc=local"), berTest(0x30)))
+		modify = ldapTestMessage(3, berTest(0x66,
+			berTestString("cn=bob,dc=corp,dc=local"),
+			berTest(0x30, berTest(0x30,
+				berTest(0x0a, []byte{2}),
+				berTest(0x30, berTestString("description"), berTest(0x31, berTestString("owned"))),
+			)),
+		))
+		startTLS = ldapTestMessage(4, berTest(0x77, berTest(0x80, []byte(ldapOIDStartTLS))))
+	)
+
+	if !isLDAP(ldapTestMessage(1, ldapTestResult(1, 0))[:6], newTestConnection(50000, 10389).transport) {
+		t.Fatal("failed to detect LDAP")
+	}
+
+	// the search request is split into two segments
+	c := newTestConnection(50000, 389,
+		append(bind, search[:10]...),
+		ldapTestMessage(1, ldapTestResult(1, 0)),
+		search[10:],
+		append(append(append([]byte{}, entry...), entry...), ldapTestMessage(2, ldapTestResult(5, 0))...),
+		append(modify, startTLS...),
+		append(ldapTestMessage(3, ldapTestResult(7, 50)), ldapTestMessage(4, ldapTestResult(24, 0))...),
+		ldapTestMessage(5, berTest(0x42, []byte("encrypted"))),
+	)
+
+	(&ldapReader{parent: c}).Decode()
+
+	if len(ldapRecords.records) != 4 {
+		t.Fatal("expected 4 LDAP records, got", len(ldapRecords.records))
+	}
+
+	r := ldapRecords.records[0].(*types.LDAP)
+	if r.Operation != "Bind" || r.DN != "cn=admin,dc=corp,dc=local" || r.AuthMechanism != "simple" || r.ResultName != "success" {
+		t.Fatal("unexpected bind record:", r)
+	}
+
+	r = ldapRecords.records[1].(*types.LDAP)
+	if r.Operation != "Search" || r.DN != "dc=corp,dc=local" || r.Scope != "wholeSubtree" || r.NumEntries != 2 {
+		t.Fatal("unexpected search record:", r)
+	}
+
+	if r.Filter != "(&(objectClass=user)(cn=adm*)(mail=*))" || strings.Join(r.Attributes, ",") != "cn,memberOf" {
+		t.Fatal("unexpected search filter or attributes:", r.Filter, r.Attributes)
+	}
+
+	r = ldapRecords.records[2].(*types.LDAP)
+	if r.Operation != "Modify" || len(r.Modifications) != 1 || r.Modifications[0] != "replace: description=owned" || r.ResultName != "insufficientAccessRights" {
+		t.Fatal("unexpected modify record:", r)
+	}
+
+	r = ldapRecords.records[3].(*types.LDAP)
+	if r.Operation != "Extended" || !strings.Contains(r.Notes, "connection encrypted") {
+		t.Fatal("unexpected extended record:", r)
+	}
+
+	if len(credRecords.records) != 1 {
+		t.Fatal("expected 1 credential, got", len(credRecords.records))
+	}
+
+	creds := credRecords.records[0].(*types.Credentials)
+	if creds.Service != serviceLDAP || creds.User != "cn=admin,dc=corp,dc=local" || creds.Password != "ldap-secret" || creds.Notes != "success" {
+		t.Fatal("unexpected credentials:", creds)
+	}
+}
+
+func TestLDAPFilter(t *testing.T) {
+	for expected, data := range map[string][]byte{
+		"(!(uid=a\\2ab))":            berTest(0xa2, berTest(0xa3, berTestString("uid"), berTestString("a*b"))),
+		"(cn=*mid*end)":              berTest(0xa4, berTestString("cn"), berTest(0x30, berTest(0x81, []byte("mid")), berTest(0x82, []byte("end")))),
+		"(|(age>=18)(sid=\\01\\05))": berTest(0xa1, berTest(0xa5, berTestString("age"), berTestString("18")), berTest(0xa3, berTestString("sid"), berTest(0x04, []byte{1, 5}))),
+		"(ou:dn:=Sales)":             berTest(0xa9, berTest(0x82, []byte("ou")), berTest(0x83, []byte("Sales")), berTest(0x84, []byte{0xff})),
+	} {
+		e, _, err := readBER(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f := ldapFilter(e); f != expected {
+			t.Fatal("expected", expected, "got", f)
+		}
+	}
+}
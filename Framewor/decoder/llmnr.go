@@ -0,0 +1,87 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * LLMNR - Link-Local Multicast Name Resolution
+ */
+
+const (
+	serviceLLMNR = "LLMNR"
+
+	llmnrPort = 5355
+)
+
+// parseLLMNR decodes the queried names and the announced addresses of a LLMNR packet.
+func parseLLMNR(data []byte) (*types.LLMNR, error) {
+	dns, err := decodeDNS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &types.LLMNR{
+		Response:  dns.QR,
+		Questions: dnsQuestions(dns.Questions),
+	}
+
+	if dns.QR {
+		r.Hosts = dnsHosts(dns.Answers)
+
+		// the conflict bit is set by responders that detected a name conflict
+		if dns.AA {
+			r.Notes = "conflict"
+		}
+	}
+
+	return r, nil
+}
+
+var llmnrDecoder = newCustomDecoder(
+	types.Type_NC_LLMNR,
+	serviceLLMNR,
+	"Link-Local Multicast Name Resolution resolves host names of Windows machines in local networks",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		udp, srcIP, dstIP := udpLayerForPort(p, llmnrPort)
+		if udp == nil {
+			return nil
+		}
+
+		r, err := parseLLMNR(udp.Payload)
+		if err != nil {
+			utils.DebugLog.Println("LLMNR: failed to decode packet:", err)
+
+			return nil
+		}
+
+		r.Timestamp = utils.TimeToString(p.Metadata().Timestamp)
+		r.SrcIP = srcIP
+		r.DstIP = dstIP
+
+		return r
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
@@ -0,0 +1,65 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+func TestLLMNR(t *testing.T) {
+	defer func() {
+		ipNames.Lock()
+		delete(ipNames.Items, "192.168.1.30")
+		ipNames.Unlock()
+	}()
+
+	data := dnsTestSerialize(t, &layers.DNS{
+		QR:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte("fileserver"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte("fileserver"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, IP: net.IP{192, 168, 1, 30}},
+		},
+	})
+
+	r, err := parseLLMNR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.Response || strings.Join(r.Questions, ",") != "fileserver" || strings.Join(r.Hosts, ",") != "fileserver=192.168.1.30" {
+		t.Fatal("unexpected LLMNR record:", r)
+	}
+
+	// the name is added to the profile once it is created
+	if names := ipNames.get("192.168.1.30"); len(names) != 1 || names[0] != "fileserver" {
+		t.Fatal("unexpected names for address:", names)
+	}
+}
+
+func TestLLMNRMalformed(t *testing.T) {
+	// the DNS layer of gopacket panics when this message is decoded directly
+	data := []byte("\x00\x00\x80\x00\x00\x01\x00\x00\x00\x00\x00\b\x00\x00\x7f\xff")
+
+	if _, err := parseLLMNR(data); err == nil {
+		t.Fatal("expected an error for a malformed LLMNR packet")
+	}
+
+	if _, err := parseMDNS(data); err == nil {
+		t.Fatal("expected an error for a malformed mDNS packet")
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// mailPartHeader returns the value of the header field, ignoring the case of the field name.
+func mailPartHeader(part *types.MailPart, name string) string {
+	for k, v := range part.Header {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// mailPartFilename returns the filename of an attachment,
+// or an empty string if the part is not an attachment.
+func mailPartFilename(part *types.MailPart) string {
+	if part.Filename != "" {
+		return part.Filename
+	}
+
+	if _, params, err := mime.ParseMediaType(mailPartHeader(part, "Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+
+	if strings.Contains(mailPartHeader(part, "Content-Disposition"), "attachment") {
+		if _, params, err := mime.ParseMediaType(mailPartHeader(part, headerContentType)); err == nil && params["name"] != "" {
+			return params["name"]
+		}
+
+		return "attachment"
+	}
+
+	return ""
+}
+
+// decodeMailPart decodes the content of a mail part according to its transfer encoding.
+func decodeMailPart(part *types.MailPart) []byte {
+	switch strings.ToLower(strings.TrimSpace(mailPartHeader(part, "Content-Transfer-Encoding"))) {
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(part.Content), ""))
+		if err != nil {
+			utils.DebugLog.Println("failed to decode base64 mail part:", err)
+		}
+
+		return data
+	case "quoted-printable":
+		data, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(part.Content)))
+		if err != nil {
+			utils.DebugLog.Println("failed to decode quoted-printable mail part:", err)
+		}
+
+		return data
+	default:
+		return []byte(part.Content)
+	}
+}
+
+// saveMailAttachments extracts the attachments of a mail transferred over the connection.
+func saveMailAttachments(mail *types.Mail, service string, c *tcpConnection, ts time.Time) {
+	for _, part := range mail.Body {
+		name := mailPartFilename(part)
+		if name == "" {
+			continue
+		}
+
+		err := saveExtractedFile(&types.File{
+			Timestamp: utils.TimeToString(ts),
+			Name:      name,
+			Ident:     c.ident,
+			Source:    service + " attachment " + mail.Subject,
+			Host:      c.net.Dst().String(),
+			Context: &types.PacketContext{
+				SrcIP:   c.net.Src().String(),
+				DstIP:   c.net.Dst().String(),
+				SrcPort: c.transport.Src().String(),
+				DstPort: c.transport.Dst().String(),
+			},
+		}, decodeMailPart(part))
+		if err != nil {
+			logReassemblyError(service+"-save", "%s: failed to save attachment %s: %s\n", c.ident, name, err)
+		}
+	}
+}
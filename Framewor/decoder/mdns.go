@@ -0,0 +1,210 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * mDNS - Multicast DNS and DNS based service discovery
+ */
+
+const (
+	serviceMDNS = "mDNS"
+
+	mdnsPort = 5353
+
+	// PTR records for this name enumerate the available service types
+	dnsSDServices = "_services._dns-sd._udp.local"
+)
+
+var errDNSInvalidPacket = errors.New("invalid DNS packet")
+
+// decodeDNS decodes a DNS message through gopacket, which recovers from panics of the DNS layer on malformed input.
+func decodeDNS(data []byte) (*layers.DNS, error) {
+	p := gopacket.NewPacket(data, layers.LayerTypeDNS, gopacket.NoCopy)
+	if errLayer := p.ErrorLayer(); errLayer != nil {
+		return nil, errLayer.Error()
+	}
+
+	dns, ok := p.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if !ok {
+		return nil, errDNSInvalidPacket
+	}
+
+	return dns, nil
+}
+
+// dnsHosts returns the address records as hostname=address
+// and adds the names to the profiles of the announced addresses.
+func dnsHosts(records ...[]layers.DNSResourceRecord) (hosts []string) {
+	for _, rrs := range records {
+		for _, rr := range rrs {
+			if (rr.Type != layers.DNSTypeA && rr.Type != layers.DNSTypeAAAA) || rr.IP == nil || len(rr.Name) == 0 {
+				continue
+			}
+
+			var (
+				name = string(rr.Name)
+				addr = rr.IP.String()
+				host = name + "=" + addr
+			)
+
+			if !containsString(hosts, host) {
+				hosts = append(hosts, host)
+				addIPProfileName(addr, name)
+			}
+		}
+	}
+
+	return hosts
+}
+
+// dnsQuestions returns the names of the questions.
+func dnsQuestions(questions []layers.DNSQuestion) (names []string) {
+	for _, q := range questions {
+		names = append(names, string(q.Name))
+	}
+
+	return names
+}
+
+// isDNSSDService checks if the name is a service type, e.g. _ipp._tcp.local.
+func isDNSSDService(name string) bool {
+	return strings.HasPrefix(name, "_") && (strings.Contains(name, "._tcp.") || strings.Contains(name, "._udp."))
+}
+
+// parseMDNS decodes the announced hosts, services and service instances of a mDNS packet.
+func parseMDNS(data []byte) (*types.MDNS, error) {
+	dns, err := decodeDNS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		r = &types.MDNS{
+			Response:  dns.QR,
+			Questions: dnsQuestions(dns.Questions),
+			Hosts:     dnsHosts(dns.Answers, dns.Additionals),
+		}
+		add = func(values []string, value string) []string {
+			if containsString(values, value) {
+				return values
+			}
+
+			return append(values, value)
+		}
+		instances = make(map[string]string)
+	)
+
+	for _, rrs := range [][]layers.DNSResourceRecord{dns.Answers, dns.Authorities, dns.Additionals} {
+		for _, rr := range rrs {
+			name := string(rr.Name)
+
+			switch rr.Type {
+			case layers.DNSTypePTR:
+				switch {
+				case name == dnsSDServices:
+					r.Services = add(r.Services, string(rr.PTR))
+				case isDNSSDService(name):
+					r.Services = add(r.Services, name)
+
+					if _, ok := instances[string(rr.PTR)]; !ok {
+						instances[string(rr.PTR)] = ""
+					}
+				}
+			case layers.DNSTypeSRV:
+				instances[name] = string(rr.SRV.Name) + ":" + strconv.Itoa(int(rr.SRV.Port))
+			case layers.DNSTypeTXT:
+				for _, txt := range rr.TXTs {
+					if len(txt) > 0 {
+						r.TXT = add(r.TXT, string(txt))
+					}
+				}
+			}
+		}
+	}
+
+	for instance, target := range instances {
+		if target != "" {
+			instance += "=" + target
+		}
+
+		r.Instances = append(r.Instances, instance)
+	}
+
+	sort.Strings(r.Instances)
+
+	return r, nil
+}
+
+// udpLayerForPort returns the UDP layer and the ip addresses of a packet sent from or to the given port.
+// The layers of tunneled packets are taken from the innermost packet.
+func udpLayerForPort(p gopacket.Packet, port layers.UDPPort) (udp *layers.UDP, srcIP, dstIP string) {
+	l := innerLayers(p)
+	if l.network == nil {
+		return nil, "", ""
+	}
+
+	udp, ok := l.transport.(*layers.UDP)
+	if !ok || (udp.SrcPort != port && udp.DstPort != port) {
+		return nil, "", ""
+	}
+
+	nf := l.network.NetworkFlow()
+
+	return udp, nf.Src().String(), nf.Dst().String()
+}
+
+var mdnsDecoder = newCustomDecoder(
+	types.Type_NC_MDNS,
+	serviceMDNS,
+	"Multicast DNS resolves host names and discovers services in local networks without a DNS server",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		udp, srcIP, dstIP := udpLayerForPort(p, mdnsPort)
+		if udp == nil {
+			return nil
+		}
+
+		r, err := parseMDNS(udp.Payload)
+		if err != nil {
+			utils.DebugLog.Println("mDNS: failed to decode packet:", err)
+
+			return nil
+		}
+
+		r.Timestamp = utils.TimeToString(p.Metadata().Timestamp)
+		r.SrcIP = srcIP
+		r.DstIP = dstIP
+
+		return r
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
@@ -0,0 +1,85 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func dnsTestSerialize(t *testing.T, dns *layers.DNS) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestMDNS(t *testing.T) {
+	defer func() {
+		ipNames.Lock()
+		delete(ipNames.Items, "192.168.1.20")
+		ipNames.Unlock()
+
+		ipProfiles.Lock()
+		delete(ipProfiles.Items, "192.168.1.20")
+		ipProfiles.Unlock()
+	}()
+
+	ipProfiles.Lock()
+	ipProfiles.Items["192.168.1.20"] = &ipProfile{IPProfile: &types.IPProfile{Addr: "192.168.1.20"}}
+	ipProfiles.Unlock()
+
+	var (
+		class    = layers.DNSClass(0x8001) // IN with the cache flush bit
+		instance = []byte("Office Printer._ipp._tcp.local")
+		data     = dnsTestSerialize(t, &layers.DNS{
+			QR: true,
+			AA: true,
+			Answers: []layers.DNSResourceRecord{
+				{Name: []byte(dnsSDServices), Type: layers.DNSTypePTR, Class: layers.DNSClassIN, PTR: []byte("_ipp._tcp.local")},
+				{Name: []byte("_ipp._tcp.local"), Type: layers.DNSTypePTR, Class: layers.DNSClassIN, PTR: instance},
+				{Name: instance, Type: layers.DNSTypeSRV, Class: class, SRV: layers.DNSSRV{Port: 631, Name: []byte("printer.local")}},
+				{Name: instance, Type: layers.DNSTypeTXT, Class: class, TXTs: [][]byte{[]byte("ty=LaserJet 400"), []byte("rp=ipp/print")}},
+			},
+			Additionals: []layers.DNSResourceRecord{
+				{Name: []byte("printer.local"), Type: layers.DNSTypeA, Class: class, IP: net.IP{192, 168, 1, 20}},
+			},
+		})
+	)
+
+	r, err := parseMDNS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.Response || strings.Join(r.Hosts, ",") != "printer.local=192.168.1.20" || strings.Join(r.Services, ",") != "_ipp._tcp.local" {
+		t.Fatal("unexpected mDNS record:", r)
+	}
+
+	if strings.Join(r.Instances, ",") != "Office Printer._ipp._tcp.local=printer.local:631" || strings.Join(r.TXT, ",") != "ty=LaserJet 400,rp=ipp/print" {
+		t.Fatal("unexpected service instances:", r.Instances, r.TXT)
+	}
+
+	if p := ipProfiles.Items["192.168.1.20"]; len(p.DNSNames) != 1 || p.DNSNames[0] != "printer.local" {
+		t.Fatal("unexpected names in profile:", p.DNSNames)
+	}
+}
@@ -0,0 +1,442 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * Memcached text protocol
+ */
+
+const (
+	// the server rejects longer command lines
+	memcachedMaxLineSize = 2048
+
+	// the item size limit can be configured up to 1GB
+	memcachedMaxItemSize = 1024 * 1024 * 1024
+)
+
+// response formats of memcached commands
+const (
+	memcachedResponseLine = iota
+	memcachedResponseMulti
+	memcachedResponseMeta
+	memcachedResponseNone
+)
+
+// memcachedDangerousCommands are commands commonly abused on exposed instances.
+var memcachedDangerousCommands = map[string]string{
+	"flush_all":            "deletes all items",
+	"shutdown":             "stops the server",
+	"cache_memlimit":       "changes the memory limit",
+	"stats cachedump":      "enumerates keys",
+	"lru_crawler metadump": "enumerates keys",
+	"watch":                "streams server logs",
+}
+
+// memcachedSubcommands take a subcommand as first argument.
+var memcachedSubcommands = map[string]bool{
+	"stats":       true,
+	"lru_crawler": true,
+	"slabs":       true,
+	"extstore":    true,
+}
+
+// isMemcached checks if the server sent a typical memcached reply or the default port is used.
+func isMemcached(banner []byte, transport gopacket.Flow) bool {
+	if transport.Dst().String() == "11211" {
+		return true
+	}
+
+	for _, prefix := range []string{"VALUE ", "STORED\r\n", "NOT_STORED\r\n", "STAT pid ", "VERSION 1."} {
+		if bytes.HasPrefix(banner, []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type memcachedCommand struct {
+	record   *types.Memcached
+	response int
+
+	// position of the command in the connection
+	index int
+
+	// data of a storage command, used to detect ASCII authentication
+	data []byte
+}
+
+type memcachedReader struct {
+	parent *tcpConnection
+
+	numCommands int
+	pending     []*memcachedCommand
+
+	done bool
+}
+
+// Decode parses the stream according to the memcached text protocol.
+func (h *memcachedReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	// the merged fragments are ordered by time, so commands are processed before their responses
+	for _, d := range h.parent.merged {
+		if h.done {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readClient(&clientBuf, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.readServer(&serverBuf)
+		}
+	}
+
+	// write commands that did not receive a response
+	for _, c := range h.pending {
+		c.record.Notes = addInfo(c.record.Notes, "no response")
+		h.write(c.record)
+	}
+
+	h.pending = nil
+}
+
+// memcachedLine returns the line at the start of b without the line break and the number of consumed bytes.
+func memcachedLine(b []byte) (string, int, bool) {
+	idx := bytes.IndexByte(b, '\n')
+	if idx == -1 {
+		return "", 0, false
+	}
+
+	return string(bytes.TrimSuffix(b[:idx], []byte("\r"))), idx + 1, true
+}
+
+func (h *memcachedReader) readClient(buf *bytes.Buffer, ts time.Time) {
+	for !h.done && buf.Len() > 0 {
+		data := buf.Bytes()
+
+		// the binary protocol is not supported
+		if data[0] == 0x80 {
+			utils.DebugLog.Println("Memcached: binary protocol on", h.parent.ident)
+
+			h.done = true
+
+			return
+		}
+
+		line, n, ok := memcachedLine(data)
+		if !ok {
+			if len(data) > memcachedMaxLineSize {
+				h.done = true
+			}
+
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			buf.Next(n)
+
+			continue
+		}
+
+		c := h.newCommand(fields, ts)
+		c.index = h.numCommands
+
+		// storage commands are followed by a data block
+		if size := h.dataSize(fields); size >= 0 {
+			if len(data) < n+size+2 {
+				return
+			}
+
+			c.data = data[n : n+size]
+			c.record.ValueSize = int64(size)
+			n += size + 2
+		}
+
+		buf.Next(n)
+
+		h.numCommands++
+
+		if c.response == memcachedResponseNone {
+			h.write(c.record)
+
+			continue
+		}
+
+		h.pending = append(h.pending, c)
+	}
+}
+
+// dataSize returns the size of the data block following the command, or -1 if there is none.
+func (h *memcachedReader) dataSize(fields []string) int {
+	idx := -1
+
+	switch strings.ToLower(fields[0]) {
+	case "set", "add", "replace", "append", "prepend", "cas":
+		// <command> <key> <flags> <exptime> <bytes>
+		idx = 4
+	case "ms":
+		// ms <key> <datalen> <flags>*
+		idx = 2
+	}
+
+	return h.blockSize(fields, idx)
+}
+
+// blockSize parses the data block size in the field at the given index, or returns -1 if there is none.
+// Invalid sizes stop processing the stream.
+func (h *memcachedReader) blockSize(fields []string, idx int) int {
+	if idx == -1 || len(fields) <= idx {
+		return -1
+	}
+
+	size, err := strconv.Atoi(fields[idx])
+	if err != nil || size < 0 || size > memcachedMaxItemSize {
+		h.done = true
+
+		return -1
+	}
+
+	return size
+}
+
+func (h *memcachedReader) newCommand(fields []string, ts time.Time) *memcachedCommand {
+	var (
+		name = strings.ToLower(fields[0])
+		args = fields[1:]
+		c    = &memcachedCommand{
+			record: &types.Memcached{
+				Timestamp: utils.TimeToString(ts),
+				ClientIP:  h.parent.net.Src().String(),
+				ServerIP:  h.parent.net.Dst().String(),
+				Flow:      h.parent.ident,
+			},
+		}
+	)
+
+	if memcachedSubcommands[name] && len(args) > 0 {
+		name += " " + strings.ToLower(args[0])
+		args = args[1:]
+	}
+
+	c.record.Command = name
+
+	if reason, ok := memcachedDangerousCommands[name]; ok {
+		c.record.Notes = "dangerous: " + reason
+	}
+
+	parseUint := func(s string) uint64 {
+		v, _ := strconv.ParseUint(s, 10, 64)
+		return v
+	}
+
+	switch name {
+	case "get", "gets":
+		c.record.Keys = args
+		c.response = memcachedResponseMulti
+	case "gat", "gats":
+		if len(args) > 0 {
+			c.record.Expiry = int64(parseUint(args[0]))
+			c.record.Keys = args[1:]
+		}
+
+		c.response = memcachedResponseMulti
+	case "set", "add", "replace", "append", "prepend", "cas":
+		if len(args) >= 3 {
+			c.record.Flags = uint32(parseUint(args[1]))
+			c.record.Expiry = int64(parseUint(args[2]))
+		}
+
+		fallthrough
+	case "delete", "incr", "decr", "touch":
+		if len(args) > 0 {
+			c.record.Keys = args[:1]
+		}
+
+		if name == "touch" && len(args) > 1 {
+			c.record.Expiry = int64(parseUint(args[1]))
+		}
+	case "mg", "ms", "md", "ma":
+		if len(args) > 0 {
+			c.record.Keys = args[:1]
+		}
+
+		if name == "mg" {
+			c.response = memcachedResponseMeta
+		}
+	case "stats", "stats items", "stats slabs", "stats sizes", "stats settings", "stats conns", "stats cachedump", "lru_crawler metadump":
+		c.response = memcachedResponseMulti
+	case "quit":
+		c.response = memcachedResponseNone
+	case "watch":
+		// the server streams log lines until the connection is closed
+		c.response = memcachedResponseNone
+		h.done = true
+	}
+
+	if len(args) > 0 && args[len(args)-1] == "noreply" {
+		c.response = memcachedResponseNone
+	}
+
+	return c
+}
+
+func (h *memcachedReader) readServer(buf *bytes.Buffer) {
+	for !h.done && buf.Len() > 0 {
+		var (
+			data        = buf.Bytes()
+			line, n, ok = memcachedLine(data)
+			fields      = strings.Fields(line)
+			consumed    = n
+			idx         = -1
+		)
+
+		if !ok {
+			if len(data) > memcachedMaxLineSize {
+				h.done = true
+			}
+
+			return
+		}
+
+		if len(h.pending) == 0 {
+			// response without a matching command
+			buf.Next(n)
+
+			continue
+		}
+
+		c := h.pending[0]
+
+		// retrieved values are followed by a data block
+		switch {
+		case len(fields) >= 4 && fields[0] == "VALUE":
+			// VALUE <key> <flags> <bytes>
+			idx = 3
+		case len(fields) >= 2 && fields[0] == "VA":
+			// VA <size> <flags>*
+			idx = 1
+		}
+
+		size := h.blockSize(fields, idx)
+		if h.done {
+			return
+		}
+
+		if size >= 0 {
+			if len(data) < n+size+2 {
+				return
+			}
+
+			consumed += size + 2
+
+			c.record.Hits++
+			c.record.ValueSize += int64(size)
+		}
+
+		buf.Next(consumed)
+
+		isError := line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR")
+
+		// multi line responses are terminated by END
+		if c.response == memcachedResponseMulti && !isError && line != "END" {
+			continue
+		}
+
+		c.record.Status = "OK"
+		if isError {
+			c.record.Status = "ERROR"
+		}
+
+		c.record.Response = line
+		if size >= 0 {
+			c.record.Response = fields[0]
+		}
+
+		h.pending = h.pending[1:]
+
+		h.checkAuthentication(c)
+		h.write(c.record)
+	}
+}
+
+// checkAuthentication harvests credentials from the ASCII authentication of memcached 1.5.15 and newer,
+// where the first command is a set with the user name and password as data.
+func (h *memcachedReader) checkAuthentication(c *memcachedCommand) {
+	if c.index != 0 || c.record.Command != "set" {
+		return
+	}
+
+	fields := strings.Fields(string(c.data))
+	if len(fields) != 2 || c.record.Flags != 0 || c.record.Expiry != 0 {
+		return
+	}
+
+	switch c.record.Response {
+	case "STORED":
+		c.record.Notes = addInfo(c.record.Notes, "authentication")
+	case "CLIENT_ERROR authentication failure":
+		c.record.Notes = addInfo(c.record.Notes, "authentication failed")
+	default:
+		return
+	}
+
+	if !useHarvesters {
+		return
+	}
+
+	writeCredentials(&types.Credentials{
+		Timestamp: c.record.Timestamp,
+		Service:   serviceMemcached,
+		Flow:      h.parent.ident,
+		User:      fields[0],
+		Password:  fields[1],
+		Notes:     c.record.Response,
+	})
+}
+
+func (h *memcachedReader) write(r *types.Memcached) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&memcachedDecoder.numRecords, 1)
+
+	err := memcachedDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
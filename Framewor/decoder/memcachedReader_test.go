@@ -0,0 +1,128 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func TestMemcachedReader(t *testing.T) {
+	var (
+		memcachedRecords = &recordCollector{}
+		credRecords      = &recordCollector{}
+		oldConf          = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+	}()
+
+	conf = &Config{}
+	useHarvesters = true
+	memcachedDecoder.writer = memcachedRecords
+	credentialsDecoder.writer = credRecords
+
+	if !isMemcached([]byte("STAT pid 1234\r\n"), newTestConnection(50000, 11212).transport) {
+		t.Fatal("failed to detect memcached")
+	}
+
+	c := newTestConnection(50000, 11211,
+		[]byte("set auth 0 0 12\r\nadmin s3cr3t\r\n"),
+		[]byte("STORED\r\n"),
+		[]byte("get user:1 user:2\r\nset user:3 5 3600 5\r\nhello\r\nstats cachedump 1 100\r\nflush_all noreply\r\n"),
+		[]byte("VALUE user:1 0 5\r\nalice\r\nEND\r\nSTORED\r\nITEM user:1 [5 b; 0 s]\r\nEND\r\n"),
+	)
+
+	(&memcachedReader{parent: c}).Decode()
+
+	if len(memcachedRecords.records) != 5 {
+		t.Fatal("expected 5 memcached records, got", len(memcachedRecords.records))
+	}
+
+	r := memcachedRecords.records[0].(*types.Memcached)
+	if r.Command != "set" || r.Response != "STORED" || r.Notes != "authentication" {
+		t.Fatal("unexpected authentication record:", r)
+	}
+
+	// commands without reply are written immediately
+	r = memcachedRecords.records[1].(*types.Memcached)
+	if r.Command != "flush_all" || r.Notes != "dangerous: deletes all items" {
+		t.Fatal("unexpected flush record:", r)
+	}
+
+	r = memcachedRecords.records[2].(*types.Memcached)
+	if r.Command != "get" || strings.Join(r.Keys, ",") != "user:1,user:2" || r.Hits != 1 || r.ValueSize != 5 || r.Response != "END" {
+		t.Fatal("unexpected get record:", r)
+	}
+
+	r = memcachedRecords.records[3].(*types.Memcached)
+	if r.Command != "set" || r.Flags != 5 || r.Expiry != 3600 || r.ValueSize != 5 || r.Status != "OK" {
+		t.Fatal("unexpected set record:", r)
+	}
+
+	r = memcachedRecords.records[4].(*types.Memcached)
+	if r.Command != "stats cachedump" || r.Notes != "dangerous: enumerates keys" || r.Response != "END" {
+		t.Fatal("unexpected stats record:", r)
+	}
+
+	if len(credRecords.records) != 1 {
+		t.Fatal("expected 1 credential, got", len(credRecords.records))
+	}
+
+	creds := credRecords.records[0].(*types.Credentials)
+	if creds.Service != serviceMemcached || creds.User != "admin" || creds.Password != "s3cr3t" {
+		t.Fatal("unexpected credentials:", creds)
+	}
+}
+
+func TestMemcachedReaderValueSize(t *testing.T) {
+	var (
+		memcachedRecords = &recordCollector{}
+		oldConf          = conf
+	)
+
+	defer func() {
+		conf = oldConf
+	}()
+
+	conf = &Config{}
+	memcachedDecoder.writer = memcachedRecords
+
+	for _, response := range []string{
+		"VALUE k 0 9223372036854775807\r\nabc\r\nEND\r\n",
+		"VALUE k 0 x\r\nabc\r\nEND\r\n",
+		"VA -1 f\r\nabc\r\nEN\r\n",
+	} {
+		memcachedRecords.records = nil
+
+		c := newTestConnection(50000, 11211,
+			[]byte("get k\r\n"),
+			[]byte(response),
+		)
+
+		(&memcachedReader{parent: c}).Decode()
+
+		if len(memcachedRecords.records) != 1 {
+			t.Fatal("expected 1 memcached record, got", len(memcachedRecords.records))
+		}
+
+		if r := memcachedRecords.records[0].(*types.Memcached); r.Notes != "no response" || r.Hits != 0 {
+			t.Fatal("unexpected record for invalid value size:", r)
+		}
+	}
+}
@@ -0,0 +1,601 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * MySQL client/server protocol
+ */
+
+const (
+	mysqlHeaderLen       = 4
+	mysqlProtocolVersion = 10
+
+	// capability flags
+	mysqlClientConnectWithDB = 0x00000008
+	mysqlClientSSL           = 0x00000800
+	mysqlClientSecureConn    = 0x00008000
+	mysqlClientPluginAuth    = 0x00080000
+	mysqlClientLenencAuth    = 0x00200000
+	mysqlClientDeprecateEOF  = 0x01000000
+
+	// response packet headers
+	mysqlOK         = 0x00
+	mysqlAuthMore   = 0x01
+	mysqlEOF        = 0xfe
+	mysqlAuthSwitch = 0xfe
+	mysqlErr        = 0xff
+
+	// commands
+	mysqlComQuit        = 0x01
+	mysqlComInitDB      = 0x02
+	mysqlComQuery       = 0x03
+	mysqlComStmtPrepare = 0x16
+	mysqlComStmtExecute = 0x17
+	mysqlComStmtSend    = 0x18
+	mysqlComStmtClose   = 0x19
+
+	mysqlNativePassword = "mysql_native_password"
+	mysqlClearPassword  = "mysql_clear_password"
+
+	hashcatModeMySQLNative = 11200
+)
+
+var mysqlCommands = map[byte]string{
+	0x00: "Sleep",
+	0x01: "Quit",
+	0x02: "InitDB",
+	0x03: "Query",
+	0x04: "FieldList",
+	0x05: "CreateDB",
+	0x06: "DropDB",
+	0x07: "Refresh",
+	0x08: "Shutdown",
+	0x09: "Statistics",
+	0x0a: "ProcessInfo",
+	0x0c: "ProcessKill",
+	0x0d: "Debug",
+	0x0e: "Ping",
+	0x11: "ChangeUser",
+	0x16: "StmtPrepare",
+	0x17: "StmtExecute",
+	0x1a: "StmtReset",
+	0x1b: "SetOption",
+	0x1c: "StmtFetch",
+	0x1f: "ResetConnection",
+}
+
+// states of a command response
+const (
+	mysqlStateFirst = iota
+	mysqlStateColumns
+	mysqlStateRows
+	mysqlStateSkip
+)
+
+// isMySQL checks if the server sent a handshake or the default port is used.
+func isMySQL(banner []byte, transport gopacket.Flow) bool {
+	if transport.Dst().String() == "3306" {
+		return true
+	}
+
+	// first packet with sequence id 0, protocol version and a server version string
+	if len(banner) < 6 || banner[3] != 0 || banner[4] != mysqlProtocolVersion {
+		return false
+	}
+
+	return banner[5] >= '0' && banner[5] <= '9' && bytes.IndexByte(banner[5:], 0) != -1
+}
+
+// mysqlLenEnc decodes a length encoded integer and returns the remaining data.
+func mysqlLenEnc(b []byte) (uint64, []byte) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	size := 0
+
+	switch b[0] {
+	case 0xfc:
+		size = 2
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 8
+	default:
+		return uint64(b[0]), b[1:]
+	}
+
+	if len(b) < 1+size {
+		return 0, nil
+	}
+
+	var v uint64
+	for i := size; i > 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+
+	return v, b[1+size:]
+}
+
+type mysqlQuery struct {
+	record  *types.MySQL
+	ts      time.Time
+	command byte
+
+	// response parsing state
+	state     int
+	remaining int
+}
+
+type mySQLReader struct {
+	parent *tcpConnection
+
+	serverVersion string
+	user          string
+	database      string
+	capabilities  uint32
+	salt          []byte
+	plugin        string
+
+	// handshake state
+	greeted    bool
+	login      *mysqlQuery
+	authSwitch bool
+
+	prepared map[uint32]string
+	pending  []*mysqlQuery
+
+	done bool
+}
+
+// Decode parses the stream according to the MySQL protocol.
+func (h *mySQLReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	h.prepared = make(map[uint32]string)
+
+	// the merged fragments are ordered by time, so commands are processed before their responses
+	for _, d := range h.parent.merged {
+		if h.done {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readPackets(&clientBuf, ts, h.handleClient)
+		} else {
+			serverBuf.Write(d.raw)
+			h.readPackets(&serverBuf, ts, h.handleServer)
+		}
+	}
+
+	// write commands that did not receive a response
+	if h.login != nil {
+		h.pending = append([]*mysqlQuery{h.login}, h.pending...)
+	}
+
+	for _, q := range h.pending {
+		q.record.Notes = addInfo(q.record.Notes, "no response")
+		h.write(q.record)
+	}
+
+	h.pending = nil
+}
+
+// readPackets consumes all complete packets from the buffer.
+func (h *mySQLReader) readPackets(buf *bytes.Buffer, ts time.Time, handle func(seq byte, payload []byte, ts time.Time)) {
+	for !h.done {
+		data := buf.Bytes()
+		if len(data) < mysqlHeaderLen {
+			return
+		}
+
+		length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+		if len(data) < mysqlHeaderLen+length {
+			return
+		}
+
+		pkt := buf.Next(mysqlHeaderLen + length)
+		handle(pkt[3], pkt[mysqlHeaderLen:], ts)
+	}
+}
+
+func (h *mySQLReader) newQuery(command byte, statement string, ts time.Time) *mysqlQuery {
+	name, ok := mysqlCommands[command]
+	if !ok {
+		name = "0x" + strconv.FormatUint(uint64(command), 16)
+	}
+
+	return &mysqlQuery{
+		ts:      ts,
+		command: command,
+		record: &types.MySQL{
+			Timestamp:     utils.TimeToString(ts),
+			ClientIP:      h.parent.net.Src().String(),
+			ServerIP:      h.parent.net.Dst().String(),
+			Flow:          h.parent.ident,
+			ServerVersion: h.serverVersion,
+			User:          h.user,
+			Database:      h.database,
+			Command:       name,
+			Statement:     statement,
+		},
+	}
+}
+
+func (h *mySQLReader) handleClient(seq byte, payload []byte, ts time.Time) {
+	switch {
+	case !h.greeted:
+		return
+	case h.login != nil && h.authSwitch:
+		// response to an authentication switch request
+		h.authSwitch = false
+		h.harvest(payload, ts)
+
+		return
+	case h.login != nil:
+		return
+	case seq == 1 && h.user == "":
+		h.handleHandshakeResponse(payload, ts)
+
+		return
+	}
+
+	if len(payload) == 0 || seq != 0 {
+		return
+	}
+
+	var (
+		cmd       = payload[0]
+		statement string
+	)
+
+	switch cmd {
+	case mysqlComQuit, mysqlComStmtSend, mysqlComStmtClose:
+		// no response is sent for these commands
+		return
+	case mysqlComQuery, mysqlComStmtPrepare:
+		statement = string(payload[1:])
+	case mysqlComInitDB:
+		statement = string(payload[1:])
+	case mysqlComStmtExecute:
+		if len(payload) >= 5 {
+			statement = h.prepared[binary.LittleEndian.Uint32(payload[1:5])]
+		}
+	}
+
+	h.pending = append(h.pending, h.newQuery(cmd, statement, ts))
+}
+
+// handleHandshakeResponse parses the HandshakeResponse41 packet with the user name, auth response and database.
+func (h *mySQLReader) handleHandshakeResponse(payload []byte, ts time.Time) {
+	if len(payload) < 32 {
+		return
+	}
+
+	h.capabilities = binary.LittleEndian.Uint32(payload)
+
+	// a short packet with the SSL capability is a SSLRequest
+	if len(payload) == 32 && h.capabilities&mysqlClientSSL != 0 {
+		// the remaining conversation is encrypted
+		h.done = true
+
+		return
+	}
+
+	var (
+		data = payload[32:]
+		auth []byte
+	)
+
+	h.user, data = cString(data)
+
+	switch {
+	case h.capabilities&mysqlClientLenencAuth != 0:
+		var n uint64
+
+		n, data = mysqlLenEnc(data)
+		if uint64(len(data)) < n {
+			return
+		}
+
+		auth, data = data[:n], data[n:]
+	case h.capabilities&mysqlClientSecureConn != 0:
+		if len(data) == 0 || len(data) < 1+int(data[0]) {
+			return
+		}
+
+		auth, data = data[1:1+int(data[0])], data[1+int(data[0]):]
+	default:
+		var s string
+
+		s, data = cString(data)
+		auth = []byte(s)
+	}
+
+	if h.capabilities&mysqlClientConnectWithDB != 0 {
+		h.database, data = cString(data)
+	}
+
+	if h.capabilities&mysqlClientPluginAuth != 0 {
+		if plugin, _ := cString(data); plugin != "" {
+			h.plugin = plugin
+		}
+	}
+
+	h.login = h.newQuery(0, "", ts)
+	h.login.record.Command = "Login"
+	h.login.record.Notes = "auth plugin: " + h.plugin
+
+	h.harvest(auth, ts)
+}
+
+// harvest writes the authentication response as credentials according to the authentication plugin.
+func (h *mySQLReader) harvest(auth []byte, ts time.Time) {
+	if len(auth) == 0 || !useHarvesters {
+		return
+	}
+
+	creds := &types.Credentials{
+		Timestamp: utils.TimeToString(ts),
+		Service:   serviceMySQL,
+		Flow:      h.parent.ident,
+		User:      h.user,
+	}
+
+	switch h.plugin {
+	case mysqlNativePassword, "":
+		if len(auth) != 20 || len(h.salt) != 20 {
+			return
+		}
+
+		creds.Password = "$mysqlna$" + hex.EncodeToString(h.salt) + "*" + hex.EncodeToString(auth)
+		creds.Notes = "hashcat mode: " + strconv.Itoa(hashcatModeMySQLNative)
+	case mysqlClearPassword:
+		creds.Password, _ = cString(auth)
+	default:
+		return
+	}
+
+	writeCredentials(creds)
+}
+
+// handleGreeting parses the initial handshake packet of the server.
+func (h *mySQLReader) handleGreeting(payload []byte, ts time.Time) {
+	h.greeted = true
+
+	if len(payload) > 0 && payload[0] == mysqlErr {
+		// e.g. the host is not allowed to connect
+		q := h.newQuery(0, "", ts)
+		q.record.Command = "Login"
+		h.complete(q, payload, ts)
+
+		h.done = true
+
+		return
+	}
+
+	if len(payload) < 1 || payload[0] != mysqlProtocolVersion {
+		h.done = true
+
+		return
+	}
+
+	var data []byte
+
+	h.serverVersion, data = cString(payload[1:])
+
+	// connection id, first part of the auth data and filler
+	if len(data) < 13 {
+		return
+	}
+
+	h.salt = append([]byte(nil), data[4:12]...)
+	data = data[13:]
+
+	// capabilities, character set, status, upper capabilities, auth data length and reserved bytes
+	if len(data) < 18 {
+		return
+	}
+
+	data = data[18:]
+
+	// second part of the auth data, terminated by a null byte
+	if idx := bytes.IndexByte(data, 0); idx != -1 {
+		h.salt = append(h.salt, data[:idx]...)
+		data = data[idx+1:]
+	}
+
+	h.plugin, _ = cString(data)
+}
+
+func (h *mySQLReader) handleServer(seq byte, payload []byte, ts time.Time) {
+	if !h.greeted {
+		h.handleGreeting(payload, ts)
+
+		return
+	}
+
+	if h.login != nil {
+		if len(payload) == 0 {
+			return
+		}
+
+		switch payload[0] {
+		case mysqlOK, mysqlErr:
+			h.complete(h.login, payload, ts)
+			h.login = nil
+		case mysqlAuthSwitch:
+			// plugin name and new auth data
+			var data []byte
+
+			h.plugin, data = cString(payload[1:])
+			h.salt = bytes.TrimRight(data, "\x00")
+			h.authSwitch = true
+			h.login.record.Notes = addInfo(h.login.record.Notes, "switched to "+h.plugin)
+		case mysqlAuthMore:
+			// e.g. caching_sha2_password fast authentication, the result follows
+		}
+
+		return
+	}
+
+	if len(h.pending) == 0 || len(payload) == 0 {
+		return
+	}
+
+	q := h.pending[0]
+	deprecateEOF := h.capabilities&mysqlClientDeprecateEOF != 0
+
+	switch q.state {
+	case mysqlStateFirst:
+		switch {
+		case payload[0] == mysqlErr:
+			h.complete(q, payload, ts)
+		case payload[0] == mysqlOK && q.command == mysqlComStmtPrepare && len(payload) >= 9:
+			// statement id, number of columns and parameters, followed by their definitions
+			h.prepared[binary.LittleEndian.Uint32(payload[1:5])] = q.record.Statement
+
+			var (
+				columns = int(binary.LittleEndian.Uint16(payload[5:7]))
+				params  = int(binary.LittleEndian.Uint16(payload[7:9]))
+			)
+
+			q.state = mysqlStateSkip
+			q.remaining = columns + params
+
+			if !deprecateEOF {
+				if columns > 0 {
+					q.remaining++
+				}
+
+				if params > 0 {
+					q.remaining++
+				}
+			}
+
+			if q.remaining == 0 {
+				h.complete(q, payload, ts)
+			}
+		case payload[0] == mysqlOK || (payload[0] == mysqlEOF && len(payload) < 9):
+			h.complete(q, payload, ts)
+		default:
+			// result set with the number of columns
+			columns, _ := mysqlLenEnc(payload)
+
+			q.state = mysqlStateColumns
+			q.remaining = int(columns)
+
+			if !deprecateEOF {
+				q.remaining++
+			}
+		}
+	case mysqlStateColumns:
+		q.remaining--
+		if q.remaining <= 0 {
+			q.state = mysqlStateRows
+		}
+	case mysqlStateRows:
+		switch {
+		case payload[0] == mysqlErr:
+			h.complete(q, payload, ts)
+		case payload[0] == mysqlEOF && (len(payload) < 9 || (deprecateEOF && len(payload) < 0xffffff)):
+			h.complete(q, nil, ts)
+		default:
+			q.record.Rows++
+		}
+	case mysqlStateSkip:
+		q.remaining--
+		if q.remaining <= 0 {
+			h.complete(q, nil, ts)
+		}
+	}
+}
+
+// complete sets the status from the final OK or ERR packet and writes the audit record.
+func (h *mySQLReader) complete(q *mysqlQuery, payload []byte, ts time.Time) {
+	q.record.Status = "OK"
+	q.record.Latency = ts.Sub(q.ts).Nanoseconds()
+
+	if len(payload) > 0 {
+		switch payload[0] {
+		case mysqlErr:
+			q.record.Status = "ERROR"
+
+			if len(payload) >= 3 {
+				q.record.ErrorCode = int32(binary.LittleEndian.Uint16(payload[1:3]))
+				msg := payload[3:]
+
+				// protocol 4.1 includes the SQL state
+				if len(msg) >= 6 && msg[0] == '#' {
+					msg = msg[6:]
+				}
+
+				q.record.Error = string(msg)
+			}
+		case mysqlOK:
+			if q.command != mysqlComStmtPrepare {
+				rows, _ := mysqlLenEnc(payload[1:])
+				q.record.Rows = int64(rows)
+			}
+		}
+	}
+
+	if q.command == mysqlComInitDB && q.record.Status == "OK" {
+		h.database = q.record.Statement
+	}
+
+	for i, p := range h.pending {
+		if p == q {
+			h.pending = append(h.pending[:i], h.pending[i+1:]...)
+
+			break
+		}
+	}
+
+	h.write(q.record)
+}
+
+func (h *mySQLReader) write(r *types.MySQL) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&mySQLDecoder.numRecords, 1)
+
+	err := mySQLDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
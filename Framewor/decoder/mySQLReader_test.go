@@ -0,0 +1,124 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func mysqlTestPacket(seq byte, data ...[]byte) []byte {
+	payload := bytes.Join(data, nil)
+
+	return append([]byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}, payload...)
+}
+
+func TestMySQLReader(t *testing.T) {
+	var (
+		mysqlRecords = &recordCollector{}
+		credRecords  = &recordCollector{}
+		oldConf      = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+	}()
+
+	conf = &Config{}
+	useHarvesters = true
+	mySQLDecoder.writer = mysqlRecords
+	credentialsDecoder.writer = credRecords
+
+	var (
+		authResponse = bytes.Repeat([]byte{0xab}, 20)
+		greeting     = mysqlTestPacket(0,
+			[]byte{mysqlProtocolVersion}, []byte("8.0.21\x00"),
+			[]byte{1, 0, 0, 0}, []byte("abcdefgh\x00"),
+			[]byte{0xff, 0xff, 0x21, 0x02, 0x00, 0xff, 0xc7, 21}, make([]byte, 10),
+			[]byte("ijklmnopqrst\x00"), []byte("mysql_native_password\x00"),
+		)
+		login = mysqlTestPacket(1,
+			[]byte{0x08, 0x80, 0x08, 0x01}, []byte{0, 0, 0, 1}, []byte{0x21}, make([]byte, 23),
+			[]byte("root\x00"), []byte{20}, authResponse, []byte("shop\x00"), []byte("mysql_native_password\x00"),
+		)
+		resultSet = bytes.Join([][]byte{
+			mysqlTestPacket(1, []byte{1}),
+			mysqlTestPacket(2, []byte("\x03def\x04shop\x05users\x05users\x02id\x02id")),
+			mysqlTestPacket(3, []byte("\x011")),
+			mysqlTestPacket(4, []byte("\x012")),
+			mysqlTestPacket(5, []byte{mysqlEOF, 0, 0, 2, 0, 0, 0}),
+		}, nil)
+		prepared = append(
+			mysqlTestPacket(1, []byte{mysqlOK, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}),
+			mysqlTestPacket(2, []byte("\x03def\x00\x00\x00\x01?"))...,
+		)
+	)
+
+	if !isMySQL(greeting, newTestConnection(50000, 13306).transport) {
+		t.Fatal("failed to detect MySQL")
+	}
+
+	c := newTestConnection(50000, 3306,
+		nil,
+		greeting,
+		login,
+		mysqlTestPacket(2, []byte{mysqlOK, 0, 0, 2, 0, 0, 0}),
+		mysqlTestPacket(0, []byte{mysqlComQuery}, []byte("SELECT id FROM users")),
+		resultSet,
+		mysqlTestPacket(0, []byte{mysqlComStmtPrepare}, []byte("DELETE FROM users WHERE id=?")),
+		prepared,
+		mysqlTestPacket(0, []byte{mysqlComStmtExecute, 1, 0, 0, 0, 0, 1, 0, 0, 0}),
+		mysqlTestPacket(1, []byte{mysqlErr, 0x76, 0x04}, []byte("#42000DELETE command denied")),
+		mysqlTestPacket(0, []byte{mysqlComQuit}),
+	)
+
+	(&mySQLReader{parent: c}).Decode()
+
+	if len(mysqlRecords.records) != 4 {
+		t.Fatal("expected 4 MySQL records, got", len(mysqlRecords.records))
+	}
+
+	r := mysqlRecords.records[0].(*types.MySQL)
+	if r.Command != "Login" || r.User != "root" || r.Database != "shop" || r.ServerVersion != "8.0.21" || r.Status != "OK" {
+		t.Fatal("unexpected login record:", r)
+	}
+
+	r = mysqlRecords.records[1].(*types.MySQL)
+	if r.Command != "Query" || r.Statement != "SELECT id FROM users" || r.Rows != 2 || r.Status != "OK" || r.Latency <= 0 {
+		t.Fatal("unexpected query record:", r)
+	}
+
+	r = mysqlRecords.records[2].(*types.MySQL)
+	if r.Command != "StmtPrepare" || r.Status != "OK" {
+		t.Fatal("unexpected prepare record:", r)
+	}
+
+	r = mysqlRecords.records[3].(*types.MySQL)
+	if r.Command != "StmtExecute" || r.Statement != "DELETE FROM users WHERE id=?" || r.Status != "ERROR" || r.ErrorCode != 1142 || r.Error != "DELETE command denied" {
+		t.Fatal("unexpected execute record:", r)
+	}
+
+	if len(credRecords.records) != 1 {
+		t.Fatal("expected 1 credential, got", len(credRecords.records))
+	}
+
+	creds := credRecords.records[0].(*types.Credentials)
+	if creds.Service != serviceMySQL || creds.User != "root" || creds.Password != "$mysqlna$"+hex.EncodeToString([]byte("abcdefghijklmnopqrst"))+"*"+hex.EncodeToString(authResponse) {
+		t.Fatal("unexpected credentials:", creds)
+	}
+}
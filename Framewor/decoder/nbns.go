@@ -0,0 +1,286 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * NBNS - NetBIOS Name Service
+ */
+
+const (
+	serviceNBNS = "NBNS"
+
+	nbnsPort      = 137
+	nbnsHeaderLen = 12
+
+	// length of an encoded NetBIOS name label
+	nbnsEncodedNameLen = 32
+
+	// resource record types
+	nbnsTypeNB     = 0x20
+	nbnsTypeNBSTAT = 0x21
+
+	// the group flag is set for names shared by multiple hosts, e.g. the domain name
+	nbnsFlagGroup = 0x8000
+
+	// name suffixes of host names
+	nbnsSuffixWorkstation = 0x00
+	nbnsSuffixServer      = 0x20
+)
+
+var (
+	nbnsOpcodes = map[byte]string{
+		0:  "Query",
+		5:  "Registration",
+		6:  "Release",
+		7:  "WACK",
+		8:  "Refresh",
+		9:  "Refresh",
+		15: "Multi-Homed Registration",
+	}
+
+	errNBNSInvalidPacket = errors.New("invalid NBNS packet")
+)
+
+// nbnsName is a decoded NetBIOS name.
+type nbnsName struct {
+	name   string
+	suffix byte
+}
+
+func (n nbnsName) String() string {
+	return fmt.Sprintf("%s<%02x>", n.name, n.suffix)
+}
+
+// isHost checks if the name identifies a single host.
+func (n nbnsName) isHost(flags uint16) bool {
+	return flags&nbnsFlagGroup == 0 && (n.suffix == nbnsSuffixWorkstation || n.suffix == nbnsSuffixServer)
+}
+
+// decodeNBNSName decodes the first level encoding of a NetBIOS name, where each nibble is added to 'A'.
+func decodeNBNSName(label []byte) (nbnsName, error) {
+	if len(label) != nbnsEncodedNameLen {
+		return nbnsName{}, errNBNSInvalidPacket
+	}
+
+	var raw [nbnsEncodedNameLen / 2]byte
+
+	for i := range raw {
+		hi, lo := label[2*i]-'A', label[2*i+1]-'A'
+		if hi > 0x0f || lo > 0x0f {
+			return nbnsName{}, errNBNSInvalidPacket
+		}
+
+		raw[i] = hi<<4 | lo
+	}
+
+	return newNBNSName(raw[:]), nil
+}
+
+// newNBNSName creates a name from the 15 character name padded with spaces and the suffix byte.
+func newNBNSName(raw []byte) nbnsName {
+	return nbnsName{
+		name:   strings.TrimRight(string(raw[:15]), " \x00"),
+		suffix: raw[15],
+	}
+}
+
+// readNBNSName reads the encoded name at the offset, the scope is ignored.
+// Names in resource records can be compressed with a pointer to the name of the question.
+func readNBNSName(data []byte, offset int) (nbnsName, int, error) {
+	var (
+		name    nbnsName
+		decoded bool
+		next    = -1
+	)
+
+	for jumps := 0; offset < len(data); {
+		length := int(data[offset])
+
+		switch {
+		case length == 0:
+			if next == -1 {
+				next = offset + 1
+			}
+
+			if !decoded {
+				return name, 0, errNBNSInvalidPacket
+			}
+
+			return name, next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(data) || jumps > 4 {
+				return name, 0, errNBNSInvalidPacket
+			}
+
+			if next == -1 {
+				next = offset + 2
+			}
+
+			offset = int(binary.BigEndian.Uint16(data[offset:]) & 0x3fff)
+			jumps++
+		default:
+			if offset+1+length > len(data) {
+				return name, 0, errNBNSInvalidPacket
+			}
+
+			if !decoded {
+				var err error
+				if name, err = decodeNBNSName(data[offset+1 : offset+1+length]); err != nil {
+					return name, 0, err
+				}
+
+				decoded = true
+			}
+
+			offset += 1 + length
+		}
+	}
+
+	return name, 0, errNBNSInvalidPacket
+}
+
+// parseNBNS decodes the names and addresses of a NBNS packet.
+func parseNBNS(data []byte, srcIP string) (*types.NBNS, error) {
+	if len(data) < nbnsHeaderLen {
+		return nil, errNBNSInvalidPacket
+	}
+
+	var (
+		opcode = data[2] >> 3 & 0x0f
+		r      = &types.NBNS{
+			Response: data[2]&0x80 != 0,
+			Opcode:   nbnsOpcodes[opcode],
+		}
+		numQuestions = int(binary.BigEndian.Uint16(data[4:6]))
+		numRecords   = int(binary.BigEndian.Uint16(data[6:8])) + int(binary.BigEndian.Uint16(data[8:10])) + int(binary.BigEndian.Uint16(data[10:12]))
+		offset       = nbnsHeaderLen
+		addHost      = func(name nbnsName, addr string) {
+			host := name.String() + "=" + addr
+			if !containsString(r.Hosts, host) {
+				r.Hosts = append(r.Hosts, host)
+				addIPProfileName(addr, name.name)
+			}
+		}
+	)
+
+	if r.Opcode == "" {
+		r.Opcode = fmt.Sprint(opcode)
+	}
+
+	for i := 0; i < numQuestions; i++ {
+		name, next, err := readNBNSName(data, offset)
+		if err != nil || next+4 > len(data) {
+			return r, errNBNSInvalidPacket
+		}
+
+		r.Questions = append(r.Questions, name.String())
+		offset = next + 4
+	}
+
+	for i := 0; i < numRecords; i++ {
+		name, next, err := readNBNSName(data, offset)
+		if err != nil || next+10 > len(data) {
+			return r, errNBNSInvalidPacket
+		}
+
+		var (
+			typ    = binary.BigEndian.Uint16(data[next:])
+			length = int(binary.BigEndian.Uint16(data[next+8:]))
+			rdata  []byte
+		)
+
+		offset = next + 10 + length
+		if offset > len(data) {
+			return r, errNBNSInvalidPacket
+		}
+
+		rdata = data[next+10 : offset]
+
+		switch typ {
+		case nbnsTypeNB:
+			// pairs of flags and IPv4 addresses
+			for ; len(rdata) >= 6; rdata = rdata[6:] {
+				if name.isHost(binary.BigEndian.Uint16(rdata)) {
+					addHost(name, net.IP(rdata[2:6]).String())
+				}
+			}
+		case nbnsTypeNBSTAT:
+			// node status response with the names registered by the sender
+			if len(rdata) == 0 {
+				continue
+			}
+
+			numNames := int(rdata[0])
+			rdata = rdata[1:]
+
+			for j := 0; j < numNames && len(rdata) >= 18; j++ {
+				n := newNBNSName(rdata[:16])
+				if n.isHost(binary.BigEndian.Uint16(rdata[16:18])) {
+					addHost(n, srcIP)
+				}
+
+				rdata = rdata[18:]
+			}
+		}
+	}
+
+	return r, nil
+}
+
+var nbnsDecoder = newCustomDecoder(
+	types.Type_NC_NBNS,
+	serviceNBNS,
+	"The NetBIOS Name Service registers and resolves the names of Windows machines in local networks",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		udp, srcIP, dstIP := udpLayerForPort(p, nbnsPort)
+		if udp == nil {
+			return nil
+		}
+
+		r, err := parseNBNS(udp.Payload, srcIP)
+		if r == nil {
+			return nil
+		}
+
+		if err != nil {
+			r.Notes = addInfo(r.Notes, err.Error())
+		}
+
+		r.Timestamp = utils.TimeToString(p.Metadata().Timestamp)
+		r.SrcIP = srcIP
+		r.DstIP = dstIP
+
+		return r
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
@@ -0,0 +1,85 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"strings"
+	"testing"
+)
+
+// nbnsTestName encodes a NetBIOS name with the given suffix.
+func nbnsTestName(name string, suffix byte) []byte {
+	raw := []byte(name + strings.Repeat(" ", 15-len(name)))
+	raw = append(raw, suffix)
+
+	out := []byte{nbnsEncodedNameLen}
+	for _, b := range raw {
+		out = append(out, 'A'+b>>4, 'A'+b&0x0f)
+	}
+
+	return append(out, 0)
+}
+
+func nbnsTestStatusName(name string, suffix byte, flags uint16) []byte {
+	raw := []byte(name + strings.Repeat(" ", 15-len(name)))
+
+	return append(raw, suffix, byte(flags>>8), byte(flags))
+}
+
+func TestNBNS(t *testing.T) {
+	defer func() {
+		ipNames.Lock()
+		delete(ipNames.Items, "192.168.1.40")
+		delete(ipNames.Items, "192.168.1.41")
+		ipNames.Unlock()
+	}()
+
+	// broadcast registration with the address in the additional record
+	data := []byte{0x12, 0x34, 0x29, 0x10, 0, 1, 0, 0, 0, 0, 0, 1}
+	data = append(data, nbnsTestName("WS01", nbnsSuffixWorkstation)...)
+	data = append(data, 0, nbnsTypeNB, 0, 1)
+	data = append(data, 0xc0, 0x0c, 0, nbnsTypeNB, 0, 1, 0, 3, 0xf4, 0x80, 0, 6, 0, 0, 192, 168, 1, 40)
+
+	r, err := parseNBNS(data, "192.168.1.40")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Response || r.Opcode != "Registration" || strings.Join(r.Questions, ",") != "WS01<00>" || strings.Join(r.Hosts, ",") != "WS01<00>=192.168.1.40" {
+		t.Fatal("unexpected NBNS registration:", r)
+	}
+
+	// node status response, the group name of the domain is ignored
+	status := append([]byte{3}, nbnsTestStatusName("FS01", nbnsSuffixWorkstation, 0x0400)...)
+	status = append(status, nbnsTestStatusName("CORP", nbnsSuffixWorkstation, nbnsFlagGroup|0x0400)...)
+	status = append(status, nbnsTestStatusName("FS01", nbnsSuffixServer, 0x0400)...)
+
+	data = []byte{0x12, 0x35, 0x84, 0x00, 0, 0, 0, 1, 0, 0, 0, 0}
+	data = append(data, nbnsTestName("*", 0)...)
+	data = append(data, 0, nbnsTypeNBSTAT, 0, 1, 0, 0, 0, 0, 0, byte(len(status)))
+	data = append(data, status...)
+
+	r, err = parseNBNS(data, "192.168.1.41")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.Response || r.Opcode != "Query" || strings.Join(r.Hosts, ",") != "FS01<00>=192.168.1.41,FS01<20>=192.168.1.41" {
+		t.Fatal("unexpected NBNS node status:", r)
+	}
+
+	if names := ipNames.get("192.168.1.41"); len(names) != 1 || names[0] != "FS01" {
+		t.Fatal("unexpected names for address:", names)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * NTLMSSP - NT LAN Manager Security Support Provider
+ */
+
+const (
+	ntlmNegotiateUnicode    = 0x00000001
+	ntlmMessageNegotiate    = 1
+	ntlmMessageChallenge    = 2
+	ntlmMessageAuthenticate = 3
+
+	serviceNetNTLMv1 = "NetNTLMv1"
+	serviceNetNTLMv2 = "NetNTLMv2"
+
+	// hashcat modes for the challenge response formats
+	hashcatModeNetNTLMv1 = 5500
+	hashcatModeNetNTLMv2 = 5600
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmAuthenticate contains the fields of interest from a NTLMSSP AUTHENTICATE message.
+type ntlmAuthenticate struct {
+	LmResponse  []byte
+	NtResponse  []byte
+	Domain      string
+	User        string
+	Workstation string
+}
+
+// ntlmField returns the payload referenced by the security buffer descriptor at offset.
+func ntlmField(msg []byte, offset int) []byte {
+	if len(msg) < offset+8 {
+		return nil
+	}
+
+	var (
+		length = int(binary.LittleEndian.Uint16(msg[offset:]))
+		start  = int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	)
+
+	if length == 0 || start+length > len(msg) {
+		return nil
+	}
+
+	return msg[start : start+length]
+}
+
+// decodeUTF16 converts little endian UTF-16 data into a string.
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+
+	return string(utf16.Decode(u))
+}
+
+// ntlmString decodes a string field according to the negotiated character set.
+func ntlmString(b []byte, flags uint32) string {
+	if flags&ntlmNegotiateUnicode != 0 {
+		return decodeUTF16(b)
+	}
+
+	return string(b)
+}
+
+// findNTLMMessage locates a NTLMSSP message of the given type in data,
+// which can be wrapped in other structures such as SPNEGO.
+func findNTLMMessage(data []byte, typ uint32) []byte {
+	idx := bytes.Index(data, ntlmSignature)
+	if idx == -1 || len(data) < idx+12 {
+		return nil
+	}
+
+	msg := data[idx:]
+	if binary.LittleEndian.Uint32(msg[8:]) != typ {
+		return nil
+	}
+
+	return msg
+}
+
+// parseNTLMAuthenticate extracts the credentials from a NTLMSSP AUTHENTICATE message.
+// It returns nil if data does not contain such a message.
+func parseNTLMAuthenticate(data []byte) *ntlmAuthenticate {
+	msg := findNTLMMessage(data, ntlmMessageAuthenticate)
+	if len(msg) < 64 {
+		return nil
+	}
+
+	flags := binary.LittleEndian.Uint32(msg[60:])
+
+	return &ntlmAuthenticate{
+		LmResponse:  ntlmField(msg, 12),
+		NtResponse:  ntlmField(msg, 20),
+		Domain:      ntlmString(ntlmField(msg, 28), flags),
+		User:        ntlmString(ntlmField(msg, 36), flags),
+		Workstation: ntlmString(ntlmField(msg, 44), flags),
+	}
+}
+
+// parseNTLMChallenge returns the 8 byte server challenge from a NTLMSSP CHALLENGE message.
+// It returns nil if data does not contain such a message.
+func parseNTLMChallenge(data []byte) []byte {
+	msg := findNTLMMessage(data, ntlmMessageChallenge)
+	if len(msg) < 32 {
+		return nil
+	}
+
+	return msg[24:32]
+}
+
+// splitNTLMMessages returns all NTLMSSP messages contained in data.
+// Each message extends until the start of the next signature or the end of data.
+func splitNTLMMessages(data []byte) [][]byte {
+	var msgs [][]byte
+
+	for {
+		idx := bytes.Index(data, ntlmSignature)
+		if idx == -1 {
+			return msgs
+		}
+
+		data = data[idx:]
+
+		next := bytes.Index(data[len(ntlmSignature):], ntlmSignature)
+		if next == -1 {
+			return append(msgs, data)
+		}
+
+		msgs = append(msgs, data[:len(ntlmSignature)+next])
+		data = data[len(ntlmSignature)+next:]
+	}
+}
+
+// hash formats the challenge response in the hashcat format for the given server challenge
+// and returns the service name and hashcat mode.
+// An empty hash is returned for anonymous authentication or unknown response formats.
+func (a *ntlmAuthenticate) hash(challenge []byte) (hash string, service string, mode int) {
+	if a.User == "" || len(a.NtResponse) == 0 || len(challenge) != 8 {
+		return "", "", 0
+	}
+
+	switch {
+	case len(a.NtResponse) == 24:
+		// user::domain:lm:nt:challenge
+		return a.User + "::" + a.Domain + ":" +
+			hex.EncodeToString(a.LmResponse) + ":" +
+			hex.EncodeToString(a.NtResponse) + ":" +
+			hex.EncodeToString(challenge), serviceNetNTLMv1, hashcatModeNetNTLMv1
+	case len(a.NtResponse) > 24:
+		// user::domain:challenge:ntproofstr:blob
+		return a.User + "::" + a.Domain + ":" +
+			hex.EncodeToString(challenge) + ":" +
+			hex.EncodeToString(a.NtResponse[:16]) + ":" +
+			hex.EncodeToString(a.NtResponse[16:]), serviceNetNTLMv2, hashcatModeNetNTLMv2
+	}
+
+	return "", "", 0
+}
+
+// writeNTLMCredentials pairs an AUTHENTICATE message with the server challenge
+// and writes the resulting hash as credentials.
+func writeNTLMCredentials(auth *ntlmAuthenticate, challenge []byte, ts time.Time, ident string) {
+	h, service, mode := auth.hash(challenge)
+	if h == "" {
+		return
+	}
+
+	user := auth.User
+	if auth.Domain != "" {
+		user = auth.Domain + "\\" + auth.User
+	}
+
+	writeCredentials(&types.Credentials{
+		Timestamp: utils.TimeToString(ts),
+		Service:   service,
+		Flow:      ident,
+		User:      user,
+		Password:  h,
+		Notes:     "workstation: " + auth.Workstation + ", hashcat mode: " + strconv.Itoa(mode),
+	})
+}
+
+// harvestNTLM searches the conversation of a TCP connection for NTLMSSP messages
+// and pairs the challenges sent by the server with the following client responses.
+func harvestNTLM(c *tcpConnection) {
+	var (
+		buf       bytes.Buffer
+		dir       reassembly.TCPFlowDirection
+		ts        time.Time
+		challenge []byte
+	)
+
+	// process the data sent into one direction once the direction changes
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+
+		for _, msg := range splitNTLMMessages(buf.Bytes()) {
+			if dir == reassembly.TCPDirServerToClient {
+				if ch := parseNTLMChallenge(msg); ch != nil {
+					// the buffer is reused, keep a copy of the challenge
+					challenge = append([]byte(nil), ch...)
+				}
+
+				continue
+			}
+
+			if auth := parseNTLMAuthenticate(msg); auth != nil {
+				writeNTLMCredentials(auth, challenge, ts, c.ident)
+			}
+		}
+
+		buf.Reset()
+	}
+
+	for i, d := range c.merged {
+		if i == 0 || d.dir != dir {
+			flush()
+
+			dir = d.dir
+			ts = d.ac.GetCaptureInfo().Timestamp
+		}
+
+		buf.Write(d.raw)
+	}
+
+	flush()
+}
@@ -0,0 +1,111 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// ntlmTestChallenge creates a NTLMSSP CHALLENGE message.
+func ntlmTestChallenge(challenge []byte) []byte {
+	msg := make([]byte, 48)
+
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], ntlmMessageChallenge)
+	binary.LittleEndian.PutUint32(msg[20:], ntlmNegotiateUnicode)
+	copy(msg[24:], challenge)
+
+	return msg
+}
+
+func TestHarvestNTLM(t *testing.T) {
+	var (
+		credRecords = &recordCollector{}
+		oldConf     = conf
+	)
+
+	defer func() {
+		conf = oldConf
+	}()
+
+	conf = &Config{}
+	credentialsDecoder.writer = credRecords
+
+	var (
+		negotiate = append(append([]byte{}, ntlmSignature...), 1, 0, 0, 0)
+		challenge = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+		ntv2      = append(bytes.Repeat([]byte{0xaa}, 16), bytes.Repeat([]byte{0xbb}, 12)...)
+		authV2    = ntlmTestAuthenticate("CORP", "alice", "WS01", bytes.Repeat([]byte{0}, 24), ntv2)
+		authV1    = ntlmTestAuthenticate("CORP", "bob", "WS02", bytes.Repeat([]byte{0xcc}, 24), bytes.Repeat([]byte{0xdd}, 24))
+		anonymous = ntlmTestAuthenticate("", "", "WS03", []byte{0}, nil)
+	)
+
+	c := newTestConnection(50000, 4444,
+		append([]byte("hello"), negotiate...),
+		append([]byte("data"), ntlmTestChallenge(challenge)...),
+		authV2[:30],
+		authV2[30:],
+		ntlmTestChallenge([]byte{8, 7, 6, 5, 4, 3, 2, 1}),
+		append(authV1, anonymous...),
+	)
+
+	// the first AUTHENTICATE message is split over two segments
+	c.merged[3].dir = reassembly.TCPDirClientToServer
+	c.merged[4].dir = reassembly.TCPDirServerToClient
+	c.merged[5].dir = reassembly.TCPDirClientToServer
+
+	harvestNTLM(c)
+
+	if len(credRecords.records) != 2 {
+		t.Fatal("expected 2 credentials, got", len(credRecords.records))
+	}
+
+	v2 := credRecords.records[0].(*types.Credentials)
+	if v2.Service != serviceNetNTLMv2 || v2.User != "CORP\\alice" || v2.Notes != "workstation: WS01, hashcat mode: 5600" {
+		t.Fatal("unexpected NetNTLMv2 credentials:", v2)
+	}
+
+	if v2.Password != "alice::CORP:0102030405060708:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa:bbbbbbbbbbbbbbbbbbbbbbbb" {
+		t.Fatal("unexpected NetNTLMv2 hash:", v2.Password)
+	}
+
+	v1 := credRecords.records[1].(*types.Credentials)
+	if v1.Service != serviceNetNTLMv1 || v1.User != "CORP\\bob" {
+		t.Fatal("unexpected NetNTLMv1 credentials:", v1)
+	}
+
+	expected := "bob::CORP:" + string(bytes.Repeat([]byte("cc"), 24)) + ":" + string(bytes.Repeat([]byte("dd"), 24)) + ":0807060504030201"
+	if v1.Password != expected {
+		t.Fatal("unexpected NetNTLMv1 hash:", v1.Password)
+	}
+}
+
+func TestNTLMHeaderToken(t *testing.T) {
+	challenge := ntlmTestChallenge([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	token := ntlmHeaderToken([]string{"Basic realm=\"test\"", "NTLM " + base64.StdEncoding.EncodeToString(challenge)})
+	if !bytes.Equal(parseNTLMChallenge(token), []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Fatal("failed to decode challenge from header")
+	}
+
+	if ntlmHeaderToken([]string{"Negotiate " + base64.StdEncoding.EncodeToString([]byte("kerberos"))}) != nil {
+		t.Fatal("expected no NTLM token")
+	}
+}
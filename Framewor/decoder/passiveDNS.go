@@ -0,0 +1,312 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// PassiveDNSStoreFile is the default filename of the persisted passive DNS database.
+const PassiveDNSStoreFile = "passive-dns.json"
+
+// passiveDNSEntry is an aggregated (query, type, answer) tuple
+// that is persisted on disk as JSON.
+type passiveDNSEntry struct {
+	Query     string    `json:"query"`
+	Type      string    `json:"type"`
+	Answer    string    `json:"answer"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int64     `json:"count"`
+	TTLMin    uint32    `json:"ttlMin"`
+	TTLMax    uint32    `json:"ttlMax"`
+}
+
+// key returns the identifier for the tuple.
+func (e *passiveDNSEntry) key() string {
+	return e.Query + "|" + e.Type + "|" + e.Answer
+}
+
+// merge the observations from another entry for the same tuple into e.
+func (e *passiveDNSEntry) merge(o *passiveDNSEntry) {
+	if o.FirstSeen.Before(e.FirstSeen) {
+		e.FirstSeen = o.FirstSeen
+	}
+
+	if o.LastSeen.After(e.LastSeen) {
+		e.LastSeen = o.LastSeen
+	}
+
+	if o.TTLMin < e.TTLMin {
+		e.TTLMin = o.TTLMin
+	}
+
+	if o.TTLMax > e.TTLMax {
+		e.TTLMax = o.TTLMax
+	}
+
+	e.Count += o.Count
+}
+
+// toAuditRecord converts the entry into a PassiveDNS audit record.
+func (e *passiveDNSEntry) toAuditRecord() *types.PassiveDNS {
+	return &types.PassiveDNS{
+		Timestamp: utils.TimeToString(e.FirstSeen),
+		Query:     e.Query,
+		Type:      e.Type,
+		Answer:    e.Answer,
+		FirstSeen: utils.TimeToString(e.FirstSeen),
+		LastSeen:  utils.TimeToString(e.LastSeen),
+		Count:     e.Count,
+		TTLMin:    e.TTLMin,
+		TTLMax:    e.TTLMax,
+	}
+}
+
+// atomicPassiveDNSMap contains all passive DNS entries and provides synchronized access.
+type atomicPassiveDNSMap struct {
+	// mapped query + type + answer to entry
+	Items map[string]*passiveDNSEntry
+	sync.Mutex
+}
+
+// Size returns the number of elements in the Items map.
+func (a *atomicPassiveDNSMap) Size() int {
+	a.Lock()
+	defer a.Unlock()
+
+	return len(a.Items)
+}
+
+// passiveDNSStore holds all tuples observed during the current run.
+var passiveDNSStore = &atomicPassiveDNSMap{
+	Items: make(map[string]*passiveDNSEntry),
+}
+
+// add an observation of the given tuple to the store.
+func (a *atomicPassiveDNSMap) add(query, typ, answer string, ttl uint32, ts time.Time) {
+	e := &passiveDNSEntry{
+		Query:     query,
+		Type:      typ,
+		Answer:    answer,
+		FirstSeen: ts,
+		LastSeen:  ts,
+		Count:     1,
+		TTLMin:    ttl,
+		TTLMax:    ttl,
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if existing, ok := a.Items[e.key()]; ok {
+		existing.merge(e)
+
+		return
+	}
+
+	a.Items[e.key()] = e
+}
+
+// dnsAnswerData returns a string representation of the data carried by a DNS resource record.
+func dnsAnswerData(a *layers.DNSResourceRecord) string {
+	switch a.Type {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		if a.IP == nil {
+			return ""
+		}
+
+		return a.IP.String()
+	case layers.DNSTypeCNAME:
+		return string(a.CNAME)
+	case layers.DNSTypeNS:
+		return string(a.NS)
+	case layers.DNSTypePTR:
+		return string(a.PTR)
+	case layers.DNSTypeMX:
+		return string(a.MX.Name)
+	case layers.DNSTypeSRV:
+		return string(a.SRV.Name) + ":" + strconv.Itoa(int(a.SRV.Port))
+	case layers.DNSTypeSOA:
+		return string(a.SOA.MName)
+	case layers.DNSTypeTXT:
+		txts := make([]string, len(a.TXTs))
+		for i, t := range a.TXTs {
+			txts[i] = string(t)
+		}
+
+		return strings.Join(txts, " ")
+	default:
+		return ""
+	}
+}
+
+// loadPassiveDNSEntries reads the JSON encoded passive DNS database from disk.
+func loadPassiveDNSEntries(path string) ([]*passiveDNSEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*passiveDNSEntry
+
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// savePassiveDNSEntries writes the passive DNS database as JSON to disk.
+func savePassiveDNSEntries(path string, entries []*passiveDNSEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key() < entries[j].key()
+	})
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, defaultFilesPermission)
+}
+
+// persistPassiveDNS merges the tuples observed in this run with the database at path
+// and writes the result back to disk.
+// The observed entries are not modified, so the audit records only cover the current run.
+func persistPassiveDNS(path string) error {
+	stored, err := loadPassiveDNSEntries(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	merged := make(map[string]*passiveDNSEntry, len(stored))
+	for _, s := range stored {
+		merged[s.key()] = s
+	}
+
+	passiveDNSStore.Lock()
+	for key, e := range passiveDNSStore.Items {
+		if s, ok := merged[key]; ok {
+			s.merge(e)
+
+			continue
+		}
+
+		c := *e
+		merged[key] = &c
+	}
+	passiveDNSStore.Unlock()
+
+	all := make([]*passiveDNSEntry, 0, len(merged))
+	for _, e := range merged {
+		all = append(all, e)
+	}
+
+	utils.DebugLog.Println("saving passive DNS database with", len(all), "entries to", path)
+
+	return savePassiveDNSEntries(path, all)
+}
+
+// LookupPassiveDNS searches the passive DNS database at path
+// for all tuples where the query or answer matches the given name or IP.
+func LookupPassiveDNS(path, term string) ([]*types.PassiveDNS, error) {
+	entries, err := loadPassiveDNSEntries(path)
+	if err != nil {
+		return nil, err
+	}
+
+	term = strings.TrimSuffix(strings.ToLower(term), ".")
+
+	var res []*types.PassiveDNS
+
+	for _, e := range entries {
+		if e.Query == term || strings.TrimSuffix(strings.ToLower(e.Answer), ".") == term {
+			res = append(res, e.toAuditRecord())
+		}
+	}
+
+	return res, nil
+}
+
+var passiveDNSDecoder = newCustomDecoder(
+	types.Type_NC_PassiveDNS,
+	"PassiveDNS",
+	"Passive DNS aggregates observed DNS answers into unique query, type and answer tuples with first and last seen timestamps",
+	nil,
+	func(p gopacket.Packet) proto.Message {
+		l := p.Layer(layers.LayerTypeDNS)
+		if l == nil {
+			return nil
+		}
+
+		dns, ok := l.(*layers.DNS)
+		if !ok || !dns.QR || dns.ResponseCode != layers.DNSResponseCodeNoErr {
+			return nil
+		}
+
+		ts := p.Metadata().Timestamp
+
+		for i := range dns.Answers {
+			a := &dns.Answers[i]
+
+			answer := dnsAnswerData(a)
+			if answer == "" {
+				continue
+			}
+
+			passiveDNSStore.add(
+				strings.TrimSuffix(strings.ToLower(string(a.Name)), "."),
+				a.Type.String(),
+				answer,
+				a.TTL,
+				ts,
+			)
+		}
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		if conf.PassiveDNSStore != "" {
+			err := persistPassiveDNS(conf.PassiveDNSStore)
+			if err != nil {
+				return err
+			}
+		}
+
+		passiveDNSStore.Lock()
+		defer passiveDNSStore.Unlock()
+
+		for _, entry := range passiveDNSStore.Items {
+			e.write(entry.toAuditRecord())
+		}
+
+		return nil
+	},
+)
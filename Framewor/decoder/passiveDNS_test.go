@@ -0,0 +1,130 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+func TestDNSAnswerData(t *testing.T) {
+	a := &layers.DNSResourceRecord{
+		Type: layers.DNSTypeA,
+		IP:   net.ParseIP("93.184.216.34"),
+	}
+	if d := dnsAnswerData(a); d != "93.184.216.34" {
+		t.Fatal("unexpected answer for A record:", d)
+	}
+
+	a = &layers.DNSResourceRecord{
+		Type:  layers.DNSTypeCNAME,
+		CNAME: []byte("www.example.com"),
+	}
+	if d := dnsAnswerData(a); d != "www.example.com" {
+		t.Fatal("unexpected answer for CNAME record:", d)
+	}
+
+	a = &layers.DNSResourceRecord{
+		Type: layers.DNSTypeTXT,
+		TXTs: [][]byte{[]byte("v=spf1"), []byte("-all")},
+	}
+	if d := dnsAnswerData(a); d != "v=spf1 -all" {
+		t.Fatal("unexpected answer for TXT record:", d)
+	}
+}
+
+func TestPassiveDNSPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pdns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		path  = filepath.Join(dir, PassiveDNSStoreFile)
+		first = time.Unix(1000, 0)
+		last  = time.Unix(2000, 0)
+	)
+
+	// first run
+	passiveDNSStore.Items = make(map[string]*passiveDNSEntry)
+	passiveDNSStore.add("example.com", "A", "93.184.216.34", 300, last)
+	passiveDNSStore.add("example.com", "A", "93.184.216.34", 60, last)
+
+	if err = persistPassiveDNS(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// second run
+	passiveDNSStore.Items = make(map[string]*passiveDNSEntry)
+	passiveDNSStore.add("example.com", "A", "93.184.216.34", 600, first)
+	passiveDNSStore.add("example.org", "A", "93.184.216.34", 60, first)
+
+	if err = persistPassiveDNS(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the observations of the current run are not changed by the stored history
+	e := passiveDNSStore.Items["example.com|A|93.184.216.34"]
+	if e.Count != 1 || e.TTLMin != 600 || e.TTLMax != 600 || !e.LastSeen.Equal(first) {
+		t.Fatal("unexpected entry for the current run:", e.Count, e.TTLMin, e.TTLMax, e.LastSeen)
+	}
+
+	stored, err := loadPassiveDNSEntries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 2 {
+		t.Fatal("expected 2 stored entries, got", len(stored))
+	}
+
+	for _, s := range stored {
+		if s.key() != e.key() {
+			continue
+		}
+
+		if s.Count != 3 || s.TTLMin != 60 || s.TTLMax != 600 {
+			t.Fatal("unexpected merge result:", s.Count, s.TTLMin, s.TTLMax)
+		}
+
+		if !s.FirstSeen.Equal(first) || !s.LastSeen.Equal(last) {
+			t.Fatal("unexpected timestamps:", s.FirstSeen, s.LastSeen)
+		}
+	}
+
+	res, err := LookupPassiveDNS(path, "93.184.216.34")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatal("expected 2 entries, got", len(res))
+	}
+
+	res, err = LookupPassiveDNS(path, "Example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Count != 3 {
+		t.Fatal("unexpected lookup result:", res)
+	}
+
+	passiveDNSStore.Items = make(map[string]*passiveDNSEntry)
+}
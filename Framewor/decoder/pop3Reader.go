@@ -491,7 +491,7 @@ func (h *pop3Reader) parseMails() (mails []*types.Mail, user, pass, token string
 
 				for _, reply := range h.pop3Responses[h.resIndex:] {
 					if reply.Command == pop3Dot {
-						mails = append(mails, h.parseMail([]byte(mailBuf)))
+						mails = append(mails, parseMail(h.parent.ident, []byte(mailBuf)))
 						mailBuf = ""
 						numMails++
 						h.resIndex++
@@ -621,7 +621,13 @@ func splitMailHeaderAndBody(buf []byte) (map[string]string, string) {
 			continue
 		}
 
+		// an empty line separates the header from the body,
+		// blank lines in front of the header are skipped
 		if line == "" {
+			if len(header) > 0 {
+				collectBody = true
+			}
+
 			continue
 		}
 
@@ -632,6 +638,14 @@ func splitMailHeaderAndBody(buf []byte) (map[string]string, string) {
 			continue
 		}
 
+		// a message without header fields starts with the body
+		if len(header) == 0 && (len(parts) == 1 || parts[0] == "" || !unicode.IsUpper(rune(parts[0][0]))) {
+			collectBody = true
+			body += line + "\n"
+
+			continue
+		}
+
 		// should be an uppercase char if header field
 		// multi line values start with a whitespace
 		if len(parts[0]) > 0 && unicode.IsUpper(rune(parts[0][0])) {
@@ -647,8 +661,9 @@ func splitMailHeaderAndBody(buf []byte) (map[string]string, string) {
 	}
 }
 
-func (h *pop3Reader) parseMail(buf []byte) *types.Mail {
-	mailDebug(ansi.Yellow, "parseMail", h.parent.ident, ansi.Reset)
+// parseMail parses a RFC 5322 message into a mail record, ident is the stream identifier used for debug output.
+func parseMail(ident string, buf []byte) *types.Mail {
+	mailDebug(ansi.Yellow, "parseMail", ident, ansi.Reset)
 
 	header, body := splitMailHeaderAndBody(buf)
 	mail := &types.Mail{
@@ -666,7 +681,7 @@ func (h *pop3Reader) parseMail(buf []byte) *types.Mail {
 		XOriginatingIP:  header["x-originating-ip"],
 		ContentType:     header[headerContentType],
 		EnvelopeTo:      header["Envelope-To"],
-		Body:            h.parseParts(body),
+		Body:            parseParts(ident, body),
 	}
 	for _, p := range mail.Body {
 		if strings.Contains(p.Header["Content-Disposition"], "attachment") {
@@ -680,7 +695,7 @@ func (h *pop3Reader) parseMail(buf []byte) *types.Mail {
 
 const partIdent = "------=_Part_"
 
-func (h *pop3Reader) parseParts(body string) []*types.MailPart {
+func parseParts(ident string, body string) []*types.MailPart {
 	var (
 		parts        []*types.MailPart
 		currentPart  *types.MailPart
@@ -688,7 +703,7 @@ func (h *pop3Reader) parseParts(body string) []*types.MailPart {
 		tr           = textproto.NewReader(bufio.NewReader(bytes.NewReader([]byte(body))))
 	)
 
-	mailDebug(ansi.White, h.parent.ident, body)
+	mailDebug(ansi.White, ident, body)
 
 	for {
 		line, err := tr.ReadLine()
@@ -702,7 +717,7 @@ func (h *pop3Reader) parseParts(body string) []*types.MailPart {
 			}
 		}
 
-		mailDebug(ansi.Green, h.parent.ident, "readLine", line)
+		mailDebug(ansi.Green, ident, "readLine", line)
 
 		if currentPart != nil {
 			if parsePayload {
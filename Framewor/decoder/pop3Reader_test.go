@@ -0,0 +1,60 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import "testing"
+
+func TestSplitMailHeaderAndBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		subject string
+		body    string
+	}{
+		{
+			name:    "header and body",
+			in:      "From: alice@example.com\r\nSubject: Report\r\n\r\nHello Bob\r\n",
+			subject: "Report",
+			body:    "Hello Bob\n",
+		},
+		{
+			name:    "leading blank lines",
+			in:      "\r\n\r\nFrom: alice@example.com\r\nSubject: Report\r\n\r\nHello Bob\r\n\r\nBye\r\n",
+			subject: "Report",
+			body:    "Hello Bob\n\nBye\n",
+		},
+		{
+			name: "empty header",
+			in:   "\r\nHello Bob\r\nsee you: tomorrow\r\n",
+			body: "Hello Bob\nsee you: tomorrow\n",
+		},
+		{
+			name: "no header",
+			in:   "hello bob\r\n",
+			body: "hello bob\n",
+		},
+	}
+
+	for _, test := range tests {
+		header, body := splitMailHeaderAndBody([]byte(test.in))
+
+		if header["Subject"] != test.subject {
+			t.Fatal(test.name, "unexpected subject:", header["Subject"])
+		}
+
+		if body != test.body {
+			t.Fatalf("%s: unexpected body: %q", test.name, body)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * PostgreSQL frontend/backend protocol version 3
+ */
+
+const (
+	pgProtocolVersion3 = 196608
+	pgSSLRequest       = 80877103
+	pgGSSENCRequest    = 80877104
+	pgCancelRequest    = 80877102
+
+	// authentication request codes
+	pgAuthOK        = 0
+	pgAuthCleartext = 3
+	pgAuthMD5       = 5
+	pgAuthSASL      = 10
+
+	// upper limit for messages kept in memory, used to detect streams that are out of sync
+	pgMaxMessageSize = 64 * 1024 * 1024
+
+	hashcatModePostgresMD5 = 11100
+)
+
+// isPostgreSQL checks if the server sent an authentication request or the default port is used.
+func isPostgreSQL(banner []byte, transport gopacket.Flow) bool {
+	if transport.Dst().String() == "5432" {
+		return true
+	}
+
+	// the response to a SSLRequest is a single byte
+	if len(banner) > 0 && banner[0] == 'N' {
+		banner = banner[1:]
+	}
+
+	if len(banner) < 9 || banner[0] != 'R' {
+		return false
+	}
+
+	length := binary.BigEndian.Uint32(banner[1:5])
+
+	return length >= 8 && length < 1024 && binary.BigEndian.Uint32(banner[5:9]) <= 12
+}
+
+// cString returns the null terminated string at the start of b and the remaining data.
+func cString(b []byte) (string, []byte) {
+	idx := bytes.IndexByte(b, 0)
+	if idx == -1 {
+		return string(b), nil
+	}
+
+	return string(b[:idx]), b[idx+1:]
+}
+
+// pgRows returns the number of rows from a CommandComplete tag, e.g. INSERT 0 1 or SELECT 5.
+func pgRows(tag string) int64 {
+	fields := strings.Fields(tag)
+	if len(fields) < 2 {
+		return 0
+	}
+
+	n, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+type pgQuery struct {
+	record *types.PostgreSQL
+	ts     time.Time
+}
+
+type postgreSQLReader struct {
+	parent *tcpConnection
+
+	serverVersion string
+	user          string
+	database      string
+	application   string
+
+	// the first client message has no type byte
+	startup bool
+
+	// the server responds with a single byte to SSLRequest and GSSENCRequest
+	awaitEncryption bool
+
+	authCode uint32
+	salt     []byte
+
+	// extended query protocol state
+	statements map[string]string
+	portals    map[string]string
+	executed   []string
+
+	// login is set until the server is ready for the first query
+	login   *pgQuery
+	pending []*pgQuery
+
+	done bool
+}
+
+// Decode parses the stream according to the PostgreSQL protocol.
+func (h *postgreSQLReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	h.startup = true
+	h.statements = make(map[string]string)
+	h.portals = make(map[string]string)
+
+	// the merged fragments are ordered by time, so queries are processed before their responses
+	for _, d := range h.parent.merged {
+		if h.done {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readClient(&clientBuf, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.readServer(&serverBuf, ts)
+		}
+	}
+
+	// write queries that did not receive a response
+	if h.login != nil {
+		h.pending = append([]*pgQuery{h.login}, h.pending...)
+	}
+
+	for _, q := range h.pending {
+		q.record.Notes = addInfo(q.record.Notes, "no response")
+		h.write(q.record)
+	}
+
+	h.pending = nil
+}
+
+func (h *postgreSQLReader) newQuery(command, statement string, ts time.Time) *pgQuery {
+	return &pgQuery{
+		ts: ts,
+		record: &types.PostgreSQL{
+			Timestamp:     utils.TimeToString(ts),
+			ClientIP:      h.parent.net.Src().String(),
+			ServerIP:      h.parent.net.Dst().String(),
+			Flow:          h.parent.ident,
+			ServerVersion: h.serverVersion,
+			User:          h.user,
+			Database:      h.database,
+			Application:   h.application,
+			Command:       command,
+			Statement:     statement,
+		},
+	}
+}
+
+// readMessage returns the next message with the type and the payload without the length field.
+// The startup message has no type byte.
+func (h *postgreSQLReader) readMessage(buf *bytes.Buffer, typed bool) (byte, []byte, bool) {
+	var (
+		data   = buf.Bytes()
+		header = 4
+		typ    byte
+	)
+
+	if typed {
+		header = 5
+	}
+
+	if len(data) < header {
+		return 0, nil, false
+	}
+
+	if typed {
+		typ = data[0]
+	}
+
+	length := int(binary.BigEndian.Uint32(data[header-4:]))
+	if length < 4 || length > pgMaxMessageSize {
+		utils.DebugLog.Println("PostgreSQL: invalid message length on", h.parent.ident, length)
+
+		h.done = true
+
+		return 0, nil, false
+	}
+
+	if len(data) < header-4+length {
+		return 0, nil, false
+	}
+
+	msg := buf.Next(header - 4 + length)
+
+	return typ, msg[header:], true
+}
+
+func (h *postgreSQLReader) readClient(buf *bytes.Buffer, ts time.Time) {
+	for !h.done {
+		typ, msg, ok := h.readMessage(buf, !h.startup)
+		if !ok {
+			return
+		}
+
+		if h.startup {
+			h.handleStartup(msg, ts)
+
+			continue
+		}
+
+		switch typ {
+		case 'Q': // simple query
+			statement, _ := cString(msg)
+			h.pending = append(h.pending, h.newQuery("Query", statement, ts))
+		case 'P': // parse: statement name and query
+			name, rest := cString(msg)
+			statement, _ := cString(rest)
+			h.statements[name] = statement
+		case 'B': // bind: portal name and statement name
+			portal, rest := cString(msg)
+			name, _ := cString(rest)
+			h.portals[portal] = h.statements[name]
+		case 'E': // execute: portal name
+			portal, _ := cString(msg)
+			h.executed = append(h.executed, h.portals[portal])
+		case 'S': // sync, the server responds with ReadyForQuery
+			if len(h.executed) > 0 {
+				h.pending = append(h.pending, h.newQuery("Execute", strings.Join(h.executed, "; "), ts))
+				h.executed = nil
+			}
+		case 'p': // password, SASL or GSSAPI response depending on the authentication request
+			h.handlePassword(msg, ts)
+		case 'X': // terminate
+			return
+		}
+	}
+}
+
+func (h *postgreSQLReader) handleStartup(msg []byte, ts time.Time) {
+	if len(msg) < 4 {
+		return
+	}
+
+	switch binary.BigEndian.Uint32(msg) {
+	case pgSSLRequest, pgGSSENCRequest:
+		h.awaitEncryption = true
+	case pgCancelRequest:
+		h.done = true
+	case pgProtocolVersion3:
+		// parameters are pairs of null terminated strings
+		params := msg[4:]
+		for len(params) > 1 {
+			var key, value string
+
+			key, params = cString(params)
+			value, params = cString(params)
+
+			switch key {
+			case "user":
+				h.user = value
+			case "database":
+				h.database = value
+			case "application_name":
+				h.application = value
+			}
+		}
+
+		// the database defaults to the user name
+		if h.database == "" {
+			h.database = h.user
+		}
+
+		h.startup = false
+		h.login = h.newQuery("Login", "", ts)
+	default:
+		utils.DebugLog.Println("PostgreSQL: unsupported protocol version on", h.parent.ident)
+
+		h.done = true
+	}
+}
+
+func (h *postgreSQLReader) handlePassword(msg []byte, ts time.Time) {
+	var (
+		password, _ = cString(msg)
+		creds       = &types.Credentials{
+			Timestamp: utils.TimeToString(ts),
+			Service:   servicePostgreSQL,
+			Flow:      h.parent.ident,
+			User:      h.user,
+		}
+	)
+
+	switch h.authCode {
+	case pgAuthCleartext:
+		creds.Password = password
+	case pgAuthMD5:
+		if !strings.HasPrefix(password, "md5") || len(h.salt) != 4 {
+			return
+		}
+
+		creds.Password = "$postgres$" + h.user + "*" + hex.EncodeToString(h.salt) + "*" + strings.TrimPrefix(password, "md5")
+		creds.Notes = "hashcat mode: " + strconv.Itoa(hashcatModePostgresMD5)
+	case pgAuthSASL:
+		// SASLInitialResponse starts with the selected mechanism
+		if h.login != nil {
+			h.login.record.Notes = addInfo(h.login.record.Notes, "SASL "+password)
+		}
+
+		return
+	default:
+		return
+	}
+
+	if useHarvesters {
+		writeCredentials(creds)
+	}
+}
+
+func (h *postgreSQLReader) readServer(buf *bytes.Buffer, ts time.Time) {
+	if h.awaitEncryption {
+		if buf.Len() == 0 {
+			return
+		}
+
+		h.awaitEncryption = false
+
+		switch buf.Bytes()[0] {
+		case 'S', 'G':
+			// the remaining conversation is encrypted
+			h.done = true
+
+			return
+		case 'N':
+			buf.Next(1)
+		}
+	}
+
+	for !h.done {
+		typ, msg, ok := h.readMessage(buf, true)
+		if !ok {
+			return
+		}
+
+		switch typ {
+		case 'R': // authentication request
+			if len(msg) < 4 {
+				continue
+			}
+
+			h.authCode = binary.BigEndian.Uint32(msg)
+			if h.authCode == pgAuthMD5 && len(msg) >= 8 {
+				h.salt = append([]byte(nil), msg[4:8]...)
+			}
+		case 'S': // parameter status
+			key, rest := cString(msg)
+			if key == "server_version" {
+				h.serverVersion, _ = cString(rest)
+				if h.login != nil {
+					h.login.record.ServerVersion = h.serverVersion
+				}
+			}
+		case 'E': // error response
+			if q := h.current(); q != nil {
+				q.record.Status = "ERROR"
+
+				// fields are identified by a single byte followed by a null terminated string
+				for len(msg) > 1 {
+					field := msg[0]
+
+					var value string
+					value, msg = cString(msg[1:])
+
+					switch field {
+					case 'C':
+						q.record.ErrorCode = value
+					case 'M':
+						q.record.Error = value
+					}
+				}
+			}
+
+			// a failed login terminates the connection
+			if h.login != nil {
+				h.complete(h.login, ts)
+				h.login = nil
+			}
+		case 'C': // command complete
+			if q := h.current(); q != nil {
+				tag, _ := cString(msg)
+				q.record.Rows += pgRows(tag)
+			}
+		case 'Z': // ready for query
+			if h.login != nil {
+				h.complete(h.login, ts)
+				h.login = nil
+			} else if len(h.pending) > 0 {
+				h.complete(h.pending[0], ts)
+				h.pending = h.pending[1:]
+			}
+		}
+	}
+}
+
+// current returns the login or query the server is responding to.
+func (h *postgreSQLReader) current() *pgQuery {
+	if h.login != nil {
+		return h.login
+	}
+
+	if len(h.pending) > 0 {
+		return h.pending[0]
+	}
+
+	return nil
+}
+
+func (h *postgreSQLReader) complete(q *pgQuery, ts time.Time) {
+	if q.record.Status == "" {
+		q.record.Status = "OK"
+	}
+
+	q.record.Latency = ts.Sub(q.ts).Nanoseconds()
+
+	h.write(q.record)
+}
+
+func (h *postgreSQLReader) write(r *types.PostgreSQL) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&postgreSQLDecoder.numRecords, 1)
+
+	err := postgreSQLDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
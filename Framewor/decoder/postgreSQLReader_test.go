@@ -0,0 +1,113 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func pgTestMessage(typ byte, data ...string) []byte {
+	payload := []byte(strings.Join(data, ""))
+	out := make([]byte, 5, 5+len(payload))
+	out[0] = typ
+	binary.BigEndian.PutUint32(out[1:], uint32(4+len(payload)))
+
+	return append(out, payload...)
+}
+
+func pgTestStartup(params ...string) []byte {
+	payload := []byte{0, 3, 0, 0}
+	for _, p := range params {
+		payload = append(payload, p...)
+		payload = append(payload, 0)
+	}
+
+	payload = append(payload, 0)
+
+	out := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(out, uint32(4+len(payload)))
+
+	return append(out, payload...)
+}
+
+func TestPostgreSQLReader(t *testing.T) {
+	var (
+		pgRecords   = &recordCollector{}
+		credRecords = &recordCollector{}
+		oldConf     = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+	}()
+
+	conf = &Config{}
+	useHarvesters = true
+	postgreSQLDecoder.writer = pgRecords
+	credentialsDecoder.writer = credRecords
+
+	authMD5 := pgTestMessage('R', "\x00\x00\x00\x05\x01\x02\x03\x04")
+	if !isPostgreSQL(authMD5, newTestConnection(50000, 15432).transport) {
+		t.Fatal("failed to detect PostgreSQL")
+	}
+
+	// queries are pipelined and the authentication result is split into two segments
+	c := newTestConnection(50000, 5432,
+		pgTestStartup("user", "alice", "database", "shop", "application_name", "psql"),
+		authMD5,
+		pgTestMessage('p', "md5a1b2c3\x00"),
+		pgTestMessage('R', "\x00\x00\x00\x00")[:5],
+		pgTestMessage('Q', "SELECT * FROM users\x00"),
+		append(append([]byte{0, 0, 0, 0}, pgTestMessage('S', "server_version\x00", "12.4\x00")...), pgTestMessage('Z', "I")...),
+		pgTestMessage('Q', "DROP TABLE orders\x00"),
+		append(append(pgTestMessage('T', "\x00\x00"), pgTestMessage('C', "SELECT 3\x00")...), pgTestMessage('Z', "I")...),
+		append(pgTestMessage('E', "SERROR\x00", "C42501\x00", "Mpermission denied\x00\x00"), pgTestMessage('Z', "I")...),
+	)
+	c.merged[8].dir = c.merged[7].dir
+
+	(&postgreSQLReader{parent: c}).Decode()
+
+	if len(pgRecords.records) != 3 {
+		t.Fatal("expected 3 PostgreSQL records, got", len(pgRecords.records))
+	}
+
+	r := pgRecords.records[0].(*types.PostgreSQL)
+	if r.Command != "Login" || r.User != "alice" || r.Database != "shop" || r.Application != "psql" || r.ServerVersion != "12.4" || r.Status != "OK" {
+		t.Fatal("unexpected login record:", r)
+	}
+
+	r = pgRecords.records[1].(*types.PostgreSQL)
+	if r.Command != "Query" || r.Statement != "SELECT * FROM users" || r.Rows != 3 || r.Status != "OK" || r.Latency <= 0 {
+		t.Fatal("unexpected query record:", r)
+	}
+
+	r = pgRecords.records[2].(*types.PostgreSQL)
+	if r.Statement != "DROP TABLE orders" || r.Status != "ERROR" || r.ErrorCode != "42501" || r.Error != "permission denied" {
+		t.Fatal("unexpected failed query record:", r)
+	}
+
+	if len(credRecords.records) != 1 {
+		t.Fatal("expected 1 credential, got", len(credRecords.records))
+	}
+
+	creds := credRecords.records[0].(*types.Credentials)
+	if creds.Service != servicePostgreSQL || creds.User != "alice" || creds.Password != "$postgres$alice*01020304*a1b2c3" {
+		t.Fatal("unexpected credentials:", creds)
+	}
+}
@@ -0,0 +1,485 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * RADIUS - Remote Authentication Dial In User Service
+ */
+
+const (
+	serviceRADIUS = "RADIUS"
+
+	radiusHeaderLen = 20
+
+	// packet codes
+	radiusAccessRequest      = 1
+	radiusAccessAccept       = 2
+	radiusAccessReject       = 3
+	radiusAccountingRequest  = 4
+	radiusAccountingResponse = 5
+	radiusAccessChallenge    = 11
+	radiusDisconnectRequest  = 40
+	radiusDisconnectACK      = 41
+	radiusDisconnectNAK      = 42
+	radiusCoARequest         = 43
+	radiusCoAACK             = 44
+	radiusCoANAK             = 45
+
+	// attribute types
+	radiusAttrUserName            = 1
+	radiusAttrUserPassword        = 2
+	radiusAttrCHAPPassword        = 3
+	radiusAttrNASIPAddress        = 4
+	radiusAttrNASPort             = 5
+	radiusAttrFramedIPAddress     = 8
+	radiusAttrReplyMessage        = 18
+	radiusAttrVendorSpecific      = 26
+	radiusAttrCalledStationID     = 30
+	radiusAttrCallingStationID    = 31
+	radiusAttrNASIdentifier       = 32
+	radiusAttrAcctStatusType      = 40
+	radiusAttrAcctInputOctets     = 42
+	radiusAttrAcctOutputOctets    = 43
+	radiusAttrAcctSessionID       = 44
+	radiusAttrAcctSessionTime     = 46
+	radiusAttrAcctTerminateCause  = 49
+	radiusAttrAcctInputGigawords  = 52
+	radiusAttrAcctOutputGigawords = 53
+	radiusAttrEAPMessage          = 79
+	radiusAttrNASPortID           = 87
+	radiusAttrNASIPv6Address      = 95
+	radiusAttrFramedIPv6Address   = 168
+
+	// accounting status types
+	radiusAcctStart   = 1
+	radiusAcctStop    = 2
+	radiusAcctInterim = 3
+
+	radiusVendorMicrosoft    = 311
+	radiusMSCHAPResponse     = 1
+	radiusMSCHAP2Response    = 25
+	radiusEAPCodeResponse    = 2
+	radiusEAPMinResponseSize = 5
+)
+
+var (
+	radiusCodes = map[byte]string{
+		radiusAccessRequest:      "Access-Request",
+		radiusAccessAccept:       "Access-Accept",
+		radiusAccessReject:       "Access-Reject",
+		radiusAccountingRequest:  "Accounting-Request",
+		radiusAccountingResponse: "Accounting-Response",
+		radiusAccessChallenge:    "Access-Challenge",
+		radiusDisconnectRequest:  "Disconnect-Request",
+		radiusDisconnectACK:      "Disconnect-ACK",
+		radiusDisconnectNAK:      "Disconnect-NAK",
+		radiusCoARequest:         "CoA-Request",
+		radiusCoAACK:             "CoA-ACK",
+		radiusCoANAK:             "CoA-NAK",
+	}
+
+	radiusAcctStatusTypes = map[uint32]string{
+		radiusAcctStart:   "Start",
+		radiusAcctStop:    "Stop",
+		radiusAcctInterim: "Interim-Update",
+		7:                 "Accounting-On",
+		8:                 "Accounting-Off",
+	}
+
+	radiusTerminateCauses = map[uint32]string{
+		1:  "User-Request",
+		2:  "Lost-Carrier",
+		3:  "Lost-Service",
+		4:  "Idle-Timeout",
+		5:  "Session-Timeout",
+		6:  "Admin-Reset",
+		7:  "Admin-Reboot",
+		8:  "Port-Error",
+		9:  "NAS-Error",
+		10: "NAS-Request",
+		11: "NAS-Reboot",
+		12: "Port-Unneeded",
+		13: "Port-Preempted",
+		14: "Port-Suspended",
+		15: "Service-Unavailable",
+		16: "Callback",
+		17: "User-Error",
+		18: "Host-Request",
+	}
+
+	radiusEAPTypes = map[byte]string{
+		4:  "EAP-MD5",
+		13: "EAP-TLS",
+		17: "LEAP",
+		21: "EAP-TTLS",
+		25: "PEAP",
+		26: "EAP-MSCHAPv2",
+		43: "EAP-FAST",
+	}
+
+	errRADIUSInvalidPacket = errors.New("invalid RADIUS packet")
+)
+
+// isRADIUSPort checks for the authentication, accounting and dynamic authorization ports, including the legacy ones.
+func isRADIUSPort(port layers.UDPPort) bool {
+	switch port {
+	case 1812, 1813, 1645, 1646, 3799:
+		return true
+	}
+
+	return false
+}
+
+// isRADIUSRequest checks if the code is sent by the client.
+func isRADIUSRequest(code byte) bool {
+	switch code {
+	case radiusAccessRequest, radiusAccountingRequest, radiusDisconnectRequest, radiusCoARequest:
+		return true
+	}
+
+	return false
+}
+
+type radiusPacket struct {
+	code       byte
+	identifier byte
+	acctStatus uint32
+	record     *types.RADIUS
+
+	// the Acct-Session-Time attribute was present
+	hasSessionTime bool
+}
+
+// radiusUint32 decodes a big endian integer attribute.
+func radiusUint32(b []byte) uint32 {
+	if len(b) != 4 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+// parseRADIUS decodes the header and the attributes of a RADIUS packet.
+func parseRADIUS(data []byte) (*radiusPacket, error) {
+	if len(data) < radiusHeaderLen {
+		return nil, errRADIUSInvalidPacket
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < radiusHeaderLen || length > len(data) {
+		return nil, errRADIUSInvalidPacket
+	}
+
+	name, ok := radiusCodes[data[0]]
+	if !ok {
+		return nil, errRADIUSInvalidPacket
+	}
+
+	var (
+		pkt = &radiusPacket{
+			code:       data[0],
+			identifier: data[1],
+			record: &types.RADIUS{
+				Identifier: int32(data[1]),
+				Code:       name,
+			},
+		}
+		r                         = pkt.record
+		inGigawords, outGigawords uint32
+		inOctets, outOctets       bool
+		attrs                     = data[radiusHeaderLen:length]
+	)
+
+	for len(attrs) >= 2 {
+		typ, size := attrs[0], int(attrs[1])
+		if size < 2 || size > len(attrs) {
+			return pkt, errRADIUSInvalidPacket
+		}
+
+		value := attrs[2:size]
+		attrs = attrs[size:]
+
+		switch typ {
+		case radiusAttrUserName:
+			r.User = string(value)
+		case radiusAttrUserPassword:
+			r.AuthMethod = "PAP"
+		case radiusAttrCHAPPassword:
+			r.AuthMethod = "CHAP"
+		case radiusAttrEAPMessage:
+			// the method is announced in the type field of EAP responses
+			if r.AuthMethod == "" {
+				r.AuthMethod = "EAP"
+			}
+
+			if len(value) >= radiusEAPMinResponseSize && value[0] == radiusEAPCodeResponse {
+				if method, ok := radiusEAPTypes[value[4]]; ok {
+					r.AuthMethod = method
+				}
+			}
+		case radiusAttrVendorSpecific:
+			if len(value) >= 6 && binary.BigEndian.Uint32(value) == radiusVendorMicrosoft {
+				switch value[4] {
+				case radiusMSCHAPResponse:
+					r.AuthMethod = "MS-CHAP"
+				case radiusMSCHAP2Response:
+					r.AuthMethod = "MS-CHAPv2"
+				}
+			}
+		case radiusAttrNASIPAddress, radiusAttrNASIPv6Address:
+			r.NASIP = net.IP(value).String()
+		case radiusAttrNASPort:
+			if r.NASPort == "" {
+				r.NASPort = strconv.FormatUint(uint64(radiusUint32(value)), 10)
+			}
+		case radiusAttrNASPortID:
+			r.NASPort = string(value)
+		case radiusAttrNASIdentifier:
+			r.NASIdentifier = string(value)
+		case radiusAttrFramedIPAddress, radiusAttrFramedIPv6Address:
+			r.FramedIP = net.IP(value).String()
+		case radiusAttrCallingStationID:
+			r.CallingStationID = string(value)
+		case radiusAttrCalledStationID:
+			r.CalledStationID = string(value)
+		case radiusAttrReplyMessage:
+			r.ReplyMessage += string(value)
+		case radiusAttrAcctStatusType:
+			pkt.acctStatus = radiusUint32(value)
+			if s, ok := radiusAcctStatusTypes[pkt.acctStatus]; ok {
+				r.AcctStatus = s
+			} else {
+				r.AcctStatus = strconv.FormatUint(uint64(pkt.acctStatus), 10)
+			}
+		case radiusAttrAcctSessionID:
+			r.SessionID = string(value)
+		case radiusAttrAcctSessionTime:
+			r.SessionDuration = int64(radiusUint32(value))
+			pkt.hasSessionTime = true
+		case radiusAttrAcctInputOctets:
+			r.InputOctets = uint64(radiusUint32(value))
+			inOctets = true
+		case radiusAttrAcctOutputOctets:
+			r.OutputOctets = uint64(radiusUint32(value))
+			outOctets = true
+		case radiusAttrAcctInputGigawords:
+			inGigawords = radiusUint32(value)
+		case radiusAttrAcctOutputGigawords:
+			outGigawords = radiusUint32(value)
+		case radiusAttrAcctTerminateCause:
+			cause := radiusUint32(value)
+			if s, ok := radiusTerminateCauses[cause]; ok {
+				r.TerminateCause = s
+			} else {
+				r.TerminateCause = strconv.FormatUint(uint64(cause), 10)
+			}
+		}
+	}
+
+	// counters overflow into the gigawords attributes
+	if inOctets {
+		r.InputOctets += uint64(inGigawords) << 32
+	}
+
+	if outOctets {
+		r.OutputOctets += uint64(outGigawords) << 32
+	}
+
+	return pkt, nil
+}
+
+type radiusRequest struct {
+	record *types.RADIUS
+	ts     time.Time
+}
+
+type radiusSession struct {
+	user  string
+	start time.Time
+}
+
+// radiusState correlates requests with their responses and accounting start with stop messages.
+var radiusState = struct {
+	// client and server endpoints with the identifier to requests
+	requests map[string]*radiusRequest
+
+	// NAS and accounting session id to sessions
+	sessions map[string]*radiusSession
+
+	sync.Mutex
+}{
+	requests: make(map[string]*radiusRequest),
+	sessions: make(map[string]*radiusSession),
+}
+
+// radiusKey returns the key used to correlate a request with its response.
+func radiusKey(clientIP, serverIP string, clientPort, serverPort int32, identifier int32) string {
+	return clientIP + ":" + strconv.Itoa(int(clientPort)) + "-" + serverIP + ":" + strconv.Itoa(int(serverPort)) + "-" + strconv.Itoa(int(identifier))
+}
+
+// trackSession updates the accounting sessions and sets the session duration of interim updates and stop messages.
+func (pkt *radiusPacket) trackSession(ts time.Time) {
+	r := pkt.record
+	if r.SessionID == "" {
+		return
+	}
+
+	key := r.NASIP + r.NASIdentifier + "-" + r.SessionID
+
+	radiusState.Lock()
+	defer radiusState.Unlock()
+
+	switch pkt.acctStatus {
+	case radiusAcctStart:
+		radiusState.sessions[key] = &radiusSession{user: r.User, start: ts}
+	case radiusAcctInterim, radiusAcctStop:
+		s, ok := radiusState.sessions[key]
+		if ok && !pkt.hasSessionTime {
+			r.SessionDuration = int64(ts.Sub(s.start).Seconds())
+		}
+
+		if ok && r.User == "" {
+			r.User = s.user
+		}
+
+		if pkt.acctStatus == radiusAcctStop {
+			delete(radiusState.sessions, key)
+		}
+	}
+}
+
+// handle correlates the packet and returns the record of a transaction once the response was seen.
+func (pkt *radiusPacket) handle(srcIP, dstIP string, srcPort, dstPort int32, ts time.Time) *types.RADIUS {
+	r := pkt.record
+
+	if isRADIUSRequest(pkt.code) {
+		r.Timestamp = utils.TimeToString(ts)
+		r.ClientIP, r.ServerIP = srcIP, dstIP
+		r.ClientPort, r.ServerPort = srcPort, dstPort
+
+		if pkt.code == radiusAccountingRequest {
+			pkt.trackSession(ts)
+
+			// attribute the assigned address to the user
+			if r.FramedIP != "" && r.User != "" && pkt.acctStatus != radiusAcctStop {
+				addIPProfileUser(r.FramedIP, r.User)
+			}
+		}
+
+		radiusState.Lock()
+		radiusState.requests[radiusKey(srcIP, dstIP, srcPort, dstPort, r.Identifier)] = &radiusRequest{record: r, ts: ts}
+		radiusState.Unlock()
+
+		return nil
+	}
+
+	key := radiusKey(dstIP, srcIP, dstPort, srcPort, r.Identifier)
+
+	radiusState.Lock()
+	req, ok := radiusState.requests[key]
+	delete(radiusState.requests, key)
+	radiusState.Unlock()
+
+	if !ok {
+		r.Timestamp = utils.TimeToString(ts)
+		r.ClientIP, r.ServerIP = dstIP, srcIP
+		r.ClientPort, r.ServerPort = dstPort, srcPort
+		r.Response, r.Code = r.Code, ""
+		r.Notes = "no request"
+
+		return r
+	}
+
+	req.record.Response = r.Code
+	req.record.Latency = ts.Sub(req.ts).Nanoseconds()
+
+	// attributes assigned by the server
+	if r.FramedIP != "" {
+		req.record.FramedIP = r.FramedIP
+	}
+
+	if r.ReplyMessage != "" {
+		req.record.ReplyMessage = r.ReplyMessage
+	}
+
+	return req.record
+}
+
+var radiusDecoder = newCustomDecoder(
+	types.Type_NC_RADIUS,
+	serviceRADIUS,
+	"RADIUS provides authentication, authorization and accounting for network access",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		l := innerLayers(p)
+		if l.network == nil {
+			return nil
+		}
+
+		udp, ok := l.transport.(*layers.UDP)
+		if !ok || !(isRADIUSPort(udp.SrcPort) || isRADIUSPort(udp.DstPort)) {
+			return nil
+		}
+
+		pkt, err := parseRADIUS(udp.Payload)
+		if pkt == nil {
+			return nil
+		}
+
+		if err != nil {
+			pkt.record.Notes = addInfo(pkt.record.Notes, err.Error())
+		}
+
+		nf := l.network.NetworkFlow()
+
+		r := pkt.handle(nf.Src().String(), nf.Dst().String(), int32(udp.SrcPort), int32(udp.DstPort), p.Metadata().Timestamp)
+		if r == nil {
+			return nil
+		}
+
+		return r
+	},
+	func(e *customDecoder) error {
+		// write requests that did not receive a response
+		radiusState.Lock()
+		defer radiusState.Unlock()
+
+		for key, req := range radiusState.requests {
+			req.record.Notes = addInfo(req.record.Notes, "no response")
+			e.write(req.record)
+
+			delete(radiusState.requests, key)
+		}
+
+		return nil
+	},
+)
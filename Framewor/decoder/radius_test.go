@@ -0,0 +1,126 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func radiusTestAttr(typ byte, value []byte) []byte {
+	return append([]byte{typ, byte(2 + len(value))}, value...)
+}
+
+func radiusTestUint32(typ byte, v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+
+	return radiusTestAttr(typ, b)
+}
+
+func radiusTestPacket(code, id byte, attrs ...[]byte) []byte {
+	pkt := make([]byte, radiusHeaderLen)
+	pkt[0], pkt[1] = code, id
+
+	for _, a := range attrs {
+		pkt = append(pkt, a...)
+	}
+
+	binary.BigEndian.PutUint16(pkt[2:], uint16(len(pkt)))
+
+	return pkt
+}
+
+func TestRADIUS(t *testing.T) {
+	var (
+		ts    = time.Unix(1600000000, 0)
+		nas   = radiusTestAttr(radiusAttrNASIPAddress, []byte{10, 0, 0, 1})
+		user  = radiusTestAttr(radiusAttrUserName, []byte("alice"))
+		sess  = radiusTestAttr(radiusAttrAcctSessionID, []byte("0000002A"))
+		ip    = radiusTestAttr(radiusAttrFramedIPAddress, []byte{10, 1, 0, 5})
+		check = func(pkt []byte, src, dst string, srcPort, dstPort int32, ts time.Time) *types.RADIUS {
+			p, err := parseRADIUS(pkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return p.handle(src, dst, srcPort, dstPort, ts)
+		}
+	)
+
+	defer func() {
+		ipProfiles.Lock()
+		delete(ipProfiles.Items, "10.1.0.5")
+		ipProfiles.Unlock()
+
+		ipUsers.Lock()
+		delete(ipUsers.Items, "10.1.0.5")
+		ipUsers.Unlock()
+	}()
+
+	// authentication
+	req := radiusTestPacket(radiusAccessRequest, 7, user, radiusTestAttr(radiusAttrUserPassword, make([]byte, 16)), nas,
+		radiusTestAttr(radiusAttrCallingStationID, []byte("00-11-22-33-44-55")))
+	if r := check(req, "10.0.0.1", "10.0.0.2", 50000, 1812, ts); r != nil {
+		t.Fatal("expected no record for a request:", r)
+	}
+
+	r := check(radiusTestPacket(radiusAccessAccept, 7, ip), "10.0.0.2", "10.0.0.1", 1812, 50000, ts.Add(time.Millisecond))
+	if r == nil || r.Code != "Access-Request" || r.Response != "Access-Accept" || r.User != "alice" || r.AuthMethod != "PAP" {
+		t.Fatal("unexpected authentication record:", r)
+	}
+
+	if r.NASIP != "10.0.0.1" || r.CallingStationID != "00-11-22-33-44-55" || r.FramedIP != "10.1.0.5" || r.Latency != int64(time.Millisecond) {
+		t.Fatal("unexpected authentication attributes:", r)
+	}
+
+	ipProfiles.Lock()
+	ipProfiles.Items["10.1.0.5"] = &ipProfile{IPProfile: &types.IPProfile{Addr: "10.1.0.5"}}
+	ipProfiles.Unlock()
+
+	// accounting start
+	start := radiusTestPacket(radiusAccountingRequest, 8, user, nas, sess, ip, radiusTestUint32(radiusAttrAcctStatusType, radiusAcctStart))
+	check(start, "10.0.0.1", "10.0.0.2", 50001, 1813, ts)
+
+	r = check(radiusTestPacket(radiusAccountingResponse, 8), "10.0.0.2", "10.0.0.1", 1813, 50001, ts)
+	if r == nil || r.AcctStatus != "Start" || r.SessionID != "0000002A" || r.Response != "Accounting-Response" {
+		t.Fatal("unexpected accounting start record:", r)
+	}
+
+	// the user is attributed to the address, also for profiles created later on
+	if p := ipProfiles.Items["10.1.0.5"]; len(p.Users) != 1 || p.Users[0] != "alice" {
+		t.Fatal("unexpected users in profile:", p.Users)
+	}
+
+	if users := ipUsers.Items["10.1.0.5"]; len(users) != 1 || users[0] != "alice" {
+		t.Fatal("unexpected users for address:", users)
+	}
+
+	// accounting stop without a session time and with octet counters larger than 32 bits
+	stop := radiusTestPacket(radiusAccountingRequest, 9, nas, sess,
+		radiusTestUint32(radiusAttrAcctStatusType, radiusAcctStop),
+		radiusTestUint32(radiusAttrAcctInputOctets, 10),
+		radiusTestUint32(radiusAttrAcctInputGigawords, 1),
+		radiusTestUint32(radiusAttrAcctTerminateCause, 1),
+	)
+	check(stop, "10.0.0.1", "10.0.0.2", 50001, 1813, ts.Add(90*time.Second))
+
+	r = check(radiusTestPacket(radiusAccountingResponse, 9), "10.0.0.2", "10.0.0.1", 1813, 50001, ts.Add(90*time.Second))
+	if r == nil || r.AcctStatus != "Stop" || r.User != "alice" || r.SessionDuration != 90 || r.InputOctets != 1<<32+10 || r.TerminateCause != "User-Request" {
+		t.Fatal("unexpected accounting stop record:", r)
+	}
+}
@@ -0,0 +1,334 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+/*
+ * RDP - Remote Desktop Protocol
+ */
+
+const (
+	serviceRDP = "RDP"
+
+	rdpTPKTVersion = 3
+
+	// X.224 TPDU codes
+	x224ConnectionRequest = 0xe0
+	x224ConnectionConfirm = 0xd0
+
+	// RDP negotiation message types
+	rdpNegRequest  = 0x01
+	rdpNegResponse = 0x02
+	rdpNegFailure  = 0x03
+
+	// type of the client core data block in the MCS Connect Initial user data
+	rdpClientCoreData = 0xc001
+
+	// length of the mandatory client core data fields and offsets of the optional ones
+	rdpCoreMinLen               = 132
+	rdpCoreHighColorDepth       = 140
+	rdpCoreSupportedColorDepths = 142
+	rdpCoreEarlyCapabilityFlags = 144
+)
+
+var rdpProtocols = []struct {
+	flag uint32
+	name string
+}{
+	{0x01, "PROTOCOL_SSL"},
+	{0x02, "PROTOCOL_HYBRID"},
+	{0x04, "PROTOCOL_RDSTLS"},
+	{0x08, "PROTOCOL_HYBRID_EX"},
+	{0x10, "PROTOCOL_RDSAAD"},
+}
+
+const rdpProtocolRDP = "PROTOCOL_RDP"
+
+var rdpNegFailures = map[uint32]string{
+	1: "SSL_REQUIRED_BY_SERVER",
+	2: "SSL_NOT_ALLOWED_BY_SERVER",
+	3: "SSL_CERT_NOT_ON_SERVER",
+	4: "INCONSISTENT_FLAGS",
+	5: "HYBRID_REQUIRED_BY_SERVER",
+	6: "SSL_WITH_USER_AUTH_REQUIRED_BY_SERVER",
+}
+
+// rdpProtocolNames returns the names of the security protocols set in flags.
+func rdpProtocolNames(flags uint32) []string {
+	if flags == 0 {
+		return []string{rdpProtocolRDP}
+	}
+
+	var names []string
+
+	for _, p := range rdpProtocols {
+		if flags&p.flag != 0 {
+			names = append(names, p.name)
+		}
+	}
+
+	return names
+}
+
+// rdpVersionName returns the RDP version advertised in the client core data.
+func rdpVersionName(v uint32) string {
+	switch {
+	case v == 0x00080001:
+		return "4.0"
+	case v == 0x00080004:
+		return "5.0-8.1"
+	case v >= 0x00080005 && v>>16 == 0x0008:
+		return "10." + strconv.Itoa(int(v&0xffff)-5)
+	}
+
+	return fmt.Sprintf("0x%08x", v)
+}
+
+// rdpClient contains the client properties used to create the fingerprint.
+type rdpClient struct {
+	requestedProtocols uint32
+	negotiationFlags   byte
+	routingToken       bool
+
+	// client core data, only available for connections using standard RDP security
+	core                 bool
+	version              uint32
+	build                uint32
+	keyboardLayout       uint32
+	keyboardType         uint32
+	keyboardSubType      uint32
+	keyboardFunctionKeys uint32
+	highColorDepth       uint16
+	supportedColorDepths uint16
+	earlyCapabilityFlags uint16
+}
+
+// fingerprint returns the MD5 hash over the client properties,
+// session specific values such as the client name and resolution are not included.
+func (c *rdpClient) fingerprint() string {
+	fields := []string{
+		strconv.FormatUint(uint64(c.requestedProtocols), 10),
+		strconv.FormatUint(uint64(c.negotiationFlags), 10),
+		strconv.FormatBool(c.routingToken),
+	}
+
+	if c.core {
+		for _, v := range []uint32{
+			c.version,
+			c.build,
+			c.keyboardLayout,
+			c.keyboardType,
+			c.keyboardSubType,
+			c.keyboardFunctionKeys,
+			uint32(c.highColorDepth),
+			uint32(c.supportedColorDepths),
+			uint32(c.earlyCapabilityFlags),
+		} {
+			fields = append(fields, strconv.FormatUint(uint64(v), 10))
+		}
+	}
+
+	sum := md5.Sum([]byte(strings.Join(fields, ",")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// rdpFrames splits data into TPKT frames.
+// Parsing stops at the first frame that is not a TPKT, e.g. once TLS has been established.
+func rdpFrames(data []byte) [][]byte {
+	var frames [][]byte
+
+	for len(data) >= 4 && data[0] == rdpTPKTVersion {
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 4 || length > len(data) {
+			break
+		}
+
+		frames = append(frames, data[:length])
+		data = data[length:]
+	}
+
+	return frames
+}
+
+// x224Payload returns the variable part of a X.224 connection request or confirm TPDU.
+func x224Payload(frame []byte, code byte) ([]byte, bool) {
+	// TPKT header (4), length indicator (1), code (1), dst ref (2), src ref (2), class (1)
+	if len(frame) < 11 || frame[5]&0xf0 != code {
+		return nil, false
+	}
+
+	end := 5 + int(frame[4])
+	if end > len(frame) {
+		end = len(frame)
+	}
+
+	if end < 11 {
+		return nil, true
+	}
+
+	return frame[11:end], true
+}
+
+// parseRDPConnectionRequest parses the X.224 Connection Request
+// with the optional cookie or routing token and the negotiation request.
+func parseRDPConnectionRequest(frame []byte, r *types.RDP, c *rdpClient) bool {
+	data, ok := x224Payload(frame, x224ConnectionRequest)
+	if !ok {
+		return false
+	}
+
+	if bytes.HasPrefix(data, []byte("Cookie: ")) {
+		end := bytes.Index(data, []byte("\r\n"))
+		if end == -1 {
+			end = len(data)
+		}
+
+		value := string(data[len("Cookie: "):end])
+		if strings.HasPrefix(value, "mstshash=") {
+			r.Cookie = strings.TrimPrefix(value, "mstshash=")
+		} else {
+			r.RoutingToken = value
+			c.routingToken = true
+		}
+
+		data = data[end:]
+		if len(data) >= 2 {
+			data = data[2:]
+		}
+	}
+
+	if len(data) >= 8 && data[0] == rdpNegRequest {
+		c.negotiationFlags = data[1]
+		c.requestedProtocols = binary.LittleEndian.Uint32(data[4:8])
+	}
+
+	r.RequestedProtocols = rdpProtocolNames(c.requestedProtocols)
+
+	return true
+}
+
+// parseRDPConnectionConfirm parses the negotiation response or failure from the X.224 Connection Confirm.
+func parseRDPConnectionConfirm(frame []byte, r *types.RDP) {
+	data, ok := x224Payload(frame, x224ConnectionConfirm)
+	if !ok {
+		return
+	}
+
+	if len(data) < 8 {
+		// servers that do not support negotiation use standard RDP security
+		r.SelectedProtocol = rdpProtocolRDP
+
+		return
+	}
+
+	code := binary.LittleEndian.Uint32(data[4:8])
+
+	switch data[0] {
+	case rdpNegResponse:
+		r.SelectedProtocol = strings.Join(rdpProtocolNames(code), ",")
+	case rdpNegFailure:
+		if name, ok := rdpNegFailures[code]; ok {
+			r.NegotiationFailure = name
+		} else {
+			r.NegotiationFailure = strconv.FormatUint(uint64(code), 10)
+		}
+	}
+}
+
+// parseRDPClientCoreData searches the MCS Connect Initial for the client core data.
+func parseRDPClientCoreData(frame []byte, r *types.RDP, c *rdpClient) bool {
+	for off := 0; off+rdpCoreMinLen <= len(frame); off++ {
+		b := frame[off:]
+
+		// the header type is followed by the length and the version 0x0008xxxx
+		if binary.LittleEndian.Uint16(b) != rdpClientCoreData || b[6] != 0x08 || b[7] != 0 {
+			continue
+		}
+
+		length := int(binary.LittleEndian.Uint16(b[2:4]))
+		if length < rdpCoreMinLen || length > len(b) {
+			continue
+		}
+
+		b = b[:length]
+
+		c.core = true
+		c.version = binary.LittleEndian.Uint32(b[4:8])
+		c.keyboardLayout = binary.LittleEndian.Uint32(b[16:20])
+		c.build = binary.LittleEndian.Uint32(b[20:24])
+		c.keyboardType = binary.LittleEndian.Uint32(b[56:60])
+		c.keyboardSubType = binary.LittleEndian.Uint32(b[60:64])
+		c.keyboardFunctionKeys = binary.LittleEndian.Uint32(b[64:68])
+
+		// the following fields are optional
+		if length >= rdpCoreHighColorDepth+2 {
+			c.highColorDepth = binary.LittleEndian.Uint16(b[rdpCoreHighColorDepth:])
+		}
+
+		if length >= rdpCoreSupportedColorDepths+2 {
+			c.supportedColorDepths = binary.LittleEndian.Uint16(b[rdpCoreSupportedColorDepths:])
+		}
+
+		if length >= rdpCoreEarlyCapabilityFlags+2 {
+			c.earlyCapabilityFlags = binary.LittleEndian.Uint16(b[rdpCoreEarlyCapabilityFlags:])
+		}
+
+		r.ClientVersion = rdpVersionName(c.version)
+		r.DesktopWidth = int32(binary.LittleEndian.Uint16(b[8:10]))
+		r.DesktopHeight = int32(binary.LittleEndian.Uint16(b[10:12]))
+		r.KeyboardLayout = fmt.Sprintf("0x%08x", c.keyboardLayout)
+		r.ClientBuild = c.build
+		r.ClientName = decodeUTF16(b[24:56])
+		if idx := strings.IndexByte(r.ClientName, 0); idx != -1 {
+			r.ClientName = r.ClientName[:idx]
+		}
+		r.KeyboardType = c.keyboardType
+
+		return true
+	}
+
+	return false
+}
+
+var rdpDecoder = newCustomDecoder(
+	types.Type_NC_RDP,
+	serviceRDP,
+	"The Remote Desktop Protocol provides remote access to the graphical desktop of a system",
+	func(d *customDecoder) error {
+		streamFactory.decodeRDP = true
+
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		return nil
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
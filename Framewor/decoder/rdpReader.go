@@ -0,0 +1,112 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+// isRDP checks if the server response is a X.224 Connection Confirm or the default RDP port is used.
+func isRDP(banner []byte, transport gopacket.Flow) bool {
+	if len(banner) >= 6 && banner[0] == rdpTPKTVersion && banner[1] == 0 && banner[5]&0xf0 == x224ConnectionConfirm {
+		return true
+	}
+
+	return transport.Dst().String() == "3389"
+}
+
+type rdpReader struct {
+	parent *tcpConnection
+}
+
+// Decode parses the connection setup of a RDP session.
+func (h *rdpReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	for _, d := range h.parent.merged {
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+		} else {
+			serverBuf.Write(d.raw)
+		}
+	}
+
+	var (
+		clientFrames = rdpFrames(clientBuf.Bytes())
+		serverFrames = rdpFrames(serverBuf.Bytes())
+		c            = &rdpClient{}
+		r            = &types.RDP{
+			Timestamp: utils.TimeToString(h.parent.firstPacket),
+			ClientIP:  h.parent.net.Src().String(),
+			ServerIP:  h.parent.net.Dst().String(),
+			Flow:      h.parent.ident,
+		}
+	)
+
+	if len(clientFrames) == 0 || !parseRDPConnectionRequest(clientFrames[0], r, c) {
+		return
+	}
+
+	if len(serverFrames) > 0 {
+		parseRDPConnectionConfirm(serverFrames[0], r)
+	}
+
+	// the MCS Connect Initial is only readable when standard RDP security is used,
+	// otherwise the TLS handshake follows the connection confirm
+	if r.SelectedProtocol == rdpProtocolRDP || (r.SelectedProtocol == "" && r.NegotiationFailure == "") {
+		for _, f := range clientFrames[1:] {
+			if parseRDPClientCoreData(f, r, c) {
+				break
+			}
+		}
+	}
+
+	if !c.core && r.SelectedProtocol == rdpProtocolRDP {
+		r.Notes = "client core data not found"
+	}
+
+	r.Fingerprint = c.fingerprint()
+
+	client := r.ClientName
+	if client == "" {
+		client = r.Cookie
+	}
+
+	if r.ClientVersion != "" {
+		client += " (RDP " + r.ClientVersion + ")"
+	}
+
+	addRDPFingerprint(r.ClientIP, r.Fingerprint, client)
+
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&rdpDecoder.numRecords, 1)
+
+	err := rdpDecoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
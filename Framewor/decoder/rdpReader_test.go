@@ -0,0 +1,143 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// rdpTestTPKT wraps the X.224 TPDU into a TPKT frame.
+func rdpTestTPKT(tpdu []byte) []byte {
+	frame := []byte{rdpTPKTVersion, 0, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(tpdu)+4))
+
+	return append(frame, tpdu...)
+}
+
+// rdpTestX224 creates a X.224 connection request or confirm with the given variable part.
+func rdpTestX224(code byte, data []byte) []byte {
+	return rdpTestTPKT(append([]byte{byte(6 + len(data)), code, 0, 0, 0, 0, 0}, data...))
+}
+
+// rdpTestNegotiation creates a RDP negotiation message.
+func rdpTestNegotiation(typ byte, value uint32) []byte {
+	msg := []byte{typ, 0, 8, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(msg[4:], value)
+
+	return msg
+}
+
+// rdpTestCoreData creates a MCS Connect Initial containing the client core data.
+func rdpTestCoreData(name string) []byte {
+	core := make([]byte, 216)
+
+	binary.LittleEndian.PutUint16(core[0:], rdpClientCoreData)
+	binary.LittleEndian.PutUint16(core[2:], uint16(len(core)))
+	binary.LittleEndian.PutUint32(core[4:], 0x0008000c)
+	binary.LittleEndian.PutUint16(core[8:], 1920)
+	binary.LittleEndian.PutUint16(core[10:], 1080)
+	binary.LittleEndian.PutUint32(core[16:], 0x409)
+	binary.LittleEndian.PutUint32(core[20:], 19041)
+	copy(core[24:56], utf16LE(name))
+	binary.LittleEndian.PutUint32(core[56:], 4)
+	binary.LittleEndian.PutUint16(core[rdpCoreHighColorDepth:], 24)
+
+	// X.224 data TPDU followed by the start of the MCS Connect Initial and GCC Conference Create Request
+	return rdpTestTPKT(append([]byte{2, 0xf0, 0x80, 0x7f, 0x65, 0x82, 0x01, 0x90, 0x04, 0x01, 0x01}, core...))
+}
+
+func TestRDPReader(t *testing.T) {
+	var (
+		rdpRecords = &recordCollector{}
+		oldConf    = conf
+	)
+
+	defer func() {
+		conf = oldConf
+
+		ipProfiles.Lock()
+		delete(ipProfiles.Items, "10.0.0.1")
+		ipProfiles.Unlock()
+
+		ipRDPFingerprints.Lock()
+		delete(ipRDPFingerprints.Items, "10.0.0.1")
+		ipRDPFingerprints.Unlock()
+	}()
+
+	conf = &Config{}
+	rdpDecoder.writer = rdpRecords
+
+	ipProfiles.Lock()
+	ipProfiles.Items["10.0.0.1"] = &ipProfile{IPProfile: &types.IPProfile{Addr: "10.0.0.1"}}
+	ipProfiles.Unlock()
+
+	request := rdpTestX224(x224ConnectionRequest, append([]byte("Cookie: mstshash=alice\r\n"), rdpTestNegotiation(rdpNegRequest, 0)...))
+	confirm := rdpTestX224(x224ConnectionConfirm, rdpTestNegotiation(rdpNegResponse, 0))
+
+	if !isRDP(confirm, newTestConnection(50000, 33890).transport) {
+		t.Fatal("failed to detect RDP connection confirm")
+	}
+
+	// standard RDP security
+	(&rdpReader{parent: newTestConnection(50000, 3389, request, confirm, rdpTestCoreData("WS01"))}).Decode()
+
+	// TLS with CredSSP
+	request = rdpTestX224(x224ConnectionRequest, append([]byte("Cookie: msts=3640205228.15629.0000\r\n"), rdpTestNegotiation(rdpNegRequest, 3)...))
+	confirm = rdpTestX224(x224ConnectionConfirm, rdpTestNegotiation(rdpNegResponse, 2))
+
+	(&rdpReader{parent: newTestConnection(50001, 3389, request, confirm, []byte{0x16, 0x03, 0x01, 0x00, 0x10})}).Decode()
+
+	if len(rdpRecords.records) != 2 {
+		t.Fatal("expected 2 RDP records, got", len(rdpRecords.records))
+	}
+
+	r := rdpRecords.records[0].(*types.RDP)
+	if r.Cookie != "alice" || r.SelectedProtocol != rdpProtocolRDP || r.ClientName != "WS01" || r.ClientVersion != "10.7" || r.ClientBuild != 19041 {
+		t.Fatal("unexpected RDP record:", r)
+	}
+
+	if r.KeyboardLayout != "0x00000409" || r.KeyboardType != 4 || r.DesktopWidth != 1920 || r.DesktopHeight != 1080 || r.Fingerprint == "" {
+		t.Fatal("unexpected RDP client core data:", r)
+	}
+
+	r2 := rdpRecords.records[1].(*types.RDP)
+	if r2.RoutingToken != "msts=3640205228.15629.0000" || strings.Join(r2.RequestedProtocols, ",") != "PROTOCOL_SSL,PROTOCOL_HYBRID" || r2.SelectedProtocol != "PROTOCOL_HYBRID" || r2.ClientName != "" {
+		t.Fatal("unexpected RDP record for TLS connection:", r2)
+	}
+
+	// a different client configuration must produce a different fingerprint
+	if r.Fingerprint == r2.Fingerprint {
+		t.Fatal("expected different fingerprints")
+	}
+
+	p := ipProfiles.Items["10.0.0.1"]
+	if len(p.RDPFingerprints) != 2 || p.RDPFingerprints[r.Fingerprint] != "WS01 (RDP 10.7)" {
+		t.Fatal("unexpected RDP fingerprints in profile:", p.RDPFingerprints)
+	}
+
+	// profiles created later receive the fingerprints seen so far
+	if f := rdpFingerprints("10.0.0.1"); len(f) != 2 || f[r2.Fingerprint] != p.RDPFingerprints[r2.Fingerprint] {
+		t.Fatal("unexpected buffered RDP fingerprints:", f)
+	}
+
+	// the device profile carries the fingerprints of all its ip addresses
+	d := deviceRDPFingerprints([]*types.IPProfile{nil, {Addr: "10.0.0.1"}, {Addr: "10.0.0.3"}})
+	if len(d) != 2 || d[r.Fingerprint] != "WS01 (RDP 10.7)" {
+		t.Fatal("unexpected RDP fingerprints in device profile:", d)
+	}
+}
@@ -0,0 +1,587 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * Redis serialization protocol (RESP2 and RESP3)
+ */
+
+const (
+	// upper limit for lines kept in memory, used to detect streams that are out of sync
+	respMaxLineSize = 64 * 1024
+
+	// nested aggregates deeper than this are considered invalid
+	respMaxDepth = 32
+
+	// maximum size of a bulk string accepted by redis (proto-max-bulk-len)
+	respMaxBulkSize = 512 * 1024 * 1024
+
+	// arguments are truncated in the audit records
+	redisMaxArgumentSize = 128
+)
+
+var (
+	errRESPIncomplete = errors.New("incomplete RESP value")
+	errRESPInvalid    = errors.New("invalid RESP value")
+)
+
+// redisDangerousCommands are commands commonly abused on exposed instances.
+var redisDangerousCommands = map[string]string{
+	"CONFIG SET":  "configuration change",
+	"SLAVEOF":     "replication change",
+	"REPLICAOF":   "replication change",
+	"FLUSHALL":    "deletes all keys",
+	"FLUSHDB":     "deletes all keys of the database",
+	"MODULE LOAD": "loads a shared library",
+	"DEBUG":       "debugging command",
+	"SHUTDOWN":    "stops the server",
+	"EVAL":        "executes a Lua script",
+	"EVALSHA":     "executes a Lua script",
+	"MIGRATE":     "transfers keys to another server",
+	"CLIENT KILL": "terminates client connections",
+}
+
+// redisContainerCommands take a subcommand as first argument.
+var redisContainerCommands = map[string]bool{
+	"ACL":      true,
+	"CLIENT":   true,
+	"CLUSTER":  true,
+	"COMMAND":  true,
+	"CONFIG":   true,
+	"FUNCTION": true,
+	"LATENCY":  true,
+	"MEMORY":   true,
+	"MODULE":   true,
+	"OBJECT":   true,
+	"SCRIPT":   true,
+	"SLOWLOG":  true,
+	"XGROUP":   true,
+	"XINFO":    true,
+}
+
+// redisKeylessCommands do not take a key as first argument.
+var redisKeylessCommands = map[string]bool{
+	"AUTH":       true,
+	"BGSAVE":     true,
+	"DBSIZE":     true,
+	"DEBUG":      true,
+	"DISCARD":    true,
+	"ECHO":       true,
+	"EVAL":       true,
+	"EVALSHA":    true,
+	"EXEC":       true,
+	"FLUSHALL":   true,
+	"FLUSHDB":    true,
+	"HELLO":      true,
+	"INFO":       true,
+	"KEYS":       true,
+	"LASTSAVE":   true,
+	"MONITOR":    true,
+	"MULTI":      true,
+	"PING":       true,
+	"PSUBSCRIBE": true,
+	"PSYNC":      true,
+	"PUBLISH":    true,
+	"QUIT":       true,
+	"RANDOMKEY":  true,
+	"REPLICAOF":  true,
+	"ROLE":       true,
+	"SAVE":       true,
+	"SCAN":       true,
+	"SELECT":     true,
+	"SHUTDOWN":   true,
+	"SLAVEOF":    true,
+	"SUBSCRIBE":  true,
+	"SWAPDB":     true,
+	"SYNC":       true,
+	"TIME":       true,
+	"UNWATCH":    true,
+	"WAIT":       true,
+}
+
+// redisValueIndex is the position of the written value for commands storing a single value.
+var redisValueIndex = map[string]int{
+	"APPEND":   2,
+	"GETSET":   2,
+	"LSET":     3,
+	"PSETEX":   3,
+	"SET":      2,
+	"SETEX":    3,
+	"SETNX":    2,
+	"SETRANGE": 3,
+}
+
+// redisMultiValueCommands store all arguments following the key.
+var redisMultiValueCommands = map[string]bool{
+	"HMSET":  true,
+	"HSET":   true,
+	"HSETNX": true,
+	"LPUSH":  true,
+	"LPUSHX": true,
+	"MSET":   true,
+	"MSETNX": true,
+	"PFADD":  true,
+	"RPUSH":  true,
+	"RPUSHX": true,
+	"SADD":   true,
+	"XADD":   true,
+	"ZADD":   true,
+}
+
+// isRedis checks if the server sent a typical Redis reply or the default port is used.
+func isRedis(banner []byte, transport gopacket.Flow) bool {
+	if transport.Dst().String() == "6379" {
+		return true
+	}
+
+	for _, prefix := range []string{"-NOAUTH ", "-WRONGPASS ", "-DENIED Redis", "+PONG\r\n"} {
+		if bytes.HasPrefix(banner, []byte(prefix)) {
+			return true
+		}
+	}
+
+	// INFO and HELLO replies
+	return bytes.Contains(banner, []byte("\r\n# Server\r\nredis_version:")) || bytes.Contains(banner, []byte("server\r\n$5\r\nredis\r\n"))
+}
+
+type respValue struct {
+	typ byte
+
+	// simple strings, errors, integers and bulk strings
+	data []byte
+
+	// length of a bulk string or number of elements, -1 for null values
+	size int64
+
+	elems []*respValue
+}
+
+// readRESPLine returns the line at the start of b without the CRLF and the number of consumed bytes.
+func readRESPLine(b []byte) ([]byte, int, error) {
+	idx := bytes.Index(b, []byte("\r\n"))
+	if idx == -1 {
+		if len(b) > respMaxLineSize {
+			return nil, 0, errRESPInvalid
+		}
+
+		return nil, 0, errRESPIncomplete
+	}
+
+	return b[:idx], idx + 2, nil
+}
+
+// readRESP parses a single RESP value and returns it with the number of consumed bytes.
+func readRESP(b []byte, depth int) (*respValue, int, error) {
+	if len(b) == 0 {
+		return nil, 0, errRESPIncomplete
+	}
+
+	if depth > respMaxDepth {
+		return nil, 0, errRESPInvalid
+	}
+
+	line, n, err := readRESPLine(b[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		v        = &respValue{typ: b[0], data: line}
+		consumed = 1 + n
+	)
+
+	switch v.typ {
+	case '+', '-', ':', ',', '#', '(':
+		return v, consumed, nil
+	case '_':
+		v.size = -1
+
+		return v, consumed, nil
+	case '$', '!', '=': // bulk string, blob error and verbatim string
+		v.size, err = strconv.ParseInt(string(line), 10, 64)
+		if err != nil {
+			return nil, 0, errRESPInvalid
+		}
+
+		if v.size < 0 {
+			v.size = -1
+
+			return v, consumed, nil
+		}
+
+		if v.size > respMaxBulkSize {
+			return nil, 0, errRESPInvalid
+		}
+
+		if v.size > int64(len(b)-consumed-2) {
+			return nil, 0, errRESPIncomplete
+		}
+
+		v.data = b[consumed : consumed+int(v.size)]
+
+		return v, consumed + int(v.size) + 2, nil
+	case '*', '~', '>', '%', '|': // array, set, push, map and attribute
+		v.size, err = strconv.ParseInt(string(line), 10, 64)
+		if err != nil || v.size > respMaxLineSize {
+			return nil, 0, errRESPInvalid
+		}
+
+		if v.size < 0 {
+			v.size = -1
+
+			return v, consumed, nil
+		}
+
+		num := v.size
+		if v.typ == '%' || v.typ == '|' {
+			num *= 2
+		}
+
+		for i := int64(0); i < num; i++ {
+			e, n, err := readRESP(b[consumed:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			v.elems = append(v.elems, e)
+			consumed += n
+		}
+
+		// attributes are followed by the actual reply
+		if v.typ == '|' {
+			next, n, err := readRESP(b[consumed:], depth)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			return next, consumed + n, nil
+		}
+
+		return v, consumed, nil
+	}
+
+	return nil, 0, errRESPInvalid
+}
+
+// summary returns a short description of a reply.
+func (v *respValue) summary() string {
+	switch v.typ {
+	case '$', '=':
+		if v.size == -1 {
+			return "(nil)"
+		}
+
+		return "string(" + strconv.FormatInt(v.size, 10) + ")"
+	case '*', '~', '>', '%':
+		if v.size == -1 {
+			return "(nil)"
+		}
+
+		return "array(" + strconv.FormatInt(v.size, 10) + ")"
+	case '_':
+		return "(nil)"
+	}
+
+	return string(v.data)
+}
+
+type redisCommand struct {
+	record *types.Redis
+	creds  *types.Credentials
+}
+
+type redisReader struct {
+	parent *tcpConnection
+
+	database int32
+	pending  []*redisCommand
+
+	// the server pushes messages after SUBSCRIBE or MONITOR
+	push bool
+
+	done bool
+}
+
+// Decode parses the stream according to the Redis protocol.
+func (h *redisReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	// the merged fragments are ordered by time, so commands are processed before their replies
+	for _, d := range h.parent.merged {
+		if h.done {
+			break
+		}
+
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readClient(&clientBuf, ts)
+		} else if !h.push {
+			serverBuf.Write(d.raw)
+			h.readServer(&serverBuf)
+		}
+	}
+
+	// write commands that did not receive a reply
+	for _, c := range h.pending {
+		c.record.Notes = addInfo(c.record.Notes, "no response")
+		h.complete(c)
+	}
+
+	h.pending = nil
+}
+
+// readClient parses commands sent as RESP arrays or in the inline format.
+func (h *redisReader) readClient(buf *bytes.Buffer, ts time.Time) {
+	for !h.done && buf.Len() > 0 {
+		var (
+			data = buf.Bytes()
+			args [][]byte
+		)
+
+		if data[0] == '*' {
+			v, n, err := readRESP(data, 0)
+			if err == errRESPIncomplete {
+				return
+			}
+
+			if err != nil {
+				utils.DebugLog.Println("Redis: invalid command on", h.parent.ident)
+
+				h.done = true
+
+				return
+			}
+
+			buf.Next(n)
+
+			for _, e := range v.elems {
+				args = append(args, e.data)
+			}
+		} else {
+			line, n, err := readRESPLine(data)
+			if err == errRESPIncomplete {
+				return
+			}
+
+			if err != nil {
+				h.done = true
+
+				return
+			}
+
+			buf.Next(n)
+
+			args = bytes.Fields(line)
+		}
+
+		if len(args) > 0 {
+			h.handleCommand(args, ts)
+		}
+	}
+}
+
+func (h *redisReader) handleCommand(args [][]byte, ts time.Time) {
+	var (
+		name  = strings.ToUpper(string(args[0]))
+		index = 1
+		c     = &redisCommand{
+			record: &types.Redis{
+				Timestamp: utils.TimeToString(ts),
+				ClientIP:  h.parent.net.Src().String(),
+				ServerIP:  h.parent.net.Dst().String(),
+				Flow:      h.parent.ident,
+			},
+		}
+	)
+
+	if redisContainerCommands[name] && len(args) > 1 {
+		name += " " + strings.ToUpper(string(args[1]))
+		index = 2
+	}
+
+	c.record.Command = name
+
+	if reason, ok := redisDangerousCommands[name]; ok {
+		c.record.Notes = "dangerous: " + reason
+	}
+
+	switch name {
+	case "AUTH":
+		c.creds = h.credentials(args[1:], ts)
+
+		// the password is not included in the arguments
+		if len(args) > 2 {
+			args = args[:2]
+		} else {
+			args = args[:1]
+		}
+	case "HELLO":
+		// HELLO [protover [AUTH username password] [SETNAME clientname]]
+		for i := 1; i+2 < len(args); i++ {
+			if strings.EqualFold(string(args[i]), "AUTH") {
+				c.creds = h.credentials(args[i+1:i+3], ts)
+				args = append(args[:i+2:i+2], args[i+3:]...)
+
+				break
+			}
+		}
+	}
+
+	for _, a := range args[index:] {
+		if len(a) > redisMaxArgumentSize {
+			a = append(a[:redisMaxArgumentSize:redisMaxArgumentSize], "..."...)
+		}
+
+		c.record.Arguments = append(c.record.Arguments, string(a))
+	}
+
+	if !redisKeylessCommands[name] && index == 1 && len(args) > 1 {
+		c.record.Key = string(args[1])
+	}
+
+	if i, ok := redisValueIndex[name]; ok && len(args) > i {
+		c.record.ValueSize = int64(len(args[i]))
+	} else if redisMultiValueCommands[name] {
+		for _, a := range args[2:] {
+			c.record.ValueSize += int64(len(a))
+		}
+	}
+
+	if h.push {
+		// replies can no longer be matched to the commands
+		h.complete(c)
+
+		return
+	}
+
+	h.pending = append(h.pending, c)
+}
+
+// credentials creates the credentials for the AUTH arguments, the user name is optional since Redis 6.
+func (h *redisReader) credentials(args [][]byte, ts time.Time) *types.Credentials {
+	if len(args) == 0 {
+		return nil
+	}
+
+	creds := &types.Credentials{
+		Timestamp: utils.TimeToString(ts),
+		Service:   serviceRedis,
+		Flow:      h.parent.ident,
+		User:      "default",
+		Password:  string(args[len(args)-1]),
+	}
+
+	if len(args) > 1 {
+		creds.User = string(args[0])
+	}
+
+	return creds
+}
+
+func (h *redisReader) readServer(buf *bytes.Buffer) {
+	// messages pushed after SUBSCRIBE or MONITOR can not be matched to commands
+	for !h.done && !h.push && buf.Len() > 0 {
+		v, n, err := readRESP(buf.Bytes(), 0)
+		if err == errRESPIncomplete {
+			return
+		}
+
+		if err != nil {
+			utils.DebugLog.Println("Redis: invalid reply on", h.parent.ident)
+
+			h.done = true
+
+			return
+		}
+
+		buf.Next(n)
+
+		// out of band push messages, e.g. for client side caching
+		if v.typ == '>' || len(h.pending) == 0 {
+			continue
+		}
+
+		c := h.pending[0]
+		h.pending = h.pending[1:]
+
+		c.record.Response = v.summary()
+		c.record.Status = "OK"
+
+		if v.typ == '-' || v.typ == '!' {
+			c.record.Status = "ERROR"
+		}
+
+		// size of the value returned for read commands
+		if c.record.ValueSize == 0 && (v.typ == '$' || v.typ == '=') && v.size > 0 {
+			c.record.ValueSize = v.size
+		}
+
+		if c.creds != nil {
+			c.creds.Notes = c.record.Response
+		}
+
+		h.complete(c)
+
+		if c.record.Command == "SELECT" && c.record.Status == "OK" && len(c.record.Arguments) > 0 {
+			if db, err := strconv.Atoi(c.record.Arguments[0]); err == nil {
+				h.database = int32(db)
+			}
+		}
+
+		switch c.record.Command {
+		case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE", "MONITOR":
+			h.push = c.record.Status == "OK"
+		}
+	}
+}
+
+func (h *redisReader) complete(c *redisCommand) {
+	// replies are sent in order, so the database selected before the command is known once it completed
+	c.record.Database = h.database
+
+	if c.creds != nil && useHarvesters {
+		writeCredentials(c.creds)
+	}
+
+	if conf.ExportMetrics {
+		c.record.Inc()
+	}
+
+	atomic.AddInt64(&redisDecoder.numRecords, 1)
+
+	err := redisDecoder.writer.Write(c.record)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
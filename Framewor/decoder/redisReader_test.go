@@ -0,0 +1,124 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func respTestCommand(args ...string) []byte {
+	var b strings.Builder
+
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+
+	for _, a := range args {
+		b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+
+	return []byte(b.String())
+}
+
+func TestRedisReader(t *testing.T) {
+	var (
+		redisRecords = &recordCollector{}
+		credRecords  = &recordCollector{}
+		oldConf      = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+	}()
+
+	conf = &Config{}
+	useHarvesters = true
+	redisDecoder.writer = redisRecords
+	credentialsDecoder.writer = credRecords
+
+	if !isRedis([]byte("-NOAUTH Authentication required.\r\n"), newTestConnection(50000, 16379).transport) {
+		t.Fatal("failed to detect Redis")
+	}
+
+	c := newTestConnection(50000, 6379,
+		[]byte(string(respTestCommand("AUTH", "secret"))+string(respTestCommand("SELECT", "2"))+string(respTestCommand("SET", "user:1", "alice"))),
+		[]byte("+OK\r\n+OK\r\n+OK\r\n"),
+		[]byte("GET user:1\r\n"+string(respTestCommand("CONFIG", "SET", "dir", "/var/www"))+string(respTestCommand("FLUSHALL"))),
+		[]byte("$5\r\nalice\r\n-ERR CONFIG SET failed\r\n"),
+	)
+
+	(&redisReader{parent: c}).Decode()
+
+	if len(redisRecords.records) != 6 {
+		t.Fatal("expected 6 Redis records, got", len(redisRecords.records))
+	}
+
+	r := redisRecords.records[0].(*types.Redis)
+	if r.Command != "AUTH" || len(r.Arguments) != 0 || r.Status != "OK" {
+		t.Fatal("unexpected auth record:", r)
+	}
+
+	r = redisRecords.records[2].(*types.Redis)
+	if r.Command != "SET" || r.Database != 2 || r.Key != "user:1" || r.ValueSize != 5 {
+		t.Fatal("unexpected set record:", r)
+	}
+
+	r = redisRecords.records[3].(*types.Redis)
+	if r.Command != "GET" || r.Key != "user:1" || r.ValueSize != 5 || r.Response != "string(5)" {
+		t.Fatal("unexpected get record:", r)
+	}
+
+	r = redisRecords.records[4].(*types.Redis)
+	if r.Command != "CONFIG SET" || strings.Join(r.Arguments, " ") != "dir /var/www" || r.Status != "ERROR" || r.Notes != "dangerous: configuration change" {
+		t.Fatal("unexpected config record:", r)
+	}
+
+	r = redisRecords.records[5].(*types.Redis)
+	if r.Command != "FLUSHALL" || r.Notes != "dangerous: deletes all keys | no response" {
+		t.Fatal("unexpected flushall record:", r)
+	}
+
+	if len(credRecords.records) != 1 {
+		t.Fatal("expected 1 credential, got", len(credRecords.records))
+	}
+
+	creds := credRecords.records[0].(*types.Credentials)
+	if creds.Service != serviceRedis || creds.User != "default" || creds.Password != "secret" || creds.Notes != "OK" {
+		t.Fatal("unexpected credentials:", creds)
+	}
+}
+
+func TestReadRESPBulkSize(t *testing.T) {
+	for _, data := range []string{
+		"$9223372036854775807\r\nabc\r\n",
+		"*1\r\n$9223372036854775807\r\nabc\r\n",
+		"$536870913\r\nabc\r\n",
+	} {
+		if _, _, err := readRESP([]byte(data), 0); err != errRESPInvalid {
+			t.Fatal("expected invalid RESP value for", strconv.Quote(data), "got", err)
+		}
+	}
+
+	if _, _, err := readRESP([]byte("$5\r\nabc\r\n"), 0); err != errRESPIncomplete {
+		t.Fatal("expected incomplete RESP value, got", err)
+	}
+
+	v, n, err := readRESP([]byte("$3\r\nabc\r\n"), 0)
+	if err != nil || n != 9 || string(v.data) != "abc" {
+		t.Fatal("unexpected bulk string:", v, n, err)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// readCRLFLine returns the next complete line from the buffer without the line terminator.
+func readCRLFLine(buf *bytes.Buffer) (string, bool) {
+	idx := bytes.IndexByte(buf.Bytes(), '\n')
+	if idx == -1 {
+		return "", false
+	}
+
+	return strings.TrimRight(string(buf.Next(idx+1)), "\r\n"), true
+}
+
+// replyReader parses the three digit replies used by FTP and SMTP.
+// Multi line replies use a hyphen after the code and are terminated by a line that starts with the code and a space.
+type replyReader struct {
+	// state of a multi line reply that has not been terminated yet
+	code  string
+	lines []string
+}
+
+// read consumes all complete replies from the buffer and calls handle for each of them.
+func (r *replyReader) read(buf *bytes.Buffer, handle func(code int, lines []string)) {
+	for {
+		line, ok := readCRLFLine(buf)
+		if !ok {
+			return
+		}
+
+		if r.code != "" {
+			if len(line) >= 4 && line[:3] == r.code && line[3] == ' ' {
+				var (
+					code, _ = strconv.Atoi(r.code)
+					lines   = append(r.lines, strings.TrimSpace(line[4:]))
+				)
+
+				r.code, r.lines = "", nil
+				handle(code, lines)
+
+				continue
+			}
+
+			// continuation lines may repeat the code
+			line = strings.TrimPrefix(line, r.code+"-")
+			r.lines = append(r.lines, strings.TrimSpace(line))
+
+			continue
+		}
+
+		if len(line) < 3 {
+			continue
+		}
+
+		code, err := strconv.Atoi(line[:3])
+		if err != nil {
+			continue
+		}
+
+		if len(line) > 3 && line[3] == '-' {
+			r.code = line[:3]
+			r.lines = []string{strings.TrimSpace(line[4:])}
+
+			continue
+		}
+
+		handle(code, []string{strings.TrimSpace(line[3:])})
+	}
+}
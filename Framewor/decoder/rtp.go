@@ -0,0 +1,332 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+/*
+ * RTP - Real-time Transport Protocol
+ */
+
+const (
+	rtpHeaderLen = 12
+	rtpVersion   = 2
+
+	// static payload types of the G.711 codecs
+	rtpPayloadPCMU = 0
+	rtpPayloadPCMA = 8
+
+	// sample rate of the G.711 codecs
+	g711ClockRate = 8000
+
+	// upper limit of silence inserted for lost packets, in samples
+	rtpMaxGapSamples = g711ClockRate
+
+	// upper limit of decoded audio per stream, one hour of 16 bit PCM
+	rtpMaxAudioSize = 2 * g711ClockRate * 60 * 60
+)
+
+var (
+	// codecs for the static payload types, dynamic ones are announced via SDP
+	rtpStaticCodecs = map[byte]rtpCodec{
+		0:  {"PCMU", 8000},
+		3:  {"GSM", 8000},
+		4:  {"G723", 8000},
+		8:  {"PCMA", 8000},
+		9:  {"G722", 8000},
+		13: {"CN", 8000},
+		18: {"G729", 8000},
+	}
+
+	errRTPInvalidPacket = errors.New("invalid RTP packet")
+)
+
+type rtpCodec struct {
+	name      string
+	clockRate int
+}
+
+type rtpPacket struct {
+	payloadType byte
+	seq         uint16
+	timestamp   uint32
+	ssrc        uint32
+	payload     []byte
+}
+
+// isRTCP checks the packet type of RTCP packets, which are distinguished from RTP when sharing the port.
+func isRTCP(data []byte) bool {
+	return len(data) >= 8 && data[0]>>6 == rtpVersion && data[1] >= 192 && data[1] <= 223
+}
+
+// parseRTP decodes the RTP header and strips CSRCs, header extension and padding from the payload.
+func parseRTP(data []byte) (*rtpPacket, error) {
+	if len(data) < rtpHeaderLen || data[0]>>6 != rtpVersion {
+		return nil, errRTPInvalidPacket
+	}
+
+	var (
+		padding   = data[0]&0x20 != 0
+		extension = data[0]&0x10 != 0
+		offset    = rtpHeaderLen + 4*int(data[0]&0x0f)
+		end       = len(data)
+	)
+
+	if extension {
+		if offset+4 > len(data) {
+			return nil, errRTPInvalidPacket
+		}
+
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+	}
+
+	if padding && end > 0 {
+		end -= int(data[end-1])
+	}
+
+	if offset > end {
+		return nil, errRTPInvalidPacket
+	}
+
+	return &rtpPacket{
+		payloadType: data[1] & 0x7f,
+		seq:         binary.BigEndian.Uint16(data[2:4]),
+		timestamp:   binary.BigEndian.Uint32(data[4:8]),
+		ssrc:        binary.BigEndian.Uint32(data[8:12]),
+		payload:     data[offset:end],
+	}, nil
+}
+
+// rtpStream collects the statistics of the packets sent from one endpoint to another.
+type rtpStream struct {
+	src, dst string
+	ssrc     uint32
+	codec    rtpCodec
+	first    time.Time
+
+	packets int64
+
+	// extended sequence numbers
+	baseSeq, maxSeq int64
+
+	// interarrival jitter in timestamp units, see RFC 3550 section 6.4.1
+	jitter      float64
+	lastTransit float64
+
+	// decoded G.711 audio as 16 bit PCM and the last sequence number written
+	audio    []byte
+	audioSeq int64
+
+	// set once the audio reached the size limit
+	audioTruncated bool
+}
+
+// update adds a packet to the statistics and decodes G.711 payloads.
+func (s *rtpStream) update(pkt *rtpPacket, ts time.Time) {
+	if s.packets == 0 {
+		s.first = ts
+		s.ssrc = pkt.ssrc
+		s.baseSeq = int64(pkt.seq)
+		s.maxSeq = s.baseSeq
+		s.audioSeq = s.baseSeq - 1
+	}
+
+	s.packets++
+
+	// extend the sequence number relative to the highest one seen, to handle wrap arounds
+	seq := s.maxSeq + int64(int16(pkt.seq-uint16(s.maxSeq)))
+	if seq > s.maxSeq {
+		s.maxSeq = seq
+	}
+
+	if s.codec.clockRate > 0 {
+		var (
+			arrival = ts.Sub(s.first).Seconds() * float64(s.codec.clockRate)
+			transit = arrival - float64(pkt.timestamp)
+		)
+
+		if s.packets > 1 {
+			s.jitter += (math.Abs(transit-s.lastTransit) - s.jitter) / 16
+		}
+
+		s.lastTransit = transit
+	}
+
+	// late packets are not inserted into the audio anymore
+	if seq <= s.audioSeq {
+		return
+	}
+
+	switch pkt.payloadType {
+	case rtpPayloadPCMU, rtpPayloadPCMA:
+		gap := (seq - s.audioSeq - 1) * int64(len(pkt.payload))
+		if gap < 0 {
+			gap = 0
+		} else if gap > rtpMaxGapSamples {
+			gap = rtpMaxGapSamples
+		}
+
+		if int64(len(s.audio))+2*(gap+int64(len(pkt.payload))) > rtpMaxAudioSize {
+			s.audioTruncated = true
+
+			return
+		}
+
+		// fill the gap of lost packets with silence
+		if gap > 0 {
+			s.audio = append(s.audio, make([]byte, 2*gap)...)
+		}
+
+		s.audio = appendG711(s.audio, pkt.payload, pkt.payloadType == rtpPayloadPCMA)
+		s.audioSeq = seq
+	}
+}
+
+// lost returns the number of packets that did not arrive.
+func (s *rtpStream) lost() int64 {
+	if lost := s.maxSeq - s.baseSeq + 1 - s.packets; lost > 0 {
+		return lost
+	}
+
+	return 0
+}
+
+// jitterMillis returns the interarrival jitter in milliseconds.
+func (s *rtpStream) jitterMillis() float64 {
+	if s.codec.clockRate == 0 {
+		return 0
+	}
+
+	return s.jitter / float64(s.codec.clockRate) * 1000
+}
+
+// mos estimates the mean opinion score with a simplified ITU-T G.107 E-model from jitter and loss.
+func (s *rtpStream) mos() float64 {
+	var (
+		expected = s.maxSeq - s.baseSeq + 1
+		loss     float64
+		// jitter buffers add latency, the network delay is unknown in passive captures
+		latency = 2*s.jitterMillis() + 10
+		r       float64
+	)
+
+	if expected > 0 {
+		loss = float64(s.lost()) / float64(expected) * 100
+	}
+
+	if latency < 160 {
+		r = 93.2 - latency/40
+	} else {
+		r = 93.2 - (latency-120)/10
+	}
+
+	r -= 2.5 * loss
+
+	switch {
+	case r < 0:
+		r = 0
+	case r > 100:
+		r = 100
+	}
+
+	return 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+}
+
+// appendG711 decodes µ-law or A-law samples and appends them as 16 bit little endian PCM.
+func appendG711(out, samples []byte, alaw bool) []byte {
+	for _, b := range samples {
+		var v int16
+		if alaw {
+			v = alawToLinear(b)
+		} else {
+			v = ulawToLinear(b)
+		}
+
+		out = append(out, byte(v), byte(uint16(v)>>8))
+	}
+
+	return out
+}
+
+// ulawToLinear decodes a G.711 µ-law sample.
+func ulawToLinear(u byte) int16 {
+	u = ^u
+
+	t := (int(u&0x0f) << 3) + 0x84
+	t <<= (u & 0x70) >> 4
+
+	if u&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+
+	return int16(t - 0x84)
+}
+
+// alawToLinear decodes a G.711 A-law sample.
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+
+	var (
+		t   = int(a&0x0f) << 4
+		seg = (a & 0x70) >> 4
+	)
+
+	switch seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+
+	return int16(-t)
+}
+
+// wavFile returns a RIFF WAVE file for 16 bit mono PCM audio.
+func wavFile(pcm []byte, sampleRate int) []byte {
+	const (
+		headerLen     = 44
+		bitsPerSample = 16
+		channels      = 1
+	)
+
+	b := make([]byte, headerLen, headerLen+len(pcm))
+
+	copy(b[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(b[4:8], uint32(headerLen-8+len(pcm)))
+	copy(b[8:12], "WAVE")
+	copy(b[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(b[16:20], 16)
+	binary.LittleEndian.PutUint16(b[20:22], 1) // PCM
+	binary.LittleEndian.PutUint16(b[22:24], channels)
+	binary.LittleEndian.PutUint32(b[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(b[28:32], uint32(sampleRate*channels*bitsPerSample/8))
+	binary.LittleEndian.PutUint16(b[32:34], channels*bitsPerSample/8)
+	binary.LittleEndian.PutUint16(b[34:36], bitsPerSample)
+	copy(b[36:40], "data")
+	binary.LittleEndian.PutUint32(b[40:44], uint32(len(pcm)))
+
+	return append(b, pcm...)
+}
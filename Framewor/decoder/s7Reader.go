@@ -0,0 +1,820 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * S7comm and S7comm-plus over TPKT and COTP (ISO 8073)
+ */
+
+const (
+	protocolS7comm     = "S7comm"
+	protocolS7commPlus = "S7comm-plus"
+
+	s7commProtocolID     = 0x32
+	s7commPlusProtocolID = 0x72
+
+	// COTP data TPDU and its end of transmission flag
+	cotpData = 0xf0
+	cotpEOT  = 0x80
+
+	// COTP connection request parameters
+	cotpTPDUSize = 0xc0
+	cotpSrcTSAP  = 0xc1
+	cotpDstTSAP  = 0xc2
+
+	// upper limit for reassembled COTP data, the negotiated PDU size is at most 960 bytes for S7comm
+	cotpMaxDataSize = 64 * 1024
+)
+
+// S7comm message types (ROSCTR)
+const (
+	s7Job      = 0x01
+	s7Ack      = 0x02
+	s7AckData  = 0x03
+	s7UserData = 0x07
+)
+
+// S7comm job functions
+const (
+	s7FuncReadVar         = 0x04
+	s7FuncWriteVar        = 0x05
+	s7FuncRequestDownload = 0x1a
+	s7FuncDownloadBlock   = 0x1b
+	s7FuncDownloadEnded   = 0x1c
+	s7FuncStartUpload     = 0x1d
+	s7FuncUpload          = 0x1e
+	s7FuncEndUpload       = 0x1f
+	s7FuncPLCControl      = 0x28
+	s7FuncPLCStop         = 0x29
+	s7FuncSetupComm       = 0xf0
+)
+
+// S7comm-plus opcodes
+const (
+	s7PlusRequest  = 0x31
+	s7PlusResponse = 0x32
+
+	// length of the integrity part of protocol version 3
+	s7PlusDigestSize = 0x20
+)
+
+var s7Types = map[byte]string{
+	s7Job:      "Job",
+	s7Ack:      "Ack",
+	s7AckData:  "Ack_Data",
+	s7UserData: "Userdata",
+}
+
+var s7Functions = map[byte]string{
+	s7FuncReadVar:         "Read Var",
+	s7FuncWriteVar:        "Write Var",
+	s7FuncRequestDownload: "Request Download",
+	s7FuncDownloadBlock:   "Download Block",
+	s7FuncDownloadEnded:   "Download Ended",
+	s7FuncStartUpload:     "Start Upload",
+	s7FuncUpload:          "Upload",
+	s7FuncEndUpload:       "End Upload",
+	s7FuncPLCControl:      "PLC Control",
+	s7FuncPLCStop:         "PLC Stop",
+	s7FuncSetupComm:       "Setup Communication",
+}
+
+// s7FunctionNotes describe jobs that change the PLC or transfer its program.
+var s7FunctionNotes = map[byte]string{
+	s7FuncWriteVar:        "writes variables",
+	s7FuncRequestDownload: "downloads a block to the PLC",
+	s7FuncStartUpload:     "uploads a block from the PLC",
+	s7FuncPLCStop:         "stops the PLC",
+}
+
+// s7PIServices are the program invocation services of the PLC Control function.
+var s7PIServices = map[string]string{
+	"P_PROGRAM": "starts the PLC",
+	"_INSE":     "activates downloaded blocks",
+	"_DELE":     "deletes blocks",
+	"_GARB":     "compresses the memory",
+	"_MODU":     "copies RAM to ROM",
+}
+
+// s7UserDataGroups are the function groups of userdata messages.
+var s7UserDataGroups = map[byte]string{
+	1: "Mode transition",
+	2: "Cyclic data",
+	3: "Block functions",
+	4: "CPU functions",
+	5: "Security",
+	6: "Programmable block functions",
+	7: "Time functions",
+}
+
+// s7UserDataFunctions are the subfunctions of userdata messages by group.
+var s7UserDataFunctions = map[uint16]string{
+	0x0301: "List blocks",
+	0x0302: "List blocks of type",
+	0x0303: "Get block info",
+	0x0401: "Read SZL",
+	0x0501: "PLC password",
+	0x0502: "Clean session",
+	0x0701: "Read clock",
+	0x0702: "Set clock",
+}
+
+var s7UserDataNotes = map[uint16]string{
+	0x0501: "password authentication",
+	0x0702: "sets the PLC clock",
+}
+
+const s7UserDataReadSZL = 0x0401
+
+var s7Areas = map[byte]string{
+	0x03: "SYS",
+	0x1c: "C",
+	0x1d: "T",
+	0x80: "P",
+	0x81: "I",
+	0x82: "Q",
+	0x83: "M",
+	0x84: "DB",
+	0x85: "DI",
+	0x86: "L",
+	0x87: "V",
+}
+
+var s7TransportSizes = map[byte]string{
+	0x01: "BIT",
+	0x02: "BYTE",
+	0x03: "CHAR",
+	0x04: "WORD",
+	0x05: "INT",
+	0x06: "DWORD",
+	0x07: "DINT",
+	0x08: "REAL",
+	0x1c: "COUNTER",
+	0x1d: "TIMER",
+}
+
+var s7ErrorClasses = map[byte]string{
+	0x81: "application relationship",
+	0x82: "object definition",
+	0x83: "no resources available",
+	0x84: "error on service processing",
+	0x85: "error on supplies",
+	0x87: "access error",
+}
+
+// s7ReturnCodes are the return codes of variable items and userdata.
+var s7ReturnCodes = map[byte]string{
+	0x01: "hardware fault",
+	0x03: "accessing the object not allowed",
+	0x05: "address out of range",
+	0x06: "data type not supported",
+	0x07: "data type inconsistent",
+	0x0a: "object does not exist",
+}
+
+const s7ReturnCodeSuccess = 0xff
+
+// s7BlockTypes are the block types in the file names of uploads and downloads.
+var s7BlockTypes = map[string]string{
+	"08": "OB",
+	"0A": "DB",
+	"0B": "SDB",
+	"0C": "FC",
+	"0D": "SFC",
+	"0E": "FB",
+	"0F": "SFB",
+}
+
+var s7PlusFunctions = map[uint16]string{
+	0x04b1: "Error",
+	0x04bb: "Explore",
+	0x04ca: "CreateObject",
+	0x04d4: "DeleteObject",
+	0x04f2: "SetVariable",
+	0x04fc: "GetVariable",
+	0x0506: "AddLink",
+	0x051a: "RemoveLink",
+	0x0524: "GetLink",
+	0x0542: "SetMultiVariables",
+	0x054c: "GetMultiVariables",
+	0x0556: "BeginSequence",
+	0x0560: "EndSequence",
+	0x056b: "Invoke",
+	0x057c: "SetVarSubStreamed",
+	0x0586: "GetVarSubStreamed",
+	0x0590: "GetVariablesAddress",
+	0x059a: "Abort",
+}
+
+var s7PlusNotes = map[uint16]string{
+	0x04d4: "deletes an object",
+	0x04f2: "writes variables",
+	0x0542: "writes variables",
+}
+
+// isS7 checks if the default port is used or the connection confirm contains TSAPs,
+// which are not used by RDP.
+func isS7(banner []byte, transport gopacket.Flow) bool {
+	if transport.Dst().String() == "102" {
+		return true
+	}
+
+	frames := rdpFrames(banner)
+	if len(frames) == 0 {
+		return false
+	}
+
+	params, ok := x224Payload(frames[0], x224ConnectionConfirm)
+
+	return ok && len(params) >= 2 && (params[0] == cotpSrcTSAP || params[0] == cotpDstTSAP || params[0] == cotpTPDUSize)
+}
+
+// s7Item describes a variable in the S7ANY address format.
+func s7Item(item []byte) string {
+	// specification type, length and syntax ID
+	if len(item) < 3 {
+		return ""
+	}
+
+	if item[2] != 0x10 || len(item) < 12 {
+		return fmt.Sprintf("syntax 0x%02x", item[2])
+	}
+
+	var (
+		size    = s7TransportSizes[item[3]]
+		count   = binary.BigEndian.Uint16(item[4:6])
+		db      = binary.BigEndian.Uint16(item[6:8])
+		area    = s7Areas[item[8]]
+		address = uint32(item[9])<<16 | uint32(item[10])<<8 | uint32(item[11])
+	)
+
+	if size == "" {
+		size = fmt.Sprintf("0x%02x", item[3])
+	}
+
+	if area == "" {
+		area = fmt.Sprintf("0x%02x", item[8])
+	}
+
+	if area == "DB" || area == "DI" {
+		area += strconv.Itoa(int(db))
+	}
+
+	return fmt.Sprintf("%s %d.%d %s[%d]", area, address>>3, address&0x07, size, count)
+}
+
+// s7Block returns the block name from a file name like _0A00001P.
+func s7Block(param []byte) string {
+	if len(param) < 9 || len(param) < 9+int(param[8]) {
+		return ""
+	}
+
+	name := string(param[9 : 9+int(param[8])])
+	if len(name) < 8 {
+		return name
+	}
+
+	typ, ok := s7BlockTypes[name[1:3]]
+	if !ok {
+		return name
+	}
+
+	num, err := strconv.Atoi(name[3:8])
+	if err != nil {
+		return name
+	}
+
+	return typ + strconv.Itoa(num)
+}
+
+// s7PIService returns the program invocation service of PLC Control and PLC Stop jobs.
+func s7PIService(fc byte, param []byte) string {
+	off := 6
+	if fc == s7FuncPLCControl {
+		// skip the parameter block
+		if len(param) < 10 {
+			return ""
+		}
+
+		off = 10 + int(binary.BigEndian.Uint16(param[8:10]))
+	}
+
+	if len(param) <= off || len(param) < off+1+int(param[off]) {
+		return ""
+	}
+
+	return string(param[off+1 : off+1+int(param[off])])
+}
+
+type s7Request struct {
+	record *types.S7
+	ts     time.Time
+	fc     byte
+}
+
+type s7Reader struct {
+	parent *tcpConnection
+
+	rack, slot int32
+
+	// COTP data by direction
+	clientData []byte
+	serverData []byte
+
+	// requests waiting for a response by PDU reference or sequence number
+	pending     map[uint16]*s7Request
+	pendingPlus map[uint16]*s7Request
+}
+
+// Decode parses the stream according to the S7comm and S7comm-plus protocols.
+func (h *s7Reader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	h.pending = make(map[uint16]*s7Request)
+	h.pendingPlus = make(map[uint16]*s7Request)
+
+	// the merged fragments are ordered by time, so jobs are processed before their responses
+	for _, d := range h.parent.merged {
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.read(&clientBuf, true, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.read(&serverBuf, false, ts)
+		}
+	}
+
+	// write requests that did not receive a response
+	for _, pending := range []map[uint16]*s7Request{h.pending, h.pendingPlus} {
+		refs := make([]int, 0, len(pending))
+		for ref := range pending {
+			refs = append(refs, int(ref))
+		}
+
+		sort.Ints(refs)
+
+		for _, ref := range refs {
+			req := pending[uint16(ref)]
+			req.record.Notes = addInfo(req.record.Notes, "no response")
+			h.write(req.record)
+		}
+	}
+}
+
+// read extracts the TPKT frames in the buffer.
+func (h *s7Reader) read(buf *bytes.Buffer, client bool, ts time.Time) {
+	for buf.Len() >= 4 {
+		data := buf.Bytes()
+		if data[0] != rdpTPKTVersion {
+			utils.DebugLog.Println("S7: invalid TPKT on", h.parent.ident)
+			buf.Reset()
+
+			return
+		}
+
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 7 {
+			buf.Reset()
+
+			return
+		}
+
+		if buf.Len() < length {
+			return
+		}
+
+		frame := buf.Next(length)
+		h.cotp(frame, client, ts)
+	}
+}
+
+// cotp handles the connection request and reassembles the data TPDUs.
+func (h *s7Reader) cotp(frame []byte, client bool, ts time.Time) {
+	if params, ok := x224Payload(frame, x224ConnectionRequest); ok && client {
+		for len(params) >= 2 && len(params) >= 2+int(params[1]) {
+			value := params[2 : 2+int(params[1])]
+			if params[0] == cotpDstTSAP && len(value) == 2 {
+				h.rack = int32(value[1] >> 5)
+				h.slot = int32(value[1] & 0x1f)
+			}
+
+			params = params[2+len(value):]
+		}
+
+		return
+	}
+
+	if frame[5] != cotpData || len(frame) < 7 || 5+int(frame[4]) > len(frame) {
+		return
+	}
+
+	var (
+		payload = frame[5+int(frame[4]):]
+		data    = &h.serverData
+	)
+
+	if client {
+		data = &h.clientData
+	}
+
+	*data = append(*data, payload...)
+	if len(*data) > cotpMaxDataSize {
+		*data = nil
+
+		return
+	}
+
+	if frame[6]&cotpEOT == 0 {
+		return
+	}
+
+	pdu := *data
+	*data = nil
+
+	if len(pdu) == 0 {
+		return
+	}
+
+	switch pdu[0] {
+	case s7commProtocolID:
+		h.s7comm(pdu, client, ts)
+	case s7commPlusProtocolID:
+		h.s7commPlus(pdu, client, ts)
+	}
+}
+
+func (h *s7Reader) newRecord(protocol string, ts time.Time) *types.S7 {
+	return &types.S7{
+		Timestamp: utils.TimeToString(ts),
+		ClientIP:  h.parent.net.Src().String(),
+		ServerIP:  h.parent.net.Dst().String(),
+		Flow:      h.parent.ident,
+		Protocol:  protocol,
+		Rack:      h.rack,
+		Slot:      h.slot,
+	}
+}
+
+// s7comm decodes a S7comm PDU.
+func (h *s7Reader) s7comm(pdu []byte, client bool, ts time.Time) {
+	if len(pdu) < 10 {
+		return
+	}
+
+	var (
+		rosctr    = pdu[1]
+		ref       = binary.BigEndian.Uint16(pdu[4:6])
+		paramLen  = int(binary.BigEndian.Uint16(pdu[6:8]))
+		dataLen   = int(binary.BigEndian.Uint16(pdu[8:10]))
+		headerLen = 10
+	)
+
+	if rosctr == s7Ack || rosctr == s7AckData {
+		headerLen = 12
+	}
+
+	if len(pdu) < headerLen+paramLen {
+		return
+	}
+
+	var (
+		param = pdu[headerLen : headerLen+paramLen]
+		data  = pdu[headerLen+paramLen:]
+	)
+
+	if len(data) > dataLen {
+		data = data[:dataLen]
+	}
+
+	switch rosctr {
+	case s7Job:
+		if !client || len(param) == 0 {
+			return
+		}
+
+		h.job(ref, param, ts)
+	case s7Ack, s7AckData:
+		req, ok := h.pending[ref]
+		if client || !ok {
+			return
+		}
+
+		delete(h.pending, ref)
+
+		req.record.Latency = ts.Sub(req.ts).Nanoseconds()
+		req.record.Status = "OK"
+
+		if class := pdu[10]; class != 0 {
+			name, known := s7ErrorClasses[class]
+			if !known {
+				name = "error"
+			}
+
+			req.record.Status = fmt.Sprintf("%s (0x%02x%02x)", name, class, pdu[11])
+		} else {
+			h.itemStatus(req, data)
+		}
+
+		h.write(req.record)
+	case s7UserData:
+		h.userData(ref, param, data, client, ts)
+	}
+}
+
+// job creates the record for a job request.
+func (h *s7Reader) job(ref uint16, param []byte, ts time.Time) {
+	var (
+		fc  = param[0]
+		req = &s7Request{
+			record: h.newRecord(protocolS7comm, ts),
+			ts:     ts,
+			fc:     fc,
+		}
+		r = req.record
+	)
+
+	r.Type = s7Types[s7Job]
+	r.PDUReference = int32(ref)
+	r.Function = s7Functions[fc]
+	r.Notes = s7FunctionNotes[fc]
+
+	if r.Function == "" {
+		r.Function = fmt.Sprintf("Function 0x%02x", fc)
+	}
+
+	switch fc {
+	case s7FuncReadVar, s7FuncWriteVar:
+		if len(param) < 2 {
+			break
+		}
+
+		items := param[2:]
+		for i := 0; i < int(param[1]) && len(items) >= 2; i++ {
+			size := 2 + int(items[1])
+			if size > len(items) {
+				break
+			}
+
+			r.Items = append(r.Items, s7Item(items[:size]))
+			items = items[size:]
+		}
+	case s7FuncRequestDownload, s7FuncDownloadBlock, s7FuncDownloadEnded, s7FuncStartUpload:
+		r.Block = s7Block(param)
+	case s7FuncPLCControl, s7FuncPLCStop:
+		r.Service = s7PIService(fc, param)
+
+		if note, ok := s7PIServices[r.Service]; ok && fc == s7FuncPLCControl {
+			r.Notes = note
+		}
+	}
+
+	if old, ok := h.pending[ref]; ok {
+		old.record.Notes = addInfo(old.record.Notes, "no response")
+		h.write(old.record)
+	}
+
+	h.pending[ref] = req
+}
+
+// itemStatus adds the return codes of the variables to the record of a Read Var or Write Var job.
+func (h *s7Reader) itemStatus(req *s7Request, data []byte) {
+	for i := 0; len(data) > 0 && i < len(req.record.Items); i++ {
+		code := data[0]
+
+		if code != s7ReturnCodeSuccess {
+			msg, ok := s7ReturnCodes[code]
+			if !ok {
+				msg = fmt.Sprintf("return code 0x%02x", code)
+			}
+
+			req.record.Notes = addInfo(req.record.Notes, "item "+strconv.Itoa(i+1)+": "+msg)
+		}
+
+		// the response to Write Var only contains the return codes
+		if req.fc != s7FuncReadVar {
+			data = data[1:]
+
+			continue
+		}
+
+		// return code, transport size, length and the value padded to an even length
+		if len(data) < 4 {
+			return
+		}
+
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+
+		switch data[1] {
+		case 0x03, 0x04, 0x05: // lengths in bits
+			length = (length + 7) / 8
+		}
+
+		if code != s7ReturnCodeSuccess {
+			length = 0
+		}
+
+		size := 4 + length
+		if size%2 == 1 && len(data) > size {
+			size++
+		}
+
+		if size > len(data) {
+			return
+		}
+
+		data = data[size:]
+	}
+}
+
+// userData handles the userdata requests and responses.
+func (h *s7Reader) userData(ref uint16, param, data []byte, client bool, ts time.Time) {
+	// parameter head, length, method, type and group, subfunction and sequence number
+	if len(param) < 8 {
+		return
+	}
+
+	var (
+		typ   = param[5] >> 4
+		group = param[5] & 0x0f
+		id    = uint16(group)<<8 | uint16(param[6])
+	)
+
+	switch {
+	case typ == 4 && client:
+		var (
+			req = &s7Request{
+				record: h.newRecord(protocolS7comm, ts),
+				ts:     ts,
+			}
+			r = req.record
+		)
+
+		r.Type = s7Types[s7UserData]
+		r.PDUReference = int32(ref)
+		r.Function = s7UserDataFunctions[id]
+		r.Notes = s7UserDataNotes[id]
+
+		if r.Function == "" {
+			r.Function = s7UserDataGroups[group]
+			if r.Function == "" {
+				r.Function = fmt.Sprintf("Group %d", group)
+			}
+
+			r.Function += fmt.Sprintf(" (subfunction %d)", param[6])
+		}
+
+		// return code, transport size, length, SZL ID and index
+		if id == s7UserDataReadSZL && len(data) >= 8 {
+			r.Items = append(r.Items, fmt.Sprintf("SZL 0x%04x index 0x%04x", binary.BigEndian.Uint16(data[4:6]), binary.BigEndian.Uint16(data[6:8])))
+		}
+
+		if old, ok := h.pending[ref]; ok {
+			old.record.Notes = addInfo(old.record.Notes, "no response")
+			h.write(old.record)
+		}
+
+		h.pending[ref] = req
+	case typ == 8 && !client:
+		req, ok := h.pending[ref]
+		if !ok {
+			return
+		}
+
+		delete(h.pending, ref)
+
+		req.record.Latency = ts.Sub(req.ts).Nanoseconds()
+		req.record.Status = "OK"
+
+		// error code of the response parameters
+		if len(param) >= 12 && binary.BigEndian.Uint16(param[10:12]) != 0 {
+			req.record.Status = fmt.Sprintf("error (0x%04x)", binary.BigEndian.Uint16(param[10:12]))
+		} else if len(data) > 0 && data[0] != s7ReturnCodeSuccess {
+			msg, known := s7ReturnCodes[data[0]]
+			if !known {
+				msg = fmt.Sprintf("return code 0x%02x", data[0])
+			}
+
+			req.record.Status = msg
+		}
+
+		h.write(req.record)
+	}
+}
+
+// s7commPlus decodes the function and sequence number of a S7comm-plus PDU.
+func (h *s7Reader) s7commPlus(pdu []byte, client bool, ts time.Time) {
+	if len(pdu) < 4 {
+		return
+	}
+
+	var (
+		version = pdu[1]
+		data    = pdu[4:]
+	)
+
+	if length := int(binary.BigEndian.Uint16(pdu[2:4])); length < len(data) {
+		data = data[:length]
+	}
+
+	// protocol version 3 adds a digest for integrity protection
+	if version == 0x03 && len(data) > s7PlusDigestSize && data[0] == s7PlusDigestSize {
+		data = data[1+s7PlusDigestSize:]
+	}
+
+	// opcode, reserved, function, reserved and sequence number
+	if len(data) < 9 {
+		return
+	}
+
+	var (
+		opcode   = data[0]
+		function = binary.BigEndian.Uint16(data[3:5])
+		seq      = binary.BigEndian.Uint16(data[7:9])
+	)
+
+	switch {
+	case opcode == s7PlusRequest && client:
+		req := &s7Request{
+			record: h.newRecord(protocolS7commPlus, ts),
+			ts:     ts,
+		}
+		r := req.record
+
+		r.Type = "Request"
+		r.PDUReference = int32(seq)
+		r.Function = s7PlusFunctions[function]
+		r.Notes = s7PlusNotes[function]
+
+		if r.Function == "" {
+			r.Function = fmt.Sprintf("Function 0x%04x", function)
+		}
+
+		if old, ok := h.pendingPlus[seq]; ok {
+			old.record.Notes = addInfo(old.record.Notes, "no response")
+			h.write(old.record)
+		}
+
+		h.pendingPlus[seq] = req
+	case opcode == s7PlusResponse && !client:
+		req, ok := h.pendingPlus[seq]
+		if !ok {
+			return
+		}
+
+		delete(h.pendingPlus, seq)
+
+		req.record.Latency = ts.Sub(req.ts).Nanoseconds()
+		req.record.Status = "OK"
+
+		if function == 0x04b1 {
+			req.record.Status = "error"
+		}
+
+		h.write(req.record)
+	}
+}
+
+func (h *s7Reader) write(r *types.S7) {
+	if conf.ExportMetrics {
+		r.Inc()
+	}
+
+	atomic.AddInt64(&s7Decoder.numRecords, 1)
+
+	err := s7Decoder.writer.Write(r)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
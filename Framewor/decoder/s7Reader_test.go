@@ -0,0 +1,144 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func s7TestTPKT(tpdu ...byte) []byte {
+	b := []byte{rdpTPKTVersion, 0, 0, 0}
+	binary.BigEndian.PutUint16(b[2:4], uint16(4+len(tpdu)))
+
+	return append(b, tpdu...)
+}
+
+// s7TestData wraps a PDU into a COTP data TPDU.
+func s7TestData(eot bool, pdu ...byte) []byte {
+	flags := byte(0)
+	if eot {
+		flags = cotpEOT
+	}
+
+	return s7TestTPKT(append([]byte{0x02, cotpData, flags}, pdu...)...)
+}
+
+// s7TestPDU builds a S7comm PDU, acknowledgements include the error class and code.
+func s7TestPDU(rosctr byte, ref uint16, param, data []byte) []byte {
+	b := []byte{s7commProtocolID, rosctr, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(b[4:6], ref)
+	binary.BigEndian.PutUint16(b[6:8], uint16(len(param)))
+	binary.BigEndian.PutUint16(b[8:10], uint16(len(data)))
+
+	if rosctr == s7Ack || rosctr == s7AckData {
+		b = append(b, 0, 0)
+	}
+
+	return append(append(b, param...), data...)
+}
+
+func TestS7Reader(t *testing.T) {
+	var (
+		records = &recordCollector{}
+		oldConf = conf
+
+		// TSAPs for rack 0 and slot 2
+		tsaps = []byte{cotpTPDUSize, 0x01, 0x0a, cotpSrcTSAP, 0x02, 0x01, 0x00, cotpDstTSAP, 0x02, 0x01, 0x02}
+		cr    = s7TestTPKT(append([]byte{byte(6 + len(tsaps)), x224ConnectionRequest, 0, 0, 0, 1, 0}, tsaps...)...)
+		cc    = s7TestTPKT(append([]byte{byte(6 + len(tsaps)), x224ConnectionConfirm, 0, 1, 0, 1, 0}, tsaps...)...)
+
+		setup = []byte{s7FuncSetupComm, 0, 0, 1, 0, 1, 0x01, 0xe0}
+		read  = s7TestPDU(s7Job, 2, []byte{
+			s7FuncReadVar, 2,
+			0x12, 0x0a, 0x10, 0x02, 0x00, 0x04, 0x00, 0x01, 0x84, 0x00, 0x00, 0x00,
+			0x12, 0x0a, 0x10, 0x02, 0x00, 0x02, 0x00, 0x00, 0x83, 0x00, 0x00, 0x50,
+		}, nil)
+		stop     = append([]byte{s7FuncPLCStop, 0, 0, 0, 0, 0, 9}, "P_PROGRAM"...)
+		download = append([]byte{s7FuncRequestDownload, 0, 1, 0, 0, 0, 0, 0, 9}, "_0A00001P"...)
+	)
+
+	defer func() {
+		conf = oldConf
+	}()
+
+	conf = &Config{}
+	s7Decoder.writer = records
+
+	if !isS7(cc, newTestConnection(50000, 1102).transport) {
+		t.Fatal("failed to detect S7")
+	}
+
+	// connection confirm with a RDP negotiation response
+	if isS7(s7TestTPKT(14, x224ConnectionConfirm, 0, 0, 0, 0, 0, rdpNegResponse, 0, 8, 0, 1, 0, 0, 0), newTestConnection(50000, 3389).transport) {
+		t.Fatal("RDP detected as S7")
+	}
+
+	c := newTestConnection(50000, 102,
+		append(cr, s7TestData(true, s7TestPDU(s7Job, 1, setup, nil)...)...),
+		append(cc, s7TestData(true, s7TestPDU(s7AckData, 1, setup, nil)...)...),
+		// read var split into two data TPDUs
+		s7TestData(false, read[:15]...),
+		nil,
+		s7TestData(true, read[15:]...),
+		s7TestData(true, s7TestPDU(s7AckData, 2, []byte{s7FuncReadVar, 2}, []byte{0xff, 0x04, 0x00, 0x20, 1, 2, 3, 4, 0x0a, 0x00, 0x00, 0x00})...),
+		append(s7TestData(true, s7TestPDU(s7Job, 3, stop, nil)...), s7TestData(true, s7TestPDU(s7Job, 4, download, nil)...)...),
+		s7TestData(true, s7TestPDU(s7AckData, 4, []byte{s7FuncRequestDownload}, nil)...),
+		// read SZL 0x0011
+		s7TestData(true, s7TestPDU(s7UserData, 5, []byte{0x00, 0x01, 0x12, 0x04, 0x11, 0x44, 0x01, 0x00}, []byte{0xff, 0x09, 0x00, 0x04, 0x00, 0x11, 0x00, 0x00})...),
+		s7TestData(true, s7TestPDU(s7UserData, 5, []byte{0x00, 0x01, 0x12, 0x08, 0x12, 0x84, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00}, []byte{0xff, 0x09, 0x00, 0x04, 0x00, 0x11, 0x00, 0x00})...),
+		// S7comm-plus SetVariable
+		s7TestData(true, s7commPlusProtocolID, 0x01, 0x00, 0x09, s7PlusRequest, 0x00, 0x00, 0x04, 0xf2, 0x00, 0x00, 0x00, 0x07, s7commPlusProtocolID, 0x01, 0x00, 0x00),
+		s7TestData(true, s7commPlusProtocolID, 0x01, 0x00, 0x09, s7PlusResponse, 0x00, 0x00, 0x04, 0xf2, 0x00, 0x00, 0x00, 0x07, s7commPlusProtocolID, 0x01, 0x00, 0x00),
+	)
+
+	(&s7Reader{parent: c}).Decode()
+
+	if len(records.records) != 6 {
+		t.Fatal("expected 6 S7 records, got", len(records.records))
+	}
+
+	r := records.records[0].(*types.S7)
+	if r.Function != "Setup Communication" || r.Type != "Job" || r.Status != "OK" || r.Rack != 0 || r.Slot != 2 || r.Latency != 1000000 {
+		t.Fatal("unexpected setup record:", r)
+	}
+
+	r = records.records[1].(*types.S7)
+	if r.Function != "Read Var" || strings.Join(r.Items, ",") != "DB1 0.0 BYTE[4],M 10.0 BYTE[2]" || r.Notes != "item 2: object does not exist" {
+		t.Fatal("unexpected read record:", r)
+	}
+
+	r = records.records[2].(*types.S7)
+	if r.Function != "Request Download" || r.Block != "DB1" || r.Notes != "downloads a block to the PLC" || r.PDUReference != 4 {
+		t.Fatal("unexpected download record:", r)
+	}
+
+	r = records.records[3].(*types.S7)
+	if r.Function != "Read SZL" || r.Type != "Userdata" || strings.Join(r.Items, ",") != "SZL 0x0011 index 0x0000" || r.Status != "OK" {
+		t.Fatal("unexpected SZL record:", r)
+	}
+
+	r = records.records[4].(*types.S7)
+	if r.Protocol != protocolS7commPlus || r.Function != "SetVariable" || r.PDUReference != 7 || r.Status != "OK" || r.Notes != "writes variables" {
+		t.Fatal("unexpected S7comm-plus record:", r)
+	}
+
+	r = records.records[5].(*types.S7)
+	if r.Function != "PLC Stop" || r.Service != "P_PROGRAM" || r.Notes != "stops the PLC | no response" {
+		t.Fatal("unexpected stop record:", r)
+	}
+}
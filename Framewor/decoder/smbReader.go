@@ -0,0 +1,698 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * SMB2 / SMB3 - Server Message Block
+ */
+
+const (
+	smbHeaderLen = 64
+
+	smbFlagResponse = 0x00000001
+	smbFlagAsync    = 0x00000002
+	smbFlagRelated  = 0x00000004
+
+	smbStatusSuccess = 0x00000000
+	smbStatusPending = 0x00000103
+
+	// NetBIOS session message type
+	nbssSessionMessage = 0x00
+
+	// upper limit for NetBIOS frames, used to detect streams that are out of sync
+	nbssMaxFrameSize = 16 * 1024 * 1024
+
+	// upper limit for the size of reconstructed files
+	smbMaxFileSize = 512 * 1024 * 1024
+)
+
+// SMB2 commands.
+const (
+	smb2Negotiate uint16 = iota
+	smb2SessionSetup
+	smb2Logoff
+	smb2TreeConnect
+	smb2TreeDisconnect
+	smb2Create
+	smb2Close
+	smb2Flush
+	smb2Read
+	smb2Write
+	smb2Lock
+	smb2Ioctl
+	smb2Cancel
+	smb2Echo
+	smb2QueryDirectory
+	smb2ChangeNotify
+	smb2QueryInfo
+	smb2SetInfo
+	smb2OplockBreak
+)
+
+var (
+	smb2ProtocolID          = []byte{0xfe, 'S', 'M', 'B'}
+	smb1ProtocolID          = []byte{0xff, 'S', 'M', 'B'}
+	smb3TransformProtocolID = []byte{0xfd, 'S', 'M', 'B'}
+
+	// file id used by related operations in a compound request to refer to the previously opened file
+	smbRelatedFileID = strings.Repeat("ff", 16)
+)
+
+var smbCommands = map[uint16]string{
+	smb2Negotiate:      "NEGOTIATE",
+	smb2SessionSetup:   "SESSION_SETUP",
+	smb2Logoff:         "LOGOFF",
+	smb2TreeConnect:    "TREE_CONNECT",
+	smb2TreeDisconnect: "TREE_DISCONNECT",
+	smb2Create:         "CREATE",
+	smb2Close:          "CLOSE",
+	smb2Flush:          "FLUSH",
+	smb2Read:           "READ",
+	smb2Write:          "WRITE",
+	smb2Lock:           "LOCK",
+	smb2Ioctl:          "IOCTL",
+	smb2Cancel:         "CANCEL",
+	smb2Echo:           "ECHO",
+	smb2QueryDirectory: "QUERY_DIRECTORY",
+	smb2ChangeNotify:   "CHANGE_NOTIFY",
+	smb2QueryInfo:      "QUERY_INFO",
+	smb2SetInfo:        "SET_INFO",
+	smb2OplockBreak:    "OPLOCK_BREAK",
+}
+
+var smbStatusNames = map[uint32]string{
+	smbStatusSuccess: "STATUS_SUCCESS",
+	smbStatusPending: "STATUS_PENDING",
+	0x80000005:       "STATUS_BUFFER_OVERFLOW",
+	0x80000006:       "STATUS_NO_MORE_FILES",
+	0xc000000d:       "STATUS_INVALID_PARAMETER",
+	0xc0000011:       "STATUS_END_OF_FILE",
+	0xc0000016:       "STATUS_MORE_PROCESSING_REQUIRED",
+	0xc0000022:       "STATUS_ACCESS_DENIED",
+	0xc0000034:       "STATUS_OBJECT_NAME_NOT_FOUND",
+	0xc0000035:       "STATUS_OBJECT_NAME_COLLISION",
+	0xc000003a:       "STATUS_OBJECT_PATH_NOT_FOUND",
+	0xc0000043:       "STATUS_SHARING_VIOLATION",
+	0xc000006d:       "STATUS_LOGON_FAILURE",
+	0xc0000071:       "STATUS_PASSWORD_EXPIRED",
+	0xc0000072:       "STATUS_ACCOUNT_DISABLED",
+	0xc00000ba:       "STATUS_FILE_IS_A_DIRECTORY",
+	0xc00000bb:       "STATUS_NOT_SUPPORTED",
+	0xc00000cc:       "STATUS_BAD_NETWORK_NAME",
+	0xc0000103:       "STATUS_NOT_A_DIRECTORY",
+	0xc0000120:       "STATUS_CANCELLED",
+	0xc0000203:       "STATUS_USER_SESSION_DELETED",
+}
+
+var smbDialects = map[uint16]string{
+	0x0202: "2.0.2",
+	0x0210: "2.1",
+	0x02ff: "2.???",
+	0x0300: "3.0",
+	0x0302: "3.0.2",
+	0x0311: "3.1.1",
+}
+
+var smbCreateActions = map[uint32]string{
+	0: "superseded",
+	1: "opened",
+	2: "created",
+	3: "overwritten",
+}
+
+var smbShareTypes = map[uint8]string{
+	1: "disk",
+	2: "pipe",
+	3: "print",
+}
+
+func smbCommandName(c uint16) string {
+	if name, ok := smbCommands[c]; ok {
+		return name
+	}
+
+	return "0x" + strconv.FormatUint(uint64(c), 16)
+}
+
+func smbStatusName(s uint32) string {
+	if name, ok := smbStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("0x%08x", s)
+}
+
+func smbDialectName(d uint16) string {
+	if name, ok := smbDialects[d]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("0x%04x", d)
+}
+
+// isSMB checks if the data starts with a NetBIOS session header followed by a SMB header.
+func isSMB(data []byte) bool {
+	if len(data) < 8 || data[0] != nbssSessionMessage {
+		return false
+	}
+
+	return bytes.Equal(data[4:8], smb2ProtocolID) ||
+		bytes.Equal(data[4:8], smb1ProtocolID) ||
+		bytes.Equal(data[4:8], smb3TransformProtocolID)
+}
+
+// smbHeader is the sync or async SMB2 packet header.
+type smbHeader struct {
+	status      uint32
+	command     uint16
+	flags       uint32
+	nextCommand uint32
+	messageID   uint64
+	treeID      uint32
+	sessionID   uint64
+}
+
+// parseSMBHeader parses the SMB2 header at the beginning of the message.
+func parseSMBHeader(b []byte) (*smbHeader, bool) {
+	if len(b) < smbHeaderLen || !bytes.Equal(b[:4], smb2ProtocolID) {
+		return nil, false
+	}
+
+	h := &smbHeader{
+		status:      binary.LittleEndian.Uint32(b[8:12]),
+		command:     binary.LittleEndian.Uint16(b[12:14]),
+		flags:       binary.LittleEndian.Uint32(b[16:20]),
+		nextCommand: binary.LittleEndian.Uint32(b[20:24]),
+		messageID:   binary.LittleEndian.Uint64(b[24:32]),
+		sessionID:   binary.LittleEndian.Uint64(b[40:48]),
+	}
+
+	// the async header uses the tree id field for the async id
+	if h.flags&smbFlagAsync == 0 {
+		h.treeID = binary.LittleEndian.Uint32(b[36:40])
+	}
+
+	return h, true
+}
+
+// smbBuffer returns the variable length data referenced by an offset relative to the start of the SMB2 header.
+func smbBuffer(msg []byte, offset, length int) []byte {
+	if offset < smbHeaderLen || length <= 0 || offset+length > len(msg) {
+		return nil
+	}
+
+	return msg[offset : offset+length]
+}
+
+type smbSession struct {
+	user        string
+	domain      string
+	workstation string
+}
+
+// smbFile is a file that is transferred via read or write commands.
+type smbFile struct {
+	name      string
+	share     string
+	source    string
+	firstSeen time.Time
+
+	// mapped file offsets to data
+	chunks map[uint64][]byte
+}
+
+// assemble the chunks into a single buffer, regions that have not been transferred are zeroed.
+func (f *smbFile) assemble() []byte {
+	var size uint64
+	for off, c := range f.chunks {
+		if end := off + uint64(len(c)); end > size {
+			size = end
+		}
+	}
+
+	if size > smbMaxFileSize {
+		return nil
+	}
+
+	data := make([]byte, size)
+	for off, c := range f.chunks {
+		copy(data[off:], c)
+	}
+
+	return data
+}
+
+type smbRequest struct {
+	record *types.SMB
+	ts     time.Time
+
+	// request specific values needed to process the response
+	path    string
+	fileID  string
+	related *smbRequest
+}
+
+type smbReader struct {
+	parent *tcpConnection
+
+	dialect   string
+	encrypted int
+
+	sessions map[uint64]*smbSession
+	trees    map[uint32]string
+	files    map[string]*smbFile
+	pending  map[uint64]*smbRequest
+
+	lastRequest *smbRequest
+}
+
+// Decode parses the stream according to the SMB2 protocol.
+func (h *smbReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	h.sessions = make(map[uint64]*smbSession)
+	h.trees = make(map[uint32]string)
+	h.files = make(map[string]*smbFile)
+	h.pending = make(map[uint64]*smbRequest)
+
+	// the merged fragments are ordered by time, so requests are processed before their responses
+	for _, d := range h.parent.merged {
+		ts := d.ac.GetCaptureInfo().Timestamp
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readFrames(&clientBuf, ts)
+		} else {
+			serverBuf.Write(d.raw)
+			h.readFrames(&serverBuf, ts)
+		}
+	}
+
+	if useHarvesters {
+		harvestNTLM(h.parent)
+	}
+
+	// write requests that did not receive a response
+	unanswered := make([]*smbRequest, 0, len(h.pending))
+	for _, r := range h.pending {
+		unanswered = append(unanswered, r)
+	}
+
+	sort.Slice(unanswered, func(i, j int) bool {
+		return unanswered[i].record.MessageID < unanswered[j].record.MessageID
+	})
+
+	for _, r := range unanswered {
+		r.record.Notes = addInfo(r.record.Notes, "no response")
+		h.write(r.record)
+	}
+
+	// save files whose handles have not been closed
+	ids := make([]string, 0, len(h.files))
+	for id := range h.files {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		h.closeFile(id)
+	}
+
+	if h.encrypted > 0 {
+		h.write(&types.SMB{
+			Timestamp: utils.TimeToString(h.parent.firstPacket),
+			ClientIP:  h.parent.net.Src().String(),
+			ServerIP:  h.parent.net.Dst().String(),
+			Flow:      h.parent.ident,
+			Dialect:   h.dialect,
+			Command:   "TRANSFORM",
+			Notes:     strconv.Itoa(h.encrypted) + " encrypted messages",
+		})
+	}
+}
+
+// readFrames consumes all complete NetBIOS session frames from the buffer.
+func (h *smbReader) readFrames(buf *bytes.Buffer, ts time.Time) {
+	for buf.Len() >= 4 {
+		b := buf.Bytes()
+
+		length := int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+		if length > nbssMaxFrameSize {
+			// out of sync, drop the data
+			buf.Reset()
+
+			return
+		}
+
+		if buf.Len() < 4+length {
+			return
+		}
+
+		frame := make([]byte, length)
+		copy(frame, b[4:4+length])
+
+		buf.Next(4 + length)
+
+		if b[0] == nbssSessionMessage {
+			h.handleMessage(frame, ts)
+		}
+	}
+}
+
+// handleMessage processes a single or a compounded SMB2 message.
+func (h *smbReader) handleMessage(msg []byte, ts time.Time) {
+	for len(msg) >= smbHeaderLen {
+		hdr, ok := parseSMBHeader(msg)
+		if !ok {
+			if bytes.HasPrefix(msg, smb3TransformProtocolID) {
+				h.encrypted++
+			}
+
+			return
+		}
+
+		end := len(msg)
+		if hdr.nextCommand >= smbHeaderLen && int(hdr.nextCommand) < len(msg) {
+			end = int(hdr.nextCommand)
+		}
+
+		if hdr.flags&smbFlagResponse != 0 {
+			h.handleResponse(hdr, msg[:end])
+		} else {
+			h.handleRequest(hdr, msg[:end], ts)
+		}
+
+		msg = msg[end:]
+	}
+}
+
+func (h *smbReader) handleRequest(hdr *smbHeader, msg []byte, ts time.Time) {
+	var (
+		body = msg[smbHeaderLen:]
+		r    = &smbRequest{
+			ts: ts,
+			record: &types.SMB{
+				Timestamp: utils.TimeToString(ts),
+				ClientIP:  h.parent.net.Src().String(),
+				ServerIP:  h.parent.net.Dst().String(),
+				Flow:      h.parent.ident,
+				Dialect:   h.dialect,
+				Command:   smbCommandName(hdr.command),
+				MessageID: hdr.messageID,
+				SessionID: hdr.sessionID,
+				TreeID:    hdr.treeID,
+				Share:     h.trees[hdr.treeID],
+			},
+		}
+		rec = r.record
+	)
+
+	if hdr.flags&smbFlagRelated != 0 {
+		r.related = h.lastRequest
+	}
+
+	switch hdr.command {
+	case smb2Negotiate:
+		if len(body) >= 36 {
+			var (
+				count    = int(binary.LittleEndian.Uint16(body[2:4]))
+				dialects []string
+			)
+
+			for i := 0; i < count && 36+i*2+2 <= len(body); i++ {
+				dialects = append(dialects, smbDialectName(binary.LittleEndian.Uint16(body[36+i*2:])))
+			}
+
+			rec.Notes = "dialects: " + strings.Join(dialects, ", ")
+		}
+	case smb2SessionSetup:
+		if len(body) >= 16 {
+			secBuf := smbBuffer(msg, int(binary.LittleEndian.Uint16(body[12:14])), int(binary.LittleEndian.Uint16(body[14:16])))
+			if auth := parseNTLMAuthenticate(secBuf); auth != nil {
+				h.sessions[hdr.sessionID] = &smbSession{
+					user:        auth.User,
+					domain:      auth.Domain,
+					workstation: auth.Workstation,
+				}
+				rec.Notes = "NTLMSSP_AUTH"
+			}
+		}
+	case smb2TreeConnect:
+		if len(body) >= 8 {
+			r.path = decodeUTF16(smbBuffer(msg, int(binary.LittleEndian.Uint16(body[4:6])), int(binary.LittleEndian.Uint16(body[6:8]))))
+			rec.Share = r.path
+		}
+	case smb2Create:
+		if len(body) >= 48 {
+			rec.Filename = decodeUTF16(smbBuffer(msg, int(binary.LittleEndian.Uint16(body[44:46])), int(binary.LittleEndian.Uint16(body[46:48]))))
+		}
+	case smb2Read:
+		if len(body) >= 32 {
+			rec.Length = binary.LittleEndian.Uint32(body[4:8])
+			rec.Offset = binary.LittleEndian.Uint64(body[8:16])
+			r.fileID = hex.EncodeToString(body[16:32])
+		}
+	case smb2Write:
+		if len(body) >= 32 {
+			rec.Length = binary.LittleEndian.Uint32(body[4:8])
+			rec.Offset = binary.LittleEndian.Uint64(body[8:16])
+			r.fileID = hex.EncodeToString(body[16:32])
+
+			data := smbBuffer(msg, int(binary.LittleEndian.Uint16(body[2:4])), int(rec.Length))
+			h.addChunk(h.resolveFileID(r), rec.Offset, data, "SMB WRITE")
+		}
+	case smb2Close, smb2Flush:
+		if len(body) >= 24 {
+			r.fileID = hex.EncodeToString(body[8:24])
+		}
+	case smb2QueryDirectory:
+		if len(body) >= 28 {
+			r.fileID = hex.EncodeToString(body[8:24])
+
+			pattern := decodeUTF16(smbBuffer(msg, int(binary.LittleEndian.Uint16(body[24:26])), int(binary.LittleEndian.Uint16(body[26:28]))))
+			if pattern != "" {
+				rec.Notes = "pattern: " + pattern
+			}
+		}
+	case smb2Ioctl:
+		if len(body) >= 24 {
+			r.fileID = hex.EncodeToString(body[8:24])
+			rec.Notes = fmt.Sprintf("ctl code: 0x%08x", binary.LittleEndian.Uint32(body[4:8]))
+		}
+	case smb2QueryInfo, smb2SetInfo:
+		if hdr.command == smb2QueryInfo && len(body) >= 40 {
+			r.fileID = hex.EncodeToString(body[24:40])
+		} else if hdr.command == smb2SetInfo && len(body) >= 32 {
+			r.fileID = hex.EncodeToString(body[16:32])
+		}
+	}
+
+	if s, ok := h.sessions[hdr.sessionID]; ok {
+		rec.User = s.user
+		rec.Domain = s.domain
+		rec.Workstation = s.workstation
+	}
+
+	h.setFile(r)
+
+	h.lastRequest = r
+	h.pending[hdr.messageID] = r
+}
+
+func (h *smbReader) handleResponse(hdr *smbHeader, msg []byte) {
+	// interim response for an async operation, the final response follows later
+	if hdr.status == smbStatusPending {
+		return
+	}
+
+	r, ok := h.pending[hdr.messageID]
+	if !ok {
+		return
+	}
+
+	delete(h.pending, hdr.messageID)
+
+	var (
+		body    = msg[smbHeaderLen:]
+		rec     = r.record
+		success = hdr.status == smbStatusSuccess
+	)
+
+	rec.Status = smbStatusName(hdr.status)
+
+	if rec.SessionID == 0 {
+		rec.SessionID = hdr.sessionID
+	}
+
+	switch hdr.command {
+	case smb2Negotiate:
+		if success && len(body) >= 6 {
+			h.dialect = smbDialectName(binary.LittleEndian.Uint16(body[4:6]))
+			rec.Dialect = h.dialect
+		}
+	case smb2TreeConnect:
+		if success && len(body) >= 3 {
+			h.trees[hdr.treeID] = r.path
+			rec.TreeID = hdr.treeID
+			rec.Notes = addInfo(rec.Notes, "share type: "+smbShareTypes[body[2]])
+		}
+	case smb2TreeDisconnect:
+		delete(h.trees, rec.TreeID)
+	case smb2Create:
+		if success && len(body) >= 80 {
+			r.fileID = hex.EncodeToString(body[64:80])
+			rec.FileID = r.fileID
+			rec.Notes = addInfo(rec.Notes, "action: "+smbCreateActions[binary.LittleEndian.Uint32(body[4:8])])
+
+			h.files[r.fileID] = &smbFile{
+				name:      rec.Filename,
+				share:     rec.Share,
+				firstSeen: r.ts,
+				chunks:    make(map[uint64][]byte),
+			}
+		}
+	case smb2Read:
+		if success && len(body) >= 8 {
+			data := smbBuffer(msg, int(body[2]), int(binary.LittleEndian.Uint32(body[4:8])))
+			h.addChunk(h.resolveFileID(r), rec.Offset, data, "SMB READ")
+		}
+	case smb2Close:
+		if success {
+			h.closeFile(h.resolveFileID(r))
+		}
+	}
+
+	h.write(rec)
+}
+
+// resolveFileID returns the file id for a request,
+// related operations in a compound request refer to the file opened by a previous operation.
+func (h *smbReader) resolveFileID(r *smbRequest) string {
+	if r.fileID == smbRelatedFileID && r.related != nil {
+		return h.resolveFileID(r.related)
+	}
+
+	return r.fileID
+}
+
+// setFile adds the file information to the audit record of a request.
+func (h *smbReader) setFile(r *smbRequest) {
+	id := h.resolveFileID(r)
+	if id == "" {
+		return
+	}
+
+	r.record.FileID = id
+
+	if f, ok := h.files[id]; ok {
+		r.record.Filename = f.name
+		if r.record.Share == "" {
+			r.record.Share = f.share
+		}
+	}
+}
+
+func (h *smbReader) addChunk(fileID string, offset uint64, data []byte, source string) {
+	f, ok := h.files[fileID]
+	if !ok || len(data) == 0 {
+		return
+	}
+
+	// offsets are chosen by the client and must not exceed the assembled file
+	if offset > smbMaxFileSize || uint64(len(data)) > smbMaxFileSize-offset {
+		utils.DebugLog.Println("SMB: ignoring chunk at offset", offset, "for", fileID)
+
+		return
+	}
+
+	f.chunks[offset] = data
+	f.source = source
+}
+
+// closeFile saves the transferred content of a file and stops tracking its handle.
+func (h *smbReader) closeFile(fileID string) {
+	f, ok := h.files[fileID]
+	if !ok {
+		return
+	}
+
+	delete(h.files, fileID)
+
+	if len(f.chunks) == 0 {
+		return
+	}
+
+	data := f.assemble()
+	if data == nil {
+		logReassemblyError("SMB-save", "%s: file %s exceeds the maximum size\n", h.parent.ident, f.name)
+
+		return
+	}
+
+	// SMB uses backslashes as path separator
+	name := f.name
+	if idx := strings.LastIndex(name, "\\"); idx != -1 {
+		name = name[idx+1:]
+	}
+
+	err := saveExtractedFile(&types.File{
+		Timestamp: utils.TimeToString(f.firstSeen),
+		Name:      name,
+		Ident:     h.parent.ident,
+		Source:    f.source + " " + f.share + "\\" + f.name,
+		Host:      h.parent.net.Dst().String(),
+		Context: &types.PacketContext{
+			SrcIP:   h.parent.net.Src().String(),
+			DstIP:   h.parent.net.Dst().String(),
+			SrcPort: h.parent.transport.Src().String(),
+			DstPort: h.parent.transport.Dst().String(),
+		},
+	}, data)
+	if err != nil {
+		logReassemblyError("SMB-save", "%s: failed to save %s: %s\n", h.parent.ident, f.name, err)
+	}
+}
+
+func (h *smbReader) write(s *types.SMB) {
+	if conf.ExportMetrics {
+		s.Inc()
+	}
+
+	atomic.AddInt64(&smbDecoder.numRecords, 1)
+
+	err := smbDecoder.writer.Write(s)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// recordCollector is an audit record writer that keeps all records in memory.
+type recordCollector struct {
+	records []proto.Message
+}
+
+func (c *recordCollector) Write(msg proto.Message) error {
+	c.records = append(c.records, msg)
+
+	return nil
+}
+
+func (c *recordCollector) WriteHeader(types.Type) error {
+	return nil
+}
+
+func (c *recordCollector) Close() (string, int64) {
+	return "", int64(len(c.records))
+}
+
+// newTestConnection creates a TCP connection from 10.0.0.1 to 10.0.0.2 with the given ports
+// and adds the fragments alternating between client and server, starting with the client.
+func newTestConnection(srcPort, dstPort uint16, fragments ...[]byte) *tcpConnection {
+	var (
+		sp = make([]byte, 2)
+		dp = make([]byte, 2)
+		ts = time.Unix(1600000000, 0)
+		c  = &tcpConnection{
+			net:         gopacket.NewFlow(layers.EndpointIPv4, net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}),
+			firstPacket: ts,
+		}
+	)
+
+	binary.BigEndian.PutUint16(sp, srcPort)
+	binary.BigEndian.PutUint16(dp, dstPort)
+	c.transport = gopacket.NewFlow(layers.EndpointTCPPort, sp, dp)
+	c.ident = c.net.String() + "-" + c.transport.String()
+
+	for i, f := range fragments {
+		dir := reassembly.TCPDirClientToServer
+		if i%2 == 1 {
+			dir = reassembly.TCPDirServerToClient
+		}
+
+		c.merged = append(c.merged, &streamData{
+			raw: f,
+			ac:  &context{CaptureInfo: gopacket.CaptureInfo{Timestamp: ts.Add(time.Duration(i) * time.Millisecond)}},
+			dir: dir,
+		})
+	}
+
+	return c
+}
+
+func utf16LE(s string) []byte {
+	var b bytes.Buffer
+	for _, r := range utf16.Encode([]rune(s)) {
+		_ = binary.Write(&b, binary.LittleEndian, r)
+	}
+
+	return b.Bytes()
+}
+
+// smbTestMessage creates a NetBIOS framed SMB2 message.
+func smbTestMessage(command uint16, response bool, status uint32, messageID uint64, treeID uint32, body []byte) []byte {
+	hdr := make([]byte, smbHeaderLen)
+	copy(hdr, smb2ProtocolID)
+	binary.LittleEndian.PutUint16(hdr[4:], smbHeaderLen)
+	binary.LittleEndian.PutUint32(hdr[8:], status)
+	binary.LittleEndian.PutUint16(hdr[12:], command)
+
+	if response {
+		binary.LittleEndian.PutUint32(hdr[16:], smbFlagResponse)
+	}
+
+	binary.LittleEndian.PutUint64(hdr[24:], messageID)
+	binary.LittleEndian.PutUint32(hdr[36:], treeID)
+	binary.LittleEndian.PutUint64(hdr[40:], 0x1234)
+
+	msg := append(hdr, body...)
+	frame := []byte{nbssSessionMessage, byte(len(msg) >> 16), byte(len(msg) >> 8), byte(len(msg))}
+
+	return append(frame, msg...)
+}
+
+// ntlmTestAuthenticate creates a NTLMSSP AUTHENTICATE message.
+func ntlmTestAuthenticate(domain, user, workstation string, lm, nt []byte) []byte {
+	var (
+		msg    = make([]byte, 64)
+		fields = [][]byte{lm, nt, utf16LE(domain), utf16LE(user), utf16LE(workstation)}
+	)
+
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], ntlmMessageAuthenticate)
+	binary.LittleEndian.PutUint32(msg[60:], ntlmNegotiateUnicode)
+
+	for i, f := range fields {
+		binary.LittleEndian.PutUint16(msg[12+i*8:], uint16(len(f)))
+		binary.LittleEndian.PutUint16(msg[14+i*8:], uint16(len(f)))
+		binary.LittleEndian.PutUint32(msg[16+i*8:], uint32(len(msg)))
+		msg = append(msg, f...)
+	}
+
+	return msg
+}
+
+func TestSMBReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		smbRecords  = &recordCollector{}
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+	}()
+
+	conf = &Config{FileStorage: dir}
+	smbDecoder.writer = smbRecords
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+
+	// session setup with NTLM authentication
+	auth := ntlmTestAuthenticate("CORP", "alice", "WS01", nil, []byte("ntresponse"))
+	sessionSetup := make([]byte, 24)
+	binary.LittleEndian.PutUint16(sessionSetup[12:], smbHeaderLen+24)
+	binary.LittleEndian.PutUint16(sessionSetup[14:], uint16(len(auth)))
+	sessionSetup = append(sessionSetup, auth...)
+
+	// tree connect
+	share := utf16LE(`\\fileserver\data`)
+	treeConnect := make([]byte, 8)
+	binary.LittleEndian.PutUint16(treeConnect[4:], smbHeaderLen+8)
+	binary.LittleEndian.PutUint16(treeConnect[6:], uint16(len(share)))
+	treeConnect = append(treeConnect, share...)
+	treeConnectResp := []byte{16, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	// create
+	name := utf16LE(`reports\secret.txt`)
+	create := make([]byte, 56)
+	binary.LittleEndian.PutUint16(create[44:], smbHeaderLen+56)
+	binary.LittleEndian.PutUint16(create[46:], uint16(len(name)))
+	create = append(create, name...)
+
+	fileID := bytes.Repeat([]byte{0x42}, 16)
+	createResp := make([]byte, 88)
+	binary.LittleEndian.PutUint32(createResp[4:], 1)
+	copy(createResp[64:], fileID)
+
+	// read
+	read := make([]byte, 48)
+	binary.LittleEndian.PutUint32(read[4:], 11)
+	copy(read[16:], fileID)
+
+	content := []byte("hello world")
+	readResp := make([]byte, 16)
+	readResp[2] = smbHeaderLen + 16
+	binary.LittleEndian.PutUint32(readResp[4:], uint32(len(content)))
+	readResp = append(readResp, content...)
+
+	// close
+	closeReq := make([]byte, 24)
+	copy(closeReq[8:], fileID)
+
+	c := newTestConnection(50000, 445,
+		smbTestMessage(smb2SessionSetup, false, 0, 1, 0, sessionSetup),
+		smbTestMessage(smb2SessionSetup, true, 0, 1, 0, make([]byte, 8)),
+		smbTestMessage(smb2TreeConnect, false, 0, 2, 0, treeConnect),
+		smbTestMessage(smb2TreeConnect, true, 0, 2, 5, treeConnectResp),
+		smbTestMessage(smb2Create, false, 0, 3, 5, create),
+		smbTestMessage(smb2Create, true, 0, 3, 5, createResp),
+		smbTestMessage(smb2Read, false, 0, 4, 5, read),
+		smbTestMessage(smb2Read, true, 0, 4, 5, readResp),
+		smbTestMessage(smb2Close, false, 0, 5, 5, closeReq),
+		smbTestMessage(smb2Close, true, 0, 5, 5, make([]byte, 60)),
+	)
+
+	if !isSMB(c.merged[1].raw) {
+		t.Fatal("expected SMB to be detected")
+	}
+
+	r := &smbReader{parent: c}
+	r.Decode()
+
+	if len(smbRecords.records) != 5 {
+		t.Fatal("expected 5 SMB records, got", len(smbRecords.records))
+	}
+
+	read2 := smbRecords.records[3].(*types.SMB)
+	if read2.Command != "READ" || read2.User != "alice" || read2.Domain != "CORP" || read2.Workstation != "WS01" {
+		t.Fatal("unexpected read record:", read2)
+	}
+
+	if read2.Share != `\\fileserver\data` || read2.Filename != `reports\secret.txt` || read2.Status != "STATUS_SUCCESS" {
+		t.Fatal("unexpected read record:", read2)
+	}
+
+	if len(fileRecords.records) != 1 {
+		t.Fatal("expected 1 file record, got", len(fileRecords.records))
+	}
+
+	f := fileRecords.records[0].(*types.File)
+	if f.Name != "secret.txt" {
+		t.Fatal("unexpected file name:", f.Name)
+	}
+
+	data, err := ioutil.ReadFile(f.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Fatal("unexpected file content:", string(data))
+	}
+}
+
+func TestSMBReaderChunkOffsets(t *testing.T) {
+	h := &smbReader{
+		files: map[string]*smbFile{
+			"1": {chunks: make(map[uint64][]byte)},
+		},
+	}
+
+	// the end of the chunk would wrap around
+	h.addChunk("1", math.MaxUint64-15, make([]byte, 32), "")
+	h.addChunk("1", smbMaxFileSize, []byte("a"), "")
+	h.addChunk("1", 4, []byte("data"), "")
+
+	if data := h.files["1"].assemble(); !bytes.Equal(data, []byte("\x00\x00\x00\x00data")) {
+		t.Fatal("unexpected file content:", data)
+	}
+}
@@ -0,0 +1,324 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * SMTP - Simple Mail Transfer Protocol
+ */
+
+const (
+	// pseudo commands used to pair the replies for the end of the mail data and authentication exchanges
+	smtpEndOfData = "."
+	smtpAuthData  = "AUTH-DATA"
+)
+
+// isSMTP checks if the server greeting looks like a SMTP service.
+func isSMTP(banner []byte, transport gopacket.Flow) bool {
+	if !bytes.HasPrefix(banner, []byte("220")) {
+		return false
+	}
+
+	switch transport.Dst().String() {
+	case "25", "587", "2525":
+		return true
+	}
+
+	return bytes.Contains(bytes.ToUpper(banner), []byte(serviceSMTP))
+}
+
+// smtpAddress returns the mailbox from a MAIL FROM or RCPT TO argument, without the angle brackets and parameters.
+func smtpAddress(arg string) string {
+	arg = strings.TrimSpace(arg)
+
+	if idx := strings.Index(arg, ">"); idx != -1 {
+		arg = arg[:idx]
+	} else if idx := strings.Index(arg, " "); idx != -1 {
+		arg = arg[:idx]
+	}
+
+	return strings.TrimPrefix(arg, "<")
+}
+
+type smtpCommand struct {
+	verb string
+	arg  string
+}
+
+type smtpReader struct {
+	parent *tcpConnection
+
+	session *types.SMTPSession
+	current *types.SMTPTransaction
+
+	pending []*smtpCommand
+	replies replyReader
+
+	// mail data sent after the 354 reply to DATA
+	inData  bool
+	data    bytes.Buffer
+	dataTS  time.Time
+	numAuth int
+	inAuth  bool
+
+	encrypted bool
+}
+
+// Decode parses the stream according to the SMTP protocol.
+func (h *smtpReader) Decode() {
+	var (
+		clientBuf bytes.Buffer
+		serverBuf bytes.Buffer
+	)
+
+	h.session = &types.SMTPSession{
+		Timestamp: utils.TimeToString(h.parent.firstPacket),
+		ClientIP:  h.parent.net.Src().String(),
+		ServerIP:  h.parent.net.Dst().String(),
+		Flow:      h.parent.ident,
+	}
+
+	// the merged fragments are ordered by time, so commands are processed before their replies
+	for _, d := range h.parent.merged {
+		if h.encrypted {
+			break
+		}
+
+		if d.dir == reassembly.TCPDirClientToServer {
+			clientBuf.Write(d.raw)
+			h.readCommands(&clientBuf, d.ac.GetCaptureInfo().Timestamp)
+		} else {
+			serverBuf.Write(d.raw)
+			h.replies.read(&serverBuf, h.handleReply)
+		}
+	}
+
+	if h.inData {
+		// the connection was closed before the end of the mail data
+		h.endData()
+		h.session.Notes = addInfo(h.session.Notes, "incomplete mail data")
+	}
+
+	if h.current != nil && (h.current.Mail != nil || len(h.current.RcptTo) > 0) {
+		h.session.Transactions = append(h.session.Transactions, h.current)
+	}
+
+	if h.session.Banner == "" && h.session.Helo == "" && len(h.session.Transactions) == 0 {
+		return
+	}
+
+	if conf.ExportMetrics {
+		h.session.Inc()
+	}
+
+	atomic.AddInt64(&smtpSessionDecoder.numRecords, 1)
+
+	err := smtpSessionDecoder.writer.Write(h.session)
+	if err != nil {
+		errorMap.Inc(err.Error())
+	}
+}
+
+func (h *smtpReader) readCommands(buf *bytes.Buffer, ts time.Time) {
+	for {
+		line, ok := readCRLFLine(buf)
+		if !ok {
+			return
+		}
+
+		if h.inData {
+			if line == "." {
+				h.endData()
+				h.pending = append(h.pending, &smtpCommand{verb: smtpEndOfData})
+
+				continue
+			}
+
+			// remove the dot stuffing
+			if strings.HasPrefix(line, "..") {
+				line = line[1:]
+			}
+
+			if h.data.Len() == 0 {
+				h.dataTS = ts
+			}
+
+			h.data.WriteString(line + "\r\n")
+
+			continue
+		}
+
+		if h.inAuth {
+			h.authenticate(line)
+			h.pending = append(h.pending, &smtpCommand{verb: smtpAuthData})
+
+			continue
+		}
+
+		var (
+			parts = strings.SplitN(line, " ", 2)
+			c     = &smtpCommand{verb: strings.ToUpper(parts[0])}
+		)
+
+		if len(parts) == 2 {
+			c.arg = strings.TrimSpace(parts[1])
+		}
+
+		switch c.verb {
+		case "HELO", "EHLO":
+			h.session.Helo = c.arg
+		case "AUTH":
+			mechanism := strings.SplitN(c.arg, " ", 2)
+			h.session.AuthMechanism = strings.ToUpper(mechanism[0])
+			h.numAuth = 0
+
+			// initial response
+			if len(mechanism) == 2 {
+				h.authenticate(mechanism[1])
+			}
+		case "BDAT":
+			h.session.Notes = addInfo(h.session.Notes, "BDAT chunking not supported")
+		}
+
+		h.pending = append(h.pending, c)
+	}
+}
+
+// authenticate extracts the user name from the base64 encoded SASL responses.
+func (h *smtpReader) authenticate(line string) {
+	h.numAuth++
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return
+	}
+
+	switch h.session.AuthMechanism {
+	case "PLAIN":
+		// authorization identity, user and password separated by null bytes
+		if parts := strings.Split(string(data), "\x00"); len(parts) == 3 {
+			h.session.User = parts[1]
+		}
+	case "LOGIN":
+		// the user is sent first, followed by the password
+		if h.numAuth == 1 {
+			h.session.User = string(data)
+		}
+	case "CRAM-MD5":
+		if fields := strings.Fields(string(data)); len(fields) == 2 {
+			h.session.User = fields[0]
+		}
+	}
+}
+
+func (h *smtpReader) handleReply(code int, lines []string) {
+	if len(h.pending) == 0 {
+		// service greeting
+		if code == 220 && h.session.Banner == "" {
+			h.session.Banner = lines[0]
+		}
+
+		return
+	}
+
+	c := h.pending[0]
+	h.pending = h.pending[1:]
+
+	success := code >= 200 && code < 300
+
+	switch c.verb {
+	case "EHLO":
+		// the first line is the greeting, followed by the supported extensions
+		if success && len(lines) > 1 {
+			h.session.Extensions = append(h.session.Extensions[:0], lines[1:]...)
+		}
+	case "MAIL":
+		if success && strings.HasPrefix(strings.ToUpper(c.arg), "FROM:") {
+			if h.current != nil && h.current.Mail == nil {
+				h.session.Transactions = append(h.session.Transactions, h.current)
+			}
+
+			h.current = &types.SMTPTransaction{
+				MailFrom: smtpAddress(c.arg[5:]),
+			}
+		}
+	case "RCPT":
+		if (code == 250 || code == 251) && h.current != nil && strings.HasPrefix(strings.ToUpper(c.arg), "TO:") {
+			h.current.RcptTo = append(h.current.RcptTo, smtpAddress(c.arg[3:]))
+		}
+	case "DATA":
+		if code == 354 {
+			h.inData = true
+			h.data.Reset()
+		} else {
+			h.finishTransaction(code, lines)
+		}
+	case smtpEndOfData:
+		h.finishTransaction(code, lines)
+	case "RSET":
+		h.current = nil
+	case "AUTH", smtpAuthData:
+		h.inAuth = code == 334
+
+		if code >= 400 {
+			h.session.Notes = addInfo(h.session.Notes, "authentication failed")
+		}
+	case "STARTTLS":
+		if code == 220 {
+			h.session.StartTLS = true
+
+			// the remaining conversation is encrypted
+			h.encrypted = true
+		}
+	}
+}
+
+// endData parses the mail transferred after the DATA command.
+func (h *smtpReader) endData() {
+	h.inData = false
+
+	if h.current == nil {
+		h.current = &types.SMTPTransaction{}
+	}
+
+	h.current.Mail = parseMail(h.parent.ident, h.data.Bytes())
+	saveMailAttachments(h.current.Mail, serviceSMTP, h.parent, h.dataTS)
+
+	h.data.Reset()
+}
+
+// finishTransaction adds the current transaction with the final reply to the session.
+func (h *smtpReader) finishTransaction(code int, lines []string) {
+	if h.current == nil {
+		return
+	}
+
+	h.current.ReplyCode = int32(code)
+	h.current.ReplyMessage = strings.Join(lines, " ")
+	h.session.Transactions = append(h.session.Transactions, h.current)
+	h.current = nil
+}
@@ -0,0 +1,144 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+const smtpTestMail = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: Report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"----=_Part_1234567890abcdefghijklmnop\"\r\n" +
+	"\r\n" +
+	"------=_Part_1234567890abcdefghijklmnop\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Hello Bob\r\n" +
+	"..see attachment\r\n" +
+	"------=_Part_1234567890abcdefghijklmnop\r\n" +
+	"Content-Type: text/plain; name=\"notes.txt\"\r\n" +
+	"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"c2VjcmV0IG5vdGVz\r\n" +
+	"------=_Part_1234567890abcdefghijklmnop--\r\n" +
+	".\r\n"
+
+func TestSMTPReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smtp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		smtpRecords = &recordCollector{}
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+	}()
+
+	conf = &Config{FileStorage: dir}
+	smtpSessionDecoder.writer = smtpRecords
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+
+	c := newTestConnection(50000, 25,
+		[]byte{},
+		[]byte("220 mail.example.com ESMTP Postfix\r\n"),
+		[]byte("EHLO client.example.com\r\n"),
+		[]byte("250-mail.example.com\r\n250-PIPELINING\r\n250-AUTH PLAIN LOGIN\r\n250 8BITMIME\r\n"),
+		[]byte("AUTH LOGIN\r\n"),
+		[]byte("334 VXNlcm5hbWU6\r\n"),
+		[]byte("YWxpY2U=\r\n"),
+		[]byte("334 UGFzc3dvcmQ6\r\n"),
+		[]byte("c2VjcmV0\r\n"),
+		[]byte("235 2.7.0 Authentication successful\r\n"),
+		// pipelined envelope
+		[]byte("MAIL FROM:<alice@example.com> SIZE=1024\r\nRCPT TO:<bob@example.com>\r\nRCPT TO:<nobody@example.com>\r\nDATA\r\n"),
+		[]byte("250 2.1.0 Ok\r\n250 2.1.5 Ok\r\n550 5.1.1 User unknown\r\n354 End data with <CR><LF>.<CR><LF>\r\n"),
+		[]byte(smtpTestMail),
+		[]byte("250 2.0.0 Ok: queued as 12345\r\n"),
+		[]byte("QUIT\r\n"),
+		[]byte("221 2.0.0 Bye\r\n"),
+	)
+
+	(&smtpReader{parent: c}).Decode()
+
+	if len(smtpRecords.records) != 1 {
+		t.Fatal("expected 1 SMTP session, got", len(smtpRecords.records))
+	}
+
+	s := smtpRecords.records[0].(*types.SMTPSession)
+	if s.Banner != "mail.example.com ESMTP Postfix" || s.Helo != "client.example.com" {
+		t.Fatal("unexpected greeting:", s.Banner, s.Helo)
+	}
+
+	if len(s.Extensions) != 3 || s.Extensions[1] != "AUTH PLAIN LOGIN" {
+		t.Fatal("unexpected extensions:", s.Extensions)
+	}
+
+	if s.AuthMechanism != "LOGIN" || s.User != "alice" {
+		t.Fatal("unexpected authentication:", s.AuthMechanism, s.User)
+	}
+
+	if len(s.Transactions) != 1 {
+		t.Fatal("expected 1 transaction, got", len(s.Transactions))
+	}
+
+	tr := s.Transactions[0]
+	if tr.MailFrom != "alice@example.com" || len(tr.RcptTo) != 1 || tr.RcptTo[0] != "bob@example.com" {
+		t.Fatal("unexpected envelope:", tr.MailFrom, tr.RcptTo)
+	}
+
+	if tr.ReplyCode != 250 || tr.Mail == nil {
+		t.Fatal("unexpected transaction result:", tr.ReplyCode, tr.Mail)
+	}
+
+	if tr.Mail.Subject != "Report" || !tr.Mail.HasAttachments || len(tr.Mail.Body) != 2 {
+		t.Fatal("unexpected mail:", tr.Mail)
+	}
+
+	if tr.Mail.Body[0].Content != "Hello Bob\n.see attachment\n" {
+		t.Fatalf("unexpected mail content: %q", tr.Mail.Body[0].Content)
+	}
+
+	if len(fileRecords.records) != 1 {
+		t.Fatal("expected 1 file record, got", len(fileRecords.records))
+	}
+
+	f := fileRecords.records[0].(*types.File)
+	if f.Name != "notes.txt" {
+		t.Fatal("unexpected file name:", f.Name)
+	}
+
+	data, err := ioutil.ReadFile(f.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "secret notes" {
+		t.Fatal("unexpected attachment content:", string(data))
+	}
+}
@@ -0,0 +1,453 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * SNMP - Simple Network Management Protocol
+ */
+
+const (
+	serviceSNMP = "SNMP"
+
+	snmpPort     = 161
+	snmpTrapPort = 162
+
+	snmpVersion1  = 0
+	snmpVersion2c = 1
+	snmpVersion3  = 3
+
+	snmpPDUTrapV1   = 4
+	snmpPDUGetBulk  = 5
+	snmpSecurityUSM = 3
+
+	// msgFlags of SNMPv3
+	snmpFlagAuth = 0x01
+	snmpFlagPriv = 0x02
+)
+
+var (
+	snmpVersions = map[int]string{
+		snmpVersion1:  "v1",
+		snmpVersion2c: "v2c",
+		snmpVersion3:  "v3",
+	}
+
+	snmpPDUTypes = map[int]string{
+		0: "GetRequest",
+		1: "GetNextRequest",
+		2: "Response",
+		3: "SetRequest",
+		4: "Trap",
+		5: "GetBulkRequest",
+		6: "InformRequest",
+		7: "SNMPv2-Trap",
+		8: "Report",
+	}
+
+	snmpErrorStatus = []string{
+		"noError",
+		"tooBig",
+		"noSuchName",
+		"badValue",
+		"readOnly",
+		"genErr",
+		"noAccess",
+		"wrongType",
+		"wrongLength",
+		"wrongEncoding",
+		"wrongValue",
+		"noCreation",
+		"inconsistentValue",
+		"resourceUnavailable",
+		"commitFailed",
+		"undoFailed",
+		"authorizationError",
+		"notWritable",
+		"inconsistentName",
+	}
+
+	snmpGenericTraps = []string{
+		"coldStart",
+		"warmStart",
+		"linkDown",
+		"linkUp",
+		"authenticationFailure",
+		"egpNeighborLoss",
+		"enterpriseSpecific",
+	}
+
+	errSNMPInvalidPDU = errors.New("invalid SNMP PDU")
+)
+
+// snmpMessage is a SNMPv1 or SNMPv2c message.
+type snmpMessage struct {
+	Version   int
+	Community []byte
+	PDU       asn1.RawValue
+}
+
+// snmpV3Message is a SNMPv3 message.
+type snmpV3Message struct {
+	Version            int
+	GlobalData         snmpV3GlobalData
+	SecurityParameters []byte
+	Data               asn1.RawValue
+}
+
+type snmpV3GlobalData struct {
+	MsgID         int
+	MaxSize       int
+	Flags         []byte
+	SecurityModel int
+}
+
+// snmpUSM contains the security parameters of the user-based security model.
+type snmpUSM struct {
+	EngineID    []byte
+	EngineBoots int
+	EngineTime  int
+	UserName    []byte
+	AuthParams  []byte
+	PrivParams  []byte
+}
+
+type snmpScopedPDU struct {
+	ContextEngineID []byte
+	ContextName     []byte
+	PDU             asn1.RawValue
+}
+
+type snmpPDU struct {
+	RequestID   int
+	ErrorStatus int
+	ErrorIndex  int
+	Variables   []snmpVarBind
+}
+
+type snmpTrapPDU struct {
+	Enterprise   asn1.ObjectIdentifier
+	AgentAddr    asn1.RawValue
+	GenericTrap  int
+	SpecificTrap int
+	Timestamp    asn1.RawValue
+	Variables    []snmpVarBind
+}
+
+type snmpVarBind struct {
+	Name  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// snmpUnsigned decodes a big endian unsigned integer.
+func snmpUnsigned(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+// snmpValue returns the type name and string representation of a variable binding value.
+func snmpValue(v asn1.RawValue) (typ string, value string) {
+	switch v.Class {
+	case asn1.ClassUniversal:
+		switch v.Tag {
+		case asn1.TagInteger:
+			return "Integer", strconv.FormatInt(berInteger(v.Bytes), 10)
+		case asn1.TagOctetString:
+			if utf8.Valid(v.Bytes) && utils.IsASCII(v.Bytes) {
+				return "OctetString", string(v.Bytes)
+			}
+
+			return "OctetString", hex.EncodeToString(v.Bytes)
+		case asn1.TagNull:
+			return "Null", ""
+		case asn1.TagOID:
+			var oid asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(v.FullBytes, &oid); err == nil {
+				return "ObjectIdentifier", oid.String()
+			}
+		}
+	case asn1.ClassApplication:
+		switch v.Tag {
+		case 0:
+			if len(v.Bytes) == net.IPv4len {
+				return "IpAddress", net.IP(v.Bytes).String()
+			}
+		case 1:
+			return "Counter32", strconv.FormatUint(snmpUnsigned(v.Bytes), 10)
+		case 2:
+			return "Gauge32", strconv.FormatUint(snmpUnsigned(v.Bytes), 10)
+		case 3:
+			return "TimeTicks", strconv.FormatUint(snmpUnsigned(v.Bytes), 10)
+		case 4:
+			return "Opaque", hex.EncodeToString(v.Bytes)
+		case 6:
+			return "Counter64", strconv.FormatUint(snmpUnsigned(v.Bytes), 10)
+		case 7:
+			return "UInteger32", strconv.FormatUint(snmpUnsigned(v.Bytes), 10)
+		}
+	case asn1.ClassContextSpecific:
+		switch v.Tag {
+		case 0:
+			return "noSuchObject", ""
+		case 1:
+			return "noSuchInstance", ""
+		case 2:
+			return "endOfMibView", ""
+		}
+	}
+
+	return fmt.Sprintf("class %d tag %d", v.Class, v.Tag), hex.EncodeToString(v.Bytes)
+}
+
+func snmpVariables(binds []snmpVarBind) []*types.SNMPVariable {
+	vars := make([]*types.SNMPVariable, 0, len(binds))
+
+	for _, b := range binds {
+		typ, val := snmpValue(b.Value)
+		vars = append(vars, &types.SNMPVariable{
+			OID:   b.Name.String(),
+			Type:  typ,
+			Value: val,
+		})
+	}
+
+	return vars
+}
+
+// parseSNMPPDU decodes the implicitly tagged PDU into the audit record.
+func parseSNMPPDU(raw asn1.RawValue, r *types.SNMP) error {
+	if raw.Class != asn1.ClassContextSpecific || !raw.IsCompound {
+		return errSNMPInvalidPDU
+	}
+
+	name, ok := snmpPDUTypes[raw.Tag]
+	if !ok {
+		return errSNMPInvalidPDU
+	}
+
+	r.PDUType = name
+	params := "tag:" + strconv.Itoa(raw.Tag)
+
+	if raw.Tag == snmpPDUTrapV1 {
+		var trap snmpTrapPDU
+		if _, err := asn1.UnmarshalWithParams(raw.FullBytes, &trap, params); err != nil {
+			return err
+		}
+
+		r.Variables = snmpVariables(trap.Variables)
+		r.Notes = "enterprise: " + trap.Enterprise.String()
+
+		if len(trap.AgentAddr.Bytes) == net.IPv4len {
+			r.Notes += ", agent: " + net.IP(trap.AgentAddr.Bytes).String()
+		}
+
+		if trap.GenericTrap >= 0 && trap.GenericTrap < len(snmpGenericTraps) {
+			r.Notes += ", trap: " + snmpGenericTraps[trap.GenericTrap]
+		}
+
+		r.Notes += ", specific trap: " + strconv.Itoa(trap.SpecificTrap)
+
+		return nil
+	}
+
+	var pdu snmpPDU
+	if _, err := asn1.UnmarshalWithParams(raw.FullBytes, &pdu, params); err != nil {
+		return err
+	}
+
+	r.RequestID = int32(pdu.RequestID)
+	r.Variables = snmpVariables(pdu.Variables)
+
+	if raw.Tag == snmpPDUGetBulk {
+		// error status and index are used for the bulk parameters
+		r.Notes = "non-repeaters: " + strconv.Itoa(pdu.ErrorStatus) + ", max-repetitions: " + strconv.Itoa(pdu.ErrorIndex)
+
+		return nil
+	}
+
+	if pdu.ErrorStatus >= 0 && pdu.ErrorStatus < len(snmpErrorStatus) {
+		r.ErrorStatus = snmpErrorStatus[pdu.ErrorStatus]
+	} else {
+		r.ErrorStatus = strconv.Itoa(pdu.ErrorStatus)
+	}
+
+	r.ErrorIndex = int32(pdu.ErrorIndex)
+
+	return nil
+}
+
+// parseSNMPv3 decodes the security parameters and the scoped PDU of a SNMPv3 message.
+func parseSNMPv3(msg *snmpV3Message, r *types.SNMP) error {
+	var flags byte
+	if len(msg.GlobalData.Flags) > 0 {
+		flags = msg.GlobalData.Flags[0]
+	}
+
+	switch {
+	case flags&snmpFlagPriv != 0:
+		r.SecurityLevel = "authPriv"
+	case flags&snmpFlagAuth != 0:
+		r.SecurityLevel = "authNoPriv"
+	default:
+		r.SecurityLevel = "noAuthNoPriv"
+	}
+
+	if msg.GlobalData.SecurityModel == snmpSecurityUSM {
+		var usm snmpUSM
+		if _, err := asn1.Unmarshal(msg.SecurityParameters, &usm); err == nil {
+			r.User = string(usm.UserName)
+			r.EngineID = hex.EncodeToString(usm.EngineID)
+		}
+	}
+
+	// encrypted PDUs are transported as an octet string
+	if msg.Data.Class == asn1.ClassUniversal && msg.Data.Tag == asn1.TagOctetString {
+		r.Notes = "encrypted PDU"
+
+		return nil
+	}
+
+	var scoped snmpScopedPDU
+	if _, err := asn1.Unmarshal(msg.Data.FullBytes, &scoped); err != nil {
+		return err
+	}
+
+	r.ContextName = string(scoped.ContextName)
+
+	return parseSNMPPDU(scoped.PDU, r)
+}
+
+// parseSNMP decodes a SNMP message of any version.
+func parseSNMP(data []byte) (*types.SNMP, error) {
+	var (
+		r   = &types.SNMP{}
+		msg snmpMessage
+	)
+
+	// SNMPv3 messages fail here, since the second element is a sequence instead of the community string
+	if _, err := asn1.Unmarshal(data, &msg); err != nil {
+		var v3 snmpV3Message
+		if _, errV3 := asn1.Unmarshal(data, &v3); errV3 != nil || v3.Version != snmpVersion3 {
+			return nil, err
+		}
+
+		r.Version = snmpVersions[snmpVersion3]
+
+		return r, parseSNMPv3(&v3, r)
+	}
+
+	version, ok := snmpVersions[msg.Version]
+	if !ok || msg.Version == snmpVersion3 {
+		return nil, errSNMPInvalidPDU
+	}
+
+	r.Version = version
+	r.Community = string(msg.Community)
+
+	return r, parseSNMPPDU(msg.PDU, r)
+}
+
+// snmpCredentials returns the community string of the message as credentials.
+func snmpCredentials(r *types.SNMP, ident string, ts time.Time) *types.Credentials {
+	if r.Community == "" {
+		return nil
+	}
+
+	return &types.Credentials{
+		Timestamp: utils.TimeToString(ts),
+		Service:   serviceSNMP,
+		Flow:      ident,
+		Password:  r.Community,
+		Notes:     r.Version + " " + r.PDUType,
+	}
+}
+
+var snmpDecoder = newCustomDecoder(
+	types.Type_NC_SNMP,
+	serviceSNMP,
+	"The Simple Network Management Protocol is used to monitor and configure network devices",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		l := innerLayers(p)
+		if l.network == nil {
+			return nil
+		}
+
+		udp, ok := l.transport.(*layers.UDP)
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case udp.SrcPort == snmpPort, udp.DstPort == snmpPort, udp.SrcPort == snmpTrapPort, udp.DstPort == snmpTrapPort:
+		default:
+			return nil
+		}
+
+		r, err := parseSNMP(udp.Payload)
+		if r == nil {
+			return nil
+		}
+
+		if err != nil {
+			r.Notes = addInfo(r.Notes, err.Error())
+		}
+
+		var (
+			nf    = l.network.NetworkFlow()
+			ts    = p.Metadata().Timestamp
+			ident = nf.String() + "-" + udp.TransportFlow().String()
+		)
+
+		r.Timestamp = utils.TimeToString(ts)
+		r.SrcIP = nf.Src().String()
+		r.DstIP = nf.Dst().String()
+		r.SrcPort = int32(udp.SrcPort)
+		r.DstPort = int32(udp.DstPort)
+
+		if useHarvesters {
+			if creds := snmpCredentials(r, ident, ts); creds != nil {
+				writeCredentials(creds)
+			}
+		}
+
+		return r
+	},
+	func(e *customDecoder) error {
+		return nil
+	},
+)
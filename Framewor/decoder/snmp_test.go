@@ -0,0 +1,116 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/asn1"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func snmpTestMarshal(t *testing.T, v interface{}, params string) []byte {
+	b, err := asn1.MarshalWithParams(v, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func snmpTestPDU(t *testing.T, tag int, binds ...snmpVarBind) asn1.RawValue {
+	return asn1.RawValue{FullBytes: snmpTestMarshal(t, snmpPDU{RequestID: 42, ErrorStatus: 2, ErrorIndex: 1, Variables: binds}, "tag:"+strconv.Itoa(tag))}
+}
+
+func TestSNMP(t *testing.T) {
+	sysDescr := snmpVarBind{
+		Name:  asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 1, 0},
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagOctetString, Bytes: []byte("Linux router")},
+	}
+	ifInOctets := snmpVarBind{
+		Name:  asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1},
+		Value: asn1.RawValue{Class: asn1.ClassApplication, Tag: 1, Bytes: []byte{0x00, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	// SNMPv2c response
+	data := snmpTestMarshal(t, snmpMessage{
+		Version:   snmpVersion2c,
+		Community: []byte("private"),
+		PDU:       snmpTestPDU(t, 2, sysDescr, ifInOctets),
+	}, "")
+
+	r, err := parseSNMP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Version != "v2c" || r.Community != "private" || r.PDUType != "Response" || r.RequestID != 42 || r.ErrorStatus != "noSuchName" || r.ErrorIndex != 1 {
+		t.Fatal("unexpected SNMP record:", r)
+	}
+
+	if len(r.Variables) != 2 || r.Variables[0].OID != "1.3.6.1.2.1.1.1.0" || r.Variables[0].Value != "Linux router" {
+		t.Fatal("unexpected variables:", r.Variables)
+	}
+
+	if r.Variables[1].Type != "Counter32" || r.Variables[1].Value != "4294967295" {
+		t.Fatal("unexpected counter:", r.Variables[1])
+	}
+
+	creds := snmpCredentials(r, "flow", time.Unix(1600000000, 0))
+	if creds == nil || creds.Service != serviceSNMP || creds.Password != "private" || creds.Notes != "v2c Response" {
+		t.Fatal("unexpected credentials:", creds)
+	}
+
+	// SNMPv3 without encryption
+	usm := snmpTestMarshal(t, snmpUSM{
+		EngineID:   []byte{0x80, 0x00, 0x1f, 0x88, 0x04},
+		UserName:   []byte("monitor"),
+		AuthParams: make([]byte, 12),
+		PrivParams: []byte{},
+	}, "")
+
+	scoped := snmpTestMarshal(t, snmpScopedPDU{
+		ContextEngineID: []byte{0x80, 0x00, 0x1f, 0x88, 0x04},
+		ContextName:     []byte("ctx"),
+		PDU:             snmpTestPDU(t, 0, snmpVarBind{Name: sysDescr.Name, Value: asn1.RawValue{Tag: asn1.TagNull}}),
+	}, "")
+
+	data = snmpTestMarshal(t, snmpV3Message{
+		Version:            snmpVersion3,
+		GlobalData:         snmpV3GlobalData{MsgID: 1, MaxSize: 65507, Flags: []byte{snmpFlagAuth}, SecurityModel: snmpSecurityUSM},
+		SecurityParameters: usm,
+		Data:               asn1.RawValue{FullBytes: scoped},
+	}, "")
+
+	r, err = parseSNMP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Version != "v3" || r.User != "monitor" || r.EngineID != "80001f8804" || r.SecurityLevel != "authNoPriv" || r.ContextName != "ctx" || r.PDUType != "GetRequest" {
+		t.Fatal("unexpected SNMPv3 record:", r)
+	}
+
+	if len(r.Variables) != 1 || r.Variables[0].Type != "Null" {
+		t.Fatal("unexpected SNMPv3 variables:", r.Variables)
+	}
+
+	if snmpCredentials(r, "flow", time.Unix(1600000000, 0)) != nil {
+		t.Fatal("expected no credentials for SNMPv3")
+	}
+
+	if _, err = parseSNMP([]byte("not snmp")); err == nil {
+		t.Fatal("expected error for invalid data")
+	}
+}
@@ -47,6 +47,8 @@ const (
 	servicePOP3   = "POP3"
 	serviceTelnet = "Telnet"
 	serviceFTP    = "FTP"
+	serviceSMTP   = "SMTP"
+	serviceIMAP   = "IMAP"
 )
 
 type software struct {
@@ -160,6 +160,27 @@ type tcpConnection struct {
 
 	isHTTPS bool
 	fsmerr  bool
+
+	// ftpChecked is set once the connection has been checked for FTP control traffic
+	ftpChecked bool
+	ftpControl bool
+}
+
+// isFTPControl checks whether the connection is a FTP control connection,
+// either by the server port or by the banner of the server.
+// The decision is made once, on the first data from the server.
+func (t *tcpConnection) isFTPControl(dir reassembly.TCPFlowDirection, data []byte) bool {
+	if !t.ftpChecked {
+		switch {
+		case t.transport.Dst().String() == "21":
+			t.ftpChecked, t.ftpControl = true, true
+		case dir == reassembly.TCPDirServerToClient:
+			t.ftpChecked = true
+			t.ftpControl = isFTP(data, t.transport)
+		}
+	}
+
+	return t.ftpControl
 }
 
 // Accept decides whether the TCP packet should be accepted
@@ -350,6 +371,16 @@ func (t *tcpConnection) ReassembledSG(sg reassembly.ScatterGather, ac reassembly
 	// fmt.Println("got raw data:", len(data), ac.GetCaptureInfo().Timestamp, "\n", hex.Dump(data))
 
 	if length > 0 {
+		// look for announced data channels on FTP control connections
+		if streamFactory.decodeFTP && t.isFTPControl(dir, data) {
+			sender, receiver := t.net.Src(), t.net.Dst()
+			if dir == reassembly.TCPDirServerToClient {
+				sender, receiver = receiver, sender
+			}
+
+			ftpData.watch(sender.String(), receiver.String(), data)
+		}
+
 		if conf.HexDump {
 			logReassemblyDebug("Feeding stream reader with:\n%s", hex.Dump(data))
 		}
@@ -441,6 +472,12 @@ func (t *tcpConnection) ReassemblyComplete(ac reassembly.AssemblerContext, first
 		// TODO: move this functionality into a dedicated package and create a voting model
 		if _, ok := t.decoder.(*tcpReader); ok {
 			switch {
+			// FTP data connections are identified by the endpoints announced on the control connection
+			// and must be checked first, since the transferred content could match any other service
+			case streamFactory.decodeFTP && ftpData.claimEndpoint(ftpDataEndpoint(t)):
+				t.decoder = &ftpDataReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
 			case bytes.Contains(t.server.ServiceBanner(), []byte(serviceHTTP)):
 				t.decoder = &httpReader{
 					parent: t.client.(*tcpStreamReader).parent,
@@ -453,6 +490,63 @@ func (t *tcpConnection) ReassemblyComplete(ac reassembly.AssemblerContext, first
 				t.decoder = &pop3Reader{
 					parent: t.client.(*tcpStreamReader).parent,
 				}
+			case streamFactory.decodeSMB && isSMB(t.server.ServiceBanner()):
+				t.decoder = &smbReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeFTP && isFTP(t.server.ServiceBanner(), t.transport):
+				t.decoder = &ftpReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeSMTP && isSMTP(t.server.ServiceBanner(), t.transport):
+				t.decoder = &smtpReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeIMAP && isIMAP(t.server.ServiceBanner(), t.transport):
+				t.decoder = &imapReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeKerberos && isKerberos(t.server.ServiceBanner()):
+				t.decoder = &kerberosReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			// S7 uses the same TPKT and COTP connection setup as RDP and must be checked first
+			case streamFactory.decodeS7 && isS7(t.server.ServiceBanner(), t.transport):
+				t.decoder = &s7Reader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeRDP && isRDP(t.server.ServiceBanner(), t.transport):
+				t.decoder = &rdpReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeLDAP && isLDAP(t.server.ServiceBanner(), t.transport):
+				t.decoder = &ldapReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodePostgres && isPostgreSQL(t.server.ServiceBanner(), t.transport):
+				t.decoder = &postgreSQLReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeMySQL && isMySQL(t.server.ServiceBanner(), t.transport):
+				t.decoder = &mySQLReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeRedis && isRedis(t.server.ServiceBanner(), t.transport):
+				t.decoder = &redisReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeMemcache && isMemcached(t.server.ServiceBanner(), t.transport):
+				t.decoder = &memcachedReader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeDNP3 && isDNP3(t.server.ServiceBanner(), t.transport):
+				t.decoder = &dnp3Reader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
+			case streamFactory.decodeIEC104 && isIEC104(t.server.ServiceBanner(), t.transport):
+				t.decoder = &iec104Reader{
+					parent: t.client.(*tcpStreamReader).parent,
+				}
 			}
 		}
 
@@ -471,9 +565,8 @@ func ReassemblePacket(packet gopacket.Packet, assembler *reassembly.Assembler) {
 	// prevent passing any non TCP packets in here
 	tcpLayer := packet.Layer(layers.LayerTypeTCP)
 	if tcpLayer == nil {
-		udpLayer := packet.Layer(layers.LayerTypeUDP)
-		if udpLayer != nil {
-			udpStreams.handleUDP(packet, udpLayer)
+		if l := innerLayers(packet); l.network != nil && l.transport != nil && l.transport.LayerType() == layers.LayerTypeUDP {
+			udpStreams.handleUDP(packet, l)
 		}
 
 		return
@@ -521,10 +614,17 @@ func ReassemblePacket(packet gopacket.Packet, assembler *reassembly.Assembler) {
 		}
 	}
 
-	tcp := tcpLayer.(*layers.TCP)
+	var (
+		tcp = tcpLayer.(*layers.TCP)
+
+		// key the stream on the network layer enclosing the TCP segment,
+		// to keep tunneled connections apart from the outer endpoints
+		l  = innerLayers(packet)
+		nl = l.network
+	)
 
 	if conf.Checksum {
-		err := tcp.SetNetworkLayerForChecksum(packet.NetworkLayer())
+		err := tcp.SetNetworkLayerForChecksum(nl)
 		if err != nil {
 			log.Fatalf("Failed to set network layer for checksum: %s\n", err)
 		}
@@ -536,8 +636,9 @@ func ReassemblePacket(packet gopacket.Packet, assembler *reassembly.Assembler) {
 
 	// for debugging:
 	// assembleWithContextTimeout(packet, assembler, tcp)
-	assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, &context{
+	assembler.AssembleWithContext(nl.NetworkFlow(), tcp, &context{
 		CaptureInfo: packet.Metadata().CaptureInfo,
+		Tunnel:      l.tunnel.streamID(),
 	})
 
 	// TODO: refactor and use a ticker model in a goroutine, similar to progress reporting
@@ -560,11 +661,16 @@ func ReassemblePacket(packet gopacket.Packet, assembler *reassembly.Assembler) {
 // used for debugging.
 //goland:noinspection GoUnusedFunction
 func assembleWithContextTimeout(packet gopacket.Packet, assembler *reassembly.Assembler, tcp *layers.TCP) {
-	done := make(chan bool, 1)
+	var (
+		done = make(chan bool, 1)
+		l    = innerLayers(packet)
+		nl   = l.network
+	)
 
 	go func() {
-		assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, &context{
+		assembler.AssembleWithContext(nl.NetworkFlow(), tcp, &context{
 			CaptureInfo: packet.Metadata().CaptureInfo,
+			Tunnel:      l.tunnel.streamID(),
 		})
 		done <- true
 	}()
@@ -573,7 +679,7 @@ func assembleWithContextTimeout(packet gopacket.Packet, assembler *reassembly.As
 	case <-done:
 	case <-time.After(5 * time.Second):
 		spew.Dump(packet.Metadata().CaptureInfo)
-		fmt.Println("HTTP AssembleWithContext timeout", packet.NetworkLayer().NetworkFlow(), packet.TransportLayer().TransportFlow())
+		fmt.Println("HTTP AssembleWithContext timeout", nl.NetworkFlow(), tcp.TransportFlow())
 		fmt.Println(assembler.Dump())
 	}
 }
@@ -54,10 +54,24 @@ type tcpConnectionFactory struct {
 	StreamPool    *reassembly.StreamPool
 	wg            sync.WaitGroup
 	sync.Mutex
-	decodeHTTP bool
-	decodePOP3 bool
-	decodeSSH  bool
-	fsmOptions reassembly.TCPSimpleFSMOptions
+	decodeHTTP     bool
+	decodePOP3     bool
+	decodeSSH      bool
+	decodeSMB      bool
+	decodeFTP      bool
+	decodeSMTP     bool
+	decodeIMAP     bool
+	decodeKerberos bool
+	decodeRDP      bool
+	decodeLDAP     bool
+	decodePostgres bool
+	decodeMySQL    bool
+	decodeRedis    bool
+	decodeMemcache bool
+	decodeDNP3     bool
+	decodeS7       bool
+	decodeIEC104   bool
+	fsmOptions     reassembly.TCPSimpleFSMOptions
 }
 
 // New handles a new stream received from the assembler
@@ -116,9 +130,17 @@ func (factory *tcpConnectionFactory) waitGoRoutines() {
 // context is the assembler context.
 type context struct {
 	CaptureInfo gopacket.CaptureInfo
+
+	// identifies the tunnel of encapsulated packets, zero otherwise
+	Tunnel uint64
 }
 
 // GetCaptureInfo returns the gopacket.CaptureInfo from the context.
 func (c *context) GetCaptureInfo() gopacket.CaptureInfo {
 	return c.CaptureInfo
 }
+
+// TunnelID returns the tunnel identifier, which is part of the connection key of the assembler.
+func (c *context) TunnelID() uint64 {
+	return c.Tunnel
+}
@@ -37,8 +37,12 @@ type tcpReader struct {
 	parent *tcpConnection
 }
 
-// Decode is a dummy to implement the streamReader interface
+// Decode searches unidentified streams for NTLM authentication exchanges
 func (h *tcpReader) Decode() {
+	if useHarvesters {
+		harvestNTLM(h.parent)
+	}
+
 	// fmt.Println("Decode", c2s, s2c)
 	// for _, f := range h.parent.merged {
 	// 	fmt.Println(f.dir, f.ac.GetCaptureInfo().Timestamp, len(f.raw))
@@ -0,0 +1,134 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+// tunnel describes the innermost encapsulation of a packet.
+type tunnel struct {
+	proto string
+
+	// outer endpoints, empty for MPLS
+	srcIP string
+	dstIP string
+
+	// VNI, GRE key, MPLS label or GTP-U TEID
+	id uint32
+}
+
+// streamID returns an identifier for the virtual network of the tunnel,
+// which keeps streams with identical inner flows in different overlays apart.
+// MPLS labels and GTP-U TEIDs are assigned per direction and would split both sides of a stream,
+// so these tunnels are not distinguished.
+func (t *tunnel) streamID() uint64 {
+	if t == nil {
+		return 0
+	}
+
+	switch t.proto {
+	case "VXLAN":
+		return 1<<32 | uint64(t.id)
+	case "GENEVE":
+		return 2<<32 | uint64(t.id)
+	case "GRE":
+		return 3<<32 | uint64(t.id)
+	}
+
+	return 0
+}
+
+// packetLayers contains the link, network and transport layers of the innermost packet.
+// For packets that are not tunneled, these are the layers returned by gopacket.Packet.
+type packetLayers struct {
+	link        gopacket.LinkLayer
+	network     gopacket.NetworkLayer
+	transport   gopacket.TransportLayer
+	application gopacket.ApplicationLayer
+	tunnel      *tunnel
+}
+
+// innerLayers returns the layers of the innermost packet of VXLAN, GENEVE, GRE, MPLS and GTP-U tunnels,
+// so that overlay traffic is tracked by the inner addresses instead of the tunnel endpoints.
+func innerLayers(p gopacket.Packet) *packetLayers {
+	l := &packetLayers{}
+
+	for _, layer := range p.Layers() {
+		var t *tunnel
+
+		switch v := layer.(type) {
+		case *layers.VXLAN:
+			t = &tunnel{proto: "VXLAN", id: v.VNI}
+		case *layers.Geneve:
+			t = &tunnel{proto: "GENEVE", id: v.VNI}
+		case *layers.GRE:
+			t = &tunnel{proto: "GRE", id: v.Key}
+		case *layers.GTPv1U:
+			// only G-PDUs carry subscriber traffic, signalling messages are kept as they are
+			if v.MessageType != gtpMessageGPDU {
+				continue
+			}
+
+			t = &tunnel{proto: "GTP-U", id: v.TEID}
+		case *layers.MPLS:
+			// only the label on top of the stack identifies the path
+			if l.tunnel != nil && l.tunnel.proto == "MPLS" && l.network == nil {
+				continue
+			}
+
+			t = &tunnel{proto: "MPLS", id: v.Label}
+		case gopacket.LinkLayer:
+			l.link = v
+		case gopacket.NetworkLayer:
+			l.network = v
+		case gopacket.TransportLayer:
+			l.transport = v
+		case gopacket.ApplicationLayer:
+			l.application = v
+		}
+
+		if t == nil {
+			continue
+		}
+
+		// the endpoints of the tunnel are the addresses of the enclosing packet
+		if l.network != nil {
+			t.srcIP = l.network.NetworkFlow().Src().String()
+			t.dstIP = l.network.NetworkFlow().Dst().String()
+		}
+
+		l.tunnel = t
+
+		// the inner packet of layer 3 tunnels has no link layer
+		l.link, l.network, l.transport, l.application = nil, nil, nil, nil
+	}
+
+	if l.tunnel == nil {
+		l.link = p.LinkLayer()
+		l.network = p.NetworkLayer()
+		l.transport = p.TransportLayer()
+		l.application = p.ApplicationLayer()
+
+		return l
+	}
+
+	// keep the outer link layer for layer 3 tunnels
+	if l.link == nil {
+		l.link = p.LinkLayer()
+	}
+
+	return l
+}
@@ -0,0 +1,210 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"net"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+
+	"github.com/dreadl0ck/netcap/reassembly"
+)
+
+func tunnelTestPacket(t *testing.T, first gopacket.LayerType, l ...gopacket.SerializableLayer) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, l...); err != nil {
+		t.Fatal(err)
+	}
+
+	return gopacket.NewPacket(buf.Bytes(), first, gopacket.Default)
+}
+
+func tunnelTestInner() (*layers.IPv4, *layers.TCP) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.IP{172, 16, 0, 1},
+		DstIP:    net.IP{172, 16, 0, 2},
+	}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 80, SYN: true}
+	_ = tcp.SetNetworkLayerForChecksum(ip)
+
+	return ip, tcp
+}
+
+func TestInnerLayersVXLAN(t *testing.T) {
+	innerIP, innerTCP := tunnelTestInner()
+
+	p := tunnelTestPacket(t, layers.LayerTypeEthernet,
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}},
+		&layers.UDP{SrcPort: 50000, DstPort: 4789},
+		&layers.VXLAN{ValidIDFlag: true, VNI: 42},
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 1, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 1, 2}, EthernetType: layers.EthernetTypeIPv4},
+		innerIP,
+		innerTCP,
+	)
+
+	l := innerLayers(p)
+	if l.tunnel == nil || l.tunnel.proto != "VXLAN" || l.tunnel.id != 42 || l.tunnel.srcIP != "10.0.0.1" || l.tunnel.dstIP != "10.0.0.2" {
+		t.Fatal("unexpected tunnel:", l.tunnel)
+	}
+
+	if l.link == nil || l.link.LinkFlow().Src().String() != "00:00:00:00:01:01" {
+		t.Fatal("expected inner link layer, got:", l.link)
+	}
+
+	if l.network == nil || l.network.NetworkFlow().String() != "172.16.0.1->172.16.0.2" {
+		t.Fatal("expected inner network layer, got:", l.network)
+	}
+
+	if l.transport == nil || l.transport.TransportFlow().String() != "40000->80" {
+		t.Fatal("expected inner transport layer, got:", l.transport)
+	}
+}
+
+func TestInnerLayersGRE(t *testing.T) {
+	innerIP, innerTCP := tunnelTestInner()
+
+	p := tunnelTestPacket(t, layers.LayerTypeIPv4,
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolGRE, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}},
+		&layers.GRE{KeyPresent: true, Key: 7, Protocol: layers.EthernetTypeIPv4},
+		innerIP,
+		innerTCP,
+	)
+
+	l := innerLayers(p)
+	if l.tunnel == nil || l.tunnel.proto != "GRE" || l.tunnel.id != 7 || l.tunnel.srcIP != "10.0.0.1" {
+		t.Fatal("unexpected tunnel:", l.tunnel)
+	}
+
+	if l.network == nil || l.network.NetworkFlow().String() != "172.16.0.1->172.16.0.2" {
+		t.Fatal("expected inner network layer, got:", l.network)
+	}
+}
+
+func TestInnerLayersGTPU(t *testing.T) {
+	innerIP, innerTCP := tunnelTestInner()
+
+	p := tunnelTestPacket(t, layers.LayerTypeIPv4,
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}},
+		&layers.UDP{SrcPort: 2152, DstPort: 2152},
+		&layers.GTPv1U{Version: 1, ProtocolType: 1, MessageType: gtpMessageGPDU, TEID: 0xabc},
+		innerIP,
+		innerTCP,
+	)
+
+	l := innerLayers(p)
+	if l.tunnel == nil || l.tunnel.proto != "GTP-U" || l.tunnel.id != 0xabc || l.tunnel.dstIP != "10.0.0.2" {
+		t.Fatal("unexpected tunnel:", l.tunnel)
+	}
+
+	if l.transport == nil || l.transport.TransportFlow().String() != "40000->80" {
+		t.Fatal("expected inner transport layer, got:", l.transport)
+	}
+}
+
+func TestInnerLayersPlain(t *testing.T) {
+	ip, tcp := tunnelTestInner()
+
+	l := innerLayers(tunnelTestPacket(t, layers.LayerTypeIPv4, ip, tcp))
+	if l.tunnel != nil || l.network == nil || l.transport == nil {
+		t.Fatal("unexpected layers for plain packet:", l)
+	}
+}
+
+// tunnelTestFactory counts the streams created by the assembler.
+type tunnelTestFactory struct {
+	streams int
+}
+
+func (f *tunnelTestFactory) New(_, _ gopacket.Flow, _ reassembly.AssemblerContext) reassembly.Stream {
+	f.streams++
+
+	return &tunnelTestStream{}
+}
+
+type tunnelTestStream struct{}
+
+func (s *tunnelTestStream) Accept(*layers.TCP, reassembly.TCPFlowDirection, reassembly.Sequence) bool {
+	return true
+}
+
+func (s *tunnelTestStream) ReassembledSG(reassembly.ScatterGather, reassembly.AssemblerContext) {}
+
+func (s *tunnelTestStream) ReassemblyComplete(reassembly.AssemblerContext, gopacket.Flow) bool {
+	return true
+}
+
+func tunnelTestVXLAN(t *testing.T, vni uint32, inner ...gopacket.SerializableLayer) gopacket.Packet {
+	return tunnelTestPacket(t, layers.LayerTypeEthernet, append([]gopacket.SerializableLayer{
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}},
+		&layers.UDP{SrcPort: 50000, DstPort: 4789},
+		&layers.VXLAN{ValidIDFlag: true, VNI: vni},
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 1, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 1, 2}, EthernetType: layers.EthernetTypeIPv4},
+	}, inner...)...)
+}
+
+func TestTunnelStreamsSeparated(t *testing.T) {
+	oldConf, oldUDP := conf, udpStreams
+	defer func() {
+		conf, udpStreams = oldConf, oldUDP
+	}()
+
+	conf = &Config{}
+	udpStreams = newUDPStreamPool()
+
+	var (
+		factory   = &tunnelTestFactory{}
+		assembler = reassembly.NewAssembler(reassembly.NewStreamPool(factory))
+	)
+
+	// two overlays carrying the same inner TCP and UDP flows
+	for _, vni := range []uint32{1, 2} {
+		innerIP, innerTCP := tunnelTestInner()
+		ReassemblePacket(tunnelTestVXLAN(t, vni, innerIP, innerTCP), assembler)
+
+		udpIP := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{172, 16, 0, 1}, DstIP: net.IP{172, 16, 0, 2}}
+		ReassemblePacket(tunnelTestVXLAN(t, vni, udpIP, &layers.UDP{SrcPort: 40000, DstPort: 53}, gopacket.Payload("query")), assembler)
+	}
+
+	if factory.streams != 2 {
+		t.Fatal("expected a TCP stream per VNI, got:", factory.streams)
+	}
+
+	if len(udpStreams.streams) != 2 {
+		t.Fatal("expected an UDP stream per VNI, got:", len(udpStreams.streams))
+	}
+}
+
+func TestUDPLayerForPortTunnel(t *testing.T) {
+	p := tunnelTestVXLAN(t, 42,
+		&layers.IPv4{Version: 4, TTL: 255, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{192, 168, 1, 20}, DstIP: net.IP{224, 0, 0, 251}},
+		&layers.UDP{SrcPort: mdnsPort, DstPort: mdnsPort},
+		gopacket.Payload("query"),
+	)
+
+	udp, srcIP, dstIP := udpLayerForPort(p, mdnsPort)
+	if udp == nil || srcIP != "192.168.1.20" || dstIP != "224.0.0.251" || string(udp.Payload) != "query" {
+		t.Fatal("expected the inner UDP layer, got:", udp, srcIP, dstIP)
+	}
+
+	if udp, _, _ := udpLayerForPort(p, 4789); udp != nil {
+		t.Fatal("unexpected outer UDP layer:", udp)
+	}
+}
@@ -53,44 +53,53 @@ type udpData struct {
 	transport gopacket.Flow
 }
 
+// udpStreamKey identifies an UDP stream by its transport flow and the tunnel it was seen in.
+type udpStreamKey struct {
+	transport uint64
+	tunnel    uint64
+}
+
 // udpStreamPool holds a pool of UDP streams.
 type udpStreamPool struct {
-	streams map[uint64]*udpStream
+	streams map[udpStreamKey]*udpStream
 	sync.Mutex
 }
 
 func newUDPStreamPool() *udpStreamPool {
 	return &udpStreamPool{
-		streams: make(map[uint64]*udpStream),
+		streams: make(map[udpStreamKey]*udpStream),
 	}
 }
 
 // takes a udp packet and tracks the data seen for the conversation.
-func (u *udpStreamPool) handleUDP(packet gopacket.Packet, udpLayer gopacket.Layer) {
+// the layers are taken from innerLayers, so tunneled datagrams are tracked by their inner flows.
+func (u *udpStreamPool) handleUDP(packet gopacket.Packet, l *packetLayers) {
+	var (
+		transport = l.transport.TransportFlow()
+		data      = &udpData{
+			raw:       l.transport.LayerPayload(),
+			ci:        packet.Metadata().CaptureInfo,
+			transport: transport,
+			net:       l.network.NetworkFlow(),
+		}
+		key = udpStreamKey{
+			transport: transport.FastHash(),
+			tunnel:    l.tunnel.streamID(),
+		}
+	)
+
 	u.Lock()
-	if s, ok := u.streams[packet.TransportLayer().TransportFlow().FastHash()]; ok {
+	if s, ok := u.streams[key]; ok {
 		u.Unlock()
 
 		// update existing
 		s.Lock()
-		s.data = append(s.data, &udpData{
-			raw:       udpLayer.LayerPayload(),
-			ci:        packet.Metadata().CaptureInfo,
-			transport: packet.TransportLayer().TransportFlow(),
-			net:       packet.NetworkLayer().NetworkFlow(),
-		})
+		s.data = append(s.data, data)
 		s.Unlock()
 	} else {
 		// add new
-		u.streams[packet.TransportLayer().TransportFlow().FastHash()] = &udpStream{
-			data: []*udpData{
-				{
-					raw:       udpLayer.LayerPayload(),
-					ci:        packet.Metadata().CaptureInfo,
-					transport: packet.TransportLayer().TransportFlow(),
-					net:       packet.NetworkLayer().NetworkFlow(),
-				},
-			},
+		u.streams[key] = &udpStream{
+			data: []*udpData{data},
 		}
 		u.Unlock()
 	}
@@ -0,0 +1,672 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * USB HID - keyboard and mouse reconstruction
+ */
+
+const (
+	hidKeyboard = "keyboard"
+	hidMouse    = "mouse"
+
+	defaultKeyboardLayout = "us"
+
+	// USB descriptor types
+	usbDescriptorDevice        = 0x01
+	usbDescriptorConfiguration = 0x02
+	usbDescriptorInterface     = 0x04
+	usbDescriptorEndpoint      = 0x05
+
+	usbClassHID = 0x03
+
+	// HID boot interface protocols
+	hidProtocolKeyboard = 0x01
+	hidProtocolMouse    = 0x02
+
+	// boot protocol keyboard report: modifiers, reserved, six key codes
+	hidKeyboardReportSize = 8
+
+	// maximum width or height of the rendered mouse path
+	hidMousePathSize    = 1024
+	hidMousePathPadding = 10
+)
+
+// modifier bits of the keyboard report
+const (
+	hidModLeftCtrl   = 0x01
+	hidModLeftShift  = 0x02
+	hidModLeftAlt    = 0x04
+	hidModLeftGUI    = 0x08
+	hidModRightCtrl  = 0x10
+	hidModRightShift = 0x20
+	hidModRightAlt   = 0x40
+	hidModRightGUI   = 0x80
+)
+
+// key codes from the HID usage tables that need special handling
+const (
+	hidKeyErrorUndefined = 0x03
+	hidKeyA              = 0x04
+	hidKeyZ              = 0x1d
+	hidKeyEnter          = 0x28
+	hidKeyBackspace      = 0x2a
+	hidKeyTab            = 0x2b
+	hidKeySpace          = 0x2c
+	hidKeySlash          = 0x38
+	hidKeyCapsLock       = 0x39
+	hidKeyPadSlash       = 0x54
+	hidKeyPadEnter       = 0x58
+	hidKeyPadDot         = 0x63
+	hidKeyNonUSBackslash = 0x64
+)
+
+// hidLayout maps the key codes from hidKeyA to hidKeySlash to characters.
+type hidLayout struct {
+	normal []rune
+	shift  []rune
+
+	// key next to the left shift on ISO keyboards
+	iso [2]rune
+
+	// characters typed with the right alt key
+	altGr map[byte]rune
+}
+
+func newHIDLayout(normal, shift string, iso [2]rune, altGr map[byte]rune) *hidLayout {
+	return &hidLayout{
+		normal: []rune(normal),
+		shift:  []rune(shift),
+		iso:    iso,
+		altGr:  altGr,
+	}
+}
+
+// hidLayouts contains the supported keyboard layouts.
+// Enter, Escape, Backspace and Tab are placeholders in the tables and are handled as special keys.
+var hidLayouts = map[string]*hidLayout{
+	"us": newHIDLayout(
+		"abcdefghijklmnopqrstuvwxyz1234567890\n\x1b\b\t -=[]\\#;'`,./",
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ!@#$%^&*()\n\x1b\b\t _+{}|~:\"~<>?",
+		[2]rune{'\\', '|'},
+		nil,
+	),
+	"de": newHIDLayout(
+		"abcdefghijklmnopqrstuvwxzy1234567890\n\x1b\b\t ß´ü+##öä^,.-",
+		"ABCDEFGHIJKLMNOPQRSTUVWXZY!\"§$%&/()=\n\x1b\b\t ?`Ü*''ÖÄ°;:_",
+		[2]rune{'<', '>'},
+		map[byte]rune{
+			0x08: '€', 0x10: 'µ', 0x14: '@',
+			0x1f: '²', 0x20: '³', 0x24: '{', 0x25: '[', 0x26: ']', 0x27: '}',
+			0x2d: '\\', 0x30: '~', hidKeyNonUSBackslash: '|',
+		},
+	),
+	"fr": newHIDLayout(
+		"qbcdefghijkl,noparstuvzxyw&é\"'(-è_çà\n\x1b\b\t )=^$**mù²;:!",
+		"QBCDEFGHIJKL?NOPARSTUVZXYW1234567890\n\x1b\b\t °+¨£µµM%²./§",
+		[2]rune{'<', '>'},
+		map[byte]rune{
+			0x08: '€',
+			0x1f: '~', 0x20: '#', 0x21: '{', 0x22: '[', 0x23: '|', 0x24: '`', 0x25: '\\', 0x26: '^', 0x27: '@',
+			0x2d: ']', 0x2e: '}',
+		},
+	),
+}
+
+// hidKeyPad contains the characters of the keypad from hidKeyPadSlash to hidKeyPadDot.
+var hidKeyPad = []rune("/*-+\n1234567890.")
+
+// hidSpecialKeys contains the names of non printable keys.
+var hidSpecialKeys = map[byte]string{
+	0x28: "ENTER", 0x29: "ESC", 0x2a: "BACKSPACE", 0x2b: "TAB", 0x39: "CAPSLOCK",
+	0x3a: "F1", 0x3b: "F2", 0x3c: "F3", 0x3d: "F4", 0x3e: "F5", 0x3f: "F6",
+	0x40: "F7", 0x41: "F8", 0x42: "F9", 0x43: "F10", 0x44: "F11", 0x45: "F12",
+	0x46: "PRINTSCREEN", 0x47: "SCROLLLOCK", 0x48: "PAUSE", 0x49: "INSERT",
+	0x4a: "HOME", 0x4b: "PAGEUP", 0x4c: "DEL", 0x4d: "END", 0x4e: "PAGEDOWN",
+	0x4f: "RIGHT", 0x50: "LEFT", 0x51: "DOWN", 0x52: "UP", 0x53: "NUMLOCK",
+	0x58: "ENTER", 0x65: "MENU",
+}
+
+// char returns the character typed with the key code and modifiers.
+func (l *hidLayout) char(key byte, shift, capsLock, altGr bool) (rune, bool) {
+	if altGr {
+		r, ok := l.altGr[key]
+
+		return r, ok
+	}
+
+	// caps lock only affects the letters
+	if capsLock && key >= hidKeyA && key <= hidKeyZ {
+		shift = !shift
+	}
+
+	idx := 0
+	if shift {
+		idx = 1
+	}
+
+	switch {
+	case key >= hidKeyA && key <= hidKeySlash && (key < hidKeyEnter || key > hidKeyTab):
+		if shift {
+			return l.shift[key-hidKeyA], true
+		}
+
+		return l.normal[key-hidKeyA], true
+	case key >= hidKeyPadSlash && key <= hidKeyPadDot && key != hidKeyPadEnter:
+		return hidKeyPad[key-hidKeyPadSlash], true
+	case key == hidKeyNonUSBackslash:
+		return l.iso[idx], true
+	}
+
+	return 0, false
+}
+
+// hidPoint is a position of the mouse relative to its first report.
+type hidPoint struct {
+	x, y    int
+	pressed bool
+}
+
+// hidDevice collects the reports of a keyboard or mouse.
+type hidDevice struct {
+	record *types.USBKeystrokes
+	layout *hidLayout
+
+	// keyboard
+	previous []byte
+	capsLock bool
+	keys     strings.Builder
+	text     []rune
+
+	// mouse
+	buttons byte
+	x, y    int
+	path    []hidPoint
+}
+
+// usbHIDState tracks the HID devices seen in the capture.
+var usbHIDState = struct {
+	// bus.device.endpoint to device type, learned from the configuration descriptors
+	endpoints map[string]string
+
+	// bus.device to vendor and product ID, learned from the device descriptors
+	ids map[string][2]string
+
+	// bus.device.endpoint to devices
+	devices map[string]*hidDevice
+
+	sync.Mutex
+}{
+	endpoints: make(map[string]string),
+	ids:       make(map[string][2]string),
+	devices:   make(map[string]*hidDevice),
+}
+
+func usbBusDevice(u *layers.USB) string {
+	return fmt.Sprintf("%d.%d", u.BusID, u.DeviceAddress)
+}
+
+// usbDescriptors learns the HID interrupt endpoints and the device identity
+// from the descriptors returned by GET_DESCRIPTOR requests.
+// The caller must hold the usbHIDState lock.
+func usbDescriptors(busDevice string, data []byte) {
+	if len(data) < 2 || int(data[0]) > len(data) {
+		return
+	}
+
+	switch data[1] {
+	case usbDescriptorDevice:
+		if data[0] != 18 {
+			return
+		}
+
+		usbHIDState.ids[busDevice] = [2]string{
+			fmt.Sprintf("%04x", binary.LittleEndian.Uint16(data[8:10])),
+			fmt.Sprintf("%04x", binary.LittleEndian.Uint16(data[10:12])),
+		}
+	case usbDescriptorConfiguration:
+		var typ string
+
+		// interface and endpoint descriptors follow the configuration
+		for i := 0; i+2 <= len(data); {
+			size := int(data[i])
+			if size < 2 || i+size > len(data) {
+				return
+			}
+
+			d := data[i : i+size]
+
+			switch d[1] {
+			case usbDescriptorInterface:
+				typ = ""
+
+				if size >= 9 && d[5] == usbClassHID {
+					switch d[7] {
+					case hidProtocolKeyboard:
+						typ = hidKeyboard
+					case hidProtocolMouse:
+						typ = hidMouse
+					}
+				}
+			case usbDescriptorEndpoint:
+				// interrupt IN endpoints of HID interfaces
+				if size >= 7 && typ != "" && d[2]&0x80 != 0 && d[3]&0x03 == 0x03 {
+					usbHIDState.endpoints[fmt.Sprintf("%s.%d", busDevice, d[2]&0x7f)] = typ
+				}
+			}
+
+			i += size
+		}
+	}
+}
+
+// hidReportType guesses the device type for reports of devices whose descriptors were not captured.
+func hidReportType(data []byte) string {
+	switch {
+	case len(data) == hidKeyboardReportSize && data[1] == 0:
+		return hidKeyboard
+	case len(data) >= 3 && len(data) <= hidKeyboardReportSize:
+		return hidMouse
+	}
+
+	return ""
+}
+
+// keyboard decodes a boot protocol keyboard report.
+func (d *hidDevice) keyboard(report []byte) {
+	if len(report) < hidKeyboardReportSize {
+		return
+	}
+
+	keys := report[2:hidKeyboardReportSize]
+
+	// phantom state, too many keys are pressed
+	for _, k := range keys {
+		if k > 0 && k <= hidKeyErrorUndefined {
+			return
+		}
+	}
+
+	for _, k := range keys {
+		if k == 0 || (d.previous != nil && bytes.IndexByte(d.previous[2:], k) != -1) {
+			continue
+		}
+
+		d.press(k, report[0])
+	}
+
+	d.previous = append(d.previous[:0], report[:hidKeyboardReportSize]...)
+}
+
+// press handles a newly pressed key.
+func (d *hidDevice) press(key, mods byte) {
+	d.record.Keystrokes++
+
+	if key == hidKeyCapsLock {
+		d.capsLock = !d.capsLock
+	}
+
+	var (
+		shift = mods&(hidModLeftShift|hidModRightShift) != 0
+		altGr = mods&hidModRightAlt != 0
+		r, ok = d.layout.char(key, shift, d.capsLock, altGr)
+		name  = hidSpecialKeys[key]
+		combo []string
+	)
+
+	if mods&(hidModLeftCtrl|hidModRightCtrl) != 0 {
+		combo = append(combo, "CTRL")
+	}
+
+	if mods&hidModLeftAlt != 0 {
+		combo = append(combo, "ALT")
+	}
+
+	if mods&(hidModLeftGUI|hidModRightGUI) != 0 {
+		combo = append(combo, "GUI")
+	}
+
+	if name == "" {
+		if ok {
+			name = string(r)
+		} else {
+			name = fmt.Sprintf("0x%02x", key)
+		}
+	}
+
+	// shortcuts do not produce text
+	if len(combo) > 0 {
+		d.keys.WriteString("[" + strings.Join(append(combo, name), "+") + "]")
+
+		return
+	}
+
+	if ok {
+		d.keys.WriteRune(r)
+		d.text = append(d.text, r)
+
+		return
+	}
+
+	d.keys.WriteString("[" + name + "]")
+
+	switch key {
+	case hidKeyEnter, hidKeyPadEnter:
+		d.text = append(d.text, '\n')
+	case hidKeyTab:
+		d.text = append(d.text, '\t')
+	case hidKeyBackspace:
+		if len(d.text) > 0 {
+			d.text = d.text[:len(d.text)-1]
+		}
+	}
+}
+
+// mouse decodes a boot protocol mouse report.
+func (d *hidDevice) mouse(report []byte) {
+	if len(report) < 3 {
+		return
+	}
+
+	buttons := report[0]
+
+	// count newly pressed buttons
+	for b := buttons &^ d.buttons; b != 0; b &= b - 1 {
+		d.record.MouseClicks++
+	}
+
+	d.buttons = buttons
+	d.x += int(int8(report[1]))
+	d.y += int(int8(report[2]))
+
+	d.path = append(d.path, hidPoint{
+		x:       d.x,
+		y:       d.y,
+		pressed: buttons&0x01 != 0,
+	})
+}
+
+// hidMousePath renders the mouse movement as PNG image.
+// Movements with the left button pressed are drawn black, others gray.
+func hidMousePath(path []hidPoint) ([]byte, error) {
+	minX, minY, maxX, maxY := path[0].x, path[0].y, path[0].x, path[0].y
+
+	for _, p := range path {
+		if p.x < minX {
+			minX = p.x
+		}
+
+		if p.x > maxX {
+			maxX = p.x
+		}
+
+		if p.y < minY {
+			minY = p.y
+		}
+
+		if p.y > maxY {
+			maxY = p.y
+		}
+	}
+
+	var (
+		width  = maxX - minX + 1
+		height = maxY - minY + 1
+		scale  = 1.0
+	)
+
+	if width > hidMousePathSize || height > hidMousePathSize {
+		if width > height {
+			scale = float64(hidMousePathSize) / float64(width)
+		} else {
+			scale = float64(hidMousePathSize) / float64(height)
+		}
+	}
+
+	var (
+		img = image.NewRGBA(image.Rect(0, 0,
+			int(float64(width)*scale)+2*hidMousePathPadding,
+			int(float64(height)*scale)+2*hidMousePathPadding,
+		))
+		pos = func(p hidPoint) (int, int) {
+			return int(float64(p.x-minX)*scale) + hidMousePathPadding, int(float64(p.y-minY)*scale) + hidMousePathPadding
+		}
+		drawn = color.RGBA{A: 0xff}
+		moved = color.RGBA{R: 0xc0, G: 0xc0, B: 0xc0, A: 0xff}
+	)
+
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	x0, y0 := pos(path[0])
+
+	for _, p := range path[1:] {
+		x1, y1 := pos(p)
+
+		c := moved
+		if p.pressed {
+			c = drawn
+		}
+
+		hidLine(img, x0, y0, x1, y1, c)
+
+		x0, y0 = x1, y1
+	}
+
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// hidLine draws a line with the Bresenham algorithm.
+func hidLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	var (
+		dx, sx = x1 - x0, 1
+		dy, sy = y1 - y0, 1
+	)
+
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+
+	e := dx - dy
+
+	for {
+		img.SetRGBA(x0, y0, c)
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		if e2 := 2 * e; e2 > -dy {
+			e -= dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// finish completes the audit record of the device.
+func (d *hidDevice) finish(busDevice string) *types.USBKeystrokes {
+	r := d.record
+
+	if ids, ok := usbHIDState.ids[busDevice]; ok {
+		r.VendorID, r.ProductID = ids[0], ids[1]
+	}
+
+	if r.Type == hidKeyboard {
+		r.Keys = d.keys.String()
+		r.Text = string(d.text)
+
+		return r
+	}
+
+	if len(d.path) < 2 {
+		return r
+	}
+
+	data, err := hidMousePath(d.path)
+	if err != nil {
+		logReassemblyError("USB-mouse", "%s: failed to render mouse path: %s\n", r.Device, err)
+
+		return r
+	}
+
+	f := &types.File{
+		Timestamp: r.Timestamp,
+		Name:      "usb-mouse-" + r.Device,
+		Ident:     "path",
+		Source:    "USB HID mouse " + r.Device,
+	}
+
+	err = saveExtractedFile(f, data)
+	if err != nil {
+		logReassemblyError("USB-mouse", "%s: failed to save mouse path: %s\n", r.Device, err)
+	}
+
+	r.MousePath = f.Location
+
+	return r
+}
+
+// handleUSBHID processes the completions of control and interrupt transfers.
+func handleUSBHID(u *layers.USB, ts time.Time) {
+	if u.EventType != layers.USBEventTypeComplete || u.Direction != layers.USBDirectionTypeIn || !u.Data || len(u.Payload) == 0 {
+		return
+	}
+
+	busDevice := usbBusDevice(u)
+
+	usbHIDState.Lock()
+	defer usbHIDState.Unlock()
+
+	switch u.TransferType {
+	case layers.USBTransportTypeControl:
+		usbDescriptors(busDevice, u.Payload)
+	case layers.USBTransportTypeInterrupt:
+		id := fmt.Sprintf("%s.%d", busDevice, u.EndpointNumber)
+
+		d, ok := usbHIDState.devices[id]
+		if !ok {
+			typ, known := usbHIDState.endpoints[id]
+			if !known {
+				typ = hidReportType(u.Payload)
+			}
+
+			if typ == "" {
+				return
+			}
+
+			d = &hidDevice{
+				record: &types.USBKeystrokes{
+					Timestamp: utils.TimeToString(ts),
+					Device:    id,
+					Type:      typ,
+				},
+			}
+
+			if typ == hidKeyboard {
+				d.record.Layout = conf.KeyboardLayout
+				if d.record.Layout == "" {
+					d.record.Layout = defaultKeyboardLayout
+				}
+
+				d.layout = hidLayouts[d.record.Layout]
+			}
+
+			usbHIDState.devices[id] = d
+		}
+
+		d.record.Reports++
+
+		if d.record.Type == hidKeyboard {
+			d.keyboard(u.Payload)
+		} else {
+			d.mouse(u.Payload)
+		}
+	}
+}
+
+var usbKeystrokesDecoder = newCustomDecoder(
+	types.Type_NC_USBKeystrokes,
+	"USBKeystrokes",
+	"USBKeystrokes contains the text typed on USB keyboards and the movement paths of USB mice",
+	func(d *customDecoder) error {
+		if conf.KeyboardLayout != "" {
+			if _, ok := hidLayouts[conf.KeyboardLayout]; !ok {
+				return fmt.Errorf("unknown keyboard layout: %s", conf.KeyboardLayout)
+			}
+		}
+
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		if usbLayer := p.Layer(layers.LayerTypeUSB); usbLayer != nil {
+			if u, ok := usbLayer.(*layers.USB); ok {
+				handleUSBHID(u, p.Metadata().Timestamp)
+			}
+		}
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		usbHIDState.Lock()
+		defer usbHIDState.Unlock()
+
+		ids := make([]string, 0, len(usbHIDState.devices))
+		for id := range usbHIDState.devices {
+			ids = append(ids, id)
+		}
+
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			e.write(usbHIDState.devices[id].finish(id[:strings.LastIndexByte(id, '.')]))
+		}
+
+		return nil
+	},
+)
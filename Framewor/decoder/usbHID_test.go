@@ -0,0 +1,222 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// usbTestURB builds a usbmon event with the 64 byte header of the mmapped interface.
+func usbTestURB(event layers.USBEventType, transfer layers.USBTransportType, device, endpoint uint8, data []byte) gopacket.Packet {
+	b := make([]byte, 64, 64+len(data))
+
+	b[8] = byte(event)
+	b[9] = byte(transfer)
+	b[10] = endpoint
+	b[11] = device
+	binary.LittleEndian.PutUint16(b[12:14], 1)
+	b[14] = '-'
+	binary.LittleEndian.PutUint32(b[32:36], uint32(len(data)))
+	binary.LittleEndian.PutUint32(b[36:40], uint32(len(data)))
+
+	p := gopacket.NewPacket(append(b, data...), layers.LinkTypeLinuxUSB, gopacket.Default)
+	p.Metadata().Timestamp = time.Unix(1600000000, 0)
+
+	return p
+}
+
+// usbTestCompletion builds the completion of an IN transfer.
+func usbTestCompletion(transfer layers.USBTransportType, device, endpoint uint8, data []byte) gopacket.Packet {
+	return usbTestURB(layers.USBEventTypeComplete, transfer, device, endpoint|0x80, data)
+}
+
+func TestUSBKeystrokes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		records     = &recordCollector{}
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+		reset       = func() {
+			usbHIDState.endpoints = make(map[string]string)
+			usbHIDState.ids = make(map[string][2]string)
+			usbHIDState.devices = make(map[string]*hidDevice)
+		}
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+
+		reset()
+	}()
+
+	conf = &Config{FileStorage: dir, KeyboardLayout: "us"}
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+
+	reset()
+
+	var (
+		// logitech keyboard
+		deviceDescriptor = []byte{18, 0x01, 0x00, 0x02, 0, 0, 0, 8, 0x6d, 0x04, 0x1c, 0xc3, 0, 0x01, 1, 2, 0, 1}
+
+		// configuration with a boot keyboard interface and its interrupt endpoint 0x81
+		configDescriptor = []byte{
+			9, 0x02, 34, 0, 1, 1, 0, 0xa0, 50,
+			9, 0x04, 0, 0, 1, 0x03, 0x01, 0x01, 0,
+			9, 0x21, 0x11, 0x01, 0, 1, 0x22, 65, 0,
+			7, 0x05, 0x81, 0x03, 8, 0, 10,
+		}
+
+		release = []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	)
+
+	packets := []gopacket.Packet{
+		usbTestCompletion(layers.USBTransportTypeControl, 3, 0, deviceDescriptor),
+		usbTestCompletion(layers.USBTransportTypeControl, 3, 0, configDescriptor),
+
+		// H with left shift, i held for two reports, space
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0x02, 0, 0x0b, 0, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, release),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x0c, 0, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x0c, 0, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x0c, 0x2c, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, release),
+
+		// x removed with backspace, phantom state is ignored
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x1b, 0, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x2a, 0, 0, 0, 0, 0}),
+
+		// ctrl+c and enter
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0x01, 0, 0x06, 0, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, []byte{0, 0, 0x28, 0, 0, 0, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 3, 1, release),
+
+		// mouse without descriptors, drawing a line with the left button
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 4, 2, []byte{0, 10, 5, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 4, 2, []byte{0x01, 20, 0, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 4, 2, []byte{0x01, 0, 0xec, 0}),
+		usbTestCompletion(layers.USBTransportTypeInterrupt, 4, 2, []byte{0, 0xf6, 0, 0}),
+	}
+
+	for _, p := range packets {
+		if m := usbKeystrokesDecoder.Handler(p); m != nil {
+			t.Fatal("expected records to be written on teardown, got", m)
+		}
+	}
+
+	err = usbKeystrokesDecoder.deInit(&customDecoder{writer: records})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records.records) != 2 {
+		t.Fatal("expected 2 records, got", len(records.records))
+	}
+
+	keyboard := records.records[0].(*types.USBKeystrokes)
+
+	if keyboard.Device != "1.3.1" || keyboard.Type != hidKeyboard || keyboard.Layout != "us" {
+		t.Fatal("unexpected keyboard:", keyboard.Device, keyboard.Type, keyboard.Layout)
+	}
+
+	if keyboard.VendorID != "046d" || keyboard.ProductID != "c31c" {
+		t.Fatal("unexpected device identity:", keyboard.VendorID, keyboard.ProductID)
+	}
+
+	if keyboard.Keys != "Hi x[BACKSPACE][CTRL+c][ENTER]" {
+		t.Fatalf("unexpected keys: %q", keyboard.Keys)
+	}
+
+	if keyboard.Text != "Hi \n" {
+		t.Fatalf("unexpected text: %q", keyboard.Text)
+	}
+
+	if keyboard.Keystrokes != 7 || keyboard.Reports != 12 {
+		t.Fatal("unexpected counts:", keyboard.Keystrokes, keyboard.Reports)
+	}
+
+	mouse := records.records[1].(*types.USBKeystrokes)
+
+	if mouse.Device != "1.4.2" || mouse.Type != hidMouse || mouse.MouseClicks != 1 || mouse.Reports != 4 {
+		t.Fatal("unexpected mouse:", mouse.Device, mouse.Type, mouse.MouseClicks, mouse.Reports)
+	}
+
+	if len(fileRecords.records) != 1 {
+		t.Fatal("expected the mouse path to be saved, got", len(fileRecords.records))
+	}
+
+	f := fileRecords.records[0].(*types.File)
+	if f.ContentType != "image/png" || f.Location != mouse.MousePath {
+		t.Fatal("unexpected mouse path:", f.ContentType, f.Location, mouse.MousePath)
+	}
+
+	if _, err = os.Stat(mouse.MousePath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHIDLayouts(t *testing.T) {
+	for name, l := range hidLayouts {
+		if len(l.normal) != int(hidKeySlash-hidKeyA+1) || len(l.shift) != len(l.normal) {
+			t.Fatal("invalid table size for layout", name, len(l.normal), len(l.shift))
+		}
+	}
+
+	tests := []struct {
+		layout             string
+		key                byte
+		shift, caps, altGr bool
+		expected           rune
+	}{
+		{"us", 0x04, false, false, false, 'a'},
+		{"us", 0x04, false, true, false, 'A'},
+		{"us", 0x04, true, true, false, 'a'},
+		{"us", 0x1e, true, true, false, '!'},
+		{"us", 0x59, false, false, false, '1'},
+		{"de", 0x1c, false, false, false, 'z'},
+		{"de", 0x1f, true, false, false, '"'},
+		{"de", 0x14, false, false, true, '@'},
+		{"de", hidKeyNonUSBackslash, true, false, false, '>'},
+		{"fr", 0x14, false, false, false, 'a'},
+		{"fr", 0x1e, false, false, false, '&'},
+		{"fr", 0x33, true, false, false, 'M'},
+	}
+
+	for _, test := range tests {
+		r, ok := hidLayouts[test.layout].char(test.key, test.shift, test.caps, test.altGr)
+		if !ok || r != test.expected {
+			t.Errorf("%s: key 0x%02x: expected %q, got %q", test.layout, test.key, test.expected, r)
+		}
+	}
+
+	if _, ok := hidLayouts["us"].char(hidKeyEnter, false, false, false); ok {
+		t.Fatal("expected enter to be a special key")
+	}
+}
@@ -0,0 +1,408 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * USB mass storage - Bulk-Only Transport with SCSI commands
+ */
+
+const (
+	// command block wrapper and command status wrapper
+	usbCBWSize = 31
+	usbCSWSize = 13
+
+	usbCSWStatusPassed = 0x00
+
+	// default logical block size, if no READ CAPACITY response was captured
+	scsiDefaultBlockSize = 512
+
+	usbStorageContentType = "application/octet-stream"
+)
+
+var (
+	usbCBWSignature = []byte("USBC")
+	usbCSWSignature = []byte("USBS")
+)
+
+// SCSI operation codes
+const (
+	scsiInquiry      = 0x12
+	scsiReadCapacity = 0x25
+	scsiRead10       = 0x28
+	scsiWrite10      = 0x2a
+)
+
+// scsiCommand is a SCSI command sent in a command block wrapper, along with its data.
+type scsiCommand struct {
+	tag    uint32
+	length uint32
+	opcode byte
+	lba    uint64
+	blocks uint32
+	data   []byte
+}
+
+// usbDisk collects the commands sent to a mass storage device.
+type usbDisk struct {
+	record    *types.USBMassStorage
+	blockSize uint32
+
+	// command waiting for its status
+	cmd *scsiCommand
+
+	// written logical blocks, start and end
+	written [][2]uint64
+
+	image *os.File
+}
+
+// usbStorageState tracks the mass storage devices seen in the capture.
+var usbStorageState = struct {
+	// bus.device to disks
+	disks map[string]*usbDisk
+
+	sync.Mutex
+}{
+	disks: make(map[string]*usbDisk),
+}
+
+// parseCBW parses a command block wrapper.
+func parseCBW(data []byte) *scsiCommand {
+	if len(data) != usbCBWSize || !bytes.Equal(data[:4], usbCBWSignature) {
+		return nil
+	}
+
+	var (
+		cbLength = int(data[14])
+		cb       = data[15:]
+		c        = &scsiCommand{
+			tag:    binary.LittleEndian.Uint32(data[4:8]),
+			length: binary.LittleEndian.Uint32(data[8:12]),
+			opcode: cb[0],
+		}
+	)
+
+	if cbLength == 0 || cbLength > len(cb) {
+		return nil
+	}
+
+	if c.opcode == scsiRead10 || c.opcode == scsiWrite10 {
+		if cbLength < 10 {
+			return nil
+		}
+
+		c.lba = uint64(binary.BigEndian.Uint32(cb[2:6]))
+		c.blocks = uint32(binary.BigEndian.Uint16(cb[7:9]))
+	}
+
+	return c
+}
+
+// command handles a new command block wrapper.
+func (d *usbDisk) command(c *scsiCommand) {
+	// the status of the previous command was not captured
+	if d.cmd != nil && d.cmd.length > 0 && len(d.cmd.data) >= int(d.cmd.length) {
+		d.complete(d.cmd)
+	}
+
+	if d.blockSize == 0 && c.blocks > 0 && c.length%c.blocks == 0 {
+		d.blockSize = c.length / c.blocks
+	}
+
+	d.cmd = c
+}
+
+// status handles a command status wrapper.
+func (d *usbDisk) status(data []byte) {
+	if d.cmd == nil || binary.LittleEndian.Uint32(data[4:8]) != d.cmd.tag {
+		return
+	}
+
+	if data[12] == usbCSWStatusPassed {
+		d.complete(d.cmd)
+	} else {
+		d.record.FailedCommands++
+	}
+
+	d.cmd = nil
+}
+
+// complete evaluates a successful command.
+func (d *usbDisk) complete(c *scsiCommand) {
+	r := d.record
+
+	switch c.opcode {
+	case scsiInquiry:
+		if len(c.data) >= 36 {
+			r.Vendor = strings.TrimSpace(string(c.data[8:16]))
+			r.Product = strings.TrimSpace(string(c.data[16:32]))
+			r.Revision = strings.TrimSpace(string(c.data[32:36]))
+		}
+	case scsiReadCapacity:
+		if len(c.data) >= 8 {
+			d.blockSize = binary.BigEndian.Uint32(c.data[4:8])
+			r.Capacity = (uint64(binary.BigEndian.Uint32(c.data[0:4])) + 1) * uint64(d.blockSize)
+		}
+	case scsiRead10:
+		r.Reads++
+		r.BytesRead += int64(len(c.data))
+
+		d.store(c)
+	case scsiWrite10:
+		r.Writes++
+		r.BytesWritten += int64(len(c.data))
+
+		if c.blocks > 0 {
+			d.written = append(d.written, [2]uint64{c.lba, c.lba + uint64(c.blocks)})
+		}
+
+		d.store(c)
+	}
+}
+
+// store writes the data of a command at the position of its sectors into the disk image.
+func (d *usbDisk) store(c *scsiCommand) {
+	if len(c.data) == 0 || conf.FileStorage == "" {
+		return
+	}
+
+	if d.image == nil {
+		root := filepath.Join(conf.FileStorage, usbStorageContentType)
+
+		createContentTypePathIfRequired(root)
+
+		var (
+			base   = "usb-storage-" + d.record.Device
+			target = filepath.Join(root, base+".img")
+			n      = 0
+			err    error
+		)
+
+		for {
+			_, errStat := os.Stat(target)
+			if errStat != nil {
+				break
+			}
+
+			target = filepath.Join(root, base+"-"+strconv.Itoa(n)+".img")
+			n++
+		}
+
+		d.image, err = os.OpenFile(target, os.O_CREATE|os.O_WRONLY, defaultFilesPermission)
+		if err != nil {
+			logReassemblyError("USB-storage", "Cannot create %s: %s\n", target, err)
+
+			return
+		}
+
+		d.record.Image = target
+	}
+
+	_, err := d.image.WriteAt(c.data, int64(c.lba)*int64(d.sectorSize()))
+	if err != nil {
+		logReassemblyError("USB-storage", "%s: failed to write sectors: %s\n", d.record.Device, err)
+	}
+}
+
+func (d *usbDisk) sectorSize() uint32 {
+	if d.blockSize == 0 {
+		return scsiDefaultBlockSize
+	}
+
+	return d.blockSize
+}
+
+// writtenSectors merges the written logical blocks into ranges.
+func (d *usbDisk) writtenSectors() (ranges []string) {
+	sort.Slice(d.written, func(i, j int) bool {
+		return d.written[i][0] < d.written[j][0]
+	})
+
+	var current [2]uint64
+
+	for i, w := range d.written {
+		if i > 0 && w[0] <= current[1] {
+			if w[1] > current[1] {
+				current[1] = w[1]
+			}
+
+			continue
+		}
+
+		if i > 0 {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", current[0], current[1]-1))
+		}
+
+		current = w
+	}
+
+	if len(d.written) > 0 {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", current[0], current[1]-1))
+	}
+
+	return ranges
+}
+
+// finish completes the audit record and emits a File audit record for the disk image.
+func (d *usbDisk) finish() *types.USBMassStorage {
+	r := d.record
+
+	if d.cmd != nil && d.cmd.length > 0 && len(d.cmd.data) >= int(d.cmd.length) {
+		d.complete(d.cmd)
+		d.cmd = nil
+	}
+
+	r.BlockSize = d.sectorSize()
+	r.WrittenSectors = d.writtenSectors()
+
+	if d.image == nil {
+		return r
+	}
+
+	info, err := d.image.Stat()
+	if err != nil {
+		logReassemblyError("USB-storage", "%s: failed to stat image: %s\n", r.Device, err)
+	}
+
+	err = d.image.Close()
+	if err != nil {
+		logReassemblyError("USB-storage", "%s: failed to close image: %s\n", r.Device, err)
+	}
+
+	d.image = nil
+
+	if fileDecoderInstance == nil || info == nil {
+		return r
+	}
+
+	writeFile(&types.File{
+		Timestamp:   r.Timestamp,
+		Name:        filepath.Base(r.Image),
+		Length:      info.Size(),
+		Ident:       r.Vendor + " " + r.Product,
+		Source:      "USB mass storage " + r.Device,
+		ContentType: usbStorageContentType,
+		Location:    r.Image,
+	})
+
+	return r
+}
+
+// handleUSBStorage processes the bulk transfers of mass storage devices.
+// Data sent to the device travels in the submissions, data from the device in the completions.
+func handleUSBStorage(u *layers.USB, ts time.Time) {
+	if u.TransferType != layers.USBTransportTypeBulk || !u.Data || len(u.Payload) == 0 {
+		return
+	}
+
+	var (
+		out = u.Direction == layers.USBDirectionTypeOut && u.EventType == layers.USBEventTypeSubmit
+		in  = u.Direction == layers.USBDirectionTypeIn && u.EventType == layers.USBEventTypeComplete
+		id  = usbBusDevice(u)
+	)
+
+	if !out && !in {
+		return
+	}
+
+	usbStorageState.Lock()
+	defer usbStorageState.Unlock()
+
+	d := usbStorageState.disks[id]
+
+	if out {
+		if c := parseCBW(u.Payload); c != nil {
+			if d == nil {
+				d = &usbDisk{
+					record: &types.USBMassStorage{
+						Timestamp: utils.TimeToString(ts),
+						Device:    id,
+					},
+				}
+				usbStorageState.disks[id] = d
+			}
+
+			d.command(c)
+
+			return
+		}
+	}
+
+	if d == nil || d.cmd == nil {
+		return
+	}
+
+	if in && len(u.Payload) == usbCSWSize && bytes.Equal(u.Payload[:4], usbCSWSignature) {
+		d.status(u.Payload)
+
+		return
+	}
+
+	d.cmd.data = append(d.cmd.data, u.Payload...)
+}
+
+var usbMassStorageDecoder = newCustomDecoder(
+	types.Type_NC_USBMassStorage,
+	"USBMassStorage",
+	"USBMassStorage summarizes the SCSI commands sent to USB mass storage devices and reconstructs the accessed sectors",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		if usbLayer := p.Layer(layers.LayerTypeUSB); usbLayer != nil {
+			if u, ok := usbLayer.(*layers.USB); ok {
+				handleUSBStorage(u, p.Metadata().Timestamp)
+			}
+		}
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		usbStorageState.Lock()
+		defer usbStorageState.Unlock()
+
+		ids := make([]string, 0, len(usbStorageState.disks))
+		for id := range usbStorageState.disks {
+			ids = append(ids, id)
+		}
+
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			e.write(usbStorageState.disks[id].finish())
+		}
+
+		return nil
+	},
+)
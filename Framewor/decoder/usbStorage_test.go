@@ -0,0 +1,169 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+func usbTestCBW(tag, length uint32, cb ...byte) []byte {
+	b := make([]byte, usbCBWSize)
+
+	copy(b, usbCBWSignature)
+	binary.LittleEndian.PutUint32(b[4:8], tag)
+	binary.LittleEndian.PutUint32(b[8:12], length)
+	b[14] = byte(len(cb))
+	copy(b[15:], cb)
+
+	return b
+}
+
+func usbTestCSW(tag uint32, status byte) []byte {
+	b := make([]byte, usbCSWSize)
+
+	copy(b, usbCSWSignature)
+	binary.LittleEndian.PutUint32(b[4:8], tag)
+	b[12] = status
+
+	return b
+}
+
+func TestUSBMassStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		records     = &recordCollector{}
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+
+		// bulk endpoints of the device
+		out = func(data []byte) gopacket.Packet {
+			return usbTestURB(layers.USBEventTypeSubmit, layers.USBTransportTypeBulk, 5, 0x02, data)
+		}
+		in = func(data []byte) gopacket.Packet {
+			return usbTestURB(layers.USBEventTypeComplete, layers.USBTransportTypeBulk, 5, 0x81, data)
+		}
+
+		inquiry  = make([]byte, 36)
+		capacity = []byte{0, 0x1d, 0xff, 0xff, 0, 0, 0x02, 0}
+		boot     = bytes.Repeat([]byte{0xeb}, 512)
+		secret   = bytes.Repeat([]byte("secret"), 1024)[:1024]
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+		usbStorageState.disks = make(map[string]*usbDisk)
+	}()
+
+	conf = &Config{FileStorage: dir}
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+	usbStorageState.disks = make(map[string]*usbDisk)
+
+	copy(inquiry[8:], "SanDisk Cruzer Blade    1.00")
+
+	packets := []gopacket.Packet{
+		out(usbTestCBW(1, 36, scsiInquiry, 0, 0, 0, 36, 0)),
+		in(inquiry),
+		in(usbTestCSW(1, 0)),
+
+		out(usbTestCBW(2, 8, scsiReadCapacity, 0, 0, 0, 0, 0, 0, 0, 0, 0)),
+		in(capacity),
+		in(usbTestCSW(2, 0)),
+
+		// read the boot sector
+		out(usbTestCBW(3, 512, scsiRead10, 0, 0, 0, 0, 0, 0, 0, 1, 0)),
+		in(boot),
+		in(usbTestCSW(3, 0)),
+
+		// write two sectors at 100, the data is split into two transfers
+		out(usbTestCBW(4, 1024, scsiWrite10, 0, 0, 0, 0, 100, 0, 0, 2, 0)),
+		out(secret[:512]),
+		out(secret[512:]),
+		in(usbTestCSW(4, 0)),
+
+		// overlapping write of sector 101 and 102
+		out(usbTestCBW(5, 1024, scsiWrite10, 0, 0, 0, 0, 101, 0, 0, 2, 0)),
+		out(secret),
+		in(usbTestCSW(5, 0)),
+
+		// failed read
+		out(usbTestCBW(6, 512, scsiRead10, 0, 0, 0, 0x10, 0, 0, 0, 1, 0)),
+		in(usbTestCSW(6, 1)),
+	}
+
+	for _, p := range packets {
+		if m := usbMassStorageDecoder.Handler(p); m != nil {
+			t.Fatal("expected records to be written on teardown, got", m)
+		}
+	}
+
+	err = usbMassStorageDecoder.deInit(&customDecoder{writer: records})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records.records) != 1 {
+		t.Fatal("expected 1 record, got", len(records.records))
+	}
+
+	r := records.records[0].(*types.USBMassStorage)
+
+	if r.Device != "1.5" || r.Vendor != "SanDisk" || r.Product != "Cruzer Blade" || r.Revision != "1.00" {
+		t.Fatalf("unexpected identity: %q %q %q %q", r.Device, r.Vendor, r.Product, r.Revision)
+	}
+
+	if r.BlockSize != 512 || r.Capacity != 0x1e0000*512 {
+		t.Fatal("unexpected geometry:", r.BlockSize, r.Capacity)
+	}
+
+	if r.Reads != 1 || r.Writes != 2 || r.BytesRead != 512 || r.BytesWritten != 2048 || r.FailedCommands != 1 {
+		t.Fatal("unexpected counts:", r.Reads, r.Writes, r.BytesRead, r.BytesWritten, r.FailedCommands)
+	}
+
+	if len(r.WrittenSectors) != 1 || r.WrittenSectors[0] != "100-102" {
+		t.Fatal("unexpected written sectors:", r.WrittenSectors)
+	}
+
+	image, err := ioutil.ReadFile(r.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(image) != 103*512 {
+		t.Fatal("unexpected image size:", len(image))
+	}
+
+	if !bytes.Equal(image[:512], boot) || !bytes.Equal(image[100*512:101*512], secret[:512]) || !bytes.Equal(image[101*512:], secret) {
+		t.Fatal("unexpected image contents")
+	}
+
+	if len(fileRecords.records) != 1 || fileRecords.records[0].(*types.File).Location != r.Image {
+		t.Fatal("expected a file record for the image")
+	}
+}
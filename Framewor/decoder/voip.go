@@ -0,0 +1,505 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * VoIP - calls from SIP dialogs and their RTP streams
+ */
+
+const serviceVoIP = "VoIP"
+
+// voipMedia is an endpoint announced in a SDP body.
+type voipMedia struct {
+	call *voipCall
+	rtcp bool
+}
+
+type voipCall struct {
+	record *types.Call
+
+	invite   time.Time
+	answer   time.Time
+	hangup   time.Time
+	lastSeen time.Time
+
+	// payload types announced via SDP
+	codecs map[byte]rtpCodec
+
+	// media endpoints announced by both parties
+	endpoints []string
+
+	// src->dst to streams
+	streams map[string]*rtpStream
+}
+
+// voipState tracks the calls by their SIP Call-ID and the media endpoints negotiated for them.
+var voipState = struct {
+	calls map[string]*voipCall
+	// ip:port to media
+	media map[string]*voipMedia
+	sync.Mutex
+}{
+	calls: make(map[string]*voipCall),
+	media: make(map[string]*voipMedia),
+}
+
+// sipURI extracts the URI from a From or To header.
+func sipURI(header string) string {
+	if start := strings.Index(header, "<"); start != -1 {
+		if end := strings.Index(header[start:], ">"); end != -1 {
+			return header[start+1 : start+end]
+		}
+	}
+
+	if i := strings.Index(header, ";"); i != -1 {
+		header = header[:i]
+	}
+
+	return strings.TrimSpace(header)
+}
+
+// sipCSeqMethod returns the method of the CSeq header, to identify the request a response belongs to.
+func sipCSeqMethod(s *layers.SIP) string {
+	fields := strings.Fields(s.GetFirstHeader("cseq"))
+	if len(fields) != 2 {
+		return ""
+	}
+
+	return strings.ToUpper(fields[1])
+}
+
+// sdpMedia parses the audio endpoints and payload types of a SDP body.
+func sdpMedia(body []byte) (endpoints []string, codecs map[byte]rtpCodec) {
+	type sdpAudio struct {
+		port string
+		addr string
+	}
+
+	var (
+		// connection address of the session, media can override it
+		session string
+		audio   []*sdpAudio
+		current *sdpAudio
+	)
+
+	codecs = make(map[byte]rtpCodec)
+
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+
+		value := line[2:]
+
+		switch line[0] {
+		case 'c':
+			// c=IN IP4 192.0.2.1
+			fields := strings.Fields(value)
+			if len(fields) != 3 {
+				continue
+			}
+
+			if current != nil {
+				current.addr = fields[2]
+			} else {
+				session = fields[2]
+			}
+		case 'm':
+			// m=audio 49170 RTP/AVP 0 8 101
+			current = nil
+
+			fields := strings.Fields(value)
+			if len(fields) < 4 || fields[0] != "audio" || fields[1] == "0" {
+				continue
+			}
+
+			current = &sdpAudio{port: fields[1]}
+			audio = append(audio, current)
+
+			for _, f := range fields[3:] {
+				pt, err := strconv.Atoi(f)
+				if err != nil {
+					continue
+				}
+
+				if c, ok := rtpStaticCodecs[byte(pt)]; ok {
+					codecs[byte(pt)] = c
+				}
+			}
+		case 'a':
+			// a=rtpmap:101 telephone-event/8000
+			if !strings.HasPrefix(value, "rtpmap:") {
+				continue
+			}
+
+			fields := strings.Fields(strings.TrimPrefix(value, "rtpmap:"))
+			if len(fields) != 2 {
+				continue
+			}
+
+			pt, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+
+			parts := strings.Split(fields[1], "/")
+			if len(parts) < 2 {
+				continue
+			}
+
+			rate, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+
+			codecs[byte(pt)] = rtpCodec{name: parts[0], clockRate: rate}
+		}
+	}
+
+	for _, a := range audio {
+		addr := a.addr
+		if addr == "" {
+			addr = session
+		}
+
+		if addr != "" {
+			endpoints = append(endpoints, net.JoinHostPort(addr, a.port))
+		}
+	}
+
+	return endpoints, codecs
+}
+
+// addMedia registers the RTP and RTCP endpoints of a SDP body for the call.
+// the state must be locked by the caller.
+func (c *voipCall) addMedia(body []byte) {
+	endpoints, codecs := sdpMedia(body)
+
+	for pt, codec := range codecs {
+		c.codecs[pt] = codec
+	}
+
+	for _, e := range endpoints {
+		host, port, err := net.SplitHostPort(e)
+		if err != nil {
+			continue
+		}
+
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+
+		rtcp := net.JoinHostPort(host, strconv.Itoa(p+1))
+
+		voipState.media[e] = &voipMedia{call: c}
+		voipState.media[rtcp] = &voipMedia{call: c, rtcp: true}
+		c.endpoints = append(c.endpoints, e, rtcp)
+	}
+}
+
+// handleSIP updates the call of a SIP message and returns the record once the call has ended.
+func handleSIP(s *layers.SIP, srcIP, dstIP string, ts time.Time) *types.Call {
+	callID := s.GetCallID()
+	if callID == "" {
+		return nil
+	}
+
+	voipState.Lock()
+	defer voipState.Unlock()
+
+	c, exists := voipState.calls[callID]
+
+	if !s.IsResponse {
+		switch s.Method {
+		case layers.SIPMethodInvite:
+			if !exists {
+				c = &voipCall{
+					record: &types.Call{
+						Timestamp: utils.TimeToString(ts),
+						CallID:    callID,
+						From:      sipURI(s.GetFrom()),
+						To:        sipURI(s.GetTo()),
+						CallerIP:  srcIP,
+						CalleeIP:  dstIP,
+						UserAgent: s.GetUserAgent(),
+					},
+					invite:  ts,
+					codecs:  make(map[byte]rtpCodec),
+					streams: make(map[string]*rtpStream),
+				}
+				voipState.calls[callID] = c
+			}
+
+			// re-INVITEs can move the media
+			c.addMedia(s.LayerPayload())
+		case layers.SIPMethodBye:
+			if exists && c.hangup.IsZero() {
+				c.hangup = ts
+			}
+		case layers.SIPMethodCancel:
+			if exists {
+				c.record.Notes = addInfo(c.record.Notes, "cancelled")
+			}
+		}
+
+		if exists {
+			c.lastSeen = ts
+		}
+
+		return nil
+	}
+
+	if !exists {
+		return nil
+	}
+
+	c.lastSeen = ts
+
+	switch sipCSeqMethod(s) {
+	case "INVITE":
+		if s.ResponseCode < 200 || c.record.Status != "" {
+			// provisional responses and responses to re-INVITEs can carry media
+			c.addMedia(s.LayerPayload())
+
+			return nil
+		}
+
+		c.record.Status = strconv.Itoa(s.ResponseCode) + " " + s.ResponseStatus
+		c.record.SetupTime = ts.Sub(c.invite).Nanoseconds()
+
+		if s.ResponseCode < 300 {
+			c.answer = ts
+			c.addMedia(s.LayerPayload())
+
+			return nil
+		}
+
+		// the call failed
+		return c.finish()
+	case "BYE":
+		return c.finish()
+	}
+
+	return nil
+}
+
+// handleRTP adds a RTP or RTCP packet to the call that negotiated the endpoint.
+func handleRTP(data []byte, src, dst string, ts time.Time) {
+	voipState.Lock()
+	defer voipState.Unlock()
+
+	m, ok := voipState.media[dst]
+	if !ok {
+		// the sender is behind NAT and its packets are addressed to the announced endpoint of the peer only
+		if m, ok = voipState.media[src]; !ok {
+			return
+		}
+	}
+
+	c := m.call
+	c.lastSeen = ts
+
+	if m.rtcp || isRTCP(data) {
+		c.record.RTCPPackets++
+
+		return
+	}
+
+	pkt, err := parseRTP(data)
+	if err != nil {
+		return
+	}
+
+	id := src + "->" + dst
+
+	s, exists := c.streams[id]
+	if !exists {
+		codec, known := c.codecs[pkt.payloadType]
+		if !known {
+			codec = rtpStaticCodecs[pkt.payloadType]
+		}
+
+		s = &rtpStream{src: src, dst: dst, codec: codec}
+		c.streams[id] = s
+	}
+
+	c.record.RTPPackets++
+	s.update(pkt, ts)
+}
+
+// finish summarizes the media streams, writes the decoded audio and removes the call.
+// the state must be locked by the caller.
+func (c *voipCall) finish() *types.Call {
+	r := c.record
+
+	delete(voipState.calls, r.CallID)
+
+	for _, e := range c.endpoints {
+		if m, ok := voipState.media[e]; ok && m.call == c {
+			delete(voipState.media, e)
+		}
+	}
+
+	if !c.answer.IsZero() {
+		end := c.hangup
+		if end.IsZero() {
+			end = c.lastSeen
+			r.Notes = addInfo(r.Notes, "no BYE")
+		}
+
+		r.Duration = int64(end.Sub(c.answer).Seconds())
+	}
+
+	ids := make([]string, 0, len(c.streams))
+	for id := range c.streams {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		s := c.streams[id]
+
+		name := s.codec.name
+		if name == "" {
+			name = "unknown"
+		}
+
+		r.Codecs = appendUnique(r.Codecs, name)
+		r.MediaStreams = append(r.MediaStreams, id+" "+name)
+		r.Lost += s.lost()
+
+		if j := s.jitterMillis(); j > r.Jitter {
+			r.Jitter = j
+		}
+
+		if mos := s.mos(); r.MOS == 0 || mos < r.MOS {
+			r.MOS = mos
+		}
+
+		if s.audioTruncated {
+			r.Notes = addInfo(r.Notes, "audio of "+id+" truncated")
+		}
+
+		if len(s.audio) > 0 {
+			if location := c.saveAudio(s); location != "" {
+				r.Files = append(r.Files, location)
+			}
+		}
+	}
+
+	return r
+}
+
+// saveAudio writes the decoded audio of a stream as WAV file into the FileStorage.
+func (c *voipCall) saveAudio(s *rtpStream) string {
+	var (
+		srcIP, srcPort, _ = net.SplitHostPort(s.src)
+		dstIP, dstPort, _ = net.SplitHostPort(s.dst)
+		f                 = &types.File{
+			Timestamp: utils.TimeToString(s.first),
+			Name:      c.record.CallID,
+			Ident:     fmt.Sprintf("%08x", s.ssrc),
+			Source:    serviceVoIP + " " + s.codec.name + " " + s.src + "->" + s.dst,
+			Host:      c.record.To,
+			Context: &types.PacketContext{
+				SrcIP:   srcIP,
+				DstIP:   dstIP,
+				SrcPort: srcPort,
+				DstPort: dstPort,
+			},
+		}
+	)
+
+	err := saveExtractedFile(f, wavFile(s.audio, g711ClockRate))
+	if err != nil {
+		logReassemblyError("VoIP-save", "%s: failed to save audio: %s\n", c.record.CallID, err)
+	}
+
+	return f.Location
+}
+
+var callDecoder = newCustomDecoder(
+	types.Type_NC_Call,
+	"Call",
+	"A Call summarizes a SIP dialog with the quality and the audio of its RTP streams",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		l := innerLayers(p)
+		if l.network == nil {
+			return nil
+		}
+
+		udp, ok := l.transport.(*layers.UDP)
+		if !ok {
+			return nil
+		}
+
+		var (
+			nf = l.network.NetworkFlow()
+			ts = p.Metadata().Timestamp
+		)
+
+		if sipLayer := p.Layer(layers.LayerTypeSIP); sipLayer != nil {
+			if s, isSIP := sipLayer.(*layers.SIP); isSIP {
+				if r := handleSIP(s, nf.Src().String(), nf.Dst().String(), ts); r != nil {
+					return r
+				}
+			}
+
+			return nil
+		}
+
+		handleRTP(
+			udp.Payload,
+			net.JoinHostPort(nf.Src().String(), strconv.Itoa(int(udp.SrcPort))),
+			net.JoinHostPort(nf.Dst().String(), strconv.Itoa(int(udp.DstPort))),
+			ts,
+		)
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		// write calls without hangup
+		voipState.Lock()
+		defer voipState.Unlock()
+
+		for _, c := range voipState.calls {
+			e.write(c.finish())
+		}
+
+		return nil
+	},
+)
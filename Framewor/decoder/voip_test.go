@@ -0,0 +1,226 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+var voipTestStart = time.Unix(1600000000, 0)
+
+func voipTestPacket(t *testing.T, src, dst net.IP, srcPort, dstPort layers.UDPPort, payload []byte, ts time.Time) gopacket.Packet {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+	udp := &layers.UDP{SrcPort: srcPort, DstPort: dstPort}
+	_ = udp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip, udp, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	p.Metadata().Timestamp = ts
+
+	return p
+}
+
+func voipTestSIP(firstLine, cseq, body string) []byte {
+	msg := firstLine + "\r\n" +
+		"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds\r\n" +
+		"From: \"Alice\" <sip:alice@example.com>;tag=1928301774\r\n" +
+		"To: <sip:bob@example.com>\r\n" +
+		"Call-ID: a84b4c76e66710@10.0.0.1\r\n" +
+		"CSeq: " + cseq + "\r\n" +
+		"User-Agent: softphone 1.0\r\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+
+	return []byte(msg)
+}
+
+func voipTestRTP(seq uint16, timestamp uint32, payload byte) []byte {
+	b := make([]byte, rtpHeaderLen, rtpHeaderLen+160)
+	b[0], b[1] = 0x80, rtpPayloadPCMU
+	binary.BigEndian.PutUint16(b[2:4], seq)
+	binary.BigEndian.PutUint32(b[4:8], timestamp)
+	binary.BigEndian.PutUint32(b[8:12], 0xdeadbeef)
+
+	for i := 0; i < 160; i++ {
+		b = append(b, payload)
+	}
+
+	return b
+}
+
+func TestCall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		fileRecords = &recordCollector{}
+		oldConf     = conf
+		oldFile     = fileDecoderInstance
+		alice       = net.IP{10, 0, 0, 1}
+		bob         = net.IP{10, 0, 0, 2}
+		handle      = func(p gopacket.Packet) *types.Call {
+			nf := p.NetworkLayer().NetworkFlow()
+			udp := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+
+			if s, ok := p.Layer(layers.LayerTypeSIP).(*layers.SIP); ok {
+				return handleSIP(s, nf.Src().String(), nf.Dst().String(), p.Metadata().Timestamp)
+			}
+
+			handleRTP(udp.Payload, net.JoinHostPort(nf.Src().String(), strconv.Itoa(int(udp.SrcPort))), net.JoinHostPort(nf.Dst().String(), strconv.Itoa(int(udp.DstPort))), p.Metadata().Timestamp)
+
+			return nil
+		}
+	)
+
+	defer func() {
+		conf = oldConf
+		fileDecoderInstance = oldFile
+	}()
+
+	conf = &Config{FileStorage: dir}
+	fileDecoderInstance = &customDecoder{writer: fileRecords}
+
+	offer := "v=0\r\no=alice 1 1 IN IP4 10.0.0.1\r\nc=IN IP4 10.0.0.1\r\nm=audio 40000 RTP/AVP 0 101\r\na=rtpmap:101 telephone-event/8000\r\n"
+	answer := "v=0\r\no=bob 1 1 IN IP4 10.0.0.2\r\nm=audio 50000 RTP/AVP 0\r\nc=IN IP4 10.0.0.2\r\n"
+
+	packets := []gopacket.Packet{
+		voipTestPacket(t, alice, bob, 5060, 5060, voipTestSIP("INVITE sip:bob@example.com SIP/2.0", "1 INVITE", offer), voipTestStart),
+		voipTestPacket(t, bob, alice, 5060, 5060, voipTestSIP("SIP/2.0 180 Ringing", "1 INVITE", ""), voipTestStart.Add(100*time.Millisecond)),
+		voipTestPacket(t, bob, alice, 5060, 5060, voipTestSIP("SIP/2.0 200 OK", "1 INVITE", answer), voipTestStart.Add(time.Second)),
+	}
+
+	// 20ms of audio per packet, the fifth packet is lost
+	for i := 0; i < 10; i++ {
+		if i == 4 {
+			continue
+		}
+
+		packets = append(packets, voipTestPacket(t, alice, bob, 40000, 50000, voipTestRTP(uint16(100+i), uint32(160*i), 0xff), voipTestStart.Add(time.Second+time.Duration(i)*20*time.Millisecond)))
+	}
+
+	packets = append(packets,
+		voipTestPacket(t, bob, alice, 50001, 40001, []byte{0x80, 200, 0, 6, 0, 0, 0, 1}, voipTestStart.Add(2*time.Second)),
+		voipTestPacket(t, alice, bob, 5060, 5060, voipTestSIP("BYE sip:bob@example.com SIP/2.0", "2 BYE", ""), voipTestStart.Add(31*time.Second)),
+	)
+
+	for _, p := range packets {
+		if r := handle(p); r != nil {
+			t.Fatal("unexpected record before hangup:", r)
+		}
+	}
+
+	r := handle(voipTestPacket(t, bob, alice, 5060, 5060, voipTestSIP("SIP/2.0 200 OK", "2 BYE", ""), voipTestStart.Add(31*time.Second+10*time.Millisecond)))
+	if r == nil {
+		t.Fatal("expected call record")
+	}
+
+	if r.From != "sip:alice@example.com" || r.To != "sip:bob@example.com" || r.CallerIP != "10.0.0.1" || r.UserAgent != "softphone 1.0" {
+		t.Fatal("unexpected parties:", r)
+	}
+
+	if r.Status != "200 OK" || r.SetupTime != int64(time.Second) || r.Duration != 30 {
+		t.Fatal("unexpected call state:", r)
+	}
+
+	if r.RTPPackets != 9 || r.RTCPPackets != 1 || r.Lost != 1 || strings.Join(r.MediaStreams, ",") != "10.0.0.1:40000->10.0.0.2:50000 PCMU" {
+		t.Fatal("unexpected media:", r)
+	}
+
+	// 10% loss degrades the call noticeably
+	if r.MOS < 3.4 || r.MOS > 3.6 || r.Jitter != 0 {
+		t.Fatal("unexpected quality:", r.MOS, r.Jitter)
+	}
+
+	if len(r.Files) != 1 || len(fileRecords.records) != 1 {
+		t.Fatal("expected decoded audio, got:", r.Files)
+	}
+
+	wav, err := ioutil.ReadFile(r.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 packets including the lost one, 160 samples of 16 bit each
+	if string(wav[:4]) != "RIFF" || string(wav[8:12]) != "WAVE" || len(wav) != 44+10*160*2 {
+		t.Fatal("unexpected WAV file of size", len(wav))
+	}
+
+	voipState.Lock()
+	calls, media := len(voipState.calls), len(voipState.media)
+	voipState.Unlock()
+
+	if calls != 0 || media != 0 {
+		t.Fatal("expected call state to be removed, got:", calls, media)
+	}
+}
+
+func TestG711(t *testing.T) {
+	for _, tc := range []struct {
+		sample   byte
+		alaw     bool
+		expected int16
+	}{
+		{0xff, false, 0},
+		{0x80, false, 32124},
+		{0x00, false, -32124},
+		{0xd5, true, 8},
+		{0xaa, true, 32256},
+		{0x2a, true, -32256},
+	} {
+		var v int16
+		if tc.alaw {
+			v = alawToLinear(tc.sample)
+		} else {
+			v = ulawToLinear(tc.sample)
+		}
+
+		if v != tc.expected {
+			t.Fatalf("sample %x: expected %d, got %d", tc.sample, tc.expected, v)
+		}
+	}
+}
+
+func TestRTPStreamAudioLimit(t *testing.T) {
+	var (
+		s  = &rtpStream{}
+		ts = time.Unix(1600000000, 0)
+	)
+
+	// every packet skips many sequence numbers and adds the maximum amount of silence
+	for i := 0; i < 2*rtpMaxAudioSize/(2*rtpMaxGapSamples); i++ {
+		s.update(&rtpPacket{payloadType: rtpPayloadPCMU, seq: uint16(i * 1000), payload: make([]byte, 160)}, ts)
+	}
+
+	if !s.audioTruncated || len(s.audio) > rtpMaxAudioSize {
+		t.Fatal("expected the audio to be truncated, got", len(s.audio), "bytes")
+	}
+}
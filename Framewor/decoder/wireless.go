@@ -0,0 +1,672 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/resolvers"
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * IEEE 802.11 - wireless site survey
+ */
+
+const (
+	// information element ids
+	dot11ElementSSID   = 0
+	dot11ElementDSSet  = 3
+	dot11ElementRSN    = 48
+	dot11ElementVendor = 221
+
+	// privacy bit of the capability information
+	dot11CapabilityPrivacy = 0x0010
+
+	// vendor specific element types of Microsoft
+	dot11VendorTypeWPA = 1
+	dot11VendorTypeWPS = 4
+)
+
+var (
+	dot11OUIMicrosoft = []byte{0x00, 0x50, 0xf2}
+	dot11OUIIEEE      = []byte{0x00, 0x0f, 0xac}
+
+	dot11Ciphers = map[byte]string{
+		1:  "WEP-40",
+		2:  "TKIP",
+		4:  "CCMP",
+		5:  "WEP-104",
+		6:  "BIP-CMAC-128",
+		8:  "GCMP-128",
+		9:  "GCMP-256",
+		10: "CCMP-256",
+	}
+
+	dot11AKMs = map[byte]string{
+		1:  "802.1X",
+		2:  "PSK",
+		3:  "FT-802.1X",
+		4:  "FT-PSK",
+		5:  "802.1X-SHA256",
+		6:  "PSK-SHA256",
+		8:  "SAE",
+		9:  "FT-SAE",
+		11: "802.1X-SuiteB",
+		12: "802.1X-SuiteB-192",
+		18: "OWE",
+		24: "SAE-EXT",
+	}
+)
+
+// dot11Element is an information element of a management frame.
+type dot11Element struct {
+	id   byte
+	data []byte
+}
+
+// dot11Elements decodes the information elements of a management frame body.
+func dot11Elements(b []byte) (elements []dot11Element) {
+	for len(b) >= 2 {
+		size := int(b[1])
+		if 2+size > len(b) {
+			break
+		}
+
+		elements = append(elements, dot11Element{id: b[0], data: b[2 : 2+size]})
+		b = b[2+size:]
+	}
+
+	return elements
+}
+
+// dot11SSID returns the network name advertised by the elements.
+// hidden networks announce an empty name or a name consisting of null bytes.
+func dot11SSID(elements []dot11Element) (ssid string, hidden bool) {
+	for _, e := range elements {
+		if e.id != dot11ElementSSID {
+			continue
+		}
+
+		if len(strings.Trim(string(e.data), "\x00")) == 0 {
+			return "", true
+		}
+
+		return string(e.data), false
+	}
+
+	return "", true
+}
+
+// dot11Suites decodes a count prefixed list of cipher or AKM suites.
+func dot11Suites(b []byte, oui []byte, names map[byte]string) (suites []string, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return nil, b, false
+	}
+
+	n := int(binary.LittleEndian.Uint16(b))
+	b = b[2:]
+
+	if len(b) < 4*n {
+		return nil, b, false
+	}
+
+	for i := 0; i < n; i++ {
+		suites = append(suites, dot11Suite(b[4*i:4*i+4], oui, names))
+	}
+
+	return suites, b[4*n:], true
+}
+
+// dot11Suite returns the name of a cipher or AKM suite selector.
+func dot11Suite(b []byte, oui []byte, names map[byte]string) string {
+	if string(b[:3]) == string(oui) {
+		if name, ok := names[b[3]]; ok {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("%x-%d", b[:3], b[3])
+}
+
+// dot11Security contains the protection announced by an access point.
+type dot11Security struct {
+	wpa     bool
+	rsn     bool
+	wps     bool
+	ciphers []string
+	akms    []string
+	vendors []string
+}
+
+// parseSecurityElement decodes the body of a RSN or WPA element, both share the same layout after the version.
+func (s *dot11Security) parseSecurityElement(b []byte, oui []byte) {
+	// version
+	if len(b) < 2+4 {
+		return
+	}
+
+	group := dot11Suite(b[2:6], oui, dot11Ciphers)
+	s.ciphers = appendUnique(s.ciphers, group)
+
+	pairwise, rest, ok := dot11Suites(b[6:], oui, dot11Ciphers)
+	if !ok {
+		return
+	}
+
+	for _, c := range pairwise {
+		s.ciphers = appendUnique(s.ciphers, c)
+	}
+
+	akms, _, ok := dot11Suites(rest, oui, dot11AKMs)
+	if !ok {
+		return
+	}
+
+	for _, a := range akms {
+		s.akms = appendUnique(s.akms, a)
+	}
+}
+
+// parseDot11Security evaluates the security elements of a beacon or probe response.
+func parseDot11Security(elements []dot11Element) *dot11Security {
+	s := &dot11Security{}
+
+	for _, e := range elements {
+		switch e.id {
+		case dot11ElementRSN:
+			s.rsn = true
+			s.parseSecurityElement(e.data, dot11OUIIEEE)
+		case dot11ElementVendor:
+			if len(e.data) < 4 {
+				continue
+			}
+
+			s.vendors = appendUnique(s.vendors, fmt.Sprintf("%x-%d", e.data[:3], e.data[3]))
+
+			if string(e.data[:3]) != string(dot11OUIMicrosoft) {
+				continue
+			}
+
+			switch e.data[3] {
+			case dot11VendorTypeWPA:
+				s.wpa = true
+				s.parseSecurityElement(e.data[4:], dot11OUIMicrosoft)
+			case dot11VendorTypeWPS:
+				s.wps = true
+			}
+		}
+	}
+
+	return s
+}
+
+// name summarizes the protection of the network.
+func (s *dot11Security) name(capabilities uint16) string {
+	var (
+		sae        = containsString(s.akms, "SAE") || containsString(s.akms, "FT-SAE") || containsString(s.akms, "SAE-EXT") || containsString(s.akms, "OWE")
+		psk        = containsString(s.akms, "PSK") || containsString(s.akms, "FT-PSK") || containsString(s.akms, "PSK-SHA256")
+		enterprise = false
+		versions   []string
+	)
+
+	for _, a := range s.akms {
+		if strings.HasPrefix(a, "802.1X") || strings.HasPrefix(a, "FT-802.1X") {
+			enterprise = true
+		}
+	}
+
+	if s.wpa {
+		versions = append(versions, "WPA")
+	}
+
+	if s.rsn && (psk || enterprise || !sae) {
+		versions = append(versions, "WPA2")
+	}
+
+	if s.rsn && sae {
+		versions = append(versions, "WPA3")
+	}
+
+	if len(versions) == 0 {
+		if capabilities&dot11CapabilityPrivacy != 0 {
+			return "WEP"
+		}
+
+		return "Open"
+	}
+
+	if enterprise {
+		return strings.Join(versions, "/") + "-Enterprise"
+	}
+
+	return strings.Join(versions, "/")
+}
+
+// appendUnique appends the value if not yet present.
+func appendUnique(values []string, value string) []string {
+	if containsString(values, value) {
+		return values
+	}
+
+	return append(values, value)
+}
+
+// dot11Channel converts a frequency in MHz into the channel number.
+func dot11Channel(freq int32) int32 {
+	switch {
+	case freq == 2484:
+		return 14
+	case freq >= 2412 && freq < 2484:
+		return (freq - 2407) / 5
+	case freq >= 5000 && freq < 5900:
+		return (freq - 5000) / 5
+	case freq > 5950 && freq <= 7125:
+		return (freq - 5950) / 5
+	}
+
+	return 0
+}
+
+// dot11Radio returns the signal strength and frequency reported by the radiotap header, if present.
+func dot11Radio(p gopacket.Packet) (signal int32, freq int32, ok bool) {
+	rl := p.Layer(layers.LayerTypeRadioTap)
+	if rl == nil {
+		return 0, 0, false
+	}
+
+	rt, isRadioTap := rl.(*layers.RadioTap)
+	if !isRadioTap {
+		return 0, 0, false
+	}
+
+	if rt.Present.Channel() {
+		freq = int32(rt.ChannelFrequency)
+	}
+
+	if rt.Present.DBMAntennaSignal() {
+		return int32(rt.DBMAntennaSignal), freq, true
+	}
+
+	return 0, freq, false
+}
+
+// isDot11Unicast checks if the address belongs to a single station.
+func isDot11Unicast(addr net.HardwareAddr) bool {
+	return len(addr) == 6 && addr[0]&0x01 == 0
+}
+
+// updateSignal keeps the strongest signal, zero means no measurement so far.
+func updateSignal(current *int32, signal int32) {
+	if *current == 0 || signal > *current {
+		*current = signal
+	}
+}
+
+/*
+ * Access points
+ */
+
+type wirelessNetwork struct {
+	*types.WirelessNetwork
+	capabilities uint16
+	sync.Mutex
+}
+
+// wirelessNetworks holds all access points seen, by BSSID.
+var wirelessNetworks = struct {
+	Items map[string]*wirelessNetwork
+	sync.Mutex
+}{
+	Items: make(map[string]*wirelessNetwork),
+}
+
+// handleWirelessNetworkPacket updates the access point inventory from beacons, probe responses and data frames.
+func handleWirelessNetworkPacket(p gopacket.Packet) {
+	dl := p.Layer(layers.LayerTypeDot11)
+	if dl == nil {
+		return
+	}
+
+	dot11, ok := dl.(*layers.Dot11)
+	if !ok {
+		return
+	}
+
+	ts := utils.TimeToString(p.Metadata().Timestamp)
+
+	var (
+		body         []byte
+		capabilities uint16
+		beacon       bool
+	)
+
+	switch dot11.Type {
+	case layers.Dot11TypeMgmtBeacon:
+		if b, isBeacon := p.Layer(layers.LayerTypeDot11MgmtBeacon).(*layers.Dot11MgmtBeacon); isBeacon {
+			body, capabilities, beacon = b.Payload, b.Flags, true
+		}
+	case layers.Dot11TypeMgmtProbeResp:
+		if r, isResp := p.Layer(layers.LayerTypeDot11MgmtProbeResp).(*layers.Dot11MgmtProbeResp); isResp {
+			body, capabilities = r.Payload, r.Flags
+		}
+	default:
+		// stations sending data to an access point
+		if dot11.Type.MainType() == layers.Dot11TypeData && dot11.Flags.ToDS() && !dot11.Flags.FromDS() && isDot11Unicast(dot11.Address2) {
+			wirelessNetworks.Lock()
+			n, exists := wirelessNetworks.Items[dot11.Address1.String()]
+			wirelessNetworks.Unlock()
+
+			if exists {
+				n.Lock()
+				n.Clients = appendUnique(n.Clients, dot11.Address2.String())
+				n.LastSeen = ts
+				n.Unlock()
+			}
+		}
+
+		return
+	}
+
+	if body == nil || len(dot11.Address3) != 6 {
+		return
+	}
+
+	var (
+		bssid        = dot11.Address3.String()
+		elements     = dot11Elements(body)
+		sec          = parseDot11Security(elements)
+		ssid, hidden = dot11SSID(elements)
+	)
+
+	wirelessNetworks.Lock()
+	n, exists := wirelessNetworks.Items[bssid]
+	if !exists {
+		n = &wirelessNetwork{
+			WirelessNetwork: &types.WirelessNetwork{
+				Timestamp: ts,
+				BSSID:     bssid,
+				Vendor:    resolvers.LookupManufacturer(bssid),
+				Hidden:    true,
+			},
+		}
+		wirelessNetworks.Items[bssid] = n
+	}
+	wirelessNetworks.Unlock()
+
+	n.Lock()
+	defer n.Unlock()
+
+	n.LastSeen = ts
+
+	if beacon {
+		n.Beacons++
+	} else {
+		n.ProbeResponses++
+	}
+
+	// probe responses reveal the names of hidden networks
+	if !hidden {
+		if n.SSID != "" && n.SSID != ssid {
+			n.Notes = addInfo(n.Notes, "SSID changed from "+n.SSID)
+		}
+
+		n.SSID = ssid
+	}
+
+	if beacon {
+		n.Hidden = hidden
+	}
+
+	n.capabilities |= capabilities
+	n.WPS = n.WPS || sec.wps
+	n.Security = sec.name(n.capabilities)
+
+	for _, c := range sec.ciphers {
+		n.Ciphers = appendUnique(n.Ciphers, c)
+	}
+
+	for _, a := range sec.akms {
+		n.AKMs = appendUnique(n.AKMs, a)
+	}
+
+	for _, v := range sec.vendors {
+		n.VendorIEs = appendUnique(n.VendorIEs, v)
+	}
+
+	for _, e := range elements {
+		if e.id == dot11ElementDSSet && len(e.data) == 1 {
+			n.Channel = int32(e.data[0])
+		}
+	}
+
+	signal, freq, ok := dot11Radio(p)
+	if ok {
+		updateSignal(&n.Signal, signal)
+	}
+
+	if freq != 0 {
+		n.Frequency = freq
+
+		if n.Channel == 0 {
+			n.Channel = dot11Channel(freq)
+		}
+	}
+}
+
+// flagRogueNetworks annotates access points that advertise the same SSID as others with a different security configuration,
+// which indicates an evil twin or a misconfigured access point.
+func flagRogueNetworks() {
+	bySSID := make(map[string][]*wirelessNetwork)
+
+	for _, n := range wirelessNetworks.Items {
+		if n.SSID != "" {
+			bySSID[n.SSID] = append(bySSID[n.SSID], n)
+		}
+	}
+
+	for _, networks := range bySSID {
+		for _, n := range networks {
+			var others []string
+
+			for _, o := range networks {
+				if o != n && o.Security != n.Security {
+					others = append(others, o.BSSID+" ("+o.Security+")")
+				}
+			}
+
+			if len(others) > 0 {
+				sort.Strings(others)
+				n.Notes = addInfo(n.Notes, "SSID advertised with different security by "+strings.Join(others, ", "))
+			}
+		}
+	}
+}
+
+var wirelessNetworkDecoder = newCustomDecoder(
+	types.Type_NC_WirelessNetwork,
+	"WirelessNetwork",
+	"A WirelessNetwork summarizes an access point seen in 802.11 beacons and probe responses",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		handleWirelessNetworkPacket(p)
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		wirelessNetworks.Lock()
+		defer wirelessNetworks.Unlock()
+
+		flagRogueNetworks()
+
+		for _, n := range wirelessNetworks.Items {
+			e.write(n.WirelessNetwork)
+		}
+
+		return nil
+	},
+)
+
+/*
+ * Stations
+ */
+
+type wirelessClient struct {
+	*types.WirelessClient
+	sync.Mutex
+}
+
+// wirelessClients holds all stations seen, by MAC address.
+var wirelessClients = struct {
+	Items map[string]*wirelessClient
+	sync.Mutex
+}{
+	Items: make(map[string]*wirelessClient),
+}
+
+// getWirelessClient returns the station for the address and creates it if necessary.
+func getWirelessClient(mac net.HardwareAddr, ts string) *wirelessClient {
+	addr := mac.String()
+
+	wirelessClients.Lock()
+	defer wirelessClients.Unlock()
+
+	if c, ok := wirelessClients.Items[addr]; ok {
+		return c
+	}
+
+	c := &wirelessClient{
+		WirelessClient: &types.WirelessClient{
+			Timestamp:  ts,
+			MAC:        addr,
+			Vendor:     resolvers.LookupManufacturer(addr),
+			Randomized: mac[0]&0x02 != 0,
+		},
+	}
+	wirelessClients.Items[addr] = c
+
+	return c
+}
+
+// handleWirelessClientPacket updates the station inventory from probe requests, associations and data frames.
+func handleWirelessClientPacket(p gopacket.Packet) {
+	dl := p.Layer(layers.LayerTypeDot11)
+	if dl == nil {
+		return
+	}
+
+	dot11, ok := dl.(*layers.Dot11)
+	if !ok {
+		return
+	}
+
+	var (
+		body  []byte
+		bssid string
+		ts    = utils.TimeToString(p.Metadata().Timestamp)
+	)
+
+	switch {
+	case dot11.Type == layers.Dot11TypeMgmtProbeReq:
+		// the elements of probe requests are kept as the layer contents
+		if r, isReq := p.Layer(layers.LayerTypeDot11MgmtProbeReq).(*layers.Dot11MgmtProbeReq); isReq {
+			body = r.Contents
+		}
+	case dot11.Type == layers.Dot11TypeMgmtAssociationReq:
+		if r, isReq := p.Layer(layers.LayerTypeDot11MgmtAssociationReq).(*layers.Dot11MgmtAssociationReq); isReq {
+			body, bssid = r.Payload, dot11.Address1.String()
+		}
+	case dot11.Type == layers.Dot11TypeMgmtReassociationReq:
+		if r, isReq := p.Layer(layers.LayerTypeDot11MgmtReassociationReq).(*layers.Dot11MgmtReassociationReq); isReq {
+			body, bssid = r.Payload, dot11.Address1.String()
+		}
+	case dot11.Type.MainType() == layers.Dot11TypeData && dot11.Flags.ToDS() && !dot11.Flags.FromDS():
+		// frames from the station to the distribution system are addressed to the access point
+		bssid = dot11.Address1.String()
+	default:
+		return
+	}
+
+	if !isDot11Unicast(dot11.Address2) || (body == nil && bssid == "") {
+		return
+	}
+
+	c := getWirelessClient(dot11.Address2, ts)
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.LastSeen = ts
+	c.Frames++
+
+	if bssid != "" {
+		c.BSSID = bssid
+	}
+
+	if signal, _, ok := dot11Radio(p); ok {
+		updateSignal(&c.Signal, signal)
+	}
+
+	if body == nil {
+		return
+	}
+
+	ssid, hidden := dot11SSID(dot11Elements(body))
+	if hidden {
+		// wildcard probe
+		return
+	}
+
+	if dot11.Type == layers.Dot11TypeMgmtProbeReq {
+		c.ProbedSSIDs = appendUnique(c.ProbedSSIDs, ssid)
+	} else {
+		c.SSID = ssid
+	}
+}
+
+var wirelessClientDecoder = newCustomDecoder(
+	types.Type_NC_WirelessClient,
+	"WirelessClient",
+	"A WirelessClient summarizes a station seen in 802.11 probe requests, associations and data frames",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		handleWirelessClientPacket(p)
+
+		return nil
+	},
+	func(e *customDecoder) error {
+		wirelessClients.Lock()
+		defer wirelessClients.Unlock()
+
+		for _, c := range wirelessClients.Items {
+			e.write(c.WirelessClient)
+		}
+
+		return nil
+	},
+)
@@ -0,0 +1,148 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+)
+
+var (
+	wirelessTestAP     = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	wirelessTestTwin   = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}
+	wirelessTestClient = net.HardwareAddr{0xda, 0xa1, 0x19, 0x00, 0x00, 0x01}
+	wirelessTestBcast  = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// wirelessTestFrame builds a radiotap header with channel and signal, followed by an 802.11 frame and a zero checksum.
+func wirelessTestFrame(t *testing.T, fc, flags byte, addr1, addr2, addr3 net.HardwareAddr, signal int8, body ...byte) gopacket.Packet {
+	data := []byte{
+		0, 0, 13, 0, // version, padding and length
+		0x28, 0, 0, 0, // channel and antenna signal present
+		0x85, 0x09, 0xa0, 0x00, // 2437 MHz
+		byte(signal),
+		fc, flags, 0, 0,
+	}
+
+	data = append(data, addr1...)
+	data = append(data, addr2...)
+	data = append(data, addr3...)
+	data = append(data, 0, 0)
+	data = append(data, body...)
+	data = append(data, 0, 0, 0, 0)
+
+	p := gopacket.NewPacket(data, layers.LayerTypeRadioTap, gopacket.Default)
+	p.Metadata().Timestamp = time.Unix(1600000000, 0)
+
+	if p.Layer(layers.LayerTypeDot11) == nil {
+		t.Fatal("failed to decode 802.11 frame:", p.ErrorLayer())
+	}
+
+	return p
+}
+
+func wirelessTestBeacon(capabilities byte, elements ...[]byte) []byte {
+	b := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x64, 0, capabilities, 0}
+
+	for _, e := range elements {
+		b = append(b, e...)
+	}
+
+	return b
+}
+
+func wirelessTestElement(id byte, data ...byte) []byte {
+	return append([]byte{id, byte(len(data))}, data...)
+}
+
+func TestWirelessInventory(t *testing.T) {
+	defer func() {
+		wirelessNetworks.Lock()
+		wirelessNetworks.Items = make(map[string]*wirelessNetwork)
+		wirelessNetworks.Unlock()
+
+		wirelessClients.Lock()
+		wirelessClients.Items = make(map[string]*wirelessClient)
+		wirelessClients.Unlock()
+	}()
+
+	var (
+		ssid = wirelessTestElement(dot11ElementSSID, []byte("corp")...)
+		rsn  = wirelessTestElement(dot11ElementRSN,
+			1, 0, // version
+			0x00, 0x0f, 0xac, 4, // group cipher
+			1, 0, 0x00, 0x0f, 0xac, 4, // pairwise ciphers
+			1, 0, 0x00, 0x0f, 0xac, 2, // AKMs
+			0, 0,
+		)
+		wps     = wirelessTestElement(dot11ElementVendor, 0x00, 0x50, 0xf2, 4, 0x10, 0x4a)
+		packets = []gopacket.Packet{
+			wirelessTestFrame(t, 0x80, 0, wirelessTestBcast, wirelessTestAP, wirelessTestAP, -40, wirelessTestBeacon(0x11, ssid, wirelessTestElement(dot11ElementDSSet, 6), rsn, wps)...),
+			wirelessTestFrame(t, 0x80, 0, wirelessTestBcast, wirelessTestAP, wirelessTestAP, -35, wirelessTestBeacon(0x11, ssid, wirelessTestElement(dot11ElementDSSet, 6), rsn, wps)...),
+			wirelessTestFrame(t, 0x80, 0, wirelessTestBcast, wirelessTestTwin, wirelessTestTwin, -70, wirelessTestBeacon(0x01, ssid)...),
+			wirelessTestFrame(t, 0x40, 0, wirelessTestBcast, wirelessTestClient, wirelessTestBcast, -60, wirelessTestElement(dot11ElementSSID, []byte("home")...)...),
+			wirelessTestFrame(t, 0x40, 0, wirelessTestBcast, wirelessTestClient, wirelessTestBcast, -60, wirelessTestElement(dot11ElementSSID)...),
+			wirelessTestFrame(t, 0x08, 0x01, wirelessTestAP, wirelessTestClient, wirelessTestAP, -50, 0xaa, 0xaa, 0x03, 0, 0, 0, 0x08, 0x00),
+		}
+	)
+
+	for _, p := range packets {
+		handleWirelessNetworkPacket(p)
+		handleWirelessClientPacket(p)
+	}
+
+	flagRogueNetworks()
+
+	n := wirelessNetworks.Items[wirelessTestAP.String()]
+	if n == nil || n.SSID != "corp" || n.Hidden || n.Channel != 6 || n.Frequency != 2437 || n.Security != "WPA2" || !n.WPS || n.Beacons != 2 || n.Signal != -35 {
+		t.Fatal("unexpected network:", n)
+	}
+
+	if strings.Join(n.Ciphers, ",") != "CCMP" || strings.Join(n.AKMs, ",") != "PSK" || strings.Join(n.Clients, ",") != wirelessTestClient.String() {
+		t.Fatal("unexpected network details:", n.Ciphers, n.AKMs, n.Clients)
+	}
+
+	if n.Notes != "SSID advertised with different security by 00:11:22:33:44:66 (Open)" {
+		t.Fatal("expected evil twin note, got:", n.Notes)
+	}
+
+	c := wirelessClients.Items[wirelessTestClient.String()]
+	if c == nil || !c.Randomized || strings.Join(c.ProbedSSIDs, ",") != "home" || c.BSSID != wirelessTestAP.String() || c.Signal != -50 || c.Frames != 3 {
+		t.Fatal("unexpected client:", c)
+	}
+}
+
+func TestDot11SecurityName(t *testing.T) {
+	for _, tc := range []struct {
+		sec      *dot11Security
+		privacy  uint16
+		expected string
+	}{
+		{&dot11Security{}, 0, "Open"},
+		{&dot11Security{}, dot11CapabilityPrivacy, "WEP"},
+		{&dot11Security{wpa: true, rsn: true, akms: []string{"PSK"}}, dot11CapabilityPrivacy, "WPA/WPA2"},
+		{&dot11Security{rsn: true, akms: []string{"PSK", "SAE"}}, dot11CapabilityPrivacy, "WPA2/WPA3"},
+		{&dot11Security{rsn: true, akms: []string{"SAE"}}, dot11CapabilityPrivacy, "WPA3"},
+		{&dot11Security{rsn: true, akms: []string{"802.1X"}}, dot11CapabilityPrivacy, "WPA2-Enterprise"},
+	} {
+		if name := tc.sec.name(tc.privacy); name != tc.expected {
+			t.Fatal("expected", tc.expected, "got", name)
+		}
+	}
+}
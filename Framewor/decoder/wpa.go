@@ -0,0 +1,414 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreadl0ck/gopacket"
+	"github.com/dreadl0ck/gopacket/layers"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/dreadl0ck/netcap/types"
+	"github.com/dreadl0ck/netcap/utils"
+)
+
+/*
+ * WPA - 4-way handshake and PMKID extraction
+ */
+
+const (
+	serviceWPA = "WPA"
+
+	hashcatModeWPA = 22000
+
+	// file name for the hashes in the output directory
+	wpaHashFile = "wpa.hc22000"
+
+	// offset and size of the MIC in an EAPOL-Key frame, including the 802.1X header
+	wpaMICOffset = 81
+	wpaMICSize   = 16
+
+	// hashcat message pairs
+	wpaMessagePairM1M2 = "00"
+	wpaMessagePairM2M3 = "02"
+)
+
+// RSN PMKID key data encapsulation
+var wpaPMKIDKDE = []byte{0xdd, 0x14, 0x00, 0x0f, 0xac, 0x04}
+
+// wpaMessage is a message of the 4-way handshake.
+type wpaMessage struct {
+	replay uint64
+	nonce  []byte
+	mic    []byte
+
+	// EAPOL frame with the MIC set to zero
+	eapol []byte
+}
+
+type wpaHandshake struct {
+	bssid  string
+	client string
+	ts     time.Time
+
+	m1, m2, m3 *wpaMessage
+	pmkid      []byte
+	messages   []string
+}
+
+// wpaState correlates the handshake messages of access point and client pairs.
+var wpaState = struct {
+	// bssid and client to handshakes
+	handshakes map[string]*wpaHandshake
+	// bssid to network names
+	ssids map[string]string
+	// hashcat lines
+	hashes []string
+	sync.Mutex
+}{
+	handshakes: make(map[string]*wpaHandshake),
+	ssids:      make(map[string]string),
+}
+
+// wpaMessageNumber identifies the handshake message by the key information flags.
+func wpaMessageNumber(k *layers.EAPOLKey) int {
+	if k.KeyType != layers.EAPOLKeyTypePairwise {
+		return 0
+	}
+
+	switch {
+	case k.KeyACK && !k.KeyMIC:
+		return 1
+	case k.KeyACK && k.KeyMIC && k.Install:
+		return 3
+	case !k.KeyACK && k.KeyMIC && !k.Install && k.KeyDataLength > 0:
+		return 2
+	case !k.KeyACK && k.KeyMIC && !k.Install:
+		return 4
+	}
+
+	return 0
+}
+
+// wpaKeyData returns the key data field, which gopacket keeps as payload when it is not encrypted.
+func wpaKeyData(k *layers.EAPOLKey) []byte {
+	if k.HasEncryptedKeyData {
+		return k.EncryptedKeyData
+	}
+
+	if int(k.KeyDataLength) > len(k.Payload) {
+		return k.Payload
+	}
+
+	return k.Payload[:k.KeyDataLength]
+}
+
+// wpaPMKID searches the key data of the first message for a non zero PMKID.
+func wpaPMKID(keyData []byte) []byte {
+	for _, e := range dot11Elements(keyData) {
+		if e.id != wpaPMKIDKDE[0] || len(e.data) != int(wpaPMKIDKDE[1]) || string(e.data[:4]) != string(wpaPMKIDKDE[2:]) {
+			continue
+		}
+
+		pmkid := e.data[4:]
+		for _, b := range pmkid {
+			if b != 0 {
+				return pmkid
+			}
+		}
+	}
+
+	return nil
+}
+
+// wpaEAPOL returns the EAPOL frame with the MIC set to zero, as expected by hashcat.
+func wpaEAPOL(e *layers.EAPOL) []byte {
+	frame := append(append([]byte{}, e.Contents...), e.Payload...)
+	if size := 4 + int(e.Length); size < len(frame) {
+		frame = frame[:size]
+	}
+
+	if len(frame) < wpaMICOffset+wpaMICSize {
+		return nil
+	}
+
+	for i := wpaMICOffset; i < wpaMICOffset+wpaMICSize; i++ {
+		frame[i] = 0
+	}
+
+	return frame
+}
+
+// hashes returns the hashcat mode 22000 lines for the PMKID and the best message pair of the handshake.
+func (h *wpaHandshake) hashes(ssid string) (hashes []string) {
+	var (
+		ap     = strings.ReplaceAll(h.bssid, ":", "")
+		client = strings.ReplaceAll(h.client, ":", "")
+		essid  = hex.EncodeToString([]byte(ssid))
+	)
+
+	if h.pmkid != nil {
+		hashes = append(hashes, fmt.Sprintf("WPA*01*%x*%s*%s*%s***", h.pmkid, ap, client, essid))
+	}
+
+	if h.m2 == nil || h.m2.eapol == nil {
+		return hashes
+	}
+
+	var (
+		anonce []byte
+		pair   string
+	)
+
+	// the third message confirms that the client used the correct passphrase
+	switch {
+	case h.m3 != nil && h.m3.replay == h.m2.replay+1:
+		anonce, pair = h.m3.nonce, wpaMessagePairM2M3
+	case h.m1 != nil && h.m1.replay == h.m2.replay:
+		anonce, pair = h.m1.nonce, wpaMessagePairM1M2
+	default:
+		return hashes
+	}
+
+	return append(hashes, fmt.Sprintf("WPA*02*%x*%s*%s*%s*%x*%x*%s", h.m2.mic, ap, client, essid, anonce, h.m2.eapol, pair))
+}
+
+// finish creates the audit record of the handshake and harvests the hashes.
+// the state must be locked by the caller.
+func (h *wpaHandshake) finish() *types.WPAHandshake {
+	ssid, ok := wpaState.ssids[h.bssid]
+
+	r := &types.WPAHandshake{
+		Timestamp: utils.TimeToString(h.ts),
+		BSSID:     h.bssid,
+		Client:    h.client,
+		SSID:      ssid,
+		Messages:  h.messages,
+	}
+
+	if h.m1 != nil {
+		r.ReplayCounter = h.m1.replay
+	} else if h.m2 != nil {
+		r.ReplayCounter = h.m2.replay
+	}
+
+	if h.pmkid != nil {
+		r.PMKID = hex.EncodeToString(h.pmkid)
+	}
+
+	if !ok {
+		r.Notes = "SSID unknown"
+
+		return r
+	}
+
+	r.Hashes = h.hashes(ssid)
+	if len(r.Hashes) == 0 {
+		r.Notes = "incomplete handshake"
+
+		return r
+	}
+
+	wpaState.hashes = append(wpaState.hashes, r.Hashes...)
+
+	if useHarvesters {
+		for _, hash := range r.Hashes {
+			writeCredentials(&types.Credentials{
+				Timestamp: r.Timestamp,
+				Service:   serviceWPA,
+				Flow:      h.client + "->" + h.bssid,
+				User:      ssid,
+				Password:  hash,
+				Notes:     fmt.Sprintf("hashcat mode: %d", hashcatModeWPA),
+			})
+		}
+	}
+
+	return r
+}
+
+// trackWPANetwork remembers the network names announced by access points or requested by clients.
+func trackWPANetwork(p gopacket.Packet, dot11 *layers.Dot11) {
+	var (
+		body  []byte
+		bssid = dot11.Address3.String()
+	)
+
+	switch dot11.Type {
+	case layers.Dot11TypeMgmtBeacon:
+		if b, ok := p.Layer(layers.LayerTypeDot11MgmtBeacon).(*layers.Dot11MgmtBeacon); ok {
+			body = b.Payload
+		}
+	case layers.Dot11TypeMgmtProbeResp:
+		if r, ok := p.Layer(layers.LayerTypeDot11MgmtProbeResp).(*layers.Dot11MgmtProbeResp); ok {
+			body = r.Payload
+		}
+	case layers.Dot11TypeMgmtAssociationReq:
+		if r, ok := p.Layer(layers.LayerTypeDot11MgmtAssociationReq).(*layers.Dot11MgmtAssociationReq); ok {
+			body, bssid = r.Payload, dot11.Address1.String()
+		}
+	case layers.Dot11TypeMgmtReassociationReq:
+		if r, ok := p.Layer(layers.LayerTypeDot11MgmtReassociationReq).(*layers.Dot11MgmtReassociationReq); ok {
+			body, bssid = r.Payload, dot11.Address1.String()
+		}
+	}
+
+	if body == nil {
+		return
+	}
+
+	if ssid, hidden := dot11SSID(dot11Elements(body)); !hidden {
+		wpaState.Lock()
+		wpaState.ssids[bssid] = ssid
+		wpaState.Unlock()
+	}
+}
+
+// handleWPAPacket tracks network names and correlates the messages of the 4-way handshake.
+// A record is returned once a handshake is complete or replaced by a new one.
+func handleWPAPacket(p gopacket.Packet) *types.WPAHandshake {
+	dl := p.Layer(layers.LayerTypeDot11)
+	if dl == nil {
+		return nil
+	}
+
+	dot11, ok := dl.(*layers.Dot11)
+	if !ok {
+		return nil
+	}
+
+	if dot11.Type.MainType() == layers.Dot11TypeMgmt {
+		trackWPANetwork(p, dot11)
+
+		return nil
+	}
+
+	key, ok := p.Layer(layers.LayerTypeEAPOLKey).(*layers.EAPOLKey)
+	if !ok {
+		return nil
+	}
+
+	eapol, ok := p.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL)
+	if !ok {
+		return nil
+	}
+
+	n := wpaMessageNumber(key)
+	if n == 0 {
+		return nil
+	}
+
+	// the access point sends the odd messages
+	bssid, client := dot11.Address2.String(), dot11.Address1.String()
+	if n%2 == 0 {
+		bssid, client = client, bssid
+	}
+
+	var (
+		id  = bssid + "-" + client
+		msg = &wpaMessage{
+			replay: key.ReplayCounter,
+			nonce:  key.Nonce,
+			mic:    key.MIC,
+		}
+		res *types.WPAHandshake
+	)
+
+	wpaState.Lock()
+	defer wpaState.Unlock()
+
+	h, exists := wpaState.handshakes[id]
+
+	// a new first message starts another handshake
+	if n == 1 && exists && (h.m2 != nil || h.pmkid != nil) {
+		res = h.finish()
+		exists = false
+	}
+
+	if !exists {
+		h = &wpaHandshake{
+			bssid:  bssid,
+			client: client,
+			ts:     p.Metadata().Timestamp,
+		}
+		wpaState.handshakes[id] = h
+	}
+
+	h.messages = appendUnique(h.messages, fmt.Sprintf("M%d", n))
+
+	switch n {
+	case 1:
+		h.m1 = msg
+
+		if pmkid := wpaPMKID(wpaKeyData(key)); pmkid != nil {
+			h.pmkid = pmkid
+		}
+	case 2:
+		msg.eapol = wpaEAPOL(eapol)
+		h.m2 = msg
+	case 3:
+		h.m3 = msg
+	case 4:
+		// the handshake is complete
+		res = h.finish()
+		delete(wpaState.handshakes, id)
+	}
+
+	return res
+}
+
+// writeWPAHashes writes the collected hashes into the output directory, for use with hashcat.
+func writeWPAHashes() error {
+	if len(wpaState.hashes) == 0 {
+		return nil
+	}
+
+	return ioutil.WriteFile(filepath.Join(conf.Out, wpaHashFile), []byte(strings.Join(wpaState.hashes, "\n")+"\n"), defaultFilesPermission)
+}
+
+var wpaHandshakeDecoder = newCustomDecoder(
+	types.Type_NC_WPAHandshake,
+	"WPAHandshake",
+	"The WPA 4-way handshake and the PMKID allow to test passphrases of wireless networks offline",
+	func(d *customDecoder) error {
+		return nil
+	},
+	func(p gopacket.Packet) proto.Message {
+		r := handleWPAPacket(p)
+		if r == nil {
+			return nil
+		}
+
+		return r
+	},
+	func(e *customDecoder) error {
+		wpaState.Lock()
+		defer wpaState.Unlock()
+
+		// write incomplete handshakes
+		for id, h := range wpaState.handshakes {
+			e.write(h.finish())
+			delete(wpaState.handshakes, id)
+		}
+
+		return writeWPAHashes()
+	},
+)
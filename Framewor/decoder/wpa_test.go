@@ -0,0 +1,139 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/dreadl0ck/gopacket"
+
+	"github.com/dreadl0ck/netcap/types"
+)
+
+// wpaTestKey builds an LLC/SNAP encapsulated EAPOL-Key frame.
+func wpaTestKey(info uint16, replay uint64, nonce, mic byte, keyData []byte) []byte {
+	body := make([]byte, 95)
+	body[0] = 2 // RSN descriptor
+	binary.BigEndian.PutUint16(body[1:3], info)
+	binary.BigEndian.PutUint16(body[3:5], 16)
+	binary.BigEndian.PutUint64(body[5:13], replay)
+	copy(body[13:45], bytes.Repeat([]byte{nonce}, 32))
+	copy(body[77:93], bytes.Repeat([]byte{mic}, 16))
+	binary.BigEndian.PutUint16(body[93:95], uint16(len(keyData)))
+	body = append(body, keyData...)
+
+	frame := []byte{0xaa, 0xaa, 0x03, 0, 0, 0, 0x88, 0x8e, 2, 3, 0, 0}
+	binary.BigEndian.PutUint16(frame[10:12], uint16(len(body)))
+
+	return append(frame, body...)
+}
+
+func TestWPAHandshake(t *testing.T) {
+	var (
+		credRecords = &recordCollector{}
+		oldConf     = conf
+	)
+
+	defer func() {
+		conf = oldConf
+		useHarvesters = false
+
+		wpaState.Lock()
+		wpaState.handshakes = make(map[string]*wpaHandshake)
+		wpaState.ssids = make(map[string]string)
+		wpaState.hashes = nil
+		wpaState.Unlock()
+	}()
+
+	var (
+		ap      = wirelessTestAP
+		client  = wirelessTestClient
+		pmkid   = append([]byte{0xdd, 0x14, 0x00, 0x0f, 0xac, 0x04}, bytes.Repeat([]byte{0x11}, 16)...)
+		rsn     = wirelessTestElement(dot11ElementRSN, 1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 4, 1, 0, 0x00, 0x0f, 0xac, 2)
+		packets = []gopacket.Packet{
+			wirelessTestFrame(t, 0x80, 0, wirelessTestBcast, ap, ap, -40, wirelessTestBeacon(0x11, wirelessTestElement(dot11ElementSSID, []byte("corp")...), rsn)...),
+			wirelessTestFrame(t, 0x08, 0x02, client, ap, ap, -40, wpaTestKey(0x008a, 1, 0xaa, 0, pmkid)...),
+			wirelessTestFrame(t, 0x08, 0x01, ap, client, ap, -40, wpaTestKey(0x010a, 1, 0xbb, 0xcc, rsn)...),
+			wirelessTestFrame(t, 0x08, 0x02, client, ap, ap, -40, wpaTestKey(0x13ca, 2, 0xaa, 0xdd, rsn)...),
+		}
+		m4 = wirelessTestFrame(t, 0x08, 0x01, ap, client, ap, -40, wpaTestKey(0x030a, 2, 0, 0xee, nil)...)
+	)
+
+	conf = &Config{}
+	useHarvesters = true
+	credentialsDecoder.writer = credRecords
+
+	for _, p := range packets {
+		if r := handleWPAPacket(p); r != nil {
+			t.Fatal("unexpected record before handshake completed:", r)
+		}
+	}
+
+	r := handleWPAPacket(m4)
+	if r == nil {
+		t.Fatal("expected record for complete handshake")
+	}
+
+	if r.SSID != "corp" || r.BSSID != ap.String() || r.Client != client.String() || strings.Join(r.Messages, ",") != "M1,M2,M3,M4" || r.PMKID != strings.Repeat("11", 16) {
+		t.Fatal("unexpected handshake:", r)
+	}
+
+	if len(r.Hashes) != 2 {
+		t.Fatal("expected PMKID and EAPOL hash, got:", r.Hashes)
+	}
+
+	if r.Hashes[0] != "WPA*01*"+strings.Repeat("11", 16)+"*001122334455*daa119000001*636f7270***" {
+		t.Fatal("unexpected PMKID hash:", r.Hashes[0])
+	}
+
+	fields := strings.Split(r.Hashes[1], "*")
+	if len(fields) != 9 || fields[1] != "02" || fields[2] != strings.Repeat("cc", 16) || fields[6] != strings.Repeat("aa", 32) || fields[8] != wpaMessagePairM2M3 {
+		t.Fatal("unexpected EAPOL hash:", r.Hashes[1])
+	}
+
+	// the MIC must be zeroed in the EAPOL frame
+	if !strings.Contains(fields[7], strings.Repeat("00", 16)) || strings.Contains(fields[7], strings.Repeat("cc", 16)) {
+		t.Fatal("MIC not removed from EAPOL frame:", fields[7])
+	}
+
+	if len(wpaState.hashes) != 2 {
+		t.Fatal("expected hashes to be collected, got:", wpaState.hashes)
+	}
+
+	if len(credRecords.records) != 2 {
+		t.Fatal("expected 2 credentials, got", len(credRecords.records))
+	}
+
+	creds := credRecords.records[1].(*types.Credentials)
+	if creds.Service != serviceWPA || creds.User != "corp" || creds.Password != r.Hashes[1] || creds.Notes != "hashcat mode: 22000" {
+		t.Fatal("unexpected credentials:", creds)
+	}
+}
+
+func TestWPAHandshakeM1M2(t *testing.T) {
+	h := &wpaHandshake{
+		bssid:  "00:11:22:33:44:55",
+		client: "da:a1:19:00:00:01",
+		m1:     &wpaMessage{replay: 1, nonce: []byte{0xaa}},
+		m2:     &wpaMessage{replay: 1, mic: []byte{0xcc}, eapol: []byte{0x01}},
+	}
+
+	hashes := h.hashes("corp")
+	if strings.Join(hashes, ",") != "WPA*02*cc*001122334455*daa119000001*636f7270*aa*01*00" {
+		t.Fatal("unexpected hashes:", hashes)
+	}
+}
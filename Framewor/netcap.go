@@ -166,6 +166,66 @@ func InitRecord(typ types.Type) (record proto.Message) {
 		record = new(types.Vulnerability)
 	case types.Type_NC_Exploit:
 		record = new(types.Exploit)
+	case types.Type_NC_PassiveDNS:
+		record = new(types.PassiveDNS)
+	case types.Type_NC_DNSAnomaly:
+		record = new(types.DNSAnomaly)
+	case types.Type_NC_SMB:
+		record = new(types.SMB)
+	case types.Type_NC_FTP:
+		record = new(types.FTP)
+	case types.Type_NC_SMTPSession:
+		record = new(types.SMTPSession)
+	case types.Type_NC_IMAP:
+		record = new(types.IMAP)
+	case types.Type_NC_Kerberos:
+		record = new(types.Kerberos)
+	case types.Type_NC_RDP:
+		record = new(types.RDP)
+	case types.Type_NC_SNMP:
+		record = new(types.SNMP)
+	case types.Type_NC_LDAP:
+		record = new(types.LDAP)
+	case types.Type_NC_PostgreSQL:
+		record = new(types.PostgreSQL)
+	case types.Type_NC_MySQL:
+		record = new(types.MySQL)
+	case types.Type_NC_Redis:
+		record = new(types.Redis)
+	case types.Type_NC_Memcached:
+		record = new(types.Memcached)
+	case types.Type_NC_RADIUS:
+		record = new(types.RADIUS)
+	case types.Type_NC_MDNS:
+		record = new(types.MDNS)
+	case types.Type_NC_LLMNR:
+		record = new(types.LLMNR)
+	case types.Type_NC_NBNS:
+		record = new(types.NBNS)
+	case types.Type_NC_GTPC:
+		record = new(types.GTPC)
+	case types.Type_NC_WirelessNetwork:
+		record = new(types.WirelessNetwork)
+	case types.Type_NC_WirelessClient:
+		record = new(types.WirelessClient)
+	case types.Type_NC_WPAHandshake:
+		record = new(types.WPAHandshake)
+	case types.Type_NC_Call:
+		record = new(types.Call)
+	case types.Type_NC_USBKeystrokes:
+		record = new(types.USBKeystrokes)
+	case types.Type_NC_USBMassStorage:
+		record = new(types.USBMassStorage)
+	case types.Type_NC_DNP3:
+		record = new(types.DNP3)
+	case types.Type_NC_S7:
+		record = new(types.S7)
+	case types.Type_NC_IEC104:
+		record = new(types.IEC104)
+	case types.Type_NC_BACnet:
+		record = new(types.BACnet)
+	case types.Type_NC_ICSDevice:
+		record = new(types.ICSDevice)
 	default:
 		panic("InitRecord: unknown type: " + typ.String())
 	}
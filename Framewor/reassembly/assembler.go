@@ -144,6 +144,13 @@ type AssemblerContext interface {
 	GetCaptureInfo() gopacket.CaptureInfo
 }
 
+// TunnelContext can be implemented by an AssemblerContext of encapsulated packets.
+// The tunnel identifier is part of the connection key, so that overlay networks
+// carrying identical inner flows are reassembled separately.
+type TunnelContext interface {
+	TunnelID() uint64
+}
+
 // Implements AssemblerContext for Assemble().
 type assemblerSimpleContext gopacket.CaptureInfo
 
@@ -182,9 +189,13 @@ func (a *Assembler) AssembleWithContext(netFlow gopacket.Flow, t *layers.TCP, ac
 		conn    *connection
 		half    *halfconnection
 		rev     *halfconnection
-		flowKey = &key{netFlow, t.TransportFlow()}
+		flowKey = &key{net: netFlow, transport: t.TransportFlow()}
 	)
 
+	if tc, ok := ac.(TunnelContext); ok {
+		flowKey.tunnel = tc.TunnelID()
+	}
+
 	// RACE
 	a.Lock()
 	a.ret = a.ret[:0]
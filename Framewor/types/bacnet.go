@@ -0,0 +1,106 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsBACnet = []string{
+	"Timestamp",      // string
+	"SrcIP",          // string
+	"DstIP",          // string
+	"SrcPort",        // int32
+	"DstPort",        // int32
+	"BVLCFunction",   // string
+	"NetworkMessage", // string
+	"APDUType",       // string
+	"Service",        // string
+	"InvokeID",       // int32
+	"ObjectID",       // string
+	"Property",       // string
+	"Value",          // string
+	"Priority",       // int32
+	"VendorID",       // int32
+	"Error",          // string
+	"Notes",          // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *BACnet) CSVHeader() []string {
+	return filter(fieldsBACnet)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *BACnet) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.SrcIP,                 // string
+		a.DstIP,                 // string
+		formatInt32(a.SrcPort),  // int32
+		formatInt32(a.DstPort),  // int32
+		a.BVLCFunction,          // string
+		a.NetworkMessage,        // string
+		a.APDUType,              // string
+		a.Service,               // string
+		formatInt32(a.InvokeID), // int32
+		a.ObjectID,              // string
+		a.Property,              // string
+		a.Value,                 // string
+		formatInt32(a.Priority), // int32
+		formatInt32(a.VendorID), // int32
+		a.Error,                 // string
+		a.Notes,                 // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *BACnet) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *BACnet) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var bacnetMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_BACnet.String()),
+		Help: Type_NC_BACnet.String() + " audit records",
+	},
+	[]string{"BVLCFunction", "APDUType", "Service"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *BACnet) Inc() {
+	bacnetMetric.WithLabelValues(a.BVLCFunction, a.APDUType, a.Service).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *BACnet) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *BACnet) Src() string {
+	return a.SrcIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *BACnet) Dst() string {
+	return a.DstIP
+}
@@ -0,0 +1,110 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsCall = []string{
+	"Timestamp",    // string
+	"CallID",       // string
+	"From",         // string
+	"To",           // string
+	"CallerIP",     // string
+	"CalleeIP",     // string
+	"UserAgent",    // string
+	"Status",       // string
+	"SetupTime",    // int64
+	"Duration",     // int64
+	"Codecs",       // []string
+	"MediaStreams", // []string
+	"RTPPackets",   // int64
+	"RTCPPackets",  // int64
+	"Lost",         // int64
+	"Jitter",       // float64
+	"MOS",          // float64
+	"Files",        // []string
+	"Notes",        // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *Call) CSVHeader() []string {
+	return filter(fieldsCall)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *Call) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.CallID,                   // string
+		a.From,                     // string
+		a.To,                       // string
+		a.CallerIP,                 // string
+		a.CalleeIP,                 // string
+		a.UserAgent,                // string
+		a.Status,                   // string
+		formatInt64(a.SetupTime),   // int64
+		formatInt64(a.Duration),    // int64
+		join(a.Codecs...),          // []string
+		join(a.MediaStreams...),    // []string
+		formatInt64(a.RTPPackets),  // int64
+		formatInt64(a.RTCPPackets), // int64
+		formatInt64(a.Lost),        // int64
+		formatFloat64(a.Jitter),    // float64
+		formatFloat64(a.MOS),       // float64
+		join(a.Files...),           // []string
+		a.Notes,                    // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *Call) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *Call) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var callMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Call.String()),
+		Help: Type_NC_Call.String() + " audit records",
+	},
+	[]string{"Status"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *Call) Inc() {
+	callMetric.WithLabelValues(a.Status).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *Call) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *Call) Src() string {
+	return a.CallerIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *Call) Dst() string {
+	return a.CalleeIP
+}
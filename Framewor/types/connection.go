@@ -38,6 +38,10 @@ var fieldsConnection = []string{
 	"UID",
 	"Duration",
 	"TimestampLast",
+	"TunnelProto",
+	"TunnelSrcIP",
+	"TunnelDstIP",
+	"TunnelID",
 }
 
 // CSVHeader returns the CSV header for the audit record.
@@ -65,6 +69,10 @@ func (c *Connection) CSVRecord() []string {
 		c.UID,
 		formatInt64(c.Duration),
 		formatTimestamp(c.TimestampLast),
+		c.TunnelProto,
+		c.TunnelSrcIP,
+		c.TunnelDstIP,
+		formatUint32(c.TunnelID),
 	})
 }
 
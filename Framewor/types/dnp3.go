@@ -0,0 +1,103 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsDNP3 = []string{
+	"Timestamp",    // string
+	"SrcIP",        // string
+	"DstIP",        // string
+	"Flow",         // string
+	"SrcAddress",   // int32
+	"DstAddress",   // int32
+	"FromMaster",   // bool
+	"Sequence",     // int32
+	"FunctionCode", // int32
+	"Function",     // string
+	"Objects",      // []string
+	"Controls",     // []string
+	"IIN",          // []string
+	"Segments",     // int32
+	"Notes",        // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *DNP3) CSVHeader() []string {
+	return filter(fieldsDNP3)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *DNP3) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.SrcIP,                          // string
+		a.DstIP,                          // string
+		a.Flow,                           // string
+		formatInt32(a.SrcAddress),        // int32
+		formatInt32(a.DstAddress),        // int32
+		strconv.FormatBool(a.FromMaster), // bool
+		formatInt32(a.Sequence),          // int32
+		formatInt32(a.FunctionCode),      // int32
+		a.Function,                       // string
+		join(a.Objects...),               // []string
+		join(a.Controls...),              // []string
+		join(a.IIN...),                   // []string
+		formatInt32(a.Segments),          // int32
+		a.Notes,                          // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *DNP3) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *DNP3) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var dnp3Metric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_DNP3.String()),
+		Help: Type_NC_DNP3.String() + " audit records",
+	},
+	[]string{"Function", "SrcIP", "DstIP"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *DNP3) Inc() {
+	dnp3Metric.WithLabelValues(a.Function, a.SrcIP, a.DstIP).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *DNP3) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *DNP3) Src() string {
+	return a.SrcIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *DNP3) Dst() string {
+	return a.DstIP
+}
@@ -0,0 +1,118 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsDNSAnomaly = []string{
+	"Timestamp",          // string
+	"LastSeen",           // string
+	"Client",             // string
+	"Domain",             // string
+	"NumQueries",         // int64
+	"NumNXDomain",        // int64
+	"UniqueSubdomains",   // int64
+	"MaxLabelLength",     // int32
+	"AvgSubdomainLength", // float64
+	"SubdomainEntropy",   // float64
+	"TXTRatio",           // float64
+	"NULLRatio",          // float64
+	"DGAScore",           // float64
+	"TunnelScore",        // float64
+	"Score",              // float64
+	"Samples",            // []string
+	"Notes",              // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *DNSAnomaly) CSVHeader() []string {
+	return filter(fieldsDNSAnomaly)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *DNSAnomaly) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		formatTimestamp(a.LastSeen),         // string
+		a.Client,                            // string
+		a.Domain,                            // string
+		formatInt64(a.NumQueries),           // int64
+		formatInt64(a.NumNXDomain),          // int64
+		formatInt64(a.UniqueSubdomains),     // int64
+		formatInt32(a.MaxLabelLength),       // int32
+		formatFloat64(a.AvgSubdomainLength), // float64
+		formatFloat64(a.SubdomainEntropy),   // float64
+		formatFloat64(a.TXTRatio),           // float64
+		formatFloat64(a.NULLRatio),          // float64
+		formatFloat64(a.DGAScore),           // float64
+		formatFloat64(a.TunnelScore),        // float64
+		formatFloat64(a.Score),              // float64
+		join(a.Samples...),                  // []string
+		a.Notes,                             // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *DNSAnomaly) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *DNSAnomaly) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	a.LastSeen = utils.TimeToUnixMilli(a.LastSeen)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var (
+	dnsAnomalyMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: strings.ToLower(Type_NC_DNSAnomaly.String()),
+			Help: Type_NC_DNSAnomaly.String() + " audit records",
+		},
+		[]string{"Client", "Domain"},
+	)
+	dnsAnomalyScore = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    strings.ToLower(Type_NC_DNSAnomaly.String()) + "_score",
+			Help:    Type_NC_DNSAnomaly.String() + " anomaly scores",
+			Buckets: prometheus.LinearBuckets(0, 0.1, 10),
+		},
+		[]string{"Client"},
+	)
+)
+
+// Inc increments the metrics for the audit record.
+func (a *DNSAnomaly) Inc() {
+	dnsAnomalyMetric.WithLabelValues(a.Client, a.Domain).Inc()
+	dnsAnomalyScore.WithLabelValues(a.Client).Observe(a.Score)
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *DNSAnomaly) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *DNSAnomaly) Src() string {
+	return a.Client
+}
+
+// Dst returns the destination address of the audit record.
+func (a *DNSAnomaly) Dst() string {
+	return a.Domain
+}
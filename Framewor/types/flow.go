@@ -38,6 +38,10 @@ var fieldsFlow = []string{
 	"UID",
 	"Duration",
 	"TimestampLast",
+	"TunnelProto",
+	"TunnelSrcIP",
+	"TunnelDstIP",
+	"TunnelID",
 }
 
 // CSVHeader returns the CSV header for the audit record.
@@ -65,6 +69,10 @@ func (f *Flow) CSVRecord() []string {
 		f.UID,
 		formatInt64(f.Duration),
 		formatTimestamp(f.TimestampLast),
+		f.TunnelProto,
+		f.TunnelSrcIP,
+		f.TunnelDstIP,
+		formatUint32(f.TunnelID),
 	})
 }
 
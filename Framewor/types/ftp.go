@@ -0,0 +1,105 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsFTP = []string{
+	"Timestamp",    // string
+	"ClientIP",     // string
+	"ServerIP",     // string
+	"Flow",         // string
+	"User",         // string
+	"Command",      // string
+	"Argument",     // string
+	"ReplyCode",    // int32
+	"ReplyMessage", // string
+	"DataMode",     // string
+	"DataEndpoint", // string
+	"Filename",     // string
+	"Direction",    // string
+	"Length",       // int64
+	"Outcome",      // string
+	"Notes",        // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *FTP) CSVHeader() []string {
+	return filter(fieldsFTP)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *FTP) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,               // string
+		a.ServerIP,               // string
+		a.Flow,                   // string
+		a.User,                   // string
+		a.Command,                // string
+		a.Argument,               // string
+		formatInt32(a.ReplyCode), // int32
+		a.ReplyMessage,           // string
+		a.DataMode,               // string
+		a.DataEndpoint,           // string
+		a.Filename,               // string
+		a.Direction,              // string
+		formatInt64(a.Length),    // int64
+		a.Outcome,                // string
+		a.Notes,                  // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *FTP) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *FTP) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var ftpMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_FTP.String()),
+		Help: Type_NC_FTP.String() + " audit records",
+	},
+	[]string{"Command", "ReplyCode", "Outcome"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *FTP) Inc() {
+	ftpMetric.WithLabelValues(a.Command, strconv.Itoa(int(a.ReplyCode)), a.Outcome).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *FTP) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *FTP) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *FTP) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,108 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsGTPC = []string{
+	"Timestamp",       // string
+	"ClientIP",        // string
+	"ServerIP",        // string
+	"Sequence",        // uint32
+	"Message",         // string
+	"Cause",           // string
+	"IMSI",            // string
+	"MSISDN",          // string
+	"MEI",             // string
+	"APN",             // string
+	"RAT",             // string
+	"UEIP",            // string
+	"ClientTEID",      // uint32
+	"ServerTEID",      // uint32
+	"BearerTEIDs",     // []string
+	"Latency",         // int64
+	"SessionDuration", // int64
+	"Notes",           // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *GTPC) CSVHeader() []string {
+	return filter(fieldsGTPC)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *GTPC) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                     // string
+		a.ServerIP,                     // string
+		formatUint32(a.Sequence),       // uint32
+		a.Message,                      // string
+		a.Cause,                        // string
+		a.IMSI,                         // string
+		a.MSISDN,                       // string
+		a.MEI,                          // string
+		a.APN,                          // string
+		a.RAT,                          // string
+		a.UEIP,                         // string
+		formatUint32(a.ClientTEID),     // uint32
+		formatUint32(a.ServerTEID),     // uint32
+		join(a.BearerTEIDs...),         // []string
+		formatInt64(a.Latency),         // int64
+		formatInt64(a.SessionDuration), // int64
+		a.Notes,                        // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *GTPC) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *GTPC) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var gtpcMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_GTPC.String()),
+		Help: Type_NC_GTPC.String() + " audit records",
+	},
+	[]string{"Message", "Cause", "APN", "RAT"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *GTPC) Inc() {
+	gtpcMetric.WithLabelValues(a.Message, a.Cause, a.APN, a.RAT).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *GTPC) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *GTPC) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *GTPC) Dst() string {
+	return a.ServerIP
+}
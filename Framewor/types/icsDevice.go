@@ -0,0 +1,98 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsICSDevice = []string{
+	"Timestamp",     // string
+	"TimestampLast", // string
+	"IP",            // string
+	"Protocol",      // string
+	"Role",          // string
+	"Addresses",     // []string
+	"VendorID",      // int32
+	"Vendor",        // string
+	"Model",         // string
+	"Name",          // string
+	"Messages",      // int64
+	"Peers",         // []string
+	"Notes",         // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *ICSDevice) CSVHeader() []string {
+	return filter(fieldsICSDevice)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *ICSDevice) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.TimestampLast,         // string
+		a.IP,                    // string
+		a.Protocol,              // string
+		a.Role,                  // string
+		join(a.Addresses...),    // []string
+		formatInt32(a.VendorID), // int32
+		a.Vendor,                // string
+		a.Model,                 // string
+		a.Name,                  // string
+		formatInt64(a.Messages), // int64
+		join(a.Peers...),        // []string
+		a.Notes,                 // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *ICSDevice) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *ICSDevice) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var icsDeviceMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_ICSDevice.String()),
+		Help: Type_NC_ICSDevice.String() + " audit records",
+	},
+	[]string{"Protocol", "Role"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *ICSDevice) Inc() {
+	icsDeviceMetric.WithLabelValues(a.Protocol, a.Role).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *ICSDevice) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *ICSDevice) Src() string {
+	return a.IP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *ICSDevice) Dst() string {
+	return ""
+}
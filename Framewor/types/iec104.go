@@ -0,0 +1,111 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsIEC104 = []string{
+	"Timestamp",         // string
+	"ClientIP",          // string
+	"ServerIP",          // string
+	"Flow",              // string
+	"FromClient",        // bool
+	"FrameType",         // string
+	"UFunction",         // string
+	"SendSeq",           // int32
+	"RecvSeq",           // int32
+	"TypeID",            // int32
+	"Type",              // string
+	"Cause",             // string
+	"Negative",          // bool
+	"Test",              // bool
+	"OriginatorAddress", // int32
+	"CommonAddress",     // int32
+	"Objects",           // []string
+	"Command",           // bool
+	"Notes",             // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *IEC104) CSVHeader() []string {
+	return filter(fieldsIEC104)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *IEC104) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                       // string
+		a.ServerIP,                       // string
+		a.Flow,                           // string
+		strconv.FormatBool(a.FromClient), // bool
+		a.FrameType,                      // string
+		a.UFunction,                      // string
+		formatInt32(a.SendSeq),           // int32
+		formatInt32(a.RecvSeq),           // int32
+		formatInt32(a.TypeID),            // int32
+		a.Type,                           // string
+		a.Cause,                          // string
+		strconv.FormatBool(a.Negative),   // bool
+		strconv.FormatBool(a.Test),       // bool
+		formatInt32(a.OriginatorAddress), // int32
+		formatInt32(a.CommonAddress),     // int32
+		join(a.Objects...),               // []string
+		strconv.FormatBool(a.Command),    // bool
+		a.Notes,                          // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *IEC104) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *IEC104) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var iec104Metric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_IEC104.String()),
+		Help: Type_NC_IEC104.String() + " audit records",
+	},
+	[]string{"FrameType", "Type", "Cause"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *IEC104) Inc() {
+	iec104Metric.WithLabelValues(a.FrameType, a.Type, a.Cause).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *IEC104) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *IEC104) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *IEC104) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,99 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsIMAP = []string{
+	"Timestamp",     // string
+	"ClientIP",      // string
+	"ServerIP",      // string
+	"Flow",          // string
+	"Tag",           // string
+	"Command",       // string
+	"Arguments",     // string
+	"Mailbox",       // string
+	"Status",        // string
+	"StatusMessage", // string
+	"User",          // string
+	"NumMails",      // []*Mail
+	"Notes",         // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *IMAP) CSVHeader() []string {
+	return filter(fieldsIMAP)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *IMAP) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                 // string
+		a.ServerIP,                 // string
+		a.Flow,                     // string
+		a.Tag,                      // string
+		a.Command,                  // string
+		a.Arguments,                // string
+		a.Mailbox,                  // string
+		a.Status,                   // string
+		a.StatusMessage,            // string
+		a.User,                     // string
+		strconv.Itoa(len(a.Mails)), // []*Mail
+		a.Notes,                    // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *IMAP) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *IMAP) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var imapMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_IMAP.String()),
+		Help: Type_NC_IMAP.String() + " audit records",
+	},
+	[]string{"Command", "Status"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *IMAP) Inc() {
+	imapMetric.WithLabelValues(a.Command, a.Status).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *IMAP) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *IMAP) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *IMAP) Dst() string {
+	return a.ServerIP
+}
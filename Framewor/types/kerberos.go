@@ -0,0 +1,101 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsKerberos = []string{
+	"Timestamp",            // string
+	"ClientIP",             // string
+	"ServerIP",             // string
+	"Transport",            // string
+	"MessageType",          // string
+	"Realm",                // string
+	"ClientPrincipal",      // string
+	"ServicePrincipal",     // string
+	"EncryptionTypes",      // []string
+	"TicketEncryptionType", // string
+	"ErrorCode",            // int32
+	"ErrorName",            // string
+	"PreAuthentication",    // bool
+	"Notes",                // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *Kerberos) CSVHeader() []string {
+	return filter(fieldsKerberos)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *Kerberos) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                              // string
+		a.ServerIP,                              // string
+		a.Transport,                             // string
+		a.MessageType,                           // string
+		a.Realm,                                 // string
+		a.ClientPrincipal,                       // string
+		a.ServicePrincipal,                      // string
+		join(a.EncryptionTypes...),              // []string
+		a.TicketEncryptionType,                  // string
+		formatInt32(a.ErrorCode),                // int32
+		a.ErrorName,                             // string
+		strconv.FormatBool(a.PreAuthentication), // bool
+		a.Notes,                                 // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *Kerberos) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *Kerberos) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var kerberosMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Kerberos.String()),
+		Help: Type_NC_Kerberos.String() + " audit records",
+	},
+	[]string{"MessageType", "ErrorName"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *Kerberos) Inc() {
+	kerberosMetric.WithLabelValues(a.MessageType, a.ErrorName).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *Kerberos) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *Kerberos) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *Kerberos) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,106 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsLDAP = []string{
+	"Timestamp",         // string
+	"ClientIP",          // string
+	"ServerIP",          // string
+	"Flow",              // string
+	"MessageID",         // int32
+	"Operation",         // string
+	"DN",                // string
+	"AuthMechanism",     // string
+	"Scope",             // string
+	"Filter",            // string
+	"Attributes",        // []string
+	"Modifications",     // []string
+	"ResultCode",        // int32
+	"ResultName",        // string
+	"DiagnosticMessage", // string
+	"NumEntries",        // int32
+	"Notes",             // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *LDAP) CSVHeader() []string {
+	return filter(fieldsLDAP)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *LDAP) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                // string
+		a.ServerIP,                // string
+		a.Flow,                    // string
+		formatInt32(a.MessageID),  // int32
+		a.Operation,               // string
+		a.DN,                      // string
+		a.AuthMechanism,           // string
+		a.Scope,                   // string
+		a.Filter,                  // string
+		join(a.Attributes...),     // []string
+		join(a.Modifications...),  // []string
+		formatInt32(a.ResultCode), // int32
+		a.ResultName,              // string
+		a.DiagnosticMessage,       // string
+		formatInt32(a.NumEntries), // int32
+		a.Notes,                   // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *LDAP) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *LDAP) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var ldapMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_LDAP.String()),
+		Help: Type_NC_LDAP.String() + " audit records",
+	},
+	[]string{"Operation", "ResultName"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *LDAP) Inc() {
+	ldapMetric.WithLabelValues(a.Operation, a.ResultName).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *LDAP) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *LDAP) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *LDAP) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,93 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsMDNS = []string{
+	"Timestamp", // string
+	"SrcIP",     // string
+	"DstIP",     // string
+	"Response",  // bool
+	"Questions", // []string
+	"Hosts",     // []string
+	"Services",  // []string
+	"Instances", // []string
+	"TXT",       // []string
+	"Notes",     // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *MDNS) CSVHeader() []string {
+	return filter(fieldsMDNS)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *MDNS) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.SrcIP,                        // string
+		a.DstIP,                        // string
+		strconv.FormatBool(a.Response), // bool
+		join(a.Questions...),           // []string
+		join(a.Hosts...),               // []string
+		join(a.Services...),            // []string
+		join(a.Instances...),           // []string
+		join(a.TXT...),                 // []string
+		a.Notes,                        // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *MDNS) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *MDNS) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var mdnsMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_MDNS.String()),
+		Help: Type_NC_MDNS.String() + " audit records",
+	},
+	[]string{"Response"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *MDNS) Inc() {
+	mdnsMetric.WithLabelValues(strconv.FormatBool(a.Response)).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *MDNS) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *MDNS) Src() string {
+	return a.SrcIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *MDNS) Dst() string {
+	return a.DstIP
+}
@@ -0,0 +1,98 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsMemcached = []string{
+	"Timestamp", // string
+	"ClientIP",  // string
+	"ServerIP",  // string
+	"Flow",      // string
+	"Command",   // string
+	"Keys",      // []string
+	"Flags",     // uint32
+	"Expiry",    // int64
+	"ValueSize", // int64
+	"Hits",      // int32
+	"Status",    // string
+	"Response",  // string
+	"Notes",     // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *Memcached) CSVHeader() []string {
+	return filter(fieldsMemcached)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *Memcached) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,               // string
+		a.ServerIP,               // string
+		a.Flow,                   // string
+		a.Command,                // string
+		join(a.Keys...),          // []string
+		formatUint32(a.Flags),    // uint32
+		formatInt64(a.Expiry),    // int64
+		formatInt64(a.ValueSize), // int64
+		formatInt32(a.Hits),      // int32
+		a.Status,                 // string
+		a.Response,               // string
+		a.Notes,                  // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *Memcached) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *Memcached) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var memcachedMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_Memcached.String()),
+		Help: Type_NC_Memcached.String() + " audit records",
+	},
+	[]string{"Command", "Status"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *Memcached) Inc() {
+	memcachedMetric.WithLabelValues(a.Command, a.Status).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *Memcached) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *Memcached) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *Memcached) Dst() string {
+	return a.ServerIP
+}
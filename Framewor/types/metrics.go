@@ -90,6 +90,37 @@ var Metrics = []prometheus.Collector{
 	lldMetric,
 	dhcp6Metric,
 	bfdMetric,
+	passiveDNSMetric,
+	dnsAnomalyMetric,
+	dnsAnomalyScore,
+	smbMetric,
+	ftpMetric,
+	smtpSessionMetric,
+	imapMetric,
+	kerberosMetric,
+	rdpMetric,
+	snmpMetric,
+	ldapMetric,
+	postgreSQLMetric,
+	mySQLMetric,
+	redisMetric,
+	memcachedMetric,
+	radiusMetric,
+	mdnsMetric,
+	llmnrMetric,
+	nbnsMetric,
+	gtpcMetric,
+	wirelessNetworkMetric,
+	wirelessClientMetric,
+	wpaHandshakeMetric,
+	callMetric,
+	usbKeystrokesMetric,
+	usbMassStorageMetric,
+	dnp3Metric,
+	s7Metric,
+	iec104Metric,
+	bacnetMetric,
+	icsDeviceMetric,
 	ethernetMetric,
 	ethernetPayloadEntropy,
 	ethernetPayloadSize,
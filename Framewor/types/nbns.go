@@ -0,0 +1,89 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsNBNS = []string{
+	"Timestamp", // string
+	"SrcIP",     // string
+	"DstIP",     // string
+	"Response",  // bool
+	"Opcode",    // string
+	"Questions", // []string
+	"Hosts",     // []string
+	"Notes",     // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *NBNS) CSVHeader() []string {
+	return filter(fieldsNBNS)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *NBNS) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.SrcIP,                        // string
+		a.DstIP,                        // string
+		strconv.FormatBool(a.Response), // bool
+		a.Opcode,                       // string
+		join(a.Questions...),           // []string
+		join(a.Hosts...),               // []string
+		a.Notes,                        // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *NBNS) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *NBNS) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var nbnsMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_NBNS.String()),
+		Help: Type_NC_NBNS.String() + " audit records",
+	},
+	[]string{"Opcode", "Response"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *NBNS) Inc() {
+	nbnsMetric.WithLabelValues(a.Opcode, strconv.FormatBool(a.Response)).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *NBNS) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *NBNS) Src() string {
+	return a.SrcIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *NBNS) Dst() string {
+	return a.DstIP
+}
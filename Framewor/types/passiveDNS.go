@@ -0,0 +1,94 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsPassiveDNS = []string{
+	"Timestamp", // string
+	"Query",     // string
+	"Type",      // string
+	"Answer",    // string
+	"FirstSeen", // string
+	"LastSeen",  // string
+	"Count",     // int64
+	"TTLMin",    // uint32
+	"TTLMax",    // uint32
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *PassiveDNS) CSVHeader() []string {
+	return filter(fieldsPassiveDNS)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *PassiveDNS) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.Query,                      // string
+		a.Type,                       // string
+		a.Answer,                     // string
+		formatTimestamp(a.FirstSeen), // string
+		formatTimestamp(a.LastSeen),  // string
+		formatInt64(a.Count),         // int64
+		formatUint32(a.TTLMin),       // uint32
+		formatUint32(a.TTLMax),       // uint32
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *PassiveDNS) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *PassiveDNS) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	a.FirstSeen = utils.TimeToUnixMilli(a.FirstSeen)
+	a.LastSeen = utils.TimeToUnixMilli(a.LastSeen)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var passiveDNSMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_PassiveDNS.String()),
+		Help: Type_NC_PassiveDNS.String() + " audit records",
+	},
+	[]string{"Query", "Type", "Answer"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *PassiveDNS) Inc() {
+	passiveDNSMetric.WithLabelValues(a.Query, a.Type, a.Answer).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *PassiveDNS) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+// For passive DNS entries this is the queried name.
+func (a *PassiveDNS) Src() string {
+	return a.Query
+}
+
+// Dst returns the destination address of the audit record.
+// For passive DNS entries this is the resolved answer.
+func (a *PassiveDNS) Dst() string {
+	return a.Answer
+}
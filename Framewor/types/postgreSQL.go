@@ -0,0 +1,104 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsPostgreSQL = []string{
+	"Timestamp",     // string
+	"ClientIP",      // string
+	"ServerIP",      // string
+	"Flow",          // string
+	"ServerVersion", // string
+	"User",          // string
+	"Database",      // string
+	"Application",   // string
+	"Command",       // string
+	"Statement",     // string
+	"Status",        // string
+	"ErrorCode",     // string
+	"Error",         // string
+	"Rows",          // int64
+	"Latency",       // int64
+	"Notes",         // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *PostgreSQL) CSVHeader() []string {
+	return filter(fieldsPostgreSQL)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *PostgreSQL) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,             // string
+		a.ServerIP,             // string
+		a.Flow,                 // string
+		a.ServerVersion,        // string
+		a.User,                 // string
+		a.Database,             // string
+		a.Application,          // string
+		a.Command,              // string
+		a.Statement,            // string
+		a.Status,               // string
+		a.ErrorCode,            // string
+		a.Error,                // string
+		formatInt64(a.Rows),    // int64
+		formatInt64(a.Latency), // int64
+		a.Notes,                // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *PostgreSQL) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *PostgreSQL) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var postgreSQLMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_PostgreSQL.String()),
+		Help: Type_NC_PostgreSQL.String() + " audit records",
+	},
+	[]string{"Command", "Status"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *PostgreSQL) Inc() {
+	postgreSQLMetric.WithLabelValues(a.Command, a.Status).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *PostgreSQL) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *PostgreSQL) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *PostgreSQL) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,122 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsRADIUS = []string{
+	"Timestamp",        // string
+	"ClientIP",         // string
+	"ServerIP",         // string
+	"ClientPort",       // int32
+	"ServerPort",       // int32
+	"Identifier",       // int32
+	"Code",             // string
+	"Response",         // string
+	"User",             // string
+	"NASIP",            // string
+	"NASIdentifier",    // string
+	"NASPort",          // string
+	"FramedIP",         // string
+	"CallingStationID", // string
+	"CalledStationID",  // string
+	"AuthMethod",       // string
+	"AcctStatus",       // string
+	"SessionID",        // string
+	"SessionDuration",  // int64
+	"InputOctets",      // uint64
+	"OutputOctets",     // uint64
+	"TerminateCause",   // string
+	"ReplyMessage",     // string
+	"Latency",          // int64
+	"Notes",            // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *RADIUS) CSVHeader() []string {
+	return filter(fieldsRADIUS)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *RADIUS) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                     // string
+		a.ServerIP,                     // string
+		formatInt32(a.ClientPort),      // int32
+		formatInt32(a.ServerPort),      // int32
+		formatInt32(a.Identifier),      // int32
+		a.Code,                         // string
+		a.Response,                     // string
+		a.User,                         // string
+		a.NASIP,                        // string
+		a.NASIdentifier,                // string
+		a.NASPort,                      // string
+		a.FramedIP,                     // string
+		a.CallingStationID,             // string
+		a.CalledStationID,              // string
+		a.AuthMethod,                   // string
+		a.AcctStatus,                   // string
+		a.SessionID,                    // string
+		formatInt64(a.SessionDuration), // int64
+		formatUint64(a.InputOctets),    // uint64
+		formatUint64(a.OutputOctets),   // uint64
+		a.TerminateCause,               // string
+		a.ReplyMessage,                 // string
+		formatInt64(a.Latency),         // int64
+		a.Notes,                        // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *RADIUS) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *RADIUS) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var radiusMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_RADIUS.String()),
+		Help: Type_NC_RADIUS.String() + " audit records",
+	},
+	[]string{"Code", "Response"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *RADIUS) Inc() {
+	radiusMetric.WithLabelValues(a.Code, a.Response).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *RADIUS) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *RADIUS) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *RADIUS) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,108 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsRDP = []string{
+	"Timestamp",          // string
+	"ClientIP",           // string
+	"ServerIP",           // string
+	"Flow",               // string
+	"Cookie",             // string
+	"RoutingToken",       // string
+	"RequestedProtocols", // []string
+	"SelectedProtocol",   // string
+	"NegotiationFailure", // string
+	"ClientName",         // string
+	"ClientVersion",      // string
+	"ClientBuild",        // uint32
+	"KeyboardLayout",     // string
+	"KeyboardType",       // uint32
+	"DesktopWidth",       // int32
+	"DesktopHeight",      // int32
+	"Fingerprint",        // string
+	"Notes",              // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *RDP) CSVHeader() []string {
+	return filter(fieldsRDP)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *RDP) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                    // string
+		a.ServerIP,                    // string
+		a.Flow,                        // string
+		a.Cookie,                      // string
+		a.RoutingToken,                // string
+		join(a.RequestedProtocols...), // []string
+		a.SelectedProtocol,            // string
+		a.NegotiationFailure,          // string
+		a.ClientName,                  // string
+		a.ClientVersion,               // string
+		formatUint32(a.ClientBuild),   // uint32
+		a.KeyboardLayout,              // string
+		formatUint32(a.KeyboardType),  // uint32
+		formatInt32(a.DesktopWidth),   // int32
+		formatInt32(a.DesktopHeight),  // int32
+		a.Fingerprint,                 // string
+		a.Notes,                       // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *RDP) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *RDP) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var rdpMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_RDP.String()),
+		Help: Type_NC_RDP.String() + " audit records",
+	},
+	[]string{"SelectedProtocol", "ClientVersion"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *RDP) Inc() {
+	rdpMetric.WithLabelValues(a.SelectedProtocol, a.ClientVersion).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *RDP) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *RDP) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *RDP) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,104 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsS7 = []string{
+	"Timestamp",    // string
+	"ClientIP",     // string
+	"ServerIP",     // string
+	"Flow",         // string
+	"Protocol",     // string
+	"Rack",         // int32
+	"Slot",         // int32
+	"PDUReference", // int32
+	"Type",         // string
+	"Function",     // string
+	"Items",        // []string
+	"Block",        // string
+	"Service",      // string
+	"Status",       // string
+	"Latency",      // int64
+	"Notes",        // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *S7) CSVHeader() []string {
+	return filter(fieldsS7)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *S7) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                  // string
+		a.ServerIP,                  // string
+		a.Flow,                      // string
+		a.Protocol,                  // string
+		formatInt32(a.Rack),         // int32
+		formatInt32(a.Slot),         // int32
+		formatInt32(a.PDUReference), // int32
+		a.Type,                      // string
+		a.Function,                  // string
+		join(a.Items...),            // []string
+		a.Block,                     // string
+		a.Service,                   // string
+		a.Status,                    // string
+		formatInt64(a.Latency),      // int64
+		a.Notes,                     // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *S7) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *S7) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var s7Metric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_S7.String()),
+		Help: Type_NC_S7.String() + " audit records",
+	},
+	[]string{"Protocol", "Function", "Status"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *S7) Inc() {
+	s7Metric.WithLabelValues(a.Protocol, a.Function, a.Status).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *S7) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *S7) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *S7) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,110 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsSMB = []string{
+	"Timestamp",   // string
+	"ClientIP",    // string
+	"ServerIP",    // string
+	"Flow",        // string
+	"Dialect",     // string
+	"Command",     // string
+	"MessageID",   // uint64
+	"SessionID",   // uint64
+	"TreeID",      // uint32
+	"Status",      // string
+	"User",        // string
+	"Domain",      // string
+	"Workstation", // string
+	"Share",       // string
+	"Filename",    // string
+	"FileID",      // string
+	"Offset",      // uint64
+	"Length",      // uint32
+	"Notes",       // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *SMB) CSVHeader() []string {
+	return filter(fieldsSMB)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *SMB) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                // string
+		a.ServerIP,                // string
+		a.Flow,                    // string
+		a.Dialect,                 // string
+		a.Command,                 // string
+		formatUint64(a.MessageID), // uint64
+		formatUint64(a.SessionID), // uint64
+		formatUint32(a.TreeID),    // uint32
+		a.Status,                  // string
+		a.User,                    // string
+		a.Domain,                  // string
+		a.Workstation,             // string
+		a.Share,                   // string
+		a.Filename,                // string
+		a.FileID,                  // string
+		formatUint64(a.Offset),    // uint64
+		formatUint32(a.Length),    // uint32
+		a.Notes,                   // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *SMB) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *SMB) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var smbMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_SMB.String()),
+		Help: Type_NC_SMB.String() + " audit records",
+	},
+	[]string{"Command", "Status"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *SMB) Inc() {
+	smbMetric.WithLabelValues(a.Command, a.Status).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *SMB) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *SMB) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *SMB) Dst() string {
+	return a.ServerIP
+}
@@ -0,0 +1,111 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsSMTPSession = []string{
+	"Timestamp",       // string
+	"ClientIP",        // string
+	"ServerIP",        // string
+	"Flow",            // string
+	"Banner",          // string
+	"Helo",            // string
+	"Extensions",      // []string
+	"StartTLS",        // bool
+	"AuthMechanism",   // string
+	"User",            // string
+	"NumTransactions", // []*SMTPTransaction
+	"NumMails",        // int
+	"Notes",           // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *SMTPSession) CSVHeader() []string {
+	return filter(fieldsSMTPSession)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *SMTPSession) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.ClientIP,                        // string
+		a.ServerIP,                        // string
+		a.Flow,                            // string
+		a.Banner,                          // string
+		a.Helo,                            // string
+		join(a.Extensions...),             // []string
+		strconv.FormatBool(a.StartTLS),    // bool
+		a.AuthMechanism,                   // string
+		a.User,                            // string
+		strconv.Itoa(len(a.Transactions)), // []*SMTPTransaction
+		strconv.Itoa(a.numMails()),        // int
+		a.Notes,                           // string
+	})
+}
+
+func (a *SMTPSession) numMails() int {
+	var n int
+
+	for _, t := range a.Transactions {
+		if t.Mail != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *SMTPSession) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *SMTPSession) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var smtpSessionMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_SMTPSession.String()),
+		Help: Type_NC_SMTPSession.String() + " audit records",
+	},
+	[]string{"StartTLS", "AuthMechanism"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *SMTPSession) Inc() {
+	smtpSessionMetric.WithLabelValues(strconv.FormatBool(a.StartTLS), a.AuthMechanism).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *SMTPSession) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *SMTPSession) Src() string {
+	return a.ClientIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *SMTPSession) Dst() string {
+	return a.ServerIP
+}
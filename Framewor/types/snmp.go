@@ -0,0 +1,125 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsSNMP = []string{
+	"Timestamp",     // string
+	"SrcIP",         // string
+	"DstIP",         // string
+	"SrcPort",       // int32
+	"DstPort",       // int32
+	"Version",       // string
+	"Community",     // string
+	"PDUType",       // string
+	"RequestID",     // int32
+	"ErrorStatus",   // string
+	"ErrorIndex",    // int32
+	"Variables",     // []*SNMPVariable
+	"User",          // string
+	"EngineID",      // string
+	"SecurityLevel", // string
+	"ContextName",   // string
+	"Notes",         // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *SNMP) CSVHeader() []string {
+	return filter(fieldsSNMP)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *SNMP) CSVRecord() []string {
+	vars := make([]string, 0, len(a.Variables))
+	for _, v := range a.Variables {
+		vars = append(vars, v.toString())
+	}
+
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.SrcIP,                   // string
+		a.DstIP,                   // string
+		formatInt32(a.SrcPort),    // int32
+		formatInt32(a.DstPort),    // int32
+		a.Version,                 // string
+		a.Community,               // string
+		a.PDUType,                 // string
+		formatInt32(a.RequestID),  // int32
+		a.ErrorStatus,             // string
+		formatInt32(a.ErrorIndex), // int32
+		strings.Join(vars, ""),    // []*SNMPVariable
+		a.User,                    // string
+		a.EngineID,                // string
+		a.SecurityLevel,           // string
+		a.ContextName,             // string
+		a.Notes,                   // string
+	})
+}
+
+func (v *SNMPVariable) toString() string {
+	var b strings.Builder
+
+	b.WriteString(StructureBegin)
+	b.WriteString(v.OID)
+	b.WriteString(FieldSeparator)
+	b.WriteString(v.Type)
+	b.WriteString(FieldSeparator)
+	b.WriteString(v.Value)
+	b.WriteString(StructureEnd)
+
+	return b.String()
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *SNMP) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *SNMP) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var snmpMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_SNMP.String()),
+		Help: Type_NC_SNMP.String() + " audit records",
+	},
+	[]string{"Version", "PDUType"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *SNMP) Inc() {
+	snmpMetric.WithLabelValues(a.Version, a.PDUType).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *SNMP) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *SNMP) Src() string {
+	return a.SrcIP
+}
+
+// Dst returns the destination address of the audit record.
+func (a *SNMP) Dst() string {
+	return a.DstIP
+}
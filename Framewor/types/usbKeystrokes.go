@@ -0,0 +1,96 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsUSBKeystrokes = []string{
+	"Timestamp",   // string
+	"Device",      // string
+	"Type",        // string
+	"VendorID",    // string
+	"ProductID",   // string
+	"Layout",      // string
+	"Keys",        // string
+	"Text",        // string
+	"Keystrokes",  // int64
+	"Reports",     // int64
+	"MouseClicks", // int64
+	"MousePath",   // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *USBKeystrokes) CSVHeader() []string {
+	return filter(fieldsUSBKeystrokes)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *USBKeystrokes) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.Device,                   // string
+		a.Type,                     // string
+		a.VendorID,                 // string
+		a.ProductID,                // string
+		a.Layout,                   // string
+		a.Keys,                     // string
+		a.Text,                     // string
+		formatInt64(a.Keystrokes),  // int64
+		formatInt64(a.Reports),     // int64
+		formatInt64(a.MouseClicks), // int64
+		a.MousePath,                // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *USBKeystrokes) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *USBKeystrokes) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var usbKeystrokesMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_USBKeystrokes.String()),
+		Help: Type_NC_USBKeystrokes.String() + " audit records",
+	},
+	[]string{"Device", "Type", "VendorID", "ProductID"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *USBKeystrokes) Inc() {
+	usbKeystrokesMetric.WithLabelValues(a.Device, a.Type, a.VendorID, a.ProductID).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *USBKeystrokes) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *USBKeystrokes) Src() string {
+	return ""
+}
+
+// Dst returns the destination address of the audit record.
+func (a *USBKeystrokes) Dst() string {
+	return ""
+}
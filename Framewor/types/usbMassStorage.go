@@ -0,0 +1,100 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsUSBMassStorage = []string{
+	"Timestamp",      // string
+	"Device",         // string
+	"Vendor",         // string
+	"Product",        // string
+	"Revision",       // string
+	"BlockSize",      // uint32
+	"Capacity",       // uint64
+	"Reads",          // int64
+	"Writes",         // int64
+	"BytesRead",      // int64
+	"BytesWritten",   // int64
+	"WrittenSectors", // []string
+	"FailedCommands", // int64
+	"Image",          // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *USBMassStorage) CSVHeader() []string {
+	return filter(fieldsUSBMassStorage)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *USBMassStorage) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.Device,                      // string
+		a.Vendor,                      // string
+		a.Product,                     // string
+		a.Revision,                    // string
+		formatUint32(a.BlockSize),     // uint32
+		formatUint64(a.Capacity),      // uint64
+		formatInt64(a.Reads),          // int64
+		formatInt64(a.Writes),         // int64
+		formatInt64(a.BytesRead),      // int64
+		formatInt64(a.BytesWritten),   // int64
+		join(a.WrittenSectors...),     // []string
+		formatInt64(a.FailedCommands), // int64
+		a.Image,                       // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *USBMassStorage) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *USBMassStorage) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var usbMassStorageMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_USBMassStorage.String()),
+		Help: Type_NC_USBMassStorage.String() + " audit records",
+	},
+	[]string{"Device", "Vendor", "Product"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *USBMassStorage) Inc() {
+	usbMassStorageMetric.WithLabelValues(a.Device, a.Vendor, a.Product).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *USBMassStorage) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *USBMassStorage) Src() string {
+	return ""
+}
+
+// Dst returns the destination address of the audit record.
+func (a *USBMassStorage) Dst() string {
+	return ""
+}
@@ -0,0 +1,95 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsWirelessClient = []string{
+	"Timestamp",   // string
+	"LastSeen",    // string
+	"MAC",         // string
+	"Vendor",      // string
+	"Randomized",  // bool
+	"ProbedSSIDs", // []string
+	"BSSID",       // string
+	"SSID",        // string
+	"Signal",      // int32
+	"Frames",      // int64
+	"Notes",       // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *WirelessClient) CSVHeader() []string {
+	return filter(fieldsWirelessClient)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *WirelessClient) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.LastSeen,                       // string
+		a.MAC,                            // string
+		a.Vendor,                         // string
+		strconv.FormatBool(a.Randomized), // bool
+		join(a.ProbedSSIDs...),           // []string
+		a.BSSID,                          // string
+		a.SSID,                           // string
+		formatInt32(a.Signal),            // int32
+		formatInt64(a.Frames),            // int64
+		a.Notes,                          // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *WirelessClient) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *WirelessClient) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var wirelessClientMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_WirelessClient.String()),
+		Help: Type_NC_WirelessClient.String() + " audit records",
+	},
+	[]string{"Vendor"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *WirelessClient) Inc() {
+	wirelessClientMetric.WithLabelValues(a.Vendor).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *WirelessClient) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *WirelessClient) Src() string {
+	return ""
+}
+
+// Dst returns the destination address of the audit record.
+func (a *WirelessClient) Dst() string {
+	return ""
+}
@@ -0,0 +1,109 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsWirelessNetwork = []string{
+	"Timestamp",      // string
+	"LastSeen",       // string
+	"BSSID",          // string
+	"SSID",           // string
+	"Hidden",         // bool
+	"Channel",        // int32
+	"Frequency",      // int32
+	"Security",       // string
+	"Ciphers",        // []string
+	"AKMs",           // []string
+	"WPS",            // bool
+	"VendorIEs",      // []string
+	"Vendor",         // string
+	"Signal",         // int32
+	"Beacons",        // int64
+	"ProbeResponses", // int64
+	"Clients",        // []string
+	"Notes",          // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *WirelessNetwork) CSVHeader() []string {
+	return filter(fieldsWirelessNetwork)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *WirelessNetwork) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.LastSeen,                    // string
+		a.BSSID,                       // string
+		a.SSID,                        // string
+		strconv.FormatBool(a.Hidden),  // bool
+		formatInt32(a.Channel),        // int32
+		formatInt32(a.Frequency),      // int32
+		a.Security,                    // string
+		join(a.Ciphers...),            // []string
+		join(a.AKMs...),               // []string
+		strconv.FormatBool(a.WPS),     // bool
+		join(a.VendorIEs...),          // []string
+		a.Vendor,                      // string
+		formatInt32(a.Signal),         // int32
+		formatInt64(a.Beacons),        // int64
+		formatInt64(a.ProbeResponses), // int64
+		join(a.Clients...),            // []string
+		a.Notes,                       // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *WirelessNetwork) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *WirelessNetwork) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var wirelessNetworkMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_WirelessNetwork.String()),
+		Help: Type_NC_WirelessNetwork.String() + " audit records",
+	},
+	[]string{"Security"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *WirelessNetwork) Inc() {
+	wirelessNetworkMetric.WithLabelValues(a.Security).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *WirelessNetwork) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *WirelessNetwork) Src() string {
+	return ""
+}
+
+// Dst returns the destination address of the audit record.
+func (a *WirelessNetwork) Dst() string {
+	return ""
+}
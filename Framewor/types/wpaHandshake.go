@@ -0,0 +1,90 @@
+/*
+ * NETCAP - Traffic Analysis Framework
+ * Copyright (c) 2017-2020 Philipp Mieden <dreadl0ck [at] protonmail [dot] ch>
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package types
+
+import (
+	"strings"
+
+	"github.com/dreadl0ck/netcap/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fieldsWPAHandshake = []string{
+	"Timestamp",     // string
+	"BSSID",         // string
+	"Client",        // string
+	"SSID",          // string
+	"Messages",      // []string
+	"ReplayCounter", // uint64
+	"PMKID",         // string
+	"Hashes",        // []string
+	"Notes",         // string
+}
+
+// CSVHeader returns the CSV header for the audit record.
+func (a *WPAHandshake) CSVHeader() []string {
+	return filter(fieldsWPAHandshake)
+}
+
+// CSVRecord returns the CSV record for the audit record.
+func (a *WPAHandshake) CSVRecord() []string {
+	return filter([]string{
+		formatTimestamp(a.Timestamp),
+		a.BSSID,                       // string
+		a.Client,                      // string
+		a.SSID,                        // string
+		join(a.Messages...),           // []string
+		formatUint64(a.ReplayCounter), // uint64
+		a.PMKID,                       // string
+		join(a.Hashes...),             // []string
+		a.Notes,                       // string
+	})
+}
+
+// Time returns the timestamp associated with the audit record.
+func (a *WPAHandshake) Time() string {
+	return a.Timestamp
+}
+
+// JSON returns the JSON representation of the audit record.
+func (a *WPAHandshake) JSON() (string, error) {
+	a.Timestamp = utils.TimeToUnixMilli(a.Timestamp)
+	return jsonMarshaler.MarshalToString(a)
+}
+
+var wpaHandshakeMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: strings.ToLower(Type_NC_WPAHandshake.String()),
+		Help: Type_NC_WPAHandshake.String() + " audit records",
+	},
+	[]string{"SSID"},
+)
+
+// Inc increments the metrics for the audit record.
+func (a *WPAHandshake) Inc() {
+	wpaHandshakeMetric.WithLabelValues(a.SSID).Inc()
+}
+
+// SetPacketContext sets the associated packet context for the audit record.
+func (a *WPAHandshake) SetPacketContext(*PacketContext) {}
+
+// Src returns the source address of the audit record.
+func (a *WPAHandshake) Src() string {
+	return ""
+}
+
+// Dst returns the destination address of the audit record.
+func (a *WPAHandshake) Dst() string {
+	return ""
+}